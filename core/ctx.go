@@ -6,6 +6,7 @@
 package gcore
 
 import (
+	"context"
 	"io"
 	"mime/multipart"
 	"net"
@@ -41,6 +42,7 @@ type Ctx interface {
 	GetParam(key string) string
 	Request() *http.Request
 	SetRequest(request *http.Request)
+	Context() context.Context
 	Response() http.ResponseWriter
 	SetResponse(response http.ResponseWriter)
 	SetParam(param Params)
@@ -86,6 +88,10 @@ type Ctx interface {
 	RequestBody() ([]byte, error)
 	SetCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool)
 	Cookie(name string) string
+	SetSignedCookie(name, value string, options ...*CookieOptions)
+	SignedCookie(name string) string
+	SetEncryptedCookie(name, value string, options ...*CookieOptions)
+	EncryptedCookie(name string) string
 	WriteFile(filepath string)
 	WriteFileFromFS(filepath string, fs http.FileSystem)
 	WriteFileAttachment(filepath, filename string)
@@ -101,6 +107,13 @@ type Ctx interface {
 	IsTLSRequest() bool
 	Controller() Controller
 	SetController(controller Controller)
+	Bind(obj interface{}) error
+	BindQuery(obj interface{}) error
+	BindForm(obj interface{}) error
+	BindMultipart(obj interface{}) error
+	BindJSON(obj interface{}) error
+	BindParams(obj interface{}) error
+	SSE(handler func(stream SSEStream), heartbeat ...time.Duration) error
 }
 
 const ctxKeyInResponseWriter = "CtxKeyInResponseWriter"
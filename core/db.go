@@ -6,6 +6,7 @@
 package gcore
 
 import (
+	"context"
 	"database/sql"
 	"time"
 )
@@ -16,6 +17,24 @@ type DBCache interface {
 	Get(key string) (data []byte, err error)
 }
 
+// DBTagCache is a DBCache supports tagging the cached queries with the tables they read,
+// the cached queries are invalidated when the tables are written.
+type DBTagCache interface {
+	DBCache
+	// TagsVersion returns the current version of tags, it must be called before querying
+	// the database, and the version is passed to SetWithTags with the query result.
+	TagsVersion(tags []string) (version string, err error)
+	SetWithTags(key string, val []byte, expire uint, tags []string, version string) (err error)
+	InvalidateTags(tags ...string) (err error)
+}
+
+// DBCond is a condition of WHERE clause, it is built by the condition builder of gdb.
+type DBCond interface {
+	// Build returns the SQL fragment with ? placeholders and its args, quote is used
+	// to quote the column names.
+	Build(quote func(column string) string) (sqlStr string, args []interface{})
+}
+
 // ActiveRecord gmc abstract sql statement builder layer.
 type ActiveRecord interface {
 	Cache(key string, seconds uint) ActiveRecord
@@ -45,6 +64,7 @@ type ActiveRecord interface {
 	Where(where map[string]interface{}) ActiveRecord
 	WhereWrap(where map[string]interface{}, leftWrap, rightWrap string) ActiveRecord
 	WhereRaw(where string) ActiveRecord
+	WhereCond(cond DBCond) ActiveRecord
 	Wrap(v string) string
 }
 
@@ -59,6 +79,74 @@ type Database interface {
 	ExecSQL(sqlStr string, values ...interface{}) (rs ResultSet, err error)
 	QuerySQL(sqlStr string, values ...interface{}) (rs ResultSet, err error)
 	Query(ar ActiveRecord) (rs ResultSet, err error)
+	BeginContext(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error)
+	ExecTxContext(ctx context.Context, ar ActiveRecord, tx *sql.Tx) (rs ResultSet, err error)
+	ExecSQLTxContext(ctx context.Context, tx *sql.Tx, sqlStr string, values ...interface{}) (rs ResultSet, err error)
+	ExecContext(ctx context.Context, ar ActiveRecord) (rs ResultSet, err error)
+	ExecSQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs ResultSet, err error)
+	QuerySQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs ResultSet, err error)
+	QueryContext(ctx context.Context, ar ActiveRecord) (rs ResultSet, err error)
+	QueryTx(ar ActiveRecord, tx *sql.Tx) (rs ResultSet, err error)
+	QuerySQLTx(tx *sql.Tx, sqlStr string, values ...interface{}) (rs ResultSet, err error)
+	QueryTxContext(ctx context.Context, ar ActiveRecord, tx *sql.Tx) (rs ResultSet, err error)
+	QuerySQLTxContext(ctx context.Context, tx *sql.Tx, sqlStr string, values ...interface{}) (rs ResultSet, err error)
+	Transaction(fn func(tx Tx) error) (err error)
+	TransactionContext(ctx context.Context, opts *sql.TxOptions, fn func(tx Tx) error) (err error)
+	AddHook(hook DBHook)
+	QueryEach(ar ActiveRecord, fn func(row DBRow) error) (err error)
+	QueryEachContext(ctx context.Context, ar ActiveRecord, fn func(row DBRow) error) (err error)
+}
+
+// DBRow is a row scanned by Database.QueryEach, the typed getters return zero value
+// if the column is NULL or not exists.
+type DBRow interface {
+	Columns() []string
+	IsNull(column string) bool
+	Bytes(column string) []byte
+	String(column string) string
+	Int64(column string) int64
+	Uint64(column string) uint64
+	Float64(column string) float64
+	Bool(column string) bool
+	Map() map[string]string
+	Struct(strucT interface{}, tagName ...string) (Struct interface{}, err error)
+}
+
+// DBQueryEvent is the information of a sql statement executed by Database, RowsAffected
+// is the count of returned rows for queries.
+type DBQueryEvent struct {
+	Driver       string
+	SQL          string
+	Args         []interface{}
+	InTx         bool
+	Start        time.Time
+	Duration     time.Duration
+	RowsAffected int64
+	Err          error
+}
+
+// DBHook is called before and after a sql statement executed by Database, the context
+// returned by Before is used to execute the statement and passed to After.
+type DBHook interface {
+	Before(ctx context.Context, event *DBQueryEvent) context.Context
+	After(ctx context.Context, event *DBQueryEvent)
+}
+
+// Tx gmc abstract db transaction layer, it has the same statement executing methods
+// as Database, all statements are executed in the transaction. Calling Transaction
+// of Tx starts a nested transaction by SAVEPOINT.
+type Tx interface {
+	AR() (ar ActiveRecord)
+	Exec(ar ActiveRecord) (rs ResultSet, err error)
+	ExecSQL(sqlStr string, values ...interface{}) (rs ResultSet, err error)
+	QuerySQL(sqlStr string, values ...interface{}) (rs ResultSet, err error)
+	Query(ar ActiveRecord) (rs ResultSet, err error)
+	ExecContext(ctx context.Context, ar ActiveRecord) (rs ResultSet, err error)
+	ExecSQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs ResultSet, err error)
+	QuerySQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs ResultSet, err error)
+	QueryContext(ctx context.Context, ar ActiveRecord) (rs ResultSet, err error)
+	Transaction(fn func(tx Tx) error) (err error)
+	Raw() *sql.Tx
 }
 
 type DatabaseGroup interface {
@@ -21,6 +21,9 @@ type (
 	Handle         func(http.ResponseWriter, *http.Request, Params)
 	Handler        func(ctx Ctx)
 	Middleware     func(ctx Ctx) (isStop bool)
+	// RouteMiddleware is a middleware of route or group, it runs after routing, next calls
+	// the rest of the chain and the handler, the chain is stopped if next is not called.
+	RouteMiddleware func(ctx Ctx, next func())
 	HttpFileFilter func(r *http.Request, filePath string) (newPath string, ok bool)
 )
 
@@ -34,6 +37,10 @@ type paramsKey struct{}
 // ParamsKey is the request context key under which URL params are stored.
 var ParamsKey = paramsKey{}
 
+// CtxKeyViewData is the ctx metadata key of a map[string]interface{}, the controller sets it
+// to the view data, so the middlewares can pass data to templates.
+const CtxKeyViewData = "gmc.view_data"
+
 // Params is a Param-slice, as returned by the router.
 // The slice is ordered, the first URL parameter is also the first slice value.
 // It is therefore safe to read values by the index.
@@ -68,6 +75,8 @@ type CookieOptions struct {
 	Domain   string // optional
 	Secure   bool   // optional
 	HTTPOnly bool   // optional, default to `true``
+	// SameSite is the SameSite attribute, optional, http.SameSiteDefaultMode or 0 means not set.
+	SameSite http.SameSite
 }
 
 var DefaultCookieOptions = &CookieOptions{
@@ -82,6 +91,16 @@ type Cookies interface {
 	Get(name string) (value string, err error)
 	Set(name, val string, options ...*CookieOptions)
 	Remove(name string, options ...*CookieOptions)
+	// GetSigned returns the value of the cookie set by SetSigned, an error is returned if the cookie
+	// is missing, expired or the signature is invalid.
+	GetSigned(name string) (value string, err error)
+	// SetSigned sets a cookie signed by HMAC, the client can read the value but can not modify it.
+	SetSigned(name, val string, options ...*CookieOptions)
+	// GetEncrypted returns the value of the cookie set by SetEncrypted, an error is returned if the cookie
+	// is missing, expired or can not be decrypted.
+	GetEncrypted(name string) (value string, err error)
+	// SetEncrypted sets a cookie encrypted by AES-GCM, the client can neither read nor modify the value.
+	SetEncrypted(name, val string, options ...*CookieOptions)
 }
 
 type SessionStorage interface {
@@ -90,6 +109,13 @@ type SessionStorage interface {
 	Delete(sessionID string) (err error)
 }
 
+// CtxSessionStorage is a SessionStorage keeps the data in the request and the response, such as
+// the cookie storage, the storage returned by WithCtx is used to load and save the sessions of ctx.
+type CtxSessionStorage interface {
+	SessionStorage
+	WithCtx(ctx Ctx) SessionStorage
+}
+
 type Session interface {
 	Set(k interface{}, v interface{})
 	Get(k interface{}) (value interface{})
@@ -102,6 +128,14 @@ type Session interface {
 	Touch()
 	Serialize() (str string, err error)
 	Unserialize(data string) (err error)
+	// Regenerate changes the session id and keeps the values.
+	Regenerate()
+	CreateTime() (time int64)
+	Fingerprint() string
+	SetFingerprint(fingerprint string)
+	// SetFlash sets a value can be read once by Flashes, it is used to pass messages to the next request.
+	SetFlash(k string, v interface{})
+	Flashes() (data map[string]interface{})
 }
 
 type Template interface {
@@ -127,6 +161,8 @@ type View interface {
 type HTTPRouter interface {
 	Group(ns string) HTTPRouter
 	Namespace() string
+	Use(m ...RouteMiddleware)
+	With(m ...RouteMiddleware) HTTPRouter
 	Ext(ext string)
 	Controller(urlPath string, obj Controller, ext ...string)
 	PrintRouteTable(w io.Writer)
@@ -146,6 +182,7 @@ type HTTPRouter interface {
 	PATCH(path string, handle Handle)
 	DELETE(path string, handle Handle)
 	ServeFiles(path string, root http.FileSystem)
+	WS(path string, handler WebSocketHandler, upgrader ...WebSocketUpgrader)
 	Lookup(method, path string) (Handle, Params, bool)
 	ServeHTTP(w http.ResponseWriter, req *http.Request)
 }
@@ -166,7 +203,10 @@ type APIServer interface {
 	ShowErrorStack(isShow bool)
 	Ext(ext string)
 	API(path string, handle func(ctx Ctx), ext ...string)
+	WS(path string, handler WebSocketHandler, upgrader ...WebSocketUpgrader)
 	Group(path string) APIServer
+	Use(m ...RouteMiddleware)
+	With(m ...RouteMiddleware) APIServer
 	PrintRouteTable(w io.Writer)
 	ActiveConnCount() int64
 	SetLog(l Logger)
@@ -227,6 +267,9 @@ type Controller interface {
 	StopE(err interface{}, fn ...func())
 	SessionStart() (err error)
 	SessionDestroy() (err error)
+	SessionRegenerate() (err error)
+	SetFlash(k string, v interface{}) (err error)
+	Flash(k string) interface{}
 	Write(data ...interface{}) (n int, err error)
 	WriteE(data ...interface{}) (n int, err error)
 	GetCtx() Ctx
@@ -0,0 +1,30 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcore
+
+import (
+	"time"
+)
+
+// SSEEvent is a server-sent event, the fields are written only if they are not empty.
+// Data of string or []byte is written as it is, other types are encoded to JSON.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Retry time.Duration
+	Data  interface{}
+}
+
+// SSEStream writes server-sent events to the client, the write methods can be called concurrently,
+// and they return error after the client disconnected.
+type SSEStream interface {
+	Send(event SSEEvent) error
+	Event(event string, data interface{}) error
+	Data(data interface{}) error
+	Comment(comment string) error
+	LastEventID() string
+	Done() <-chan struct{}
+}
@@ -0,0 +1,34 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcore
+
+import (
+	"net"
+)
+
+// WebSocketHandler handles a websocket connection, the connection is closed after it returned.
+type WebSocketHandler func(ctx Ctx, conn WebSocketConn)
+
+// WebSocketConn is a websocket connection, ReadMessage can be called in one goroutine,
+// and the write methods can be called concurrently.
+type WebSocketConn interface {
+	ReadMessage() (messageType int, data []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	ReadJSON(v interface{}) error
+	WriteJSON(v interface{}) error
+	Ping(data []byte) error
+	Close() error
+	CloseWithReason(code int, reason string) error
+	SetReadLimit(limit int64)
+	Subprotocol() string
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+}
+
+// WebSocketUpgrader upgrades the request of ctx to a websocket connection.
+type WebSocketUpgrader interface {
+	Upgrade(ctx Ctx) (WebSocketConn, error)
+}
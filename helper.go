@@ -147,6 +147,11 @@ func (s *DBAssistant) SQLite3(id ...string) *gdb.SQLite3DB {
 	return gdb.DBSQLite3(id...)
 }
 
+// Postgres acquires the postgres db group object, you must be call Init firstly.
+func (s *DBAssistant) Postgres(id ...string) *gdb.PostgresDB {
+	return gdb.DBPostgres(id...)
+}
+
 // Table acquires the table model object, you must be call Init firstly.
 func (s *DBAssistant) Table(tableName string) *gdb.Model {
 	return gdb.Table(tableName)
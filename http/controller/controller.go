@@ -10,8 +10,10 @@ import (
 	"net"
 	"net/http"
 	"net/textproto"
+	"strings"
 
 	gcore "github.com/snail007/gmc/core"
+	gsession "github.com/snail007/gmc/http/session"
 	ghttputil "github.com/snail007/gmc/internal/util/http"
 	gcast "github.com/snail007/gmc/util/cast"
 )
@@ -32,6 +34,7 @@ type Controller struct {
 	View         gcore.View
 	Lang         string
 	Logger       gcore.Logger
+	flash        map[string]interface{}
 }
 
 func (this *Controller) GetParam() gcore.Params {
@@ -87,6 +90,9 @@ func (this *Controller) MethodCallPre(ctx gcore.Ctx) {
 	this.Param = ctx.Param()
 	this.Tpl = ctx.WebServer().Tpl()
 	this.SessionStore = ctx.WebServer().SessionStore()
+	if st, ok := this.SessionStore.(gcore.CtxSessionStorage); ok {
+		this.SessionStore = st.WithCtx(ctx)
+	}
 	this.Router = ctx.WebServer().Router()
 	this.Config = ctx.WebServer().Config()
 	this.Logger = ctx.WebServer().Logger()
@@ -94,6 +100,11 @@ func (this *Controller) MethodCallPre(ctx gcore.Ctx) {
 	this.Cookie = gcore.ProviderCookies()(ctx)
 	// 2.init stuff below
 	this.View.SetLayoutDir(this.Config.GetString("template.layout"))
+	if v, ok := ctx.Get(gcore.CtxKeyViewData); ok {
+		if data, ok := v.(map[string]interface{}); ok {
+			this.View.SetMap(data)
+		}
+	}
 
 	//init lang
 	this.initLang()
@@ -233,6 +244,9 @@ func (this *Controller) StopE(err interface{}, fn ...func()) {
 	ghttputil.StopE(err, fn...)
 }
 
+// SessionStart starts the session, the session exceeded the absolute lifetime or not bound to
+// the client is dropped and a new session is started. The flash values set by the previous
+// request are moved to Flash and the view variable Flash.
 func (this *Controller) SessionStart() (err error) {
 	if this.SessionStore == nil {
 		err = fmt.Errorf("session is disabled")
@@ -247,21 +261,77 @@ func (this *Controller) SessionStart() (err error) {
 	var isExists bool
 	if sid != "" {
 		this.Session, isExists = this.SessionStore.Load(sid)
+		if isExists && !gsession.IsValid(this.Ctx, this.Session) {
+			this.SessionStore.Delete(sid)
+			this.Session, isExists = nil, false
+		}
 	}
 	if !isExists {
 		sess := gcore.ProviderSession()()
 		sess.Touch()
-		this.Cookie.Set(sessionCookieName, sess.SessionID(), &gcore.CookieOptions{
-			Path:     "/",
-			MaxAge:   this.Config.GetInt("session.ttl"),
-			HTTPOnly: true,
-		})
+		sess.SetFingerprint(gsession.Fingerprint(this.Ctx))
+		this.setSessionCookie(sess.SessionID())
 		err = this.SessionStore.Save(sess)
 		this.Session = sess
 	}
+	this.flash = this.Session.Flashes()
+	this.View.Set("Flash", this.flash)
+	return
+}
+
+// SessionRegenerate changes the session id and keeps the data, the data is moved from the old id
+// to the new id in the session store. It should be called after login to prevent session fixation.
+func (this *Controller) SessionRegenerate() (err error) {
+	err = this.SessionStart()
+	if err != nil {
+		return
+	}
+	oldID := this.Session.SessionID()
+	this.Session.Regenerate()
+	this.Session.SetFingerprint(gsession.Fingerprint(this.Ctx))
+	this.SessionStore.Delete(oldID)
+	this.setSessionCookie(this.Session.SessionID())
+	return this.SessionStore.Save(this.Session)
+}
 
+// SetFlash sets a flash value to the session, it can be read by Flash or {{.Flash.key}} in views
+// of the next request which starts the session, then it is removed.
+func (this *Controller) SetFlash(k string, v interface{}) (err error) {
+	err = this.SessionStart()
+	if err != nil {
+		return
+	}
+	this.Session.SetFlash(k, v)
 	return
 }
+
+// Flash returns the flash value set by the previous request, nil if not exists or the session
+// is not started.
+func (this *Controller) Flash(k string) interface{} {
+	return this.flash[k]
+}
+
+// setSessionCookie sets the session id cookie, the session id cookie set before is replaced.
+func (this *Controller) setSessionCookie(sessionID string) {
+	name := this.Config.GetString("session.cookiename")
+	h := this.Response.Header()
+	var lines []string
+	for _, v := range h["Set-Cookie"] {
+		if !strings.HasPrefix(v, name+"=") {
+			lines = append(lines, v)
+		}
+	}
+	if lines == nil {
+		h.Del("Set-Cookie")
+	} else {
+		h["Set-Cookie"] = lines
+	}
+	this.Cookie.Set(name, sessionID, &gcore.CookieOptions{
+		Path:     "/",
+		MaxAge:   this.Config.GetInt("session.ttl"),
+		HTTPOnly: true,
+	})
+}
 func (this *Controller) GetCtx() gcore.Ctx {
 	return this.Ctx
 }
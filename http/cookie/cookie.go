@@ -12,16 +12,32 @@ import (
 )
 
 func New(w http.ResponseWriter, r *http.Request) (cookie *Cookies) {
+	return NewWithOptions(w, r, nil)
+}
+
+// NewWithOptions creates a Cookies with the keys and the default cookie options of opt.
+func NewWithOptions(w http.ResponseWriter, r *http.Request, opt *Options) (cookie *Cookies) {
+	if opt == nil {
+		opt = &Options{}
+	}
 	c := &Cookies{
 		req: r,
 		w:   w,
+		opt: opt,
 	}
 	return c
 }
 
+// NewFromConfig creates a Cookies of ctx with the options of section [cookie] in ctx.Config(),
+// the options are parsed once for a config.
+func NewFromConfig(ctx gcore.Ctx) (cookie *Cookies) {
+	return NewWithOptions(ctx.Response(), ctx.Request(), OptionsFromConfig(ctx.Config()))
+}
+
 type Cookies struct {
 	req *http.Request
 	w   http.ResponseWriter
+	opt *Options
 }
 
 func (c *Cookies) Get(name string) (value string, err error) {
@@ -33,14 +49,8 @@ func (c *Cookies) Get(name string) (value string, err error) {
 	return
 }
 
-// Set set the given cookie to the response and returns the current context to allow chaining.
-// If options omit, it will use default options.
 func (c *Cookies) Set(name, val string, options ...*gcore.CookieOptions) {
-	opts := gcore.DefaultCookieOptions
-	if len(options) > 0 {
-		opts = options[0]
-	}
-
+	opts := c.options(options)
 	cookie := &http.Cookie{
 		Name:     name,
 		Value:    val,
@@ -49,6 +59,7 @@ func (c *Cookies) Set(name, val string, options ...*gcore.CookieOptions) {
 		MaxAge:   opts.MaxAge,
 		Domain:   opts.Domain,
 		Path:     opts.Path,
+		SameSite: opts.SameSite,
 	}
 	if opts.MaxAge > 0 {
 		d := time.Duration(opts.MaxAge) * time.Second
@@ -59,12 +70,58 @@ func (c *Cookies) Set(name, val string, options ...*gcore.CookieOptions) {
 	http.SetCookie(c.w, cookie)
 }
 
-// Remove remove the given cookie
 func (c *Cookies) Remove(name string, options ...*gcore.CookieOptions) {
-	opts := *gcore.DefaultCookieOptions // should copy because we will change MaxAge
-	if len(options) > 0 {
-		opts = *options[0]
-	}
+	opts := *c.options(options) // should copy because we will change MaxAge
 	opts.MaxAge = -1
 	c.Set(name, "", &opts)
 }
+
+// GetSigned returns the value of the cookie set by SetSigned, all the keys are tried to verify it.
+func (c *Cookies) GetSigned(name string) (value string, err error) {
+	v, err := c.Get(name)
+	if err != nil {
+		return
+	}
+	return c.opt.verify(name, v)
+}
+
+// SetSigned sets a cookie signed by the first key, the value is base64 encoded, it can be read
+// but can not be modified by the client. If MaxAge > 0, the expiry is signed too.
+// It panics if no key is set.
+func (c *Cookies) SetSigned(name, val string, options ...*gcore.CookieOptions) {
+	opts := c.options(options)
+	c.Set(name, c.opt.sign(name, val, expires(opts)), opts)
+}
+
+// GetEncrypted returns the value of the cookie set by SetEncrypted, all the keys are tried to decrypt it.
+func (c *Cookies) GetEncrypted(name string) (value string, err error) {
+	v, err := c.Get(name)
+	if err != nil {
+		return
+	}
+	return c.opt.Decrypt(name, v)
+}
+
+// SetEncrypted sets a cookie encrypted by AES-GCM with the first key, the client can neither read
+// nor modify it. If MaxAge > 0, the expiry is encrypted too. It panics if no key is set.
+func (c *Cookies) SetEncrypted(name, val string, options ...*gcore.CookieOptions) {
+	opts := c.options(options)
+	c.Set(name, c.opt.Encrypt(name, val, expires(opts)), opts)
+}
+
+func (c *Cookies) options(options []*gcore.CookieOptions) *gcore.CookieOptions {
+	if len(options) > 0 && options[0] != nil {
+		return options[0]
+	}
+	if c.opt.Default != nil {
+		return c.opt.Default
+	}
+	return gcore.DefaultCookieOptions
+}
+
+func expires(opts *gcore.CookieOptions) int64 {
+	if opts.MaxAge > 0 {
+		return time.Now().Unix() + int64(opts.MaxAge)
+	}
+	return 0
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gconfig "github.com/snail007/gmc/module/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTrip sets cookies by fn, then returns a Cookies of the request with the cookies.
+func roundTrip(opt *Options, fn func(c *Cookies)) (*Cookies, []*http.Cookie) {
+	w := httptest.NewRecorder()
+	fn(NewWithOptions(w, httptest.NewRequest("GET", "/", nil), opt))
+	cookies := w.Result().Cookies()
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, v := range cookies {
+		r.AddCookie(v)
+	}
+	return NewWithOptions(httptest.NewRecorder(), r, opt), cookies
+}
+
+func TestSetGet(t *testing.T) {
+	assert := assert.New(t)
+	c, cookies := roundTrip(nil, func(c *Cookies) {
+		c.Set("a", "1")
+		c.Set("b", "2", &gcore.CookieOptions{MaxAge: 60, SameSite: http.SameSiteNoneMode, Secure: true})
+		c.Remove("c")
+	})
+	assert.Len(cookies, 3)
+	assert.True(cookies[0].HttpOnly)
+	assert.Equal(60, cookies[1].MaxAge)
+	assert.Equal(http.SameSiteNoneMode, cookies[1].SameSite)
+	assert.Equal(-1, cookies[2].MaxAge)
+	v, err := c.Get("a")
+	assert.Nil(err)
+	assert.Equal("1", v)
+	_, err = c.Get("d")
+	assert.Equal(http.ErrNoCookie, err)
+}
+
+func TestSigned(t *testing.T) {
+	assert := assert.New(t)
+	opt := &Options{Keys: []string{"k1"}}
+	c, cookies := roundTrip(opt, func(c *Cookies) {
+		c.SetSigned("a", "hello world")
+		c.SetSigned("b", "foo", &gcore.CookieOptions{MaxAge: 60})
+	})
+	v, err := c.GetSigned("a")
+	assert.Nil(err)
+	assert.Equal("hello world", v)
+	v, err = c.GetSigned("b")
+	assert.Nil(err)
+	assert.Equal("foo", v)
+
+	// tampered
+	parts := strings.Split(cookies[0].Value, ".")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "a", Value: b64.EncodeToString([]byte("hello gmc")) + "." + parts[1] + "." + parts[2]})
+	// copied to another cookie
+	r.AddCookie(&http.Cookie{Name: "c", Value: cookies[0].Value})
+	r.AddCookie(&http.Cookie{Name: "d", Value: "foo"})
+	c = NewWithOptions(nil, r, opt)
+	for _, name := range []string{"a", "c", "d"} {
+		_, err = c.GetSigned(name)
+		assert.Equal(ErrInvalid, err, name)
+	}
+	_, err = c.GetSigned("e")
+	assert.Equal(http.ErrNoCookie, err)
+
+	// expired
+	r = httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "a", Value: opt.sign("a", "foo", time.Now().Unix()-1)})
+	_, err = NewWithOptions(nil, r, opt).GetSigned("a")
+	assert.Equal(ErrExpired, err)
+
+	// no keys
+	_, err = NewWithOptions(nil, r, nil).GetSigned("a")
+	assert.Equal(ErrNoKeys, err)
+	assert.Panics(func() { New(httptest.NewRecorder(), r).SetSigned("a", "foo") })
+}
+
+func TestEncrypted(t *testing.T) {
+	assert := assert.New(t)
+	opt := &Options{Keys: []string{"k1"}}
+	c, cookies := roundTrip(opt, func(c *Cookies) {
+		c.SetEncrypted("a", "hello world")
+		c.SetEncrypted("b", "", &gcore.CookieOptions{MaxAge: 60})
+	})
+	assert.NotContains(cookies[0].Value, b64.EncodeToString([]byte("hello")))
+	v, err := c.GetEncrypted("a")
+	assert.Nil(err)
+	assert.Equal("hello world", v)
+	v, err = c.GetEncrypted("b")
+	assert.Nil(err)
+	assert.Equal("", v)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "c", Value: cookies[0].Value})
+	r.AddCookie(&http.Cookie{Name: "d", Value: cookies[0].Value[:len(cookies[0].Value)-2]})
+	r.AddCookie(&http.Cookie{Name: "e", Value: "foo"})
+	r.AddCookie(&http.Cookie{Name: "f", Value: opt.Encrypt("f", "foo", time.Now().Unix()-1)})
+	c = NewWithOptions(nil, r, opt)
+	for _, name := range []string{"c", "d", "e"} {
+		_, err = c.GetEncrypted(name)
+		assert.Equal(ErrInvalid, err, name)
+	}
+	_, err = c.GetEncrypted("f")
+	assert.Equal(ErrExpired, err)
+}
+
+func TestKeyRotation(t *testing.T) {
+	assert := assert.New(t)
+	old := &Options{Keys: []string{"k1"}}
+	c, _ := roundTrip(old, func(c *Cookies) {
+		c.SetSigned("a", "1")
+		c.SetEncrypted("b", "2")
+	})
+	c.opt = &Options{Keys: []string{"k2", "k1"}}
+	v, err := c.GetSigned("a")
+	assert.Nil(err)
+	assert.Equal("1", v)
+	v, err = c.GetEncrypted("b")
+	assert.Nil(err)
+	assert.Equal("2", v)
+	c.opt = &Options{Keys: []string{"k2"}}
+	_, err = c.GetSigned("a")
+	assert.Equal(ErrInvalid, err)
+	_, err = c.GetEncrypted("b")
+	assert.Equal(ErrInvalid, err)
+}
+
+func TestOptionsFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gconfig.New()
+	opt := OptionsFromConfig(cfg)
+	assert.Nil(opt.Default)
+	assert.Empty(opt.Keys)
+
+	cfg = gconfig.New()
+	cfg.Set("cookie.keys", []string{"k1", "k2"})
+	cfg.Set("cookie.same_site", "Lax")
+	cfg.Set("cookie.max_age", 60)
+	cfg.Set("cookie.http_only", false)
+	opt = OptionsFromConfig(cfg)
+	assert.Same(opt, OptionsFromConfig(cfg))
+	assert.Equal([]string{"k1", "k2"}, opt.Keys)
+	assert.Equal(&gcore.CookieOptions{Path: "/", MaxAge: 60, SameSite: http.SameSiteLaxMode}, opt.Default)
+	_, cookies := roundTrip(opt, func(c *Cookies) {
+		c.SetSigned("a", "1")
+	})
+	assert.Equal(http.SameSiteLaxMode, cookies[0].SameSite)
+	assert.False(cookies[0].HttpOnly)
+	exp, _ := strconv.ParseInt(strings.Split(cookies[0].Value, ".")[1], 10, 64)
+	assert.InDelta(time.Now().Unix()+60, exp, 2)
+
+	assert.Equal(http.SameSiteStrictMode, ParseSameSite("strict"))
+	assert.Equal(http.SameSiteNoneMode, ParseSameSite("none"))
+	assert.Equal(http.SameSiteDefaultMode, ParseSameSite(""))
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gcookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+var (
+	// ErrNoKeys is returned when reading a signed or encrypted cookie without keys.
+	ErrNoKeys = errors.New("gcookie: no keys, set keys of [cookie] in config")
+	// ErrInvalid is returned when the signature of a cookie is invalid or a cookie can not be decrypted.
+	ErrInvalid = errors.New("gcookie: invalid cookie value")
+	// ErrExpired is returned when a signed or encrypted cookie is expired.
+	ErrExpired = errors.New("gcookie: cookie expired")
+
+	configOptions sync.Map
+	b64           = base64.RawURLEncoding
+)
+
+// Options is the options of Cookies.
+type Options struct {
+	// Keys are the secret keys to sign and encrypt cookies, the first key is used to sign and encrypt,
+	// all the keys are tried to verify and decrypt, so a new key is prepended and the old keys are kept
+	// for a while when rotating keys.
+	Keys []string
+	// Default is the cookie options used when no options passed to Set, nil means gcore.DefaultCookieOptions.
+	Default *gcore.CookieOptions
+
+	keysOnce sync.Once
+	keys     []*key
+}
+
+type key struct {
+	sign []byte
+	aead cipher.AEAD
+}
+
+// OptionsFromConfig parses the options from section [cookie] of config, the result is cached by config.
+// If the section is missing, the options without keys is returned.
+func OptionsFromConfig(c gcore.Config) *Options {
+	if c == nil {
+		return &Options{}
+	}
+	if v, ok := configOptions.Load(c); ok {
+		return v.(*Options)
+	}
+	opt := &Options{}
+	if cfg := c.Sub("cookie"); cfg != nil {
+		opt.Keys = cfg.GetStringSlice("keys")
+		d := *gcore.DefaultCookieOptions
+		if cfg.IsSet("path") {
+			d.Path = cfg.GetString("path")
+		}
+		if cfg.IsSet("http_only") {
+			d.HTTPOnly = cfg.GetBool("http_only")
+		}
+		d.Domain = cfg.GetString("domain")
+		d.Secure = cfg.GetBool("secure")
+		d.MaxAge = cfg.GetInt("max_age")
+		d.SameSite = ParseSameSite(cfg.GetString("same_site"))
+		opt.Default = &d
+	}
+	v, _ := configOptions.LoadOrStore(c, opt)
+	return v.(*Options)
+}
+
+// ParseSameSite parses lax, strict and none to http.SameSite, the others are http.SameSiteDefaultMode.
+func ParseSameSite(s string) http.SameSite {
+	switch strings.ToLower(s) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteDefaultMode
+}
+
+// derive derives the signing key and the encryption key from the secret keys.
+func (o *Options) derive() []*key {
+	o.keysOnce.Do(func() {
+		for _, k := range o.Keys {
+			if k == "" {
+				continue
+			}
+			m := hmac.New(sha256.New, []byte(k))
+			m.Write([]byte("gmc-cookie-sign"))
+			sign := m.Sum(nil)
+			m.Reset()
+			m.Write([]byte("gmc-cookie-encrypt"))
+			block, _ := aes.NewCipher(m.Sum(nil))
+			aead, _ := cipher.NewGCM(block)
+			o.keys = append(o.keys, &key{sign: sign, aead: aead})
+		}
+	})
+	return o.keys
+}
+
+func (o *Options) firstKey() *key {
+	keys := o.derive()
+	if len(keys) == 0 {
+		panic(ErrNoKeys)
+	}
+	return keys[0]
+}
+
+// sign returns base64(value).expires.base64(mac), the name is signed to prevent the value
+// being copied to another cookie.
+func (o *Options) sign(name, value string, expires int64) string {
+	payload := b64.EncodeToString([]byte(value)) + "." + strconv.FormatInt(expires, 10)
+	return payload + "." + b64.EncodeToString(mac(o.firstKey().sign, name, payload))
+}
+
+func (o *Options) verify(name, value string) (string, error) {
+	keys := o.derive()
+	if len(keys) == 0 {
+		return "", ErrNoKeys
+	}
+	idx := strings.LastIndexByte(value, '.')
+	if idx < 0 {
+		return "", ErrInvalid
+	}
+	payload := value[:idx]
+	sum, err := b64.DecodeString(value[idx+1:])
+	if err != nil {
+		return "", ErrInvalid
+	}
+	valid := false
+	for _, k := range keys {
+		if hmac.Equal(sum, mac(k.sign, name, payload)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", ErrInvalid
+	}
+	parts := strings.SplitN(payload, ".", 2)
+	if len(parts) != 2 {
+		return "", ErrInvalid
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	if exp > 0 && time.Now().Unix() > exp {
+		return "", ErrExpired
+	}
+	b, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalid
+	}
+	return string(b), nil
+}
+
+// Encrypt returns base64(nonce + AES-GCM(expires + value)) encrypted by the first key, the name is
+// the additional data, expires is the unix time the value expires, 0 means never. It panics if no key is set.
+func (o *Options) Encrypt(name, value string, expires int64) string {
+	aead := o.firstKey().aead
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+8+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	plain := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(plain, uint64(expires))
+	plain = append(plain, value...)
+	return b64.EncodeToString(aead.Seal(nonce, nonce, plain, []byte(name)))
+}
+
+// Decrypt returns the value encrypted by Encrypt, all the keys are tried to decrypt it.
+func (o *Options) Decrypt(name, value string) (string, error) {
+	keys := o.derive()
+	if len(keys) == 0 {
+		return "", ErrNoKeys
+	}
+	data, err := b64.DecodeString(value)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	for _, k := range keys {
+		n := k.aead.NonceSize()
+		if len(data) < n {
+			return "", ErrInvalid
+		}
+		plain, err := k.aead.Open(nil, data[:n], data[n:], []byte(name))
+		if err != nil || len(plain) < 8 {
+			continue
+		}
+		exp := int64(binary.BigEndian.Uint64(plain))
+		if exp > 0 && time.Now().Unix() > exp {
+			return "", ErrExpired
+		}
+		return string(plain[8:]), nil
+	}
+	return "", ErrInvalid
+}
+
+func mac(key []byte, name, payload string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(name))
+	m.Write([]byte{0})
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
@@ -0,0 +1,570 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gopenapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	gcore "github.com/snail007/gmc/core"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	paramRegexp = regexp.MustCompile(`[:*]([^/]+)`)
+	anyMethods  = []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPatch,
+		http.MethodOptions,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodDelete,
+	}
+	validMethods = map[string]bool{
+		"get": true, "put": true, "post": true, "delete": true,
+		"options": true, "head": true, "patch": true, "trace": true,
+	}
+	bodyMethods = map[string]bool{"post": true, "put": true, "patch": true}
+)
+
+// Route is the annotation of a route.
+type Route struct {
+	Summary     string
+	Description string
+	Tags        []string
+	OperationID string
+	Deprecated  bool
+	// Security is the names of Doc.SecuritySchemes required by the route.
+	Security []string
+	// Request is a value of the struct bound by ctx.Bind, the fields are documented by the tags
+	// param, query, form and json, the tag validate is converted to the schema constraints,
+	// the tags description, example and default are also used.
+	Request interface{}
+	// Response is a value of the body type of response 200.
+	Response interface{}
+	// Responses is the responses by status code, the value is a value of the body type,
+	// or a Content, nil means no body.
+	Responses map[int]interface{}
+}
+
+// Content describes a response body in Route.Responses.
+type Content struct {
+	Description string
+	// ContentType default is application/json.
+	ContentType string
+	Body        interface{}
+}
+
+type annotation struct {
+	method string
+	path   string
+	route  Route
+}
+
+// Doc generates an OpenAPI 3 document by the route table of router and the annotations of routes.
+type Doc struct {
+	Info            Info
+	Servers         []Server
+	SecuritySchemes map[string]*SecurityScheme
+	// Path is the path to serve the document and the docs UI by Serve, empty means not served.
+	Path        string
+	annotations []*annotation
+	exclude     []string
+	served      map[string]bool
+	lock        sync.RWMutex
+}
+
+// New creates a Doc with the info.
+func New(info Info) *Doc {
+	return &Doc{
+		Info:            info,
+		SecuritySchemes: map[string]*SecurityScheme{},
+		served:          map[string]bool{},
+	}
+}
+
+// NewFromConfig creates a Doc from section [openapi] of config, Path is set only if enable is true.
+func NewFromConfig(c gcore.Config) *Doc {
+	d := New(Info{Title: "API", Version: "1.0.0"})
+	cfg := c.Sub("openapi")
+	if cfg == nil {
+		return d
+	}
+	if v := cfg.GetString("title"); v != "" {
+		d.Info.Title = v
+	}
+	if v := cfg.GetString("version"); v != "" {
+		d.Info.Version = v
+	}
+	d.Info.Description = cfg.GetString("description")
+	for _, v := range cfg.GetStringSlice("servers") {
+		d.Servers = append(d.Servers, Server{URL: v})
+	}
+	if cfg.GetBool("enable") {
+		d.Path = cfg.GetString("path")
+		if d.Path == "" {
+			d.Path = "/docs"
+		}
+	}
+	return d
+}
+
+// Add annotates the route of method and path, path is the full path of route, the extension
+// of API can be omitted. If method is empty, the annotation applies to all methods of the route,
+// for the route registered by HandleAny or API, POST is used if the request has body fields,
+// otherwise GET is used. The annotation of a route not in the route table is ignored.
+func (d *Doc) Add(method, path string, r Route) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.annotations = append(d.annotations, &annotation{
+		method: strings.ToUpper(method),
+		path:   path,
+		route:  r,
+	})
+}
+
+// API registers the handle to api by api.API and annotates it.
+//
+//	doc.API(api, "/user/:id", getUser, gopenapi.Route{Summary: "get user", Request: GetUserReq{}, Response: User{}})
+func (d *Doc) API(api gcore.APIServer, path string, handle func(ctx gcore.Ctx), r Route, ext ...string) {
+	api.API(path, handle, ext...)
+	if len(ext) > 0 {
+		path += ext[0]
+	}
+	d.Add("", strings.TrimRight(api.Router().Namespace(), "/")+path, r)
+}
+
+// Handle registers the handle to router by router.Handle and annotates it.
+func (d *Doc) Handle(router gcore.HTTPRouter, method, path string, handle gcore.Handle, r Route) {
+	router.Handle(method, path, handle)
+	d.Add(method, strings.TrimRight(router.Namespace(), "/")+path, r)
+}
+
+// Exclude excludes the routes with the path prefix from the document.
+func (d *Doc) Exclude(prefix ...string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.exclude = append(d.exclude, prefix...)
+}
+
+// Build generates the document by the route table of router, the routes without annotation
+// are documented with the path params only.
+func (d *Doc) Build(router gcore.HTTPRouter) *Document {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	s := newSchemas()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    d.Info,
+		Servers: d.Servers,
+		Paths:   map[string]PathItem{},
+	}
+	table := router.RouteTable()
+	paths := make([]string, 0, len(table))
+	for k := range table {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+	tags := map[string]bool{}
+	for _, p := range paths {
+		if d.excluded(p) {
+			continue
+		}
+		methods := table[p]
+		isAny := isAnyRoute(methods)
+		oaPath := paramRegexp.ReplaceAllString(p, "{$1}")
+		var params []string
+		for _, m := range paramRegexp.FindAllStringSubmatch(p, -1) {
+			params = append(params, m[1])
+		}
+		item := PathItem{}
+		add := func(method string, r *Route) {
+			method = strings.ToLower(method)
+			if !validMethods[method] || item[method] != nil {
+				return
+			}
+			op := operation(s, method, params, r)
+			for _, t := range op.Tags {
+				tags[t] = true
+			}
+			item[method] = op
+		}
+		annotated := false
+		for _, a := range d.annotations {
+			if !matchPath(p, a.path) {
+				continue
+			}
+			switch {
+			case a.method != "":
+				if hasMethod(methods, a.method) {
+					annotated = true
+					add(a.method, &a.route)
+				}
+			case isAny:
+				annotated = true
+				if hasBody(a.route.Request) {
+					add(http.MethodPost, &a.route)
+				} else {
+					add(http.MethodGet, &a.route)
+				}
+			default:
+				annotated = true
+				for _, m := range methods {
+					add(m, &a.route)
+				}
+			}
+		}
+		if !annotated {
+			if isAny {
+				add(http.MethodGet, &Route{})
+			} else {
+				for _, m := range methods {
+					add(m, &Route{})
+				}
+			}
+		}
+		if len(item) > 0 {
+			doc.Paths[oaPath] = item
+		}
+	}
+	for t := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: t})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	if len(s.components) > 0 || len(d.SecuritySchemes) > 0 {
+		doc.Components = &Components{SecuritySchemes: d.SecuritySchemes}
+		if len(s.components) > 0 {
+			doc.Components.Schemas = s.components
+		}
+	}
+	return doc
+}
+
+// JSON returns the json of document built by router.
+func (d *Doc) JSON(router gcore.HTTPRouter) ([]byte, error) {
+	return json.MarshalIndent(d.Build(router), "", "  ")
+}
+
+// YAML returns the yaml of document built by router.
+func (d *Doc) YAML(router gcore.HTTPRouter) ([]byte, error) {
+	b, err := json.Marshal(d.Build(router))
+	if err != nil {
+		return nil, err
+	}
+	// json is a subset of yaml, decoding into a node keeps the order of keys.
+	var node yaml.Node
+	if err = yaml.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err = enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Serve registers the routes of the document and the docs UI to router if Path is not empty,
+// the document is built by router on each request, so the routes added later are included.
+//
+//	GET {Path}               the docs UI
+//	GET {Path}/openapi.json  the json document
+//	GET {Path}/openapi.yaml  the yaml document
+func (d *Doc) Serve(router gcore.HTTPRouter) {
+	if d.Path == "" {
+		return
+	}
+	p := strings.TrimRight(d.Path, "/")
+	uiPath := p
+	if uiPath == "" {
+		uiPath = "/"
+	}
+	ns := strings.TrimRight(router.Namespace(), "/")
+	d.lock.Lock()
+	d.served[ns+uiPath] = true
+	d.served[ns+p+"/openapi.json"] = true
+	d.served[ns+p+"/openapi.yaml"] = true
+	d.lock.Unlock()
+	write := func(w http.ResponseWriter, contentType string, b []byte, err error) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(b)
+	}
+	router.HandlerFunc(http.MethodGet, p+"/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		b, err := d.JSON(router)
+		write(w, "application/json; charset=utf-8", b, err)
+	})
+	router.HandlerFunc(http.MethodGet, p+"/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		b, err := d.YAML(router)
+		write(w, "application/yaml; charset=utf-8", b, err)
+	})
+	ui := renderUI(d.Info.Title, ns+p+"/openapi.json")
+	router.HandlerFunc(http.MethodGet, uiPath, func(w http.ResponseWriter, r *http.Request) {
+		write(w, "text/html; charset=utf-8", ui, nil)
+	})
+}
+
+func (d *Doc) excluded(path string) bool {
+	if d.served[path] {
+		return true
+	}
+	for _, v := range d.exclude {
+		if strings.HasPrefix(path, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// operation creates the operation of method by the annotation r.
+func operation(s *schemas, method string, params []string, r *Route) *Operation {
+	op := &Operation{
+		Tags:        r.Tags,
+		Summary:     r.Summary,
+		Description: r.Description,
+		OperationID: r.OperationID,
+		Deprecated:  r.Deprecated,
+		Responses:   map[string]*Response{},
+	}
+	for _, v := range r.Security {
+		op.Security = append(op.Security, map[string][]string{v: {}})
+	}
+	var reqType reflect.Type
+	if r.Request != nil {
+		reqType = reflect.TypeOf(r.Request)
+		for reqType.Kind() == reflect.Ptr {
+			reqType = reqType.Elem()
+		}
+		if reqType.Kind() != reflect.Struct {
+			reqType = nil
+		}
+	}
+	for _, name := range params {
+		p := &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		if reqType != nil {
+			walkFields(reqType, func(f reflect.StructField) {
+				if tagName(f, "param") == name {
+					p.Schema = s.field(f, s.of(f.Type))
+					p.Description, p.Schema.Description = p.Schema.Description, ""
+				}
+			})
+		}
+		op.Parameters = append(op.Parameters, p)
+	}
+	if reqType != nil {
+		requestBody(s, op, method, reqType)
+	}
+	if r.Response != nil {
+		op.Responses["200"] = response(s, http.StatusOK, r.Response)
+	}
+	for code, v := range r.Responses {
+		op.Responses[strconv.Itoa(code)] = response(s, code, v)
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["200"] = &Response{Description: http.StatusText(http.StatusOK)}
+	}
+	return op
+}
+
+// requestBody adds the query params and the request body of struct t to op.
+func requestBody(s *schemas, op *Operation, method string, t reflect.Type) {
+	hasBody := bodyMethods[method]
+	form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	multipart := false
+	allJSON := true
+	hasJSON := false
+	walkFields(t, func(f reflect.StructField) {
+		q, fm := tagName(f, "query"), tagName(f, "form")
+		if tagName(f, "param") != "" {
+			allJSON = false
+			return
+		}
+		if q == "" && fm != "" && !hasBody {
+			q = fm
+		}
+		if q != "" {
+			allJSON = false
+			if f.Type == fileHeaderType || f.Type == fileHeaderSliceType {
+				return
+			}
+			schema := s.field(f, s.of(f.Type))
+			p := &Parameter{Name: q, In: "query", Required: fieldRequired(f), Schema: schema}
+			p.Description, schema.Description = schema.Description, ""
+			op.Parameters = append(op.Parameters, p)
+			return
+		}
+		if fm != "" {
+			if f.Type == fileHeaderType || f.Type == fileHeaderSliceType {
+				multipart = true
+			}
+			form.Properties[fm] = s.field(f, s.of(f.Type))
+			if fieldRequired(f) {
+				form.Required = append(form.Required, fm)
+			}
+		}
+		if j := tagName(f, "json"); j != "-" && (j != "" || fm == "") {
+			hasJSON = true
+		} else {
+			allJSON = false
+		}
+	})
+	if !hasBody {
+		return
+	}
+	body := &RequestBody{Content: map[string]*MediaType{}}
+	if hasJSON {
+		var schema *Schema
+		if allJSON {
+			schema = s.of(t)
+		} else {
+			schema = s.object(t, func(f reflect.StructField) bool {
+				fm := tagName(f, "form")
+				return tagName(f, "param") == "" && tagName(f, "query") == "" && (tagName(f, "json") != "" || fm == "")
+			})
+		}
+		body.Content["application/json"] = &MediaType{Schema: schema}
+		body.Required = hasRequired(s, schema)
+	}
+	if len(form.Properties) > 0 {
+		if multipart {
+			body.Content["multipart/form-data"] = &MediaType{Schema: form}
+		} else {
+			body.Content["application/x-www-form-urlencoded"] = &MediaType{Schema: form}
+		}
+		body.Required = body.Required || len(form.Required) > 0
+	}
+	if len(body.Content) > 0 {
+		op.RequestBody = body
+	}
+}
+
+func response(s *schemas, code int, v interface{}) *Response {
+	c, ok := v.(Content)
+	if p, isPtr := v.(*Content); isPtr && p != nil {
+		c, ok = *p, true
+	}
+	if !ok {
+		c = Content{Body: v}
+	}
+	resp := &Response{Description: c.Description}
+	if resp.Description == "" {
+		resp.Description = http.StatusText(code)
+	}
+	if c.Body != nil {
+		if c.ContentType == "" {
+			c.ContentType = "application/json"
+		}
+		resp.Content = map[string]*MediaType{
+			c.ContentType: {Schema: s.of(reflect.TypeOf(c.Body))},
+		}
+	}
+	return resp
+}
+
+func hasRequired(s *schemas, schema *Schema) bool {
+	if schema.Ref != "" {
+		schema = s.components[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema != nil && len(schema.Required) > 0
+}
+
+// hasBody returns true if the request struct has the fields in body, the tag form or json.
+func hasBody(req interface{}) bool {
+	if req == nil {
+		return false
+	}
+	t := reflect.TypeOf(req)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	found := false
+	walkFields(t, func(f reflect.StructField) {
+		if tagName(f, "param") == "" && tagName(f, "query") == "" && tagName(f, "json") != "-" {
+			found = true
+		}
+	})
+	return found
+}
+
+// walkFields calls fn with the exported fields of struct t, the anonymous struct fields without
+// tag param, query, form and json are flattened, the same as binding.
+func walkFields(t reflect.Type, fn func(f reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && tagName(f, "param") == "" && tagName(f, "query") == "" &&
+			tagName(f, "form") == "" && tagName(f, "json") == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				walkFields(ft, fn)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		fn(f)
+	}
+}
+
+func tagName(f reflect.StructField, tag string) string {
+	return strings.Split(f.Tag.Get(tag), ",")[0]
+}
+
+// matchPath checks the route path with the annotation path, the extension of route can be omitted.
+func matchPath(route, path string) bool {
+	if route == path {
+		return true
+	}
+	if !strings.HasPrefix(route, path) {
+		return false
+	}
+	ext := route[len(path):]
+	return strings.HasPrefix(ext, ".") && !strings.Contains(ext, "/")
+}
+
+func isAnyRoute(methods []string) bool {
+	for _, m := range anyMethods {
+		if !hasMethod(methods, m) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, v := range n.Content {
+		blockStyle(v)
+	}
+}
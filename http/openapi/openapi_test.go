@@ -0,0 +1,260 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gopenapi
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttpserver "github.com/snail007/gmc/http/server"
+	gconfig "github.com/snail007/gmc/module/config"
+	_ "github.com/snail007/gmc/using/web"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+type Base struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type User struct {
+	Base
+	ID      int64             `json:"id"`
+	Name    string            `json:"name" description:"user name" example:"jack"`
+	Email   string            `json:"email,omitempty"`
+	Friends []*User           `json:"friends"`
+	Extra   map[string]string `json:"extra"`
+	secret  string
+}
+
+type GetUserReq struct {
+	ID     int64  `param:"id" validate:"min=1" description:"user id"`
+	Fields string `query:"fields" validate:"omitempty,oneof=name email"`
+}
+
+type CreateUserReq struct {
+	Name  string   `form:"name" json:"name" validate:"required,max=20"`
+	Email string   `json:"email" validate:"required,email"`
+	Age   int      `json:"age" validate:"min=1,max=150" default:"18"`
+	Tags  []string `json:"tags" validate:"max=5"`
+	Code  string   `json:"code" validate:"omitempty,regex=^[a-z]{2,4}$"`
+	Debug bool     `query:"debug"`
+}
+
+type UploadReq struct {
+	Title string                `form:"title" validate:"required"`
+	File  *multipart.FileHeader `form:"file" validate:"required"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func newAPI() *ghttpserver.APIServer {
+	return ghttpserver.NewAPIServer(gcore.ProviderCtx()(), ":")
+}
+
+func handle(ctx gcore.Ctx) {}
+
+func build(t *testing.T, doc *Doc, router gcore.HTTPRouter) map[string]interface{} {
+	b, err := doc.JSON(router)
+	assert.Nil(t, err)
+	m := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(b, &m))
+	return m
+}
+
+func get(m interface{}, path ...string) interface{} {
+	for _, k := range path {
+		mm, ok := m.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		m = mm[k]
+	}
+	return m
+}
+
+func TestBuild(t *testing.T) {
+	assert := assert.New(t)
+	api := newAPI()
+	api.Ext(".json")
+	doc := New(Info{Title: "test", Version: "1.0"})
+	doc.SecuritySchemes["token"] = &SecurityScheme{Type: "http", Scheme: "bearer"}
+	v1 := api.Group("/v1")
+	doc.API(v1, "/user/:id", handle, Route{
+		Summary:   "get user",
+		Tags:      []string{"user"},
+		Security:  []string{"token"},
+		Request:   GetUserReq{},
+		Response:  User{},
+		Responses: map[int]interface{}{404: Content{Description: "user not found", Body: Error{}}},
+	}, "")
+	doc.API(v1, "/user", handle, Route{Summary: "create user", Tags: []string{"user"}, Request: &CreateUserReq{}})
+	doc.Handle(v1.Router(), http.MethodPost, "/upload", func(w http.ResponseWriter, r *http.Request, ps gcore.Params) {},
+		Route{Request: UploadReq{}, Deprecated: true})
+	api.Router().GET("/files/*path", func(w http.ResponseWriter, r *http.Request, ps gcore.Params) {})
+	api.API("/internal/ping", handle)
+	doc.Exclude("/internal/")
+	// not in route table
+	doc.Add("GET", "/not/found", Route{})
+
+	m := build(t, doc, api.Router())
+	assert.Equal(Version, m["openapi"])
+	assert.Equal("test", get(m, "info", "title"))
+	paths := m["paths"].(map[string]interface{})
+	assert.Len(paths, 4)
+
+	// path params and query params
+	op := get(paths, "/v1/user/{id}", "get")
+	assert.NotNil(op)
+	assert.Nil(get(paths, "/v1/user/{id}", "post"))
+	assert.Equal("get user", get(op, "summary"))
+	assert.Equal([]interface{}{map[string]interface{}{"token": []interface{}{}}}, get(op, "security"))
+	params := get(op, "parameters").([]interface{})
+	assert.Len(params, 2)
+	assert.Equal(map[string]interface{}{"name": "id", "in": "path", "required": true, "description": "user id",
+		"schema": map[string]interface{}{"type": "integer", "format": "int64", "minimum": float64(1)}}, params[0])
+	assert.Equal("fields", get(params[1], "name"))
+	assert.Equal("query", get(params[1], "in"))
+	assert.Nil(get(params[1], "required"))
+	assert.Equal([]interface{}{"name", "email"}, get(params[1], "schema", "enum"))
+	assert.Equal("#/components/schemas/User", get(op, "responses", "200", "content", "application/json", "schema", "$ref"))
+	assert.Equal("user not found", get(op, "responses", "404", "description"))
+	assert.Equal("#/components/schemas/Error", get(op, "responses", "404", "content", "application/json", "schema", "$ref"))
+
+	// request body of json and form
+	op = get(paths, "/v1/user.json", "post")
+	assert.NotNil(op)
+	assert.Nil(get(paths, "/v1/user.json", "get"))
+	assert.Equal("debug", get(op, "parameters").([]interface{})[0].(map[string]interface{})["name"])
+	assert.Equal(true, get(op, "requestBody", "required"))
+	s := get(op, "requestBody", "content", "application/json", "schema")
+	assert.Equal([]interface{}{"name", "email"}, get(s, "required"))
+	assert.Nil(get(s, "properties", "Debug"))
+	assert.Equal(map[string]interface{}{"type": "string", "maxLength": float64(20)}, get(s, "properties", "name"))
+	assert.Equal("email", get(s, "properties", "email", "format"))
+	assert.Equal(float64(18), get(s, "properties", "age", "default"))
+	assert.Equal(float64(150), get(s, "properties", "age", "maximum"))
+	assert.Equal(float64(5), get(s, "properties", "tags", "maxItems"))
+	assert.Equal("^[a-z]{2,4}$", get(s, "properties", "code", "pattern"))
+	assert.Equal([]interface{}{"name"}, get(op, "requestBody", "content", "application/x-www-form-urlencoded", "schema", "required"))
+
+	// multipart
+	op = get(paths, "/v1/upload", "post")
+	assert.Equal(true, get(op, "deprecated"))
+	s = get(op, "requestBody", "content", "multipart/form-data", "schema")
+	assert.Equal("binary", get(s, "properties", "file", "format"))
+	assert.Nil(get(op, "requestBody", "content", "application/json"))
+
+	// not annotated
+	op = get(paths, "/files/{path}", "get")
+	assert.Equal("path", get(op, "parameters").([]interface{})[0].(map[string]interface{})["name"])
+	assert.Equal("OK", get(op, "responses", "200", "description"))
+
+	// components
+	user := get(m, "components", "schemas", "User")
+	assert.Equal("date-time", get(user, "properties", "created_at", "format"))
+	assert.Equal("jack", get(user, "properties", "name", "example"))
+	assert.Equal("user name", get(user, "properties", "name", "description"))
+	assert.Equal("#/components/schemas/User", get(user, "properties", "friends", "items", "$ref"))
+	assert.Equal("string", get(user, "properties", "extra", "additionalProperties", "type"))
+	assert.Nil(get(user, "properties", "secret"))
+	assert.Equal("bearer", get(m, "components", "securitySchemes", "token", "scheme"))
+	assert.Equal([]interface{}{map[string]interface{}{"name": "user"}}, m["tags"])
+}
+
+func TestMethods(t *testing.T) {
+	assert := assert.New(t)
+	api := newAPI()
+	doc := New(Info{Title: "test", Version: "1.0"})
+	api.API("/a", handle)
+	api.API("/b", handle)
+	doc.Add("GET", "/a", Route{Summary: "get a"})
+	doc.Add("DELETE", "/a", Route{Summary: "delete a"})
+	doc.Add("", "/b", Route{Request: GetUserReq{}})
+	api.Router().PUT("/c", func(w http.ResponseWriter, r *http.Request, ps gcore.Params) {})
+	api.Router().DELETE("/c", func(w http.ResponseWriter, r *http.Request, ps gcore.Params) {})
+	doc.Add("", "/c", Route{Summary: "c"})
+	paths := build(t, doc, api.Router())["paths"].(map[string]interface{})
+	assert.Len(paths["/a"], 2)
+	assert.Equal("delete a", get(paths, "/a", "delete", "summary"))
+	assert.NotNil(get(paths, "/b", "get"))
+	assert.Len(paths["/c"], 2)
+	assert.Equal("c", get(paths, "/c", "put", "summary"))
+	assert.Equal("c", get(paths, "/c", "delete", "summary"))
+}
+
+func TestYAML(t *testing.T) {
+	assert := assert.New(t)
+	api := newAPI()
+	doc := New(Info{Title: "test", Version: "1.0"})
+	doc.API(api, "/user/:id", handle, Route{Request: GetUserReq{}, Response: User{}})
+	b, err := doc.YAML(api.Router())
+	assert.Nil(err)
+	assert.Contains(string(b), "openapi: 3.0.3\n")
+	m := map[string]interface{}{}
+	assert.Nil(yaml.Unmarshal(b, &m))
+	j := build(t, doc, api.Router())
+	b1, _ := json.Marshal(m)
+	b2, _ := json.Marshal(j)
+	assert.JSONEq(string(b2), string(b1))
+}
+
+func TestServe(t *testing.T) {
+	assert := assert.New(t)
+	api := newAPI()
+	doc := New(Info{Title: "<test>", Version: "1.0"})
+	doc.Serve(api.Router())
+	assert.Empty(api.Router().RouteTable())
+	doc.Path = "/docs/"
+	api.API("/hello", handle)
+	doc.Serve(api.Router().Group("/api"))
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs/openapi.json", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	m := map[string]interface{}{}
+	assert.Nil(json.Unmarshal(w.Body.Bytes(), &m))
+	assert.Len(m["paths"], 1)
+	assert.NotNil(get(m, "paths", "/hello", "get"))
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs/openapi.yaml", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), "/hello:")
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(w.Body.String(), `var specURL = "/api/docs/openapi.json"`)
+	assert.Contains(w.Body.String(), "<title>&lt;test&gt;</title>")
+}
+
+func TestNewFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gconfig.New()
+	doc := NewFromConfig(cfg)
+	assert.Equal("API", doc.Info.Title)
+	assert.Empty(doc.Path)
+	cfg.Set("openapi.title", "demo")
+	cfg.Set("openapi.servers", []string{"http://127.0.0.1:7081"})
+	doc = NewFromConfig(cfg)
+	assert.Equal("demo", doc.Info.Title)
+	assert.Equal("1.0.0", doc.Info.Version)
+	assert.Equal([]Server{{URL: "http://127.0.0.1:7081"}}, doc.Servers)
+	assert.Empty(doc.Path)
+	cfg.Set("openapi.enable", true)
+	assert.Equal("/docs", NewFromConfig(cfg).Path)
+}
@@ -0,0 +1,266 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gopenapi
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	gvalidator "github.com/snail007/gmc/util/validator"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+	invalidNameRegexp   = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// schemas generates the schemas of go types, the named structs are added to components
+// and referenced by $ref.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: map[string]*Schema{},
+		names:      map[reflect.Type]string{},
+	}
+}
+
+// of returns the schema of t, the struct fields are named by the tag json.
+func (s *schemas) of(t reflect.Type) *Schema {
+	switch t {
+	case fileHeaderType:
+		return &Schema{Type: "string", Format: "binary"}
+	case fileHeaderSliceType:
+		return &Schema{Type: "array", Items: &Schema{Type: "string", Format: "binary"}}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t, nil)
+		}
+		return s.ref(t)
+	}
+	// interface and the others, any value.
+	return &Schema{}
+}
+
+// ref adds the named struct t to components and returns the reference.
+func (s *schemas) ref(t reflect.Type) *Schema {
+	name, ok := s.names[t]
+	if !ok {
+		name = s.name(t)
+		s.names[t] = name
+		// placeholder for recursive types.
+		s.components[name] = &Schema{}
+		*s.components[name] = *s.object(t, nil)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *schemas) name(t reflect.Type) string {
+	name := invalidNameRegexp.ReplaceAllString(t.Name(), "_")
+	if _, ok := s.components[name]; !ok {
+		return name
+	}
+	name = path.Base(t.PkgPath()) + "." + name
+	n := name
+	for i := 2; ; i++ {
+		if _, ok := s.components[n]; !ok {
+			return n
+		}
+		n = name + strconv.Itoa(i)
+	}
+}
+
+// object returns the object schema of struct t with the fields filtered by include,
+// nil include means all fields. The anonymous struct fields without json name are flattened.
+func (s *schemas) object(t reflect.Type, include func(f reflect.StructField) bool) *Schema {
+	o := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.fields(t, include, o)
+	return o
+}
+
+func (s *schemas) fields(t reflect.Type, include func(f reflect.StructField) bool, o *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(ft, include, o)
+				continue
+			}
+		}
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+		if include != nil && !include(f) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		var fs *Schema
+		if len(tag) > 1 && tag[1] == "string" {
+			fs = &Schema{Type: "string"}
+		} else {
+			fs = s.of(f.Type)
+		}
+		o.Properties[name] = s.field(f, fs)
+		if fieldRequired(f) {
+			o.Required = append(o.Required, name)
+		}
+	}
+}
+
+// field applies the tags description, example and the validate rules of f to schema.
+func (s *schemas) field(f reflect.StructField, schema *Schema) *Schema {
+	desc := f.Tag.Get("description")
+	if schema.Ref != "" {
+		// the siblings of $ref are ignored, the description is kept by allOf.
+		if desc == "" {
+			return schema
+		}
+		return &Schema{AllOf: []*Schema{schema}, Description: desc}
+	}
+	schema.Description = desc
+	t := f.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if v, ok := f.Tag.Lookup("example"); ok {
+		schema.Example = convert(t, v)
+	}
+	if v, ok := f.Tag.Lookup("default"); ok {
+		schema.Default = convert(t, v)
+	}
+	applyRules(schema, t, f.Tag.Get(gvalidator.TagName))
+	return schema
+}
+
+// applyRules converts the validate rules to the schema constraints.
+func applyRules(schema *Schema, t reflect.Type, tag string) {
+	for _, r := range gvalidator.ParseRules(tag) {
+		rule, param := r[0], r[1]
+		switch rule {
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			setSize(schema, t, rule, n)
+		case "email":
+			schema.Format = "email"
+		case "oneof":
+			schema.Enum = nil
+			for _, v := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, convert(t, v))
+			}
+		case "regex":
+			schema.Pattern = param
+		}
+	}
+}
+
+func setSize(schema *Schema, t reflect.Type, rule string, n float64) {
+	var min, max **int
+	switch t.Kind() {
+	case reflect.String:
+		min, max = &schema.MinLength, &schema.MaxLength
+	case reflect.Slice, reflect.Array:
+		min, max = &schema.MinItems, &schema.MaxItems
+	case reflect.Map:
+		min, max = &schema.MinProperties, &schema.MaxProperties
+	default:
+		if rule != "max" {
+			schema.Minimum = &n
+		}
+		if rule != "min" {
+			schema.Maximum = &n
+		}
+		return
+	}
+	i := int(n)
+	if rule != "max" {
+		*min = &i
+	}
+	if rule != "min" {
+		*max = &i
+	}
+}
+
+// convert converts the tag value to the value of type t, the string is returned if failed.
+func convert(t reflect.Type, s string) interface{} {
+	switch t.Kind() {
+	case reflect.Bool:
+		if v, err := strconv.ParseBool(s); err == nil {
+			return v
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return v
+		}
+	case reflect.Float32, reflect.Float64:
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v
+		}
+	}
+	return s
+}
+
+func fieldRequired(f reflect.StructField) bool {
+	for _, r := range gvalidator.ParseRules(f.Tag.Get(gvalidator.TagName)) {
+		if r[0] == "required" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gopenapi
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+)
+
+//go:embed ui.html
+var uiHTML string
+
+var uiTemplate = template.Must(template.New("ui").Parse(uiHTML))
+
+// renderUI renders the docs UI which loads the json document from url.
+func renderUI(title, url string) []byte {
+	var buf bytes.Buffer
+	uiTemplate.Execute(&buf, map[string]string{"Title": title, "URL": url})
+	return buf.Bytes()
+}
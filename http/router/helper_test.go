@@ -6,8 +6,11 @@
 package grouter
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	gcore "github.com/snail007/gmc/core"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -48,3 +51,20 @@ func TestParseObject(t *testing.T) {
 	v = invoke(obj2, m[0], "called")
 	assert.Equal("called", v[0].String())
 }
+
+func TestHTTPRouter_NoCtxProvider(t *testing.T) {
+	assert := assert.New(t)
+	r := NewHTTPRouter(nil)
+	called := false
+	r.Use(func(ctx gcore.Ctx, next func()) {
+		next()
+	})
+	r.Handle("GET", "/", func(w http.ResponseWriter, req *http.Request, ps gcore.Params) {
+		called = true
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	assert.NotPanics(func() { r.ServeHTTP(w, req) })
+	assert.False(called)
+	assert.Equal(http.StatusInternalServerError, w.Code)
+}
@@ -16,8 +16,11 @@ import (
 	"strings"
 
 	gcore "github.com/snail007/gmc/core"
+	gwebsocket "github.com/snail007/gmc/http/websocket"
 )
 
+const errNoCtxProvider = "gmc router: no ctx provider registered"
+
 var (
 	anyMethods = []string{
 		http.MethodGet,
@@ -65,6 +68,10 @@ type HTTPRouter struct {
 	ns  string
 	ext string
 	ctx gcore.Ctx
+	// middlewares of current group
+	middlewares []gcore.RouteMiddleware
+	// with is true if the router is created by With, it shares the namespace of parent
+	with bool
 }
 
 func NewHTTPRouter(ctx gcore.Ctx) *HTTPRouter {
@@ -94,14 +101,96 @@ func (s *HTTPRouter) Group(ns string) gcore.HTTPRouter {
 		ctx:    s.ctx,
 	}
 }
+
 func (s *HTTPRouter) Namespace() string {
+	if s.with {
+		return s.hr.Namespace()
+	}
 	parentNS := ""
 	if s.hr != nil {
-		parentNS = s.hr.ns
+		parentNS = s.hr.Namespace()
 	}
 	return strings.TrimRight(parentNS, "/") + s.ns
 }
 
+// Use adds middlewares to current group, they run in order after routing for all routes
+// of the group and its sub groups, including the routes added before Use called.
+// The middlewares of parent group run before the middlewares of sub group.
+func (s *HTTPRouter) Use(m ...gcore.RouteMiddleware) {
+	s.middlewares = append(s.middlewares, m...)
+}
+
+// With returns a router in the same namespace with the middlewares, the middlewares only
+// apply to the routes added by the returned router.
+//
+//	r.With(auth).GET("/user/:id", handle)
+func (s *HTTPRouter) With(m ...gcore.RouteMiddleware) gcore.HTTPRouter {
+	return &HTTPRouter{
+		Router:      s.Router,
+		hr:          s,
+		ns:          "/",
+		ext:         s.ext,
+		ctx:         s.ctx,
+		middlewares: m,
+		with:        true,
+	}
+}
+
+// chain returns the middlewares of current group and all parent groups, root first.
+func (s *HTTPRouter) chain() (chain []gcore.RouteMiddleware) {
+	for r := s; r != nil; r = r.hr {
+		chain = append(append([]gcore.RouteMiddleware{}, r.middlewares...), chain...)
+	}
+	return
+}
+
+// requestCtx returns the ctx of the request, a new one is created if the router is used
+// without gmc server. It returns nil if no ctx can be created, the caller should reject the request.
+func (s *HTTPRouter) requestCtx(w http.ResponseWriter, req *http.Request, ps gcore.Params) gcore.Ctx {
+	ctx := gcore.GetCtx(w)
+	if ctx == nil {
+		if s.ctx != nil {
+			return s.ctx.CloneWithHTTP(w, req, ps)
+		}
+		provider := gcore.ProviderCtx()
+		if provider == nil {
+			return nil
+		}
+		ctx = provider().CloneWithHTTP(w, req)
+	}
+	ctx.SetParam(ps)
+	return ctx
+}
+
+// wrap returns a handle which runs the middlewares of current group before handle.
+func (s *HTTPRouter) wrap(handle gcore.Handle) gcore.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps gcore.Params) {
+		chain := s.chain()
+		if len(chain) == 0 {
+			handle(w, req, ps)
+			return
+		}
+		ctx := s.requestCtx(w, req, ps)
+		if ctx == nil {
+			// never skip the middlewares, they may be used to reject the request.
+			http.Error(w, errNoCtxProvider, http.StatusInternalServerError)
+			return
+		}
+		i := 0
+		var next func()
+		next = func() {
+			if i < len(chain) {
+				m := chain[i]
+				i++
+				m(ctx, next)
+				return
+			}
+			handle(ctx.Response(), ctx.Request(), ps)
+		}
+		next()
+	}
+}
+
 // Ext sets Controller()'s default ext
 func (this *HTTPRouter) Ext(ext string) {
 	this.ext = ext
@@ -277,7 +366,67 @@ func (s *HTTPRouter) path(path string) string {
 // communication with a proxy).
 func (s *HTTPRouter) Handle(method, path string, handle gcore.Handle) {
 	p := s.path(path)
-	s.Router.Handle(method, p, handle)
+	s.Router.Handle(method, p, s.wrap(handle))
+}
+
+// GET is a shortcut for router.Handle(http.MethodGet, path, handle)
+func (s *HTTPRouter) GET(path string, handle gcore.Handle) {
+	s.Handle(http.MethodGet, path, handle)
+}
+
+// HEAD is a shortcut for router.Handle(http.MethodHead, path, handle)
+func (s *HTTPRouter) HEAD(path string, handle gcore.Handle) {
+	s.Handle(http.MethodHead, path, handle)
+}
+
+// OPTIONS is a shortcut for router.Handle(http.MethodOptions, path, handle)
+func (s *HTTPRouter) OPTIONS(path string, handle gcore.Handle) {
+	s.Handle(http.MethodOptions, path, handle)
+}
+
+// POST is a shortcut for router.Handle(http.MethodPost, path, handle)
+func (s *HTTPRouter) POST(path string, handle gcore.Handle) {
+	s.Handle(http.MethodPost, path, handle)
+}
+
+// PUT is a shortcut for router.Handle(http.MethodPut, path, handle)
+func (s *HTTPRouter) PUT(path string, handle gcore.Handle) {
+	s.Handle(http.MethodPut, path, handle)
+}
+
+// PATCH is a shortcut for router.Handle(http.MethodPatch, path, handle)
+func (s *HTTPRouter) PATCH(path string, handle gcore.Handle) {
+	s.Handle(http.MethodPatch, path, handle)
+}
+
+// DELETE is a shortcut for router.Handle(http.MethodDelete, path, handle)
+func (s *HTTPRouter) DELETE(path string, handle gcore.Handle) {
+	s.Handle(http.MethodDelete, path, handle)
+}
+
+// WS registers a websocket handler with the given path, the request is upgraded by upgrader,
+// the default is gwebsocket.NewUpgrader(), and the connection is closed after handler returned.
+// The middlewares of the router run before upgrading, so they can reject the request.
+func (s *HTTPRouter) WS(path string, handler gcore.WebSocketHandler, upgrader ...gcore.WebSocketUpgrader) {
+	var u gcore.WebSocketUpgrader
+	if len(upgrader) > 0 && upgrader[0] != nil {
+		u = upgrader[0]
+	} else {
+		u = gwebsocket.NewUpgrader()
+	}
+	s.GET(path, func(w http.ResponseWriter, r *http.Request, ps gcore.Params) {
+		ctx := s.requestCtx(w, r, ps)
+		if ctx == nil {
+			http.Error(w, errNoCtxProvider, http.StatusInternalServerError)
+			return
+		}
+		conn, err := u.Upgrade(ctx)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(ctx, conn)
+	})
 }
 
 // HandleAny registers a new request handle with the given path and all http methods,
@@ -292,7 +441,7 @@ func (s *HTTPRouter) HandleAny(path string, handle gcore.Handle) {
 // request handle.
 // The Params are available in the request context under ParamsKey.
 func (s *HTTPRouter) Handler(method, path string, handler http.Handler) {
-	s.Router.Handler(method, s.path(path), handler)
+	s.Handle(method, path, handlerToHandle(handler))
 }
 
 // HandlerAny is an adapter which allows the usage of an http.Handler as a
@@ -307,7 +456,7 @@ func (s *HTTPRouter) HandlerAny(path string, handler http.Handler) {
 // HandlerFunc is an adapter which allows the usage of an http.HandlerFunc as a
 // request handle.
 func (s *HTTPRouter) HandlerFunc(method, path string, handler http.HandlerFunc) {
-	s.Router.HandlerFunc(method, s.path(path), handler)
+	s.Handler(method, path, handler)
 }
 
 // HandlerFuncAny is an adapter which allows the usage of an http.HandlerFunc as a
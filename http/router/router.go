@@ -291,16 +291,20 @@ func (r *Router) Handle(method, path string, handle gcore.Handle) {
 // request handle.
 // The Params are available in the request context under ParamsKey.
 func (r *Router) Handler(method, path string, handler http.Handler) {
-	r.Handle(method, path,
-		func(w http.ResponseWriter, req *http.Request, p gcore.Params) {
-			if len(p) > 0 {
-				ctx := req.Context()
-				ctx = context.WithValue(ctx, gcore.ParamsKey, p)
-				req = req.WithContext(ctx)
-			}
-			handler.ServeHTTP(w, req)
-		},
-	)
+	r.Handle(method, path, handlerToHandle(handler))
+}
+
+// handlerToHandle converts the http.Handler to a Handle, the Params are stored
+// in the request context under ParamsKey.
+func handlerToHandle(handler http.Handler) gcore.Handle {
+	return func(w http.ResponseWriter, req *http.Request, p gcore.Params) {
+		if len(p) > 0 {
+			ctx := req.Context()
+			ctx = context.WithValue(ctx, gcore.ParamsKey, p)
+			req = req.WithContext(ctx)
+		}
+		handler.ServeHTTP(w, req)
+	}
 }
 
 // HandlerFunc is an adapter which allows the usage of an http.HandlerFunc as a
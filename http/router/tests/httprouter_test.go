@@ -6,9 +6,13 @@
 package grouter_test
 
 import (
-	gctx "github.com/snail007/gmc/module/ctx"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	gcore "github.com/snail007/gmc/core"
+	gctx "github.com/snail007/gmc/module/ctx"
+
 	gcontroller "github.com/snail007/gmc/http/controller"
 
 	"github.com/stretchr/testify/assert"
@@ -107,3 +111,114 @@ func TestGroup_2(t *testing.T) {
 	r.PrintRouteTable(nil)
 	// t.Fail()
 }
+
+func mark(name string, log *[]string) gcore.RouteMiddleware {
+	return func(ctx gcore.Ctx, next func()) {
+		*log = append(*log, name+":"+ctx.GetParam("id"))
+		next()
+		*log = append(*log, "/"+name)
+	}
+}
+
+func serve(r http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	assert := assert.New(t)
+	var log []string
+	r := grouter.NewHTTPRouter(gctx.NewCtx())
+	handle := func(w http.ResponseWriter, req *http.Request, ps gcore.Params) {
+		log = append(log, "handle:"+ps.ByName("id"))
+	}
+	r.Handle("GET", "/home/:id", handle)
+	g1 := r.Group("/v1")
+	g1.Handle("GET", "/user/:id", handle)
+	g11 := g1.Group("/admin")
+	g11.With(mark("route", &log)).Handle("GET", "/user/:id", handle)
+	g11.Handle("GET", "/plain/:id", handle)
+	// Use affects the routes added before
+	r.Use(mark("root", &log))
+	g1.Use(mark("g1", &log))
+	g11.Use(mark("g11", &log))
+
+	serve(r, "GET", "/home/1")
+	assert.Equal([]string{"root:1", "handle:1", "/root"}, log)
+
+	log = nil
+	serve(r, "GET", "/v1/user/2")
+	assert.Equal([]string{"root:2", "g1:2", "handle:2", "/g1", "/root"}, log)
+
+	log = nil
+	serve(r, "GET", "/v1/admin/user/3")
+	assert.Equal([]string{"root:3", "g1:3", "g11:3", "route:3", "handle:3", "/route", "/g11", "/g1", "/root"}, log)
+
+	log = nil
+	serve(r, "GET", "/v1/admin/plain/4")
+	assert.Equal([]string{"root:4", "g1:4", "g11:4", "handle:4", "/g11", "/g1", "/root"}, log)
+}
+
+func TestMiddleware_Stop(t *testing.T) {
+	assert := assert.New(t)
+	r := grouter.NewHTTPRouter(gctx.NewCtx())
+	auth := func(ctx gcore.Ctx, next func()) {
+		if ctx.GET("token") != "ok" {
+			ctx.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ctx.SetHeader("X-Auth", "1")
+		next()
+	}
+	g := r.Group("/api")
+	g.Use(auth)
+	g.HandlerFunc("GET", "/user", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("user"))
+	})
+	w := serve(r, "GET", "/api/user")
+	assert.Equal(http.StatusUnauthorized, w.Code)
+	assert.Equal("", w.Body.String())
+	w = serve(r, "GET", "/api/user?token=ok")
+	assert.Equal("user", w.Body.String())
+	assert.Equal("1", w.Header().Get("X-Auth"))
+}
+
+func TestGroup_Shortcuts(t *testing.T) {
+	assert := assert.New(t)
+	r := grouter.NewHTTPRouter(gctx.NewCtx())
+	var log []string
+	api := r.Group("/api")
+	api.Use(mark("api", &log))
+	handle := func(w http.ResponseWriter, req *http.Request, ps gcore.Params) {
+		w.Write([]byte(req.Method))
+	}
+	api.GET("/user/:id", handle)
+	api.POST("/user/:id", handle)
+	api.PUT("/user/:id", handle)
+	api.PATCH("/user/:id", handle)
+	api.DELETE("/user/:id", handle)
+	api.OPTIONS("/user/:id", handle)
+	api.HEAD("/user/:id", handle)
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HEAD"} {
+		log = nil
+		w := serve(r, method, "/api/user/1")
+		assert.Equal(http.StatusOK, w.Code, method)
+		assert.Equal([]string{"api:1", "/api"}, log, method)
+	}
+	// the shortcuts do not register the routes without the group prefix.
+	assert.Equal(http.StatusNotFound, serve(r, "GET", "/user/1").Code)
+}
+
+func TestGroup_NestedNamespace(t *testing.T) {
+	assert := assert.New(t)
+	r := grouter.NewHTTPRouter(gctx.NewCtx())
+	g := r.Group("/a").Group("/b").Group("/c")
+	assert.Equal("/a/b/c/", g.Namespace())
+	assert.Equal("/a/b/c/", g.With().Namespace())
+	g.GET("/d", func(w http.ResponseWriter, req *http.Request, ps gcore.Params) {
+		w.Write([]byte("d"))
+	})
+	assert.Equal("d", serve(r, "GET", "/a/b/c/d").Body.String())
+	assert.Equal(http.StatusNotFound, serve(r, "GET", "/b/c/d").Code)
+}
@@ -237,12 +237,32 @@ func (this *APIServer) API(path string, handle func(ctx gcore.Ctx), ext ...strin
 	})
 }
 
+// WS registers a websocket handler, see HTTPRouter.WS.
+func (this *APIServer) WS(path string, handler gcore.WebSocketHandler, upgrader ...gcore.WebSocketUpgrader) {
+	this.router.WS(path, handler, upgrader...)
+}
+
 func (this *APIServer) Group(path string) gcore.APIServer {
 	newAPI := *this
 	newAPI.router = this.router.Group(path)
 	return &newAPI
 }
 
+// Use adds middlewares to the routes of current group, see HTTPRouter.Use.
+func (this *APIServer) Use(m ...gcore.RouteMiddleware) {
+	this.router.Use(m...)
+}
+
+// With returns an APIServer in the same group with the middlewares, the middlewares only
+// apply to the APIs added by the returned APIServer.
+//
+//	api.With(auth).API("/user/info", handle)
+func (this *APIServer) With(m ...gcore.RouteMiddleware) gcore.APIServer {
+	newAPI := *this
+	newAPI.router = this.router.With(m...)
+	return &newAPI
+}
+
 // PrintRouteTable dump all routes into `w`, if `w` is nil, os.Stdout will be used.
 func (this *APIServer) PrintRouteTable(w io.Writer) {
 	this.router.PrintRouteTable(w)
@@ -6,11 +6,16 @@
 package ghttpserver
 
 import (
+	"net/http/httptest"
+	"strings"
+
 	gcore "github.com/snail007/gmc/core"
 	"github.com/snail007/gmc/http/template/testdata"
+	gwebsocket "github.com/snail007/gmc/http/websocket"
 	"net"
 	"net/http"
 	"testing"
+	"time"
 
 	ghttputil "github.com/snail007/gmc/internal/util/http"
 
@@ -53,6 +58,85 @@ func TestAPI(t *testing.T) {
 	assert.Equal("a", data)
 }
 
+func TestAPIServer_Use(t *testing.T) {
+	assert := assert.New(t)
+	api := NewAPIServer(gcore.ProviderCtx()(), ":")
+	api.AddMiddleware1(func(c gcore.Ctx) bool {
+		c.Write("m1,")
+		return false
+	})
+	wrap := func(name string) gcore.RouteMiddleware {
+		return func(c gcore.Ctx, next func()) {
+			c.Write(name + "(" + c.GetParam("id") + "),")
+			next()
+			c.Write("/" + name)
+		}
+	}
+	api.API("/home", func(c gcore.Ctx) {
+		c.Write("home,")
+	})
+	v1 := api.Group("/v1")
+	v1.Use(wrap("v1"))
+	v1.API("/user/:id", func(c gcore.Ctx) {
+		c.Write("user,")
+	})
+	v1.With(wrap("auth")).API("/admin/:id", func(c gcore.Ctx) {
+		c.Write("admin,")
+	})
+	for path, want := range map[string]string{
+		"/home":       "m1,home,",
+		"/v1/user/1":  "m1,v1(1),user,/v1",
+		"/v1/admin/2": "m1,v1(2),auth(2),admin,/auth/v1",
+	} {
+		w, r := mockRequest(path)
+		api.ServeHTTP(w, r)
+		data, _, err := mockResponse(w)
+		assert.Nil(err)
+		assert.Equal(want, data)
+	}
+}
+
+func TestAPIServer_WS(t *testing.T) {
+	assert := assert.New(t)
+	api := NewAPIServer(gcore.ProviderCtx()(), ":")
+	g := api.Group("/ws")
+	g.Use(func(c gcore.Ctx, next func()) {
+		if c.GET("token") != "ok" {
+			c.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next()
+	})
+	g.WS("/echo/:room", func(c gcore.Ctx, conn gcore.WebSocketConn) {
+		conn.WriteMessage(gwebsocket.TextMessage, []byte(c.GetParam("room")))
+		for {
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(mt, data)
+		}
+	})
+	s := httptest.NewServer(api)
+	defer s.Close()
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws/echo/r1"
+	_, resp, err := gwebsocket.Dial(url, nil)
+	assert.NotNil(err)
+	assert.Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	conn, _, err := gwebsocket.Dial(url+"?token=ok", nil)
+	assert.Nil(err)
+	defer conn.Close()
+	_, data, err := conn.ReadMessage()
+	assert.Nil(err)
+	assert.Equal("r1", string(data))
+	assert.Nil(conn.WriteMessage(gwebsocket.BinaryMessage, []byte("hi")))
+	mt, data, err := conn.ReadMessage()
+	assert.Nil(err)
+	assert.Equal(gwebsocket.BinaryMessage, mt)
+	assert.Equal("hi", string(data))
+}
+
 func TestAfter(t *testing.T) {
 	assert := assert.New(t)
 	api := NewAPIServer(gcore.ProviderCtx()(), ":")
@@ -241,3 +325,19 @@ func TestAPIServer_ServeFilesWithFilter(t *testing.T) {
 	str, _ = result(w)
 	assert.Equal("abc", str)
 }
+
+func TestAPIServer_SSE(t *testing.T) {
+	assert := assert.New(t)
+	api := NewAPIServer(gcore.ProviderCtx()(), ":")
+	api.API("/events", func(c gcore.Ctx) {
+		c.SSE(func(stream gcore.SSEStream) {
+			stream.Event("progress", map[string]int{"done": 1})
+			<-stream.Done()
+		}, 10*time.Millisecond)
+	})
+	s := httptest.NewServer(api)
+	defer s.Close()
+	lines, err := readSSELines(s.URL+"/events", 3)
+	assert.Nil(err)
+	assert.Equal([]string{"event: progress", `data: {"done":1}`, ": heartbeat"}, lines)
+}
@@ -81,6 +81,7 @@ type HTTPServer struct {
 	remoteAddrDataMap    *sync.Map
 	ctx                  gcore.Ctx
 	binData              map[string][]byte
+	staticCacheControl   string
 }
 
 // SetBinBytes key is file path no slash prefix, value is file's bytes contents.
@@ -459,6 +460,10 @@ func (s *HTTPServer) connState(c net.Conn, st http.ConnState) {
 func (s *HTTPServer) initStatic() {
 	s.staticDir = s.config.GetString("static.dir")
 	s.staticUrlpath = s.config.GetString("static.urlpath")
+	s.staticCacheControl = s.config.GetString("static.cache_control")
+	if s.staticCacheControl == "" {
+		s.staticCacheControl = "public, max-age=31536000"
+	}
 	if s.staticDir != "" && s.staticUrlpath != "" {
 		if strings.HasSuffix(s.staticUrlpath, "/") {
 			s.staticUrlpath = strings.TrimRight(s.staticUrlpath, "/")
@@ -495,6 +500,8 @@ func (s *HTTPServer) serveStatic(w http.ResponseWriter, r *http.Request) {
 	path = strings.TrimPrefix(path, s.staticUrlpath)
 	var b []byte
 	var ok bool
+	// the bin data is built into the executable.
+	modTime := ghttputil.BuildTime()
 	//1. find in s.binData
 	b, ok = s.binData[path]
 
@@ -505,25 +512,34 @@ func (s *HTTPServer) serveStatic(w http.ResponseWriter, r *http.Request) {
 
 	//3. find in system path
 	if !ok {
-		var e error
-		b, e = ioutil.ReadFile(filepath.Join(s.staticDir, path))
-		ok = e == nil
+		file := filepath.Join(s.staticDir, path)
+		if info, e := os.Stat(file); e == nil && !info.IsDir() {
+			b, e = ioutil.ReadFile(file)
+			ok = e == nil
+			modTime = info.ModTime()
+		}
 	}
 	if !ok {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte("Not Found"))
 		return
 	}
-	cacheSince := time.Now().Format(http.TimeFormat)
-	cacheUntil := time.Now().AddDate(66, 0, 0).Format(http.TimeFormat)
 	ext := filepath.Ext(path)
 	typ := mime.TypeByExtension(ext)
-	w.Header().Set("Cache-Control", "max-age:290304000, public")
-	w.Header().Set("Last-Modified", cacheSince)
-	w.Header().Set("Expires", cacheUntil)
+	w.Header().Set("Cache-Control", s.staticCacheControl)
+	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	w.Header().Set("ETag", ghttputil.FileETag(modTime, int64(len(b))))
 	w.Header().Set("Content-Type", typ)
 	gizpCheck := map[string]bool{".js": true, ".css": true}
-	if _, ok := gizpCheck[ext]; ok {
+	_, gzipExt := gizpCheck[ext]
+	if gzipExt {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if ghttputil.NotModified(r, w.Header()) {
+		ghttputil.WriteNotModified(w)
+		return
+	}
+	if gzipExt {
 		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
 			w.Header().Set("Content-Encoding", "gzip")
 			gz := gzip.NewWriter(w)
@@ -634,7 +650,12 @@ func serveEmbedFS(router gcore.HTTPRouter, fs embed.FS, urlPath string, filter g
 		}
 		// embed files will not change, so set long cache time
 		w.Header().Set("Cache-Control", "public, max-age=31536000")
-		http.ServeContent(w, r, filepath.Base(path), time.Time{}, bytes.NewReader(b))
+		// embed files have no modification time, the build time is used.
+		modTime := ghttputil.BuildTime()
+		if w.Header().Get("ETag") == "" {
+			w.Header().Set("ETag", ghttputil.FileETag(modTime, int64(len(b))))
+		}
+		http.ServeContent(w, r, filepath.Base(path), modTime, bytes.NewReader(b))
 	})
 }
 
@@ -651,12 +672,19 @@ func serveFiles(router gcore.HTTPRouter, root, urlPath string, filter gcore.Http
 			}
 			path = newPath
 		}
-		b, err := os.ReadFile(filepath.Join(root, path))
+		file := filepath.Join(root, path)
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() {
+			notFound(w)
+			return
+		}
+		b, err := os.ReadFile(file)
 		if err != nil {
 			notFound(w)
 			return
 		}
-		http.ServeContent(w, r, filepath.Base(path), time.Time{}, bytes.NewReader(b))
+		ghttputil.SetFileETag(w, info)
+		http.ServeContent(w, r, filepath.Base(path), info.ModTime(), bytes.NewReader(b))
 	})
 }
 
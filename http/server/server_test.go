@@ -6,6 +6,7 @@
 package ghttpserver
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"encoding/base64"
@@ -21,6 +22,8 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -596,6 +599,63 @@ func (this *User) Ps() {
 	this.Write(this.Param.ByName("args") + this.Param.MatchedRoutePath())
 }
 
+type Login struct {
+	gcontroller.Controller
+}
+
+func (this *Login) Login() {
+	this.SessionStart()
+	this.Session.Set("user", "jack")
+	this.SetFlash("msg", "welcome")
+	this.SessionRegenerate()
+	this.Write(this.Session.SessionID())
+}
+
+func (this *Login) Index() {
+	this.SessionStart()
+	this.Write(fmt.Sprintf("%v|%v", this.Session.Get("user"), this.Flash("msg")))
+}
+
+func Test_Controller_Session(t *testing.T) {
+	assert := assert.New(t)
+	s := mockHTTPServer()
+	s.router.Controller("/login/", new(Login))
+	request := func(uri string, cookies []*http.Cookie, ua string) (string, []*http.Cookie) {
+		w, r := mockRequest(uri)
+		r.Header.Set("User-Agent", ua)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		s.ServeHTTP(w, r)
+		str, resp := result(w)
+		return str, resp.Cookies()
+	}
+	// the session id before login is dropped.
+	_, c0 := request("/login/index", nil, "a")
+	assert.Len(c0, 1)
+	sid, c1 := request("/login/login", c0, "a")
+	assert.Len(c1, 1)
+	assert.Equal(sid, c1[0].Value)
+	assert.NotEqual(c0[0].Value, sid)
+	_, ok := s.SessionStore().Load(c0[0].Value)
+	assert.False(ok)
+
+	str, _ := request("/login/index", c1, "a")
+	assert.Equal("jack|welcome", str)
+	str, _ = request("/login/index", c1, "a")
+	assert.Equal("jack|<nil>", str)
+
+	// the session bound to the user agent.
+	s.Config().Set("session.bind_ua", true)
+	defer s.Config().Set("session.bind_ua", false)
+	sid, c1 = request("/login/login", nil, "a")
+	str, _ = request("/login/index", c1, "a")
+	assert.Equal("jack|welcome", str)
+	str, c2 := request("/login/index", c1, "b")
+	assert.Equal("<nil>|<nil>", str)
+	assert.NotEqual(sid, c2[0].Value)
+}
+
 func mockConfig() gcore.Config {
 	cfg := gcore.ProviderConfig()()
 	cfg.SetConfigFile("../../module/app/app.toml")
@@ -687,6 +747,53 @@ func TestHTTPServer_ServeEmbedFS(t *testing.T) {
 	assert.Equal(404, w.Result().StatusCode)
 }
 
+func TestHTTPServer_ServeEmbedFS_NotModified(t *testing.T) {
+	assert := assert.New(t)
+	s := mockHTTPServer()
+	s.ServeEmbedFS(testdata.TplFS, "/tpls/")
+	s.ServeFiles("tests", "/files/")
+	for _, path := range []string{"/tpls/f/f.txt", "/files/d.txt"} {
+		w, r := mockRequest(path)
+		s.ServeHTTP(w, r)
+		etag := w.Header().Get("ETag")
+		lm := w.Header().Get("Last-Modified")
+		assert.NotEmpty(etag)
+		assert.NotEmpty(lm)
+
+		w, r = mockRequest(path)
+		r.Header.Set("If-None-Match", etag)
+		s.ServeHTTP(w, r)
+		assert.Equal(http.StatusNotModified, w.Code)
+
+		w, r = mockRequest(path)
+		r.Header.Set("If-Modified-Since", lm)
+		s.ServeHTTP(w, r)
+		assert.Equal(http.StatusNotModified, w.Code)
+	}
+}
+
+func Test_serveStatic_NotModified(t *testing.T) {
+	assert := assert.New(t)
+	cfg := mockConfig()
+	cfg.Set("static.dir", "tests")
+	cfg.Set("static.urlpath", "/static")
+	cfg.Set("static.cache_control", "no-cache")
+	s := mockHTTPServer(cfg)
+	w, r := mockRequest("/static/d.txt")
+	s.serveStatic(w, r)
+	assert.Equal("no-cache", w.Header().Get("Cache-Control"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(etag)
+	info, _ := os.Stat("tests/d.txt")
+	assert.Equal(info.ModTime().UTC().Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+
+	w, r = mockRequest("/static/d.txt")
+	r.Header.Set("If-None-Match", etag)
+	s.serveStatic(w, r)
+	assert.Equal(http.StatusNotModified, w.Code)
+	assert.Empty(w.Body.String())
+}
+
 func TestHTTPServer_ServeEmbedFSWithFilter(t *testing.T) {
 	assert := assert.New(t)
 	s := mockHTTPServer()
@@ -728,3 +835,39 @@ func TestHTTPServer_ServeFilesWithFilter(t *testing.T) {
 	str, _ = result(w)
 	assert.Equal("abc", str)
 }
+
+func readSSELines(url string, n int) (lines []string, err error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+	for len(lines) < n {
+		var line string
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return
+}
+
+func TestHTTPServer_SSE(t *testing.T) {
+	assert := assert.New(t)
+	s := mockHTTPServer()
+	s.router.HandlerFunc("GET", "/events", func(w http.ResponseWriter, r *http.Request) {
+		gcore.GetCtx(w).SSE(func(stream gcore.SSEStream) {
+			stream.Send(gcore.SSEEvent{ID: "1", Data: stream.LastEventID()})
+			<-stream.Done()
+		}, 10*time.Millisecond)
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	lines, err := readSSELines(ts.URL+"/events?lastEventId=0", 3)
+	assert.Nil(err)
+	assert.Equal([]string{"id: 1", "data: 0", ": heartbeat"}, lines)
+}
@@ -0,0 +1,311 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gsession
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gcookie "github.com/snail007/gmc/http/cookie"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+)
+
+var (
+	// ErrCookieTooLarge is returned when the encrypted session is larger than ChunkSize * MaxChunks.
+	ErrCookieTooLarge = errors.New("gsession: session is too large to be stored in cookies")
+	// ErrHeaderWritten is returned when the session is changed after the response header is written.
+	ErrHeaderWritten = errors.New("gsession: response header is written, the changes of session are lost")
+	// ErrNoCtx is returned when CookieStore is used without WithCtx.
+	ErrNoCtx = errors.New("gsession: cookie store should be used by WithCtx")
+)
+
+type CookieStoreConfig struct {
+	// Keys are the secret keys to encrypt the session, the first key is used to encrypt,
+	// all the keys are tried to decrypt.
+	Keys []string
+	// Name is the name of the cookie, the chunks are named Name, Name_1, Name_2 ...
+	Name      string
+	ChunkSize int // bytes of the value of a cookie
+	MaxChunks int
+	Path      string
+	Domain    string
+	Secure    bool
+	SameSite  http.SameSite
+	TTL       int64 //seconds
+}
+
+func NewCookieStoreConfig() CookieStoreConfig {
+	return CookieStoreConfig{
+		Name:      "gmcsdata",
+		ChunkSize: 4000,
+		MaxChunks: 5,
+		Path:      "/",
+		SameSite:  http.SameSiteLaxMode,
+		TTL:       15 * 60,
+	}
+}
+
+// CookieStore keeps the session in the cookies of the client, the session is serialized and encrypted
+// by AES-GCM, so the client can neither read nor modify it. The cookies are written before the response
+// header is written, the changes of the session after that are lost.
+type CookieStore struct {
+	gcore.SessionStorage
+	cfg CookieStoreConfig
+	opt *gcookie.Options
+}
+
+type cookieCtxKey struct{}
+
+func NewCookieStore(config interface{}) (st gcore.SessionStorage, err error) {
+	cfg := config.(CookieStoreConfig)
+	var keys []string
+	for _, k := range cfg.Keys {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		err = fmt.Errorf("cookie store keys is required")
+		return
+	}
+	if cfg.Name == "" {
+		cfg.Name = "gmcsdata"
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = 4000
+	}
+	if cfg.MaxChunks <= 0 {
+		cfg.MaxChunks = 1
+	}
+	st = &CookieStore{
+		cfg: cfg,
+		opt: &gcookie.Options{Keys: keys},
+	}
+	return
+}
+
+// WithCtx returns the storage to load and save the session of ctx, it is cached in ctx,
+// so the middlewares and the controller share the same session.
+func (s *CookieStore) WithCtx(ctx gcore.Ctx) gcore.SessionStorage {
+	if v, ok := ctx.Get(cookieCtxKey{}); ok {
+		if c, ok := v.(*cookieCtxStore); ok && c.CookieStore == s {
+			return c
+		}
+	}
+	c := &cookieCtxStore{CookieStore: s, ctx: ctx}
+	ctx.Set(cookieCtxKey{}, c)
+	return c
+}
+
+func (s *CookieStore) Load(sessionID string) (sess gcore.Session, isExists bool) {
+	return
+}
+
+func (s *CookieStore) Save(sess gcore.Session) (err error) {
+	return ErrNoCtx
+}
+
+func (s *CookieStore) Delete(sessionID string) (err error) {
+	return ErrNoCtx
+}
+
+func (s *CookieStore) encode(sess gcore.Session) (value string, err error) {
+	str, err := sess.Serialize()
+	if err != nil {
+		return
+	}
+	b, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return
+	}
+	value = s.opt.Encrypt(s.cfg.Name, string(b), 0)
+	if len(value) > s.cfg.ChunkSize*s.cfg.MaxChunks {
+		return "", ErrCookieTooLarge
+	}
+	return
+}
+
+func (s *CookieStore) decode(value string) (sess gcore.Session, err error) {
+	str, err := s.opt.Decrypt(s.cfg.Name, value)
+	if err != nil {
+		return
+	}
+	sess0 := NewSession()
+	if err = sess0.Unserialize(base64.StdEncoding.EncodeToString([]byte(str))); err != nil {
+		return
+	}
+	sess = sess0
+	return
+}
+
+func (s *CookieStore) chunkName(i int) string {
+	if i == 0 {
+		return s.cfg.Name
+	}
+	return s.cfg.Name + "_" + strconv.Itoa(i)
+}
+
+// cookieCtxStore is the cookie store bound to a ctx.
+type cookieCtxStore struct {
+	*CookieStore
+	ctx     gcore.Ctx
+	sess    gcore.Session
+	deleted bool
+	hooked  bool
+	written bool
+	values  map[interface{}]interface{}
+	err     error
+}
+
+func (c *cookieCtxStore) Load(sessionID string) (sess gcore.Session, isExists bool) {
+	if c.sess != nil && !c.deleted {
+		if c.sess.SessionID() != sessionID {
+			return
+		}
+		return c.sess, true
+	}
+	var a []string
+	for i := 0; i < c.cfg.MaxChunks; i++ {
+		ck, err := c.ctx.Request().Cookie(c.chunkName(i))
+		if err != nil {
+			break
+		}
+		a = append(a, ck.Value)
+	}
+	if len(a) == 0 {
+		return
+	}
+	sess, err := c.decode(strings.Join(a, ""))
+	if err != nil || sess.SessionID() != sessionID {
+		return nil, false
+	}
+	if time.Now().Unix()-sess.TouchTime() > c.cfg.TTL {
+		return nil, false
+	}
+	c.sess = sess
+	c.hook()
+	isExists = true
+	return
+}
+
+// Save writes the session to the cookies at once if the response header is not written, and the
+// cookies are written again before the header is written, so the changes after Save are kept.
+func (c *cookieCtxStore) Save(sess gcore.Session) (err error) {
+	c.sess = sess
+	c.deleted = false
+	if c.written {
+		if c.err != nil {
+			return c.err
+		}
+		if !reflect.DeepEqual(c.values, sess.Values()) {
+			return ErrHeaderWritten
+		}
+		return
+	}
+	c.hook()
+	return c.write()
+}
+
+func (c *cookieCtxStore) Delete(sessionID string) (err error) {
+	if c.sess != nil && c.sess.SessionID() != sessionID {
+		return
+	}
+	c.deleted = true
+	if c.written {
+		return
+	}
+	c.hook()
+	return c.write()
+}
+
+func (c *cookieCtxStore) hook() {
+	if c.hooked {
+		return
+	}
+	c.hooked = true
+	ghttputil.BeforeWriteHeader(c.ctx.Response(), func() {
+		c.err = c.write()
+		c.written = true
+	})
+}
+
+// write sets the cookies of the session, the cookies of the chunks not used are removed.
+func (c *cookieCtxStore) write() (err error) {
+	var chunks []string
+	if !c.deleted && c.sess != nil && !c.sess.IsDestroy() {
+		c.values = c.sess.Values()
+		var value string
+		value, err = c.encode(c.sess)
+		if err != nil {
+			return
+		}
+		for len(value) > c.cfg.ChunkSize {
+			chunks = append(chunks, value[:c.cfg.ChunkSize])
+			value = value[c.cfg.ChunkSize:]
+		}
+		chunks = append(chunks, value)
+	}
+	for i, v := range chunks {
+		c.setCookie(c.chunkName(i), v, int(c.cfg.TTL))
+	}
+	for i := len(chunks); i < c.cfg.MaxChunks; i++ {
+		name := c.chunkName(i)
+		if _, e := c.ctx.Request().Cookie(name); e != nil && !c.isSet(name) {
+			continue
+		}
+		c.setCookie(name, "", -1)
+	}
+	return
+}
+
+// isSet returns true if the cookie is set to the response.
+func (c *cookieCtxStore) isSet(name string) bool {
+	for _, v := range c.ctx.Response().Header()["Set-Cookie"] {
+		if strings.HasPrefix(v, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// setCookie sets the cookie and replaces the same name cookie set before.
+func (c *cookieCtxStore) setCookie(name, value string, maxAge int) {
+	h := c.ctx.Response().Header()
+	var lines []string
+	for _, v := range h["Set-Cookie"] {
+		if !strings.HasPrefix(v, name+"=") {
+			lines = append(lines, v)
+		}
+	}
+	if lines == nil {
+		h.Del("Set-Cookie")
+	} else {
+		h["Set-Cookie"] = lines
+	}
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     c.cfg.Path,
+		Domain:   c.cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   c.cfg.Secure,
+		HttpOnly: true,
+		SameSite: c.cfg.SameSite,
+	}
+	if maxAge > 0 {
+		cookie.Expires = time.Now().Add(time.Duration(maxAge) * time.Second).UTC()
+	} else if maxAge < 0 {
+		cookie.Expires = time.Unix(1, 0).UTC()
+	}
+	http.SetCookie(c.ctx.Response(), cookie)
+}
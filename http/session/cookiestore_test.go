@@ -0,0 +1,199 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gsession
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gctx "github.com/snail007/gmc/module/ctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCookieStore(t *testing.T, f ...func(cfg *CookieStoreConfig)) *CookieStore {
+	cfg := NewCookieStoreConfig()
+	cfg.Keys = []string{"secret"}
+	for _, fn := range f {
+		fn(&cfg)
+	}
+	st, err := NewCookieStore(cfg)
+	assert.Nil(t, err)
+	return st.(*CookieStore)
+}
+
+// cookieCtx creates a ctx of a request with the cookies of the response w.
+func cookieCtx(w *httptest.ResponseRecorder) (gcore.Ctx, *httptest.ResponseRecorder) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if w != nil {
+		for _, c := range w.Result().Cookies() {
+			if c.MaxAge >= 0 {
+				r.AddCookie(c)
+			}
+		}
+	}
+	w0 := httptest.NewRecorder()
+	return gctx.NewCtxWithHTTP(ghttputil.NewResponseWriter(w0), r), w0
+}
+
+func TestCookieStore(t *testing.T) {
+	assert := assert.New(t)
+	st := newCookieStore(t)
+	ctx, w := cookieCtx(nil)
+	s := st.WithCtx(ctx)
+	assert.Same(s, st.WithCtx(ctx))
+	sess := NewSession()
+	sess.Set("name", "foo")
+	assert.Nil(s.Save(sess))
+	ctx.Write("ok")
+	assert.Len(w.Result().Cookies(), 1)
+	assert.NotContains(w.Result().Cookies()[0].Value, "foo")
+
+	ctx, w = cookieCtx(w)
+	s = st.WithCtx(ctx)
+	_, ok := s.Load("none")
+	assert.False(ok)
+	sess0, ok := s.Load(sess.SessionID())
+	assert.True(ok)
+	assert.Equal("foo", sess0.Get("name"))
+
+	// the changes before the header written are saved.
+	sess0.Set("name", "bar")
+	ctx.Write("ok")
+	assert.Nil(s.Save(sess0))
+	sess0.Set("name", "baz")
+	assert.Equal(ErrHeaderWritten, s.Save(sess0))
+	ctx, _ = cookieCtx(w)
+	sess0, ok = st.WithCtx(ctx).Load(sess.SessionID())
+	assert.True(ok)
+	assert.Equal("bar", sess0.Get("name"))
+}
+
+func TestCookieStore_Chunks(t *testing.T) {
+	assert := assert.New(t)
+	st := newCookieStore(t, func(cfg *CookieStoreConfig) {
+		cfg.ChunkSize = 100
+		cfg.MaxChunks = 10
+	})
+	ctx, w := cookieCtx(nil)
+	sess := NewSession()
+	sess.Set("data", strings.Repeat("a", 300))
+	assert.Nil(st.WithCtx(ctx).Save(sess))
+	cookies := w.Result().Cookies()
+	assert.Greater(len(cookies), 3)
+	assert.Equal("gmcsdata", cookies[0].Name)
+	assert.Equal("gmcsdata_1", cookies[1].Name)
+	for _, c := range cookies {
+		assert.LessOrEqual(len(c.Value), 100)
+	}
+
+	// the chunks not used are removed.
+	ctx, w0 := cookieCtx(w)
+	s := st.WithCtx(ctx)
+	sess0, ok := s.Load(sess.SessionID())
+	assert.True(ok)
+	assert.Equal(strings.Repeat("a", 300), sess0.Get("data"))
+	sess0.Delete("data")
+	assert.Nil(s.Save(sess0))
+	removed := 0
+	for _, c := range w0.Result().Cookies() {
+		if c.MaxAge < 0 {
+			removed++
+		}
+	}
+	assert.Greater(removed, 0)
+	assert.Len(w0.Result().Cookies(), len(cookies))
+
+	sess.Set("data", strings.Repeat("a", 2000))
+	ctx, _ = cookieCtx(nil)
+	assert.Equal(ErrCookieTooLarge, st.WithCtx(ctx).Save(sess))
+}
+
+func TestCookieStore_Expired(t *testing.T) {
+	assert := assert.New(t)
+	st := newCookieStore(t, func(cfg *CookieStoreConfig) {
+		cfg.TTL = 1
+	})
+	ctx, w := cookieCtx(nil)
+	sess := NewSession()
+	sess.Set("name", "foo")
+	assert.Nil(st.WithCtx(ctx).Save(sess))
+	time.Sleep(time.Second * 2)
+	ctx, _ = cookieCtx(w)
+	_, ok := st.WithCtx(ctx).Load(sess.SessionID())
+	assert.False(ok)
+}
+
+func TestCookieStore_Delete(t *testing.T) {
+	assert := assert.New(t)
+	st := newCookieStore(t)
+	ctx, w := cookieCtx(nil)
+	sess := NewSession()
+	sess.Set("name", "foo")
+	assert.Nil(st.WithCtx(ctx).Save(sess))
+
+	ctx, w = cookieCtx(w)
+	s := st.WithCtx(ctx)
+	_, ok := s.Load(sess.SessionID())
+	assert.True(ok)
+	assert.Nil(s.Delete(sess.SessionID()))
+	c := w.Result().Cookies()
+	assert.Len(c, 1)
+	assert.Equal(-1, c[0].MaxAge)
+}
+
+func TestCookieStore_Keys(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewCookieStore(NewCookieStoreConfig())
+	assert.NotNil(err)
+
+	old := newCookieStore(t, func(cfg *CookieStoreConfig) {
+		cfg.Keys = []string{"old"}
+	})
+	ctx, w := cookieCtx(nil)
+	sess := NewSession()
+	sess.Set("name", "foo")
+	assert.Nil(old.WithCtx(ctx).Save(sess))
+
+	st := newCookieStore(t, func(cfg *CookieStoreConfig) {
+		cfg.Keys = []string{"new", "old"}
+	})
+	ctx, _ = cookieCtx(w)
+	_, ok := st.WithCtx(ctx).Load(sess.SessionID())
+	assert.True(ok)
+
+	st = newCookieStore(t, func(cfg *CookieStoreConfig) {
+		cfg.Keys = []string{"new"}
+	})
+	ctx, _ = cookieCtx(w)
+	_, ok = st.WithCtx(ctx).Load(sess.SessionID())
+	assert.False(ok)
+
+	assert.Equal(ErrNoCtx, st.Save(sess))
+}
+
+func TestInit_Cookie(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gcore.ProviderConfig()()
+	cfg.Set("session.enable", true)
+	cfg.Set("session.store", "cookie")
+	cfg.Set("session.ttl", 60)
+	_, err := Init(cfg)
+	assert.NotNil(err)
+	cfg.Set("cookie.keys", []string{"secret"})
+	cfg.Set("session.cookie.chunksize", 1000)
+	cfg.Set("session.cookie.same_site", "strict")
+	st, err := Init(cfg)
+	assert.Nil(err)
+	c := st.(*CookieStore).cfg
+	assert.Equal(1000, c.ChunkSize)
+	assert.Equal(http.SameSiteStrictMode, c.SameSite)
+	assert.Equal(int64(60), c.TTL)
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gsession
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gmap "github.com/snail007/gmc/util/map"
+)
+
+type DBStoreConfig struct {
+	// DB is the database to store the sessions, mysql, sqlite3 and postgres are supported,
+	// the sessions are read from the primary if DB has read replicas.
+	DB gcore.Database
+	// Table is the name of session table, it is created if not exists,
+	// the table prefix of DB is added.
+	Table  string
+	GCtime int //seconds
+	Logger gcore.Logger
+	TTL    int64 //seconds
+}
+
+func NewDBStoreConfig() DBStoreConfig {
+	return DBStoreConfig{
+		Table:  "gmc_sessions",
+		GCtime: 300,
+		TTL:    15 * 60,
+		Logger: gcore.ProviderLogger()(nil, "[dbstore]"),
+	}
+}
+
+// DBStore keeps the sessions in a table of database, the table has three columns:
+// id is the session id, data is the serialized session and touchtime is the last access time.
+type DBStore struct {
+	gcore.SessionStorage
+	cfg       DBStoreConfig
+	stop      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewDBStore(config interface{}) (st gcore.SessionStorage, err error) {
+	cfg := config.(DBStoreConfig)
+	if cfg.DB == nil {
+		err = fmt.Errorf("dbstore database is required")
+		return
+	}
+	if cfg.Table == "" {
+		cfg.Table = "gmc_sessions"
+	}
+	if cfg.GCtime <= 0 {
+		cfg.GCtime = 300
+	}
+	// the session saved must be loaded by the next request, so it is never read from a lagging replica.
+	if v, ok := cfg.DB.(interface{ PrimaryDatabase() gcore.Database }); ok {
+		cfg.DB = v.PrimaryDatabase()
+	}
+	s := &DBStore{
+		cfg:  cfg,
+		stop: make(chan struct{}),
+	}
+	err = s.createTable()
+	if err != nil {
+		return
+	}
+	s.wg.Add(1)
+	go s.gc()
+	st = s
+	return
+}
+
+func (s *DBStore) createTable() (err error) {
+	table := s.cfg.DB.AR().Wrap(s.cfg.Table)
+	_, err = s.cfg.DB.ExecSQL(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id VARCHAR(64) NOT NULL PRIMARY KEY, "+
+		"data TEXT NOT NULL, touchtime BIGINT NOT NULL)", table))
+	if err != nil {
+		return fmt.Errorf("dbstore create table fail, error: %s", err)
+	}
+	// the syntax of creating index if not exists is different between the databases,
+	// so the error of existing index is ignored.
+	s.cfg.DB.ExecSQL(fmt.Sprintf("CREATE INDEX %s_touchtime ON %s (touchtime)", s.cfg.Table, table))
+	return
+}
+
+func (s *DBStore) Load(sessionID string) (sess gcore.Session, isExists bool) {
+	rs, err := s.cfg.DB.Query(s.cfg.DB.AR().From(s.cfg.Table).Where(gmap.M{"id": sessionID}).Limit(1))
+	if err != nil {
+		s.cfg.Logger.Warnf("dbstore load error: %s", err)
+		return
+	}
+	row := rs.Row()
+	if len(row) == 0 {
+		return
+	}
+	sess = NewSession()
+	err = sess.Unserialize(row["data"])
+	if err != nil {
+		sess = nil
+		s.cfg.Logger.Warnf("dbstore unserialize error: %s", err)
+		return
+	}
+	if time.Now().Unix()-sess.TouchTime() > s.cfg.TTL {
+		sess = nil
+		s.Delete(sessionID)
+		return
+	}
+	isExists = true
+	return
+}
+
+// Save inserts the session, or updates it if the session id exists.
+func (s *DBStore) Save(sess gcore.Session) (err error) {
+	str, err := sess.Serialize()
+	if err != nil {
+		return
+	}
+	_, err = s.cfg.DB.Exec(s.replace(gmap.M{
+		"id":        sess.SessionID(),
+		"data":      str,
+		"touchtime": sess.TouchTime(),
+	}))
+	return
+}
+
+// replace returns the ActiveRecord of replacing the row, the conflict column of postgres is
+// set to id explicitly, so it does not depend on the primary key of database config.
+func (s *DBStore) replace(data gmap.M) gcore.ActiveRecord {
+	ar := s.cfg.DB.AR()
+	if v, ok := ar.(interface {
+		OnConflict(columns ...string) gcore.ActiveRecord
+	}); ok {
+		ar = v.OnConflict("id")
+	}
+	return ar.Replace(s.cfg.Table, data)
+}
+
+func (s *DBStore) Delete(sessionID string) (err error) {
+	_, err = s.cfg.DB.Exec(s.cfg.DB.AR().Delete(s.cfg.Table, gmap.M{"id": sessionID}))
+	return
+}
+
+// Close stops the gc goroutine of the store and waits for it to exit, the database is not closed.
+func (s *DBStore) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	s.wg.Wait()
+}
+
+func (s *DBStore) gc() {
+	defer s.wg.Done()
+	defer gcore.ProviderError()().Recover(func(e interface{}) {
+		fmt.Printf("dbstore gc error: %s", gcore.ProviderError()().StackError(e))
+	})
+	for {
+		_, err := s.cfg.DB.Exec(s.cfg.DB.AR().Delete(s.cfg.Table, gmap.M{
+			"touchtime <": time.Now().Unix() - s.cfg.TTL,
+		}))
+		if err != nil {
+			s.cfg.Logger.Warnf("dbstore gc error: %s", err)
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(time.Second * time.Duration(s.cfg.GCtime)):
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gsession
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gdb "github.com/snail007/gmc/module/db"
+	gmap "github.com/snail007/gmc/util/map"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSQLite3(t *testing.T) gcore.Database {
+	cfg := gdb.NewSQLite3DBConfigWith(filepath.Join(t.TempDir(), "session.db"),
+		gdb.OpenModeReadWriteCreate, gdb.CacheModeShared, gdb.SyncModeOff)
+	cfg.TablePrefix = "app_"
+	db, err := gdb.NewSQLite3DB(cfg)
+	assert.Nil(t, err)
+	return &db
+}
+
+func TestDBStore(t *testing.T) {
+	assert := assert.New(t)
+	db := newSQLite3(t)
+	cfg := NewDBStoreConfig()
+	cfg.DB = db
+	st, err := NewDBStore(cfg)
+	assert.Nil(err)
+	defer st.(*DBStore).Close()
+	// creating the table again is ok.
+	st0, err := NewDBStore(cfg)
+	assert.Nil(err)
+	st0.(*DBStore).Close()
+
+	_, ok := st.Load("none")
+	assert.False(ok)
+	sess := NewSession()
+	sess.Set("name", "foo")
+	assert.Nil(st.Save(sess))
+	sess.Set("name", "bar")
+	assert.Nil(st.Save(sess))
+	rs, err := db.Query(db.AR().From("gmc_sessions"))
+	assert.Nil(err)
+	assert.Equal(1, rs.Len())
+	sess0, ok := st.Load(sess.SessionID())
+	assert.True(ok)
+	assert.Equal("bar", sess0.Get("name"))
+
+	assert.Nil(st.Delete(sess.SessionID()))
+	_, ok = st.Load(sess.SessionID())
+	assert.False(ok)
+}
+
+func TestDBStore_Expired(t *testing.T) {
+	assert := assert.New(t)
+	db := newSQLite3(t)
+	cfg := NewDBStoreConfig()
+	cfg.DB = db
+	cfg.TTL = 1
+	cfg.GCtime = 1
+	st, err := NewDBStore(cfg)
+	assert.Nil(err)
+	defer st.(*DBStore).Close()
+	sess := NewSession()
+	sess.Touch()
+	assert.Nil(st.Save(sess))
+	sess0 := NewSession()
+	sess0.Touch()
+	assert.Nil(st.Save(sess0))
+	time.Sleep(time.Second * 2)
+	_, ok := st.Load(sess.SessionID())
+	assert.False(ok)
+	time.Sleep(time.Second * 2)
+	rs, err := db.Query(db.AR().From("gmc_sessions").Where(gmap.M{"id": sess0.SessionID()}))
+	assert.Nil(err)
+	assert.Equal(0, rs.Len())
+}
+
+// laggingDB reads from a replica which never receives the writes.
+type laggingDB struct {
+	gcore.Database
+	replica gcore.Database
+}
+
+func (db *laggingDB) Query(ar gcore.ActiveRecord) (gcore.ResultSet, error) {
+	return db.replica.Query(ar)
+}
+
+func (db *laggingDB) QuerySQL(sqlStr string, values ...interface{}) (gcore.ResultSet, error) {
+	return db.replica.QuerySQL(sqlStr, values...)
+}
+
+func (db *laggingDB) PrimaryDatabase() gcore.Database {
+	return db.Database
+}
+
+func TestDBStore_LaggingReplica(t *testing.T) {
+	assert := assert.New(t)
+	// the mysql db with replicas forces the primary by PrimaryDatabase.
+	var _ interface{ PrimaryDatabase() gcore.Database } = &gdb.MySQLDB{}
+	replica := newSQLite3(t)
+	db := &laggingDB{Database: newSQLite3(t), replica: replica}
+	cfg := NewDBStoreConfig()
+	cfg.DB = replica
+	st, err := NewDBStore(cfg)
+	assert.Nil(err)
+	st.(*DBStore).Close()
+
+	cfg.DB = db
+	st, err = NewDBStore(cfg)
+	assert.Nil(err)
+	defer st.(*DBStore).Close()
+	sess := NewSession()
+	sess.Set("name", "foo")
+	assert.Nil(st.Save(sess))
+	sess0, ok := st.Load(sess.SessionID())
+	assert.True(ok)
+	assert.Equal("foo", sess0.Get("name"))
+}
+
+func TestDBStore_Postgres(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gdb.NewPostgresDBConfig()
+	cfg.PrimaryKey = "pk"
+	s := &DBStore{cfg: DBStoreConfig{DB: &gdb.PostgresDB{Config: cfg}, Table: "gmc_sessions"}}
+	sql := s.replace(gmap.M{"id": "a", "data": "b", "touchtime": 1}).SQL()
+	assert.Contains(sql, `ON CONFLICT ("id") DO UPDATE`)
+}
+
+func TestDBStore_Close(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewDBStoreConfig()
+	cfg.DB = newSQLite3(t)
+	cfg.GCtime = 1
+	st, err := NewDBStore(cfg)
+	assert.Nil(err)
+	s := st.(*DBStore)
+	s.Close()
+	s.Close()
+	select {
+	case <-s.stop:
+	default:
+		assert.Fail("store is not closed")
+	}
+}
+
+func TestDBStore_NoDB(t *testing.T) {
+	_, err := NewDBStore(NewDBStoreConfig())
+	assert.NotNil(t, err)
+}
+
+func TestInit_DB(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gcore.ProviderConfig()()
+	cfg.Set("session.enable", true)
+	cfg.Set("session.store", "db")
+	cfg.Set("session.ttl", 60)
+	cfg.Set("session.db.id", "session")
+	cfg.Set("database.default", "sqlite3")
+	cfg.Set("database.sqlite3", []interface{}{map[string]interface{}{
+		"enable":    true,
+		"id":        "session",
+		"database":  filepath.Join(t.TempDir(), "session.db"),
+		"openmode":  gdb.OpenModeReadWriteCreate,
+		"cachemode": gdb.CacheModeShared,
+	}})
+	st, err := Init(cfg)
+	assert.Nil(err)
+	defer st.(*DBStore).Close()
+	assert.Equal(int64(60), st.(*DBStore).cfg.TTL)
+	sess := NewSession()
+	sess.Set("name", "foo")
+	assert.Nil(st.Save(sess))
+	sess0, ok := st.Load(sess.SessionID())
+	assert.True(ok)
+	assert.Equal("foo", sess0.Get("name"))
+
+	cfg.Set("session.db.id", "none")
+	_, err = Init(cfg)
+	assert.NotNil(err)
+}
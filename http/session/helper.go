@@ -8,6 +8,7 @@ package gsession
 import (
 	"fmt"
 	gcore "github.com/snail007/gmc/core"
+	gcookie "github.com/snail007/gmc/http/cookie"
 	"time"
 )
 
@@ -47,8 +48,71 @@ func Init(config gcore.Config) (sessionStore gcore.SessionStorage, err error) {
 		cfg.RedisCfg.Wait = config.GetBool("session.redis.wait")
 		cfg.TTL = ttl
 		sessionStore, err = NewRedisStore(cfg)
+	case "cookie":
+		cfg := NewCookieStoreConfig()
+		cfg.TTL = ttl
+		cfg.Keys = config.GetStringSlice("session.cookie.keys")
+		if len(cfg.Keys) == 0 {
+			cfg.Keys = config.GetStringSlice("cookie.keys")
+		}
+		if config.IsSet("session.cookie.name") {
+			cfg.Name = config.GetString("session.cookie.name")
+		}
+		if config.IsSet("session.cookie.chunksize") {
+			cfg.ChunkSize = config.GetInt("session.cookie.chunksize")
+		}
+		if config.IsSet("session.cookie.maxchunks") {
+			cfg.MaxChunks = config.GetInt("session.cookie.maxchunks")
+		}
+		if config.IsSet("session.cookie.path") {
+			cfg.Path = config.GetString("session.cookie.path")
+		}
+		cfg.Domain = config.GetString("session.cookie.domain")
+		cfg.Secure = config.GetBool("session.cookie.secure")
+		if config.IsSet("session.cookie.same_site") {
+			cfg.SameSite = gcookie.ParseSameSite(config.GetString("session.cookie.same_site"))
+		}
+		sessionStore, err = NewCookieStore(cfg)
+	case "db":
+		cfg := NewDBStoreConfig()
+		cfg.TTL = ttl
+		if config.IsSet("session.db.table") {
+			cfg.Table = config.GetString("session.db.table")
+		}
+		cfg.GCtime = config.GetInt("session.db.gctime")
+		cfg.DB, err = database(config, config.GetString("session.db.id"))
+		if err != nil {
+			return
+		}
+		sessionStore, err = NewDBStore(cfg)
 	default:
 		err = fmt.Errorf("unknown session store type %s", typ)
 	}
 	return
 }
+
+// database returns the database of id in [database] of config, empty id means the default database.
+func database(config gcore.Config, id string) (db gcore.Database, err error) {
+	p := gcore.ProviderDatabaseGroup()
+	if p == nil {
+		return nil, fmt.Errorf("database provider not found")
+	}
+	ctx := gcore.ProviderCtx()()
+	ctx.SetConfig(config)
+	group, err := p(ctx)
+	if err != nil {
+		return
+	}
+	if id == "" {
+		db = group.DB()
+	} else {
+		db = group.DB(id)
+	}
+	if db == nil {
+		if id == "" {
+			id = "default"
+		}
+		err = fmt.Errorf("database [%s] not found", id)
+	}
+	return
+}
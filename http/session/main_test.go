@@ -10,6 +10,8 @@ import (
 	gcore "github.com/snail007/gmc/core"
 	gcache "github.com/snail007/gmc/module/cache"
 	gconfig "github.com/snail007/gmc/module/config"
+	gctx "github.com/snail007/gmc/module/ctx"
+	gdb "github.com/snail007/gmc/module/db"
 	gerror "github.com/snail007/gmc/module/error"
 	glog "github.com/snail007/gmc/module/log"
 	"github.com/snail007/gmc/util/sync/once"
@@ -48,6 +50,18 @@ func TestMain(m *testing.M) {
 		return gcache.Cache(), nil
 	})
 
+	gcore.RegisterDatabaseGroup(gcore.DefaultProviderKey, func(ctx gcore.Ctx) (gcore.DatabaseGroup, error) {
+		err := gdb.Init(ctx.Config())
+		if err != nil {
+			return nil, err
+		}
+		return gdb.Group(), nil
+	})
+
+	gcore.RegisterCtx(gcore.DefaultProviderKey, func() gcore.Ctx {
+		return gctx.NewCtx()
+	})
+
 	gcore.RegisterError(gcore.DefaultProviderKey, func() gcore.Error {
 		return gerror.New()
 	})
@@ -0,0 +1,58 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gsession
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// Fingerprint returns the fingerprint of the client of ctx, it is built by the options of
+// section [session] in config: bind_ua binds the user agent, bind_ip and bind_ip6 are the
+// prefix bits of IPv4 and IPv6 address to bind. Empty is returned if no binding is enabled.
+func Fingerprint(ctx gcore.Ctx) string {
+	cfg := ctx.Config()
+	var parts []string
+	if cfg.GetBool("session.bind_ua") {
+		parts = append(parts, "ua:"+ctx.Request().UserAgent())
+	}
+	if ip := net.ParseIP(ctx.ClientIP()); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			if bits := cfg.GetInt("session.bind_ip"); bits > 0 {
+				parts = append(parts, "ip:"+ip4.Mask(net.CIDRMask(minInt(bits, 32), 32)).String())
+			}
+		} else if bits := cfg.GetInt("session.bind_ip6"); bits > 0 {
+			parts = append(parts, "ip:"+ip.Mask(net.CIDRMask(minInt(bits, 128), 128)).String())
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// IsValid checks the session is not exceeded the absolute lifetime of section [session] in config,
+// and the session is bound to the client of ctx.
+func IsValid(ctx gcore.Ctx, sess gcore.Session) bool {
+	lifetime := ctx.Config().GetInt64("session.lifetime")
+	if lifetime > 0 && time.Now().Unix()-sess.CreateTime() > lifetime {
+		return false
+	}
+	return sess.Fingerprint() == Fingerprint(ctx)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gsession
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gctx "github.com/snail007/gmc/module/ctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func securityCtx(cfg gcore.Config, ip, ua string) gcore.Ctx {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = ip + ":1234"
+	r.Header.Set("User-Agent", ua)
+	ctx := gctx.NewCtxWithHTTP(httptest.NewRecorder(), r)
+	ctx.SetConfig(cfg)
+	return ctx
+}
+
+func TestFingerprint(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gcore.ProviderConfig()()
+	assert.Empty(Fingerprint(securityCtx(cfg, "1.2.3.4", "a")))
+
+	cfg.Set("session.bind_ua", true)
+	cfg.Set("session.bind_ip", 24)
+	cfg.Set("session.bind_ip6", 64)
+	fp := Fingerprint(securityCtx(cfg, "1.2.3.4", "a"))
+	assert.Len(fp, 32)
+	assert.Equal(fp, Fingerprint(securityCtx(cfg, "1.2.3.100", "a")))
+	assert.NotEqual(fp, Fingerprint(securityCtx(cfg, "1.2.4.4", "a")))
+	assert.NotEqual(fp, Fingerprint(securityCtx(cfg, "1.2.3.4", "b")))
+
+	fp6 := Fingerprint(securityCtx(cfg, "[2001:db8::1]", "a"))
+	assert.Equal(fp6, Fingerprint(securityCtx(cfg, "[2001:db8::2]", "a")))
+	assert.NotEqual(fp6, Fingerprint(securityCtx(cfg, "[2001:db9::1]", "a")))
+}
+
+func TestIsValid(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gcore.ProviderConfig()()
+	cfg.Set("session.bind_ip", 32)
+	ctx := securityCtx(cfg, "1.2.3.4", "a")
+	sess := NewSession()
+	assert.False(IsValid(ctx, sess))
+	sess.SetFingerprint(Fingerprint(ctx))
+	assert.True(IsValid(ctx, sess))
+	assert.False(IsValid(securityCtx(cfg, "1.2.3.5", "a"), sess))
+
+	cfg.Set("session.lifetime", 60)
+	assert.True(IsValid(ctx, sess))
+	sess.createtime = time.Now().Unix() - 61
+	assert.False(IsValid(ctx, sess))
+}
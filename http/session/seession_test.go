@@ -69,3 +69,53 @@ func TestUnserialize(t *testing.T) {
 	assert.Equal(sess.Get("a").(string), "b")
 	assert.Equal(sess2.Get("a"), sess.Get("a"))
 }
+
+func TestSession_Regenerate(t *testing.T) {
+	assert := assert.New(t)
+	sess := NewSession()
+	sess.Set("a", "b")
+	sess.SetFingerprint("fp")
+	id := sess.SessionID()
+	sess.createtime = 1
+	sess.Regenerate()
+	assert.NotEqual(id, sess.SessionID())
+	assert.Len(sess.SessionID(), 32)
+	assert.Greater(sess.CreateTime(), int64(1))
+	assert.Equal("b", sess.Get("a"))
+	assert.Equal("fp", sess.Fingerprint())
+}
+
+func TestSession_Flash(t *testing.T) {
+	assert := assert.New(t)
+	sess := NewSession()
+	assert.Empty(sess.Flashes())
+	sess.SetFlash("msg", "saved")
+	sess.SetFingerprint("fp")
+	str, err := sess.Serialize()
+	assert.Nil(err)
+	sess2 := NewSession()
+	assert.Nil(sess2.Unserialize(str))
+	assert.Equal(sess.CreateTime(), sess2.CreateTime())
+	assert.Equal("fp", sess2.Fingerprint())
+	assert.Equal(map[string]interface{}{"msg": "saved"}, sess2.Flashes())
+	assert.Empty(sess2.Flashes())
+	assert.Empty(sess2.Values())
+}
+
+func TestSession_Concurrent(t *testing.T) {
+	sess := NewSession()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			sess.Regenerate()
+			sess.SetFingerprint("fp")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		sess.CreateTime()
+		sess.Fingerprint()
+		sess.TouchTime()
+	}
+	<-done
+}
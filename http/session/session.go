@@ -18,17 +18,23 @@ import (
 )
 
 type sData struct {
-	ID        string
-	Values    map[interface{}]interface{}
-	Touchtime int64
+	ID          string
+	Values      map[interface{}]interface{}
+	Touchtime   int64
+	Createtime  int64
+	Fingerprint string
+	Flash       map[string]interface{}
 }
 
 type Session struct {
-	id        string
-	values    map[interface{}]interface{}
-	lock      *sync.Mutex
-	isDestroy bool
-	touchtime int64
+	id          string
+	values      map[interface{}]interface{}
+	lock        *sync.RWMutex
+	isDestroy   bool
+	touchtime   int64
+	createtime  int64
+	fingerprint string
+	flash       map[string]interface{}
 }
 
 func init() {
@@ -45,9 +51,10 @@ func init() {
 
 func NewSession() *Session {
 	s := &Session{
-		id:     newSessionID(),
-		lock:   &sync.Mutex{},
-		values: map[interface{}]interface{}{},
+		id:         newSessionID(),
+		lock:       &sync.RWMutex{},
+		values:     map[interface{}]interface{}{},
+		createtime: time.Now().Unix(),
 	}
 	return s
 }
@@ -94,6 +101,7 @@ func (s *Session) Destroy() (err error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	s.values = map[interface{}]interface{}{}
+	s.flash = nil
 	s.isDestroy = true
 	s.touch()
 	return
@@ -120,6 +128,8 @@ func (s *Session) SessionID() (sessionID string) {
 
 // TouchTime return the last access unix time seconds of session.
 func (s *Session) TouchTime() (time int64) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	return s.touchtime
 }
 func (s *Session) Touch() {
@@ -130,6 +140,70 @@ func (s *Session) Touch() {
 	defer s.lock.Unlock()
 	s.touch()
 }
+
+// Regenerate changes the session id and resets the create time, the values are kept,
+// it should be called after login to prevent session fixation.
+func (s *Session) Regenerate() {
+	if s.isDestroy {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.id = newSessionID()
+	s.createtime = time.Now().Unix()
+	s.touch()
+}
+
+// CreateTime returns the unix time seconds the session is created or regenerated.
+func (s *Session) CreateTime() (time int64) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.createtime
+}
+
+// Fingerprint returns the fingerprint of the client the session bound to.
+func (s *Session) Fingerprint() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.fingerprint
+}
+
+func (s *Session) SetFingerprint(fingerprint string) {
+	if s.isDestroy {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.fingerprint = fingerprint
+}
+
+// SetFlash sets a flash value, it can be read once by Flashes.
+func (s *Session) SetFlash(k string, v interface{}) {
+	if s.isDestroy {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.flash == nil {
+		s.flash = map[string]interface{}{}
+	}
+	s.flash[k] = v
+	gob.Register(v)
+	s.touch()
+}
+
+// Flashes returns the flash values and removes them from the session.
+func (s *Session) Flashes() (data map[string]interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data = s.flash
+	s.flash = nil
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	return
+}
+
 func (s *Session) touch() {
 	s.touchtime = time.Now().Unix()
 	return
@@ -149,9 +223,12 @@ func (s *Session) Serialize() (str string, err error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	item := sData{
-		ID:        s.id,
-		Values:    s.values,
-		Touchtime: s.touchtime,
+		ID:          s.id,
+		Values:      s.values,
+		Touchtime:   s.touchtime,
+		Createtime:  s.createtime,
+		Fingerprint: s.fingerprint,
+		Flash:       s.flash,
 	}
 	var buf bytes.Buffer
 	enc := gob.NewEncoder(&buf)
@@ -183,5 +260,12 @@ func (s *Session) Unserialize(data string) (err error) {
 	s.touchtime = q.Touchtime
 	s.values = q.Values
 	s.id = q.ID
+	s.createtime = q.Createtime
+	if s.createtime == 0 {
+		// the session saved by old version
+		s.createtime = q.Touchtime
+	}
+	s.fingerprint = q.Fingerprint
+	s.flash = q.Flash
 	return
 }
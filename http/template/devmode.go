@@ -0,0 +1,233 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gtemplate
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	gotemplate "text/template"
+	"time"
+)
+
+// errorContextLines is the count of the source lines shown before and after the error line.
+const errorContextLines = 5
+
+var errorPosRe = regexp.MustCompile(`template: ([^:]+):(\d+):(?:(\d+):)?`)
+
+// Error is the error of parsing or executing a view in dev mode, it has the file and line context.
+type Error struct {
+	Err    error
+	File   string // path of the view file relative to the views folder
+	Line   int
+	Column int
+	Lines  []ErrorLine // the source lines around Line
+}
+
+type ErrorLine struct {
+	Num     int
+	Text    string
+	Current bool
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// SetDevMode sets the dev mode, it should be called before Parse. In dev mode the views folder is checked
+// when executing a template, the changed files are parsed again, the errors are *Error with the source
+// context, and ErrorPage renders them. It is ignored when the views are loaded from bin data.
+func (s *Template) SetDevMode(dev bool) {
+	s.dev = dev
+}
+
+func (s *Template) DevMode() bool {
+	return s.dev
+}
+
+// ErrorPage returns a html page of the error err with the file and line context,
+// ok is false if the template is not in dev mode.
+func (s *Template) ErrorPage(err error) (page []byte, ok bool) {
+	if !s.dev || err == nil {
+		return
+	}
+	e, isErr := err.(*Error)
+	if !isErr {
+		e = s.newError(err)
+	}
+	buf := &bytes.Buffer{}
+	if errorPageTpl.Execute(buf, e) != nil {
+		return
+	}
+	return buf.Bytes(), true
+}
+
+func (s *Template) file(name string) string {
+	return filepath.Join(s.rootDir, name+s.ext)
+}
+
+// parseDev parses all view files, every file is parsed in a template named as the file with extension,
+// so the file and line are reported by the errors of text/template.
+func (s *Template) parseDev() (err error) {
+	s.base, err = s.tpl.Clone()
+	if err != nil {
+		return
+	}
+	s.mtimes = map[string]time.Time{}
+	return s.reload()
+}
+
+// reload parses the files changed since last parsing, all files are parsed again if a file is deleted
+// or the last parsing failed.
+func (s *Template) reload() (err error) {
+	s.devLock.Lock()
+	defer s.devLock.Unlock()
+	names := []string{}
+	err = s.tree(s.rootDir, &names)
+	if err != nil {
+		return
+	}
+	mtimes := map[string]time.Time{}
+	changed := []string{}
+	kept := 0
+	for _, v := range names {
+		fi, e := os.Stat(s.file(v))
+		if e != nil {
+			return e
+		}
+		mtimes[v] = fi.ModTime()
+		t, ok := s.mtimes[v]
+		if ok {
+			kept++
+		}
+		if !ok || !t.Equal(fi.ModTime()) {
+			changed = append(changed, v)
+		}
+	}
+	deleted := kept < len(s.mtimes)
+	if !deleted && len(changed) == 0 {
+		return s.devErr
+	}
+	full := deleted || s.devErr != nil
+	base := s.tpl
+	if full {
+		base, changed = s.base, names
+	}
+	tpl, err := base.Clone()
+	if err != nil {
+		return
+	}
+	s.mtimes = mtimes
+	for _, v := range changed {
+		if err = s.parseFile(tpl, v); err != nil {
+			s.devErr = s.newError(err)
+			return s.devErr
+		}
+	}
+	s.tpl, s.devErr = tpl, nil
+	return
+}
+
+func (s *Template) parseFile(tpl *gotemplate.Template, name string) (err error) {
+	b, err := ioutil.ReadFile(s.file(name))
+	if err != nil {
+		return
+	}
+	t, err := tpl.New(name + s.ext).Parse(string(b))
+	if err != nil {
+		return
+	}
+	if t.Tree != nil {
+		_, err = tpl.AddParseTree(name, t.Tree)
+	}
+	return
+}
+
+func (s *Template) executeDev(name string, data interface{}) (output []byte, err error) {
+	if err = s.reload(); err != nil {
+		return
+	}
+	s.devLock.RLock()
+	tpl := s.tpl
+	s.devLock.RUnlock()
+	buf := &bytes.Buffer{}
+	if err = tpl.ExecuteTemplate(buf, name, data); err != nil {
+		return nil, s.newError(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// newError parses the file, line and column from the message of err, and reads the source lines.
+func (s *Template) newError(err error) *Error {
+	e := &Error{}
+	if errors.As(err, &e) {
+		return e
+	}
+	e = &Error{Err: err}
+	m := errorPosRe.FindStringSubmatch(err.Error())
+	if m == nil || !strings.HasSuffix(m[1], s.ext) {
+		return e
+	}
+	e.File = m[1]
+	e.Line, _ = strconv.Atoi(m[2])
+	e.Column, _ = strconv.Atoi(m[3])
+	b, rErr := ioutil.ReadFile(s.file(strings.TrimSuffix(e.File, s.ext)))
+	if rErr != nil {
+		return e
+	}
+	lines := strings.Split(strings.Replace(string(b), "\r\n", "\n", -1), "\n")
+	for i := e.Line - errorContextLines; i <= e.Line+errorContextLines; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		e.Lines = append(e.Lines, ErrorLine{Num: i, Text: lines[i-1], Current: i == e.Line})
+	}
+	return e
+}
+
+var errorPageTpl = htmltemplate.Must(htmltemplate.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Template Error</title>
+<style>
+body{margin:0;font-family:-apple-system,Helvetica,Arial,sans-serif;background:#f6f6f6;color:#333}
+.head{background:#c0392b;color:#fff;padding:20px 30px}
+.head h1{margin:0 0 10px;font-size:22px}
+.head p{margin:0;font-family:Menlo,Consolas,monospace;white-space:pre-wrap;word-break:break-all}
+.body{padding:20px 30px}
+.file{font-family:Menlo,Consolas,monospace;margin-bottom:10px}
+table{width:100%;border-collapse:collapse;background:#fff;font-family:Menlo,Consolas,monospace;font-size:13px}
+td{padding:2px 10px;white-space:pre}
+td.num{width:1%;text-align:right;color:#999;border-right:1px solid #eee}
+tr.current{background:#fdecea}
+tr.current td.num{color:#c0392b;font-weight:bold}
+</style>
+</head>
+<body>
+<div class="head">
+<h1>Template Error</h1>
+<p>{{.Err.Error}}</p>
+</div>
+<div class="body">
+{{if .File}}<div class="file">{{.File}}:{{.Line}}{{if .Column}}:{{.Column}}{{end}}</div>{{end}}
+{{if .Lines}}<table>
+{{range .Lines}}<tr{{if .Current}} class="current"{{end}}><td class="num">{{.Num}}</td><td>{{.Text}}</td></tr>
+{{end}}</table>{{end}}
+</div>
+</body>
+</html>
+`))
@@ -0,0 +1,145 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gtemplate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+var viewSeq int
+
+func writeView(t *testing.T, dir, name, content string) {
+	file := filepath.Join(dir, name)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0755))
+	assert.Nil(t, os.WriteFile(file, []byte(content), 0644))
+	// make sure the modification time changed
+	viewSeq++
+	mt := time.Now().Add(time.Duration(viewSeq) * time.Second)
+	assert.Nil(t, os.Chtimes(file, mt, mt))
+}
+
+func newDevTpl(t *testing.T, dev bool) (*Template, string) {
+	dir := t.TempDir()
+	writeView(t, dir, "layout/page.html", `[{{template "common/head" .}}]{{.GMC_LAYOUT_CONTENT}}`)
+	writeView(t, dir, "common/head.html", `head`)
+	writeView(t, dir, "user/list.html", "line1\n{{.name}}")
+	ctx := gcore.ProviderCtx()()
+	ctx.SetConfig(gcore.ProviderConfig()())
+	tpl, err := NewTemplate(ctx, dir)
+	assert.Nil(t, err)
+	tpl.DisableLoadDefaultBinData()
+	tpl.DdisableLogging()
+	tpl.SetDevMode(dev)
+	assert.Nil(t, tpl.Parse())
+	return tpl, dir
+}
+
+func TestDevMode_Reload(t *testing.T) {
+	assert := assert.New(t)
+	tpl, dir := newDevTpl(t, true)
+	d, err := tpl.Execute("user/list", map[string]string{"name": "foo"})
+	assert.Nil(err)
+	assert.Equal("line1\nfoo", string(d))
+	d, err = tpl.Execute("layout/page.html", map[string]string{"GMC_LAYOUT_CONTENT": "c"})
+	assert.Nil(err)
+	assert.Equal("[head]c", string(d))
+
+	writeView(t, dir, "user/list.html", "changed {{.name}}")
+	d, err = tpl.Execute("user/list.html", map[string]string{"name": "foo"})
+	assert.Nil(err)
+	assert.Equal("changed foo", string(d))
+
+	// the layout uses the changed included file.
+	writeView(t, dir, "common/head.html", `head1`)
+	d, err = tpl.Execute("layout/page", map[string]string{"GMC_LAYOUT_CONTENT": "c"})
+	assert.Nil(err)
+	assert.Equal("[head1]c", string(d))
+
+	writeView(t, dir, "user/new.html", "new")
+	d, err = tpl.Execute("user/new", nil)
+	assert.Nil(err)
+	assert.Equal("new", string(d))
+
+	assert.Nil(os.Remove(filepath.Join(dir, "user/new.html")))
+	_, err = tpl.Execute("user/new", nil)
+	assert.NotNil(err)
+}
+
+func TestDevMode_Error(t *testing.T) {
+	assert := assert.New(t)
+	tpl, dir := newDevTpl(t, true)
+
+	writeView(t, dir, "user/list.html", "line1\nline2\n{{.name | nofunc}}\nline4")
+	_, err := tpl.Execute("user/list", nil)
+	e, ok := err.(*Error)
+	assert.True(ok)
+	assert.Equal("user/list.html", e.File)
+	assert.Equal(3, e.Line)
+	assert.Len(e.Lines, 4)
+	assert.True(e.Lines[2].Current)
+	page, ok := tpl.ErrorPage(err)
+	assert.True(ok)
+	assert.Contains(string(page), "user/list.html:3")
+	assert.Contains(string(page), "{{.name | nofunc}}")
+
+	// the other views are not affected by the error.
+	writeView(t, dir, "user/list.html", "{{.name.x}}")
+	d, err := tpl.Execute("common/head", nil)
+	assert.Nil(err)
+	assert.Equal("head", string(d))
+
+	_, err = tpl.Execute("user/list", map[string]string{"name": "foo"})
+	e, ok = err.(*Error)
+	assert.True(ok)
+	assert.Equal("user/list.html", e.File)
+	assert.Equal(1, e.Line)
+	assert.Equal(7, e.Column)
+}
+
+func TestDevMode_Disabled(t *testing.T) {
+	assert := assert.New(t)
+	tpl, dir := newDevTpl(t, false)
+	writeView(t, dir, "user/list.html", "changed")
+	d, err := tpl.Execute("user/list", map[string]string{"name": "foo"})
+	assert.Nil(err)
+	assert.Equal("line1\nfoo", string(d))
+	_, err = tpl.Execute("none", nil)
+	_, ok := err.(*Error)
+	assert.False(ok)
+	_, ok = tpl.ErrorPage(err)
+	assert.False(ok)
+
+	tpl = New()
+	tpl.SetCtx(gcore.ProviderCtx()())
+	tpl.DdisableLogging()
+	tpl.DisableLoadDefaultBinData()
+	tpl.SetDevMode(true)
+	tpl.SetBinString(map[string]string{"bin": "bin"})
+	assert.Nil(tpl.Parse())
+	assert.False(tpl.DevMode())
+	d, err = tpl.Execute("bin", nil)
+	assert.Nil(err)
+	assert.Equal("bin", strings.TrimSpace(string(d)))
+}
+
+func TestInit_DevMode(t *testing.T) {
+	ctx := gcore.ProviderCtx()()
+	cfg := gcore.ProviderConfig()()
+	cfg.Set("template.dir", "tests/views")
+	cfg.Set("template.ext", ".html")
+	cfg.Set("template.dev", true)
+	ctx.SetConfig(cfg)
+	tpl, err := Init(ctx)
+	assert.Nil(t, err)
+	assert.True(t, tpl.(*Template).DevMode())
+}
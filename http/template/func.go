@@ -9,6 +9,7 @@ import (
 	gcore "github.com/snail007/gmc/core"
 	"github.com/snail007/gmc/http/template/sprig"
 	"github.com/snail007/gmc/util/cast"
+	"html"
 	"html/template"
 )
 
@@ -21,6 +22,9 @@ func addFunc(ctx gcore.Ctx) map[string]interface{} {
 		"string": anyToString,
 		"tohtml": anyToTplHTML,
 		"val":    trimNoValue,
+		// the csrf token is set to the view data by csrf middleware.
+		"csrf_token": csrfToken,
+		"csrf_field": csrfField,
 	}
 	for k, v := range f2 {
 		funcMap[k] = v
@@ -65,3 +69,12 @@ func trimNoValue(m interface{}, key ...string) interface{} {
 	}
 	return ""
 }
+
+func csrfToken(data interface{}) string {
+	return gcast.ToString(trimNoValue(data, "CSRFToken"))
+}
+
+func csrfField(data interface{}) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + html.EscapeString(gcast.ToString(trimNoValue(data, "CSRFField"))) +
+		`" value="` + html.EscapeString(csrfToken(data)) + `">`)
+}
@@ -44,3 +44,18 @@ func TestVal(t *testing.T) {
 	assert.Nil(err)
 	assert.Empty(output)
 }
+
+func TestCSRF(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := gcore.ProviderCtx()()
+	ctx.SetConfig(gcore.ProviderConfig()())
+	tpl, _ := NewTemplate(ctx, "tests/views")
+	tpl.Delims("{{", "}}")
+	assert.Nil(tpl.Parse())
+	output, err := tpl.Execute("csrf/csrf", map[string]interface{}{
+		"CSRFToken": "a<b",
+		"CSRFField": "_csrf",
+	})
+	assert.Nil(err)
+	assert.Equal(`<input type="hidden" name="_csrf" value="a&lt;b">|a<b`, string(output))
+}
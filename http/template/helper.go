@@ -20,10 +20,12 @@ import (
 
 func Init(ctx gcore.Ctx) (tpl gcore.Template, err error) {
 	cfg := ctx.Config()
-	tpl, err = NewTemplate(ctx, cfg.GetString("template.dir"))
+	t, err := NewTemplate(ctx, cfg.GetString("template.dir"))
 	if err != nil {
 		return nil, err
 	}
+	t.SetDevMode(cfg.GetBool("template.dev"))
+	tpl = t
 	tpl.Delims(cfg.GetString("template.delimiterleft"),
 		cfg.GetString("template.delimiterright"))
 	tpl.Extension(cfg.GetString("template.ext"))
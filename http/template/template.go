@@ -13,7 +13,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	gotemplate "text/template"
+	"time"
 )
 
 var (
@@ -53,6 +55,11 @@ type Template struct {
 	disableLoadDefaultBinData bool
 	disableLogging            bool
 	left, right               string
+	dev                       bool
+	devLock                   sync.RWMutex
+	devErr                    error
+	base                      *gotemplate.Template
+	mtimes                    map[string]time.Time
 }
 
 func (s *Template) DisableLoadDefaultBinData() {
@@ -168,6 +175,9 @@ func (s *Template) Execute(name string, data interface{}) (output []byte, err er
 	if strings.HasSuffix(name, s.ext) {
 		name = strings.TrimSuffix(name, s.ext)
 	}
+	if s.dev {
+		return s.executeDev(name, data)
+	}
 	buf := &bytes.Buffer{}
 	err = s.tpl.ExecuteTemplate(buf, name, data)
 	if err != nil {
@@ -192,7 +202,16 @@ func (s *Template) Parse() (err error) {
 		if !s.disableLogging {
 			s.ctx.Logger().Infof("parse views from binary data")
 		}
+		s.dev = false
 		err = s.parseFromBinData()
+	} else if s.dev {
+		if !s.disableLogging {
+			s.ctx.Logger().Infof("parse views from disk in dev mode")
+		}
+		// the error is reported by Execute until the view files are fixed.
+		if e := s.parseDev(); e != nil && !s.disableLogging {
+			s.ctx.Logger().Warnf("parse views fail, error: %s", e)
+		}
 	} else {
 		if !s.disableLogging {
 			s.ctx.Logger().Infof("parse views from disk")
@@ -9,6 +9,7 @@ import (
 	gcore "github.com/snail007/gmc/core"
 	ghttputil "github.com/snail007/gmc/internal/util/http"
 	"io"
+	"net/http"
 	"strings"
 	"sync"
 )
@@ -78,8 +79,7 @@ func (this *View) RenderR(tpl string, data ...map[string]interface{}) (d []byte)
 	}
 	d, this.lasterr = this.tpl.Execute(tpl, data0)
 	if this.lasterr != nil {
-		msg := gcore.ProviderError()().StackError(this.lasterr)
-		ghttputil.Stop(this.writer, msg)
+		this.stopWithError(this.lasterr)
 		return
 	}
 	if this.layout != "" {
@@ -90,14 +90,33 @@ func (this *View) RenderR(tpl string, data ...map[string]interface{}) (d []byte)
 		}
 		d, this.lasterr = this.tpl.Execute(layout, data0)
 		if this.lasterr != nil {
-			msg := gcore.ProviderError()().StackError(this.lasterr)
-			ghttputil.Stop(this.writer, msg)
+			this.stopWithError(this.lasterr)
 			return
 		}
 	}
 	return
 }
 
+// errorPager is implemented by the template which renders the errors as a html page, such as
+// gtemplate.Template in dev mode.
+type errorPager interface {
+	ErrorPage(err error) (page []byte, ok bool)
+}
+
+// stopWithError writes the error page if the template has one, otherwise the error stack, and stops.
+func (this *View) stopWithError(err error) {
+	if p, ok := this.tpl.(errorPager); ok {
+		if page, ok := p.ErrorPage(err); ok {
+			if w, ok := this.writer.(http.ResponseWriter); ok {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			ghttputil.Stop(this.writer, page)
+		}
+	}
+	ghttputil.Stop(this.writer, gcore.ProviderError()().StackError(err))
+}
+
 // Layout sets the views layout when render template.
 func (this *View) Layout(l string) gcore.View {
 	this.layout = l
@@ -10,6 +10,10 @@ import (
 	gcore "github.com/snail007/gmc/core"
 	gtemplate "github.com/snail007/gmc/http/template"
 	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -47,3 +51,30 @@ func mockTpl() (t *gtemplate.Template, ctx gcore.Ctx) {
 	t.Parse()
 	return
 }
+
+func TestView_ErrorPage(t *testing.T) {
+	assert := assert2.New(t)
+	dir := t.TempDir()
+	assert.Nil(os.WriteFile(filepath.Join(dir, "index.html"), []byte("{{.name | nofunc}}"), 0644))
+	render := func(dev bool) (w *httptest.ResponseRecorder) {
+		ctx := gcore.ProviderCtx()()
+		ctx.SetConfig(gcore.ProviderConfig()())
+		tpl, _ := gtemplate.NewTemplate(ctx, dir)
+		tpl.DisableLoadDefaultBinData()
+		tpl.DdisableLogging()
+		tpl.SetDevMode(dev)
+		tpl.Parse()
+		w = httptest.NewRecorder()
+		defer func() { recover() }()
+		gcore.ProviderView()(w, tpl).Render("index")
+		return
+	}
+	w := render(true)
+	assert.Equal(http.StatusInternalServerError, w.Code)
+	assert.Contains(w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(w.Body.String(), "index.html:1")
+
+	w = render(false)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.NotContains(w.Body.String(), "<html>")
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gwebsocket
+
+import (
+	"bufio"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var errBadHandshake = errors.New("websocket: bad handshake")
+
+// Dialer connects to a websocket server.
+type Dialer struct {
+	HandshakeTimeout time.Duration
+	TLSConfig        *tls.Config
+	Subprotocols     []string
+	// EnableCompression offers the permessage-deflate extension to server.
+	EnableCompression bool
+	// CompressionThreshold is the min size of a message to be compressed.
+	CompressionThreshold int
+	// ReadLimit is the max size of a message, 0 means DefaultReadLimit.
+	ReadLimit int64
+}
+
+// DefaultDialer is used by Dial.
+var DefaultDialer = &Dialer{
+	HandshakeTimeout:  30 * time.Second,
+	EnableCompression: true,
+}
+
+// Dial connects to the websocket server urlStr with DefaultDialer, such as ws://127.0.0.1/ws.
+func Dial(urlStr string, header http.Header) (*Conn, *http.Response, error) {
+	return DefaultDialer.Dial(urlStr, header)
+}
+
+// Dial connects to the websocket server urlStr, the scheme must be ws or wss, the header
+// is sent in the handshake request, such as Origin and Cookie.
+// The response is returned if the server replied, it can be used to check the status when handshake failed.
+func (d *Dialer) Dial(urlStr string, header http.Header) (conn *Conn, resp *http.Response, err error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	dialer := &net.Dialer{Timeout: d.HandshakeTimeout}
+	var netConn net.Conn
+	switch u.Scheme {
+	case "ws":
+		netConn, err = dialer.Dial("tcp", addr)
+	case "wss":
+		cfg := d.TLSConfig
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg = cfg.Clone()
+			cfg.ServerName = u.Hostname()
+		}
+		netConn, err = tls.DialWithDialer(dialer, "tcp", addr, cfg)
+	default:
+		return nil, nil, fmt.Errorf("websocket: unsupported scheme %s", u.Scheme)
+	}
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			netConn.Close()
+		}
+	}()
+	if d.HandshakeTimeout > 0 {
+		netConn.SetDeadline(time.Now().Add(d.HandshakeTimeout))
+	}
+	var k [16]byte
+	if _, err = rand.Read(k[:]); err != nil {
+		return
+	}
+	key := base64.StdEncoding.EncodeToString(k[:])
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Host:       u.Host,
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(d.Subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(d.Subprotocols, ", "))
+	}
+	if d.EnableCompression {
+		req.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate; server_no_context_takeover; client_no_context_takeover")
+	}
+	if err = req.Write(netConn); err != nil {
+		return
+	}
+	br := bufio.NewReader(netConn)
+	resp, err = http.ReadResponse(br, req)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!headerContains(resp.Header, "Upgrade", "websocket") ||
+		!headerContains(resp.Header, "Connection", "upgrade") ||
+		resp.Header.Get("Sec-Websocket-Accept") != acceptKey(key) {
+		return nil, resp, errBadHandshake
+	}
+	netConn.SetDeadline(time.Time{})
+	conn = newConn(netConn, br, false)
+	conn.subprotocol = resp.Header.Get("Sec-Websocket-Protocol")
+	conn.compress = d.EnableCompression && deflateNegotiated(resp.Header)
+	conn.compressionLevel = flate.BestSpeed
+	conn.compressionThreshold = d.CompressionThreshold
+	conn.readLimit = d.ReadLimit
+	return conn, resp, nil
+}
+
+func deflateNegotiated(h http.Header) bool {
+	for _, ext := range headerTokens(h, "Sec-Websocket-Extensions") {
+		if strings.TrimSpace(strings.Split(ext, ";")[0]) == "permessage-deflate" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,591 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gwebsocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// The message types, same as the opcodes defined in RFC 6455.
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// The close codes defined in RFC 6455, section 11.7.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	finalBit = 1 << 7
+	rsv1Bit  = 1 << 6
+	rsv2Bit  = 1 << 5
+	rsv3Bit  = 1 << 4
+	maskBit  = 1 << 7
+
+	maxControlPayload = 125
+)
+
+// DefaultReadLimit is the max size of a message if the read limit is not set.
+const DefaultReadLimit = 16 << 20
+
+var (
+	// ErrCloseSent is returned by the write methods after the close frame sent.
+	ErrCloseSent = errors.New("websocket: close sent")
+	// ErrConcurrentRead is returned if ReadMessage is called concurrently.
+	ErrConcurrentRead = errors.New("websocket: concurrent read")
+
+	// CloseTimeout is the max time to wait the close frame of peer after sending a close frame.
+	CloseTimeout = 3 * time.Second
+
+	deflateTail   = []byte{0x00, 0x00, 0xff, 0xff}
+	deflateFinal  = []byte{0x01, 0x00, 0x00, 0xff, 0xff}
+	flateWriters  = map[int]*sync.Pool{}
+	flateWritersM sync.Mutex
+)
+
+var _ gcore.WebSocketConn = &Conn{}
+
+// CloseError is returned by ReadMessage when a close frame is received or the
+// connection is closed because of a protocol error.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// IsCloseError returns true if err is a *CloseError with one of the codes,
+// any code is matched if codes is empty.
+func IsCloseError(err error, codes ...int) bool {
+	e, ok := err.(*CloseError)
+	if !ok {
+		return false
+	}
+	if len(codes) == 0 {
+		return true
+	}
+	for _, code := range codes {
+		if e.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a websocket connection of server or client side.
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	isServer    bool
+	subprotocol string
+	// compress is true if permessage-deflate is negotiated.
+	compress             bool
+	compressionLevel     int
+	compressionThreshold int
+	readLimit            int64
+	writeTimeout         time.Duration
+	pongWait             time.Duration
+
+	wmu       sync.Mutex
+	closeSent bool
+	reading   int32
+	readErr   error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, isServer bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	return &Conn{
+		conn:             conn,
+		br:               br,
+		isServer:         isServer,
+		compressionLevel: flate.BestSpeed,
+		done:             make(chan struct{}),
+	}
+}
+
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadLimit sets the max size of a message, the connection is closed with
+// CloseMessageTooBig if a message exceeds it, 0 means DefaultReadLimit.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+func (c *Conn) maxMessageSize() int64 {
+	if c.readLimit > 0 {
+		return c.readLimit
+	}
+	return DefaultReadLimit
+}
+
+// ReadMessage reads a text or binary message. The ping, pong and close frames are
+// handled internally, the error is *CloseError if the connection is closed by peer.
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	if !atomic.CompareAndSwapInt32(&c.reading, 0, 1) {
+		return 0, nil, ErrConcurrentRead
+	}
+	defer atomic.StoreInt32(&c.reading, 0)
+	return c.readMessage()
+}
+
+// ReadJSON reads a message and decodes it to v.
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage writes a message, messageType is TextMessage, BinaryMessage or a control message type.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case PingMessage, PongMessage, CloseMessage:
+		return c.writeControl(byte(messageType), data)
+	default:
+		return fmt.Errorf("websocket: unknown message type %d", messageType)
+	}
+	compressed := false
+	if c.compress && len(data) >= c.compressionThreshold {
+		var err error
+		if data, err = c.deflate(data); err != nil {
+			return err
+		}
+		compressed = true
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	return c.writeFrame(byte(messageType), compressed, data)
+}
+
+// WriteJSON encodes v to json and writes it as a text message.
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// Ping sends a ping frame, the pong frame of peer is handled by ReadMessage.
+func (c *Conn) Ping(data []byte) error {
+	return c.writeControl(PingMessage, data)
+}
+
+// Close closes the connection with CloseNormalClosure.
+func (c *Conn) Close() error {
+	return c.CloseWithReason(CloseNormalClosure, "")
+}
+
+// CloseWithReason sends a close frame and waits the close frame of peer at most
+// CloseTimeout, then closes the underlying connection.
+func (c *Conn) CloseWithReason(code int, reason string) error {
+	if err := c.sendClose(code, reason); err != nil && err != ErrCloseSent {
+		c.closeConn()
+		return err
+	}
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+	if atomic.CompareAndSwapInt32(&c.reading, 0, 1) {
+		// no reader, wait the close frame here.
+		c.conn.SetReadDeadline(time.Now().Add(CloseTimeout))
+		for {
+			if _, _, e := c.readMessage(); e != nil {
+				break
+			}
+		}
+		c.closeConn()
+		atomic.StoreInt32(&c.reading, 0)
+	} else {
+		time.AfterFunc(CloseTimeout, c.closeConn)
+	}
+	return nil
+}
+
+// Done returns a channel which is closed after the underlying connection closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Conn) closeConn() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *Conn) sendClose(code int, reason string) error {
+	var payload []byte
+	if code != CloseNoStatusReceived {
+		payload = make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+	}
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	c.closeSent = true
+	return c.writeFrame(CloseMessage, false, payload)
+}
+
+func (c *Conn) writeControl(opcode byte, data []byte) error {
+	if opcode == CloseMessage {
+		code, text := CloseNoStatusReceived, ""
+		if len(data) >= 2 {
+			code, text = int(binary.BigEndian.Uint16(data)), string(data[2:])
+		}
+		return c.sendClose(code, text)
+	}
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control frame payload too large")
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	return c.writeFrame(opcode, false, data)
+}
+
+// writeFrame writes a final frame, the caller must hold c.wmu.
+func (c *Conn) writeFrame(opcode byte, rsv1 bool, payload []byte) (err error) {
+	buf := make([]byte, 0, 14+len(payload))
+	b0 := finalBit | opcode
+	if rsv1 {
+		b0 |= rsv1Bit
+	}
+	buf = append(buf, b0)
+	var b1 byte
+	if !c.isServer {
+		b1 |= maskBit
+	}
+	n := len(payload)
+	switch {
+	case n <= 125:
+		buf = append(buf, b1|byte(n))
+	case n <= 65535:
+		buf = append(buf, b1|126, byte(n>>8), byte(n))
+	default:
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(n))
+		buf = append(append(buf, b1|127), b[:]...)
+	}
+	if c.isServer {
+		buf = append(buf, payload...)
+	} else {
+		var key [4]byte
+		if _, err = rand.Read(key[:]); err != nil {
+			return
+		}
+		buf = append(buf, key[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		maskBytes(key, buf[start:])
+	}
+	if c.writeTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	_, err = c.conn.Write(buf)
+	return
+}
+
+type frame struct {
+	fin     bool
+	rsv1    bool
+	opcode  byte
+	payload []byte
+}
+
+func (c *Conn) readFrame() (f frame, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(c.br, h[:]); err != nil {
+		return
+	}
+	if c.pongWait > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.pongWait))
+	}
+	f.fin = h[0]&finalBit != 0
+	f.rsv1 = h[0]&rsv1Bit != 0
+	f.opcode = h[0] & 0x0f
+	if h[0]&(rsv2Bit|rsv3Bit) != 0 {
+		return f, c.fail(CloseProtocolError, "unexpected reserved bits")
+	}
+	masked := h[1]&maskBit != 0
+	if masked != c.isServer {
+		return f, c.fail(CloseProtocolError, "incorrect mask flag")
+	}
+	length := uint64(h[1] & 0x7f)
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(b[:])
+		if length>>63 != 0 {
+			return f, c.fail(CloseProtocolError, "invalid payload length")
+		}
+	}
+	switch f.opcode {
+	case CloseMessage, PingMessage, PongMessage:
+		if length > maxControlPayload || !f.fin || f.rsv1 {
+			return f, c.fail(CloseProtocolError, "invalid control frame")
+		}
+	case continuationFrame, TextMessage, BinaryMessage:
+		if f.rsv1 && (!c.compress || f.opcode == continuationFrame) {
+			return f, c.fail(CloseProtocolError, "unexpected rsv1 bit")
+		}
+	default:
+		return f, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", f.opcode))
+	}
+	if length > uint64(c.maxMessageSize()) {
+		return f, c.fail(CloseMessageTooBig, "message too big")
+	}
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, key[:]); err != nil {
+			return
+		}
+	}
+	f.payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, f.payload); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(key, f.payload)
+	}
+	return
+}
+
+func (c *Conn) readMessage() (messageType int, data []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	defer func() {
+		if err != nil {
+			c.readErr = err
+			c.closeConn()
+		}
+	}()
+	compressed := false
+	for {
+		var f frame
+		if f, err = c.readFrame(); err != nil {
+			return
+		}
+		switch f.opcode {
+		case PingMessage:
+			if e := c.writeControl(PongMessage, f.payload); e != nil && e != ErrCloseSent {
+				return 0, nil, e
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(f.payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			messageType = int(f.opcode)
+			compressed = f.rsv1
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		}
+		if int64(len(data))+int64(len(f.payload)) > c.maxMessageSize() {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		data = append(data, f.payload...)
+		if f.fin {
+			break
+		}
+	}
+	if compressed {
+		if data, err = c.inflate(data); err != nil {
+			if err == errTooBig {
+				err = c.fail(CloseMessageTooBig, "message too big")
+			} else {
+				err = c.fail(CloseInvalidFramePayloadData, "invalid compressed data")
+			}
+			return 0, nil, err
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid utf8 payload")
+	}
+	return
+}
+
+func (c *Conn) handleClose(payload []byte) error {
+	code, text := CloseNoStatusReceived, ""
+	if len(payload) == 1 {
+		return c.fail(CloseProtocolError, "invalid close payload")
+	}
+	if len(payload) >= 2 {
+		code, text = int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+		if !validCloseCode(code) || !utf8.ValidString(text) {
+			return c.fail(CloseProtocolError, "invalid close payload")
+		}
+	}
+	// echo the close frame, ErrCloseSent means it is the reply of our close frame.
+	c.sendClose(code, "")
+	return &CloseError{Code: code, Text: text}
+}
+
+// fail sends a close frame with code and returns the CloseError.
+func (c *Conn) fail(code int, text string) error {
+	c.sendClose(code, text)
+	return &CloseError{Code: code, Text: text}
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011, code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
+
+var errTooBig = errors.New("websocket: message too big")
+
+func (c *Conn) deflate(data []byte) ([]byte, error) {
+	pool := flateWriterPool(c.compressionLevel)
+	buf := &bytes.Buffer{}
+	w, _ := pool.Get().(*flate.Writer)
+	if w == nil {
+		var err error
+		if w, err = flate.NewWriter(buf, c.compressionLevel); err != nil {
+			return nil, err
+		}
+	} else {
+		w.Reset(buf)
+	}
+	defer pool.Put(w)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail), nil
+}
+
+func (c *Conn) inflate(data []byte) ([]byte, error) {
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateTail), bytes.NewReader(deflateFinal)))
+	defer r.Close()
+	limit := c.maxMessageSize()
+	out, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > limit {
+		return nil, errTooBig
+	}
+	return out, nil
+}
+
+func flateWriterPool(level int) *sync.Pool {
+	flateWritersM.Lock()
+	defer flateWritersM.Unlock()
+	p, ok := flateWriters[level]
+	if !ok {
+		p = &sync.Pool{}
+		flateWriters[level] = p
+	}
+	return p
+}
+
+// keepalive sends a ping frame every interval until the connection closed.
+func (c *Conn) keepalive(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+			if err := c.Ping(nil); err != nil {
+				if err != ErrCloseSent {
+					c.closeConn()
+				}
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gwebsocket
+
+import (
+	"sort"
+	"sync"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// Hub manages the connections in rooms, and broadcasts messages to the connections of a room.
+// It is safe for concurrent use.
+type Hub struct {
+	mu    sync.RWMutex
+	rooms map[string]map[gcore.WebSocketConn]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		rooms: map[string]map[gcore.WebSocketConn]struct{}{},
+	}
+}
+
+// Join adds the connection to the room, the room is created if not exists.
+func (h *Hub) Join(room string, conn gcore.WebSocketConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conns, ok := h.rooms[room]
+	if !ok {
+		conns = map[gcore.WebSocketConn]struct{}{}
+		h.rooms[room] = conns
+	}
+	conns[conn] = struct{}{}
+}
+
+// Leave removes the connection from the room, the room is removed if it is empty.
+func (h *Hub) Leave(room string, conn gcore.WebSocketConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leave(room, conn)
+}
+
+// LeaveAll removes the connection from all rooms, it should be called when the connection closed.
+func (h *Hub) LeaveAll(conn gcore.WebSocketConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for room := range h.rooms {
+		h.leave(room, conn)
+	}
+}
+
+func (h *Hub) leave(room string, conn gcore.WebSocketConn) {
+	conns, ok := h.rooms[room]
+	if !ok {
+		return
+	}
+	delete(conns, conn)
+	if len(conns) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+// Count returns the count of connections in the room.
+func (h *Hub) Count(room string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms[room])
+}
+
+// Rooms returns the names of all rooms in order.
+func (h *Hub) Rooms() (rooms []string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return
+}
+
+// Broadcast writes the message to all connections in the room except the excluded connections,
+// the connections failed to write are closed and removed from all rooms.
+// The count of connections written successfully is returned.
+func (h *Hub) Broadcast(room string, messageType int, data []byte, exclude ...gcore.WebSocketConn) int {
+	h.mu.RLock()
+	conns := make([]gcore.WebSocketConn, 0, len(h.rooms[room]))
+	for conn := range h.rooms[room] {
+		conns = append(conns, conn)
+	}
+	h.mu.RUnlock()
+	return h.send(conns, messageType, data, exclude)
+}
+
+// BroadcastAll writes the message to all connections in all rooms, see Broadcast.
+func (h *Hub) BroadcastAll(messageType int, data []byte, exclude ...gcore.WebSocketConn) int {
+	h.mu.RLock()
+	set := map[gcore.WebSocketConn]struct{}{}
+	for _, conns := range h.rooms {
+		for conn := range conns {
+			set[conn] = struct{}{}
+		}
+	}
+	h.mu.RUnlock()
+	conns := make([]gcore.WebSocketConn, 0, len(set))
+	for conn := range set {
+		conns = append(conns, conn)
+	}
+	return h.send(conns, messageType, data, exclude)
+}
+
+func (h *Hub) send(conns []gcore.WebSocketConn, messageType int, data []byte, exclude []gcore.WebSocketConn) (n int) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, conn := range conns {
+		if contains(exclude, conn) {
+			continue
+		}
+		wg.Add(1)
+		go func(conn gcore.WebSocketConn) {
+			defer wg.Done()
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				h.LeaveAll(conn)
+				go conn.CloseWithReason(CloseGoingAway, "")
+				return
+			}
+			mu.Lock()
+			n++
+			mu.Unlock()
+		}(conn)
+	}
+	wg.Wait()
+	return
+}
+
+func contains(conns []gcore.WebSocketConn, conn gcore.WebSocketConn) bool {
+	for _, c := range conns {
+		if c == conn {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gwebsocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub(t *testing.T) {
+	assert := assert.New(t)
+	hub := NewHub()
+	ready := make(chan struct{}, 3)
+	s, url := newServer(NewUpgrader(), func(c *Conn) {
+		_, room, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		hub.Join(string(room), c)
+		hub.Join("all", c)
+		defer hub.LeaveAll(c)
+		ready <- struct{}{}
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			hub.Broadcast(string(room), TextMessage, data, c)
+		}
+	})
+	defer s.Close()
+	var conns []*Conn
+	for _, room := range []string{"a", "a", "b"} {
+		c, _, err := Dial(url, nil)
+		assert.Nil(err)
+		c.WriteMessage(TextMessage, []byte(room))
+		<-ready
+		conns = append(conns, c)
+	}
+	assert.Equal([]string{"a", "all", "b"}, hub.Rooms())
+	assert.Equal(2, hub.Count("a"))
+	assert.Equal(3, hub.Count("all"))
+
+	// the sender is excluded
+	conns[0].WriteMessage(TextMessage, []byte("hello a"))
+	_, data, err := conns[1].ReadMessage()
+	assert.Nil(err)
+	assert.Equal("hello a", string(data))
+
+	assert.Equal(3, hub.BroadcastAll(BinaryMessage, []byte("all")))
+	for _, c := range conns {
+		mt, data, err := c.ReadMessage()
+		assert.Nil(err)
+		assert.Equal(BinaryMessage, mt)
+		assert.Equal("all", string(data))
+	}
+
+	conns[2].Close()
+	for i := 0; i < 100 && hub.Count("b") > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal([]string{"a", "all"}, hub.Rooms())
+	assert.Equal(2, hub.Count("all"))
+	conns[0].Close()
+	conns[1].Close()
+}
@@ -0,0 +1,253 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gwebsocket
+
+import (
+	"bufio"
+	"compress/flate"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var _ gcore.WebSocketUpgrader = &Upgrader{}
+
+// HandshakeError is returned by Upgrade if the request is not a valid websocket handshake,
+// the error response has been written to client.
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Message
+}
+
+// Upgrader upgrades the http request to websocket connection.
+type Upgrader struct {
+	// CheckOrigin returns true if the Origin header is allowed, if it is nil,
+	// the request without Origin header or the Origin host equals to Host header is allowed.
+	CheckOrigin func(r *http.Request) bool
+	// Subprotocols are the supported protocols in order of preference.
+	Subprotocols []string
+	// EnableCompression negotiates the permessage-deflate extension if the client supports it.
+	EnableCompression bool
+	// CompressionLevel is the level of compress/flate, default is flate.BestSpeed.
+	CompressionLevel int
+	// CompressionThreshold is the min size of a message to be compressed.
+	CompressionThreshold int
+	// ReadLimit is the max size of a message, 0 means DefaultReadLimit.
+	ReadLimit int64
+	// WriteTimeout is the timeout of writing a frame, 0 means no timeout.
+	WriteTimeout time.Duration
+	// PingInterval is the interval of sending ping frames, 0 means no ping.
+	PingInterval time.Duration
+	// PongWait is the max time to wait a frame from the client, the connection is closed
+	// if nothing received, it must be greater than PingInterval, 0 means no timeout.
+	PongWait time.Duration
+}
+
+// NewUpgrader returns an Upgrader with the default options, compression is enabled,
+// ping every 30 seconds and close the connection if the client is silent for 60 seconds.
+func NewUpgrader() *Upgrader {
+	return &Upgrader{
+		EnableCompression:    true,
+		CompressionLevel:     flate.BestSpeed,
+		CompressionThreshold: 512,
+		ReadLimit:            DefaultReadLimit,
+		WriteTimeout:         10 * time.Second,
+		PingInterval:         30 * time.Second,
+		PongWait:             60 * time.Second,
+	}
+}
+
+// Upgrade upgrades the request of ctx to a websocket connection.
+func (u *Upgrader) Upgrade(ctx gcore.Ctx) (gcore.WebSocketConn, error) {
+	c, err := u.UpgradeHTTP(ctx.Response(), ctx.Request())
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UpgradeHTTP upgrades the http request to a websocket connection.
+func (u *Upgrader) UpgradeHTTP(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return nil, u.error(w, http.StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, u.error(w, http.StatusBadRequest, "not a websocket handshake")
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		w.Header().Set("Sec-Websocket-Version", "13")
+		return nil, u.error(w, http.StatusUpgradeRequired, "unsupported version")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = SameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, u.error(w, http.StatusForbidden, "origin not allowed")
+	}
+	key := r.Header.Get("Sec-Websocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return nil, u.error(w, http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, u.error(w, http.StatusInternalServerError, "response does not implement http.Hijacker")
+	}
+	protocol := u.selectProtocol(r)
+	compress := u.EnableCompression && acceptDeflate(r.Header)
+	netConn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	var buf strings.Builder
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	buf.WriteString(acceptKey(key))
+	buf.WriteString("\r\n")
+	if protocol != "" {
+		buf.WriteString("Sec-WebSocket-Protocol: " + protocol + "\r\n")
+	}
+	if compress {
+		buf.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	buf.WriteString("\r\n")
+	if u.WriteTimeout > 0 {
+		netConn.SetWriteDeadline(time.Now().Add(u.WriteTimeout))
+	}
+	if _, err = netConn.Write([]byte(buf.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	// clear the deadline of http server.
+	netConn.SetDeadline(time.Time{})
+	var br *bufio.Reader
+	if brw.Reader.Buffered() > 0 {
+		br = brw.Reader
+	}
+	c := newConn(netConn, br, true)
+	c.subprotocol = protocol
+	c.compress = compress
+	if u.CompressionLevel != 0 {
+		c.compressionLevel = u.CompressionLevel
+	}
+	c.compressionThreshold = u.CompressionThreshold
+	c.readLimit = u.ReadLimit
+	c.writeTimeout = u.WriteTimeout
+	c.pongWait = u.PongWait
+	if c.pongWait > 0 {
+		netConn.SetReadDeadline(time.Now().Add(c.pongWait))
+	}
+	if u.PingInterval > 0 {
+		go c.keepalive(u.PingInterval)
+	}
+	return c, nil
+}
+
+func (u *Upgrader) error(w http.ResponseWriter, status int, msg string) error {
+	http.Error(w, http.StatusText(status), status)
+	return &HandshakeError{Status: status, Message: msg}
+}
+
+func (u *Upgrader) selectProtocol(r *http.Request) string {
+	for _, p := range headerTokens(r.Header, "Sec-Websocket-Protocol") {
+		for _, sp := range u.Subprotocols {
+			if p == sp {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// SameOrigin returns true if the request has no Origin header or the host of Origin
+// equals to the Host header, it is the default CheckOrigin of Upgrader.
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// AllowOrigins returns a CheckOrigin function which allows the request without Origin
+// header or the Origin is one of origins, such as https://example.com, * allows any.
+func AllowOrigins(origins ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, o := range origins {
+			if o == "*" || strings.EqualFold(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerTokens returns the comma separated tokens of the header.
+func headerTokens(h http.Header, name string) (tokens []string) {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptDeflate returns true if there is a permessage-deflate offer can be accepted,
+// compress/flate always uses 15 bits window, so the offer limits the window of server is refused.
+func acceptDeflate(h http.Header) bool {
+	for _, ext := range headerTokens(h, "Sec-Websocket-Extensions") {
+		params := strings.Split(ext, ";")
+		if strings.TrimSpace(params[0]) != "permessage-deflate" {
+			continue
+		}
+		ok := true
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if kv[0] == "server_max_window_bits" && (len(kv) < 2 || strings.Trim(kv[1], `"`) != "15") {
+				ok = false
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
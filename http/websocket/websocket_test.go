@@ -0,0 +1,211 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gwebsocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newServer(u *Upgrader, handler func(c *Conn)) (*httptest.Server, string) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := u.UpgradeHTTP(w, r)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		handler(c)
+	}))
+	return s, "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func echo(c *Conn) {
+	for {
+		mt, data, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err = c.WriteMessage(mt, data); err != nil {
+			return
+		}
+	}
+}
+
+func TestEcho(t *testing.T) {
+	assert := assert.New(t)
+	for _, compress := range []bool{true, false} {
+		u := NewUpgrader()
+		u.CompressionThreshold = 10
+		u.EnableCompression = compress
+		s, url := newServer(u, echo)
+		d := &Dialer{EnableCompression: true, CompressionThreshold: 10}
+		c, resp, err := d.Dial(url, nil)
+		assert.Nil(err)
+		assert.Equal(http.StatusSwitchingProtocols, resp.StatusCode)
+		assert.Equal(compress, c.compress)
+		big := bytes.Repeat([]byte("gmc"), 50000)
+		for _, msg := range []struct {
+			mt   int
+			data []byte
+		}{{TextMessage, []byte("hi")}, {BinaryMessage, []byte{0, 1, 2}}, {TextMessage, big}, {BinaryMessage, big}} {
+			assert.Nil(c.WriteMessage(msg.mt, msg.data))
+			mt, data, err := c.ReadMessage()
+			assert.Nil(err)
+			assert.Equal(msg.mt, mt)
+			assert.Equal(msg.data, data)
+		}
+		assert.Nil(c.WriteJSON(map[string]int{"a": 1}))
+		var v map[string]int
+		assert.Nil(c.ReadJSON(&v))
+		assert.Equal(1, v["a"])
+		assert.Nil(c.Close())
+		select {
+		case <-c.Done():
+		case <-time.After(time.Second):
+			t.Fatal("close timeout")
+		}
+		assert.Equal(ErrCloseSent, c.WriteMessage(TextMessage, []byte("x")))
+		s.Close()
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	assert := assert.New(t)
+	u := NewUpgrader()
+	u.Subprotocols = []string{"v2", "v1"}
+	s, url := newServer(u, func(c *Conn) {
+		c.WriteMessage(TextMessage, []byte(c.Subprotocol()))
+	})
+	defer s.Close()
+	d := &Dialer{Subprotocols: []string{"v1", "v2"}}
+	c, _, err := d.Dial(url, nil)
+	assert.Nil(err)
+	assert.Equal("v1", c.Subprotocol())
+	_, data, err := c.ReadMessage()
+	assert.Nil(err)
+	assert.Equal("v1", string(data))
+	// the close frame of server
+	_, _, err = c.ReadMessage()
+	assert.True(IsCloseError(err, CloseNormalClosure))
+
+	_, resp, err := Dial(url, http.Header{"Origin": {"http://evil.com"}})
+	assert.NotNil(err)
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+
+	u.CheckOrigin = AllowOrigins("http://evil.com")
+	c, _, err = Dial(url, http.Header{"Origin": {"http://evil.com"}})
+	assert.Nil(err)
+	c.Close()
+
+	resp, err = http.Get(s.URL)
+	assert.Nil(err)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	_, _, err = Dial("http://"+strings.TrimPrefix(url, "ws://"), nil)
+	assert.NotNil(err)
+}
+
+func TestCloseHandshake(t *testing.T) {
+	assert := assert.New(t)
+	errCh := make(chan error, 1)
+	s, url := newServer(NewUpgrader(), func(c *Conn) {
+		_, _, err := c.ReadMessage()
+		errCh <- err
+	})
+	defer s.Close()
+	c, _, err := Dial(url, nil)
+	assert.Nil(err)
+	assert.Nil(c.CloseWithReason(CloseGoingAway, "bye"))
+	err = <-errCh
+	assert.True(IsCloseError(err, CloseGoingAway))
+	assert.Equal("bye", err.(*CloseError).Text)
+	assert.False(IsCloseError(nil))
+}
+
+func TestReadLimit(t *testing.T) {
+	assert := assert.New(t)
+	u := NewUpgrader()
+	u.ReadLimit = 10
+	errCh := make(chan error, 1)
+	s, url := newServer(u, func(c *Conn) {
+		_, _, err := c.ReadMessage()
+		errCh <- err
+	})
+	defer s.Close()
+	c, _, err := Dial(url, nil)
+	assert.Nil(err)
+	c.WriteMessage(TextMessage, []byte("01234567890"))
+	assert.True(IsCloseError(<-errCh, CloseMessageTooBig))
+	_, _, err = c.ReadMessage()
+	assert.True(IsCloseError(err, CloseMessageTooBig))
+}
+
+func TestReadLimit_Length(t *testing.T) {
+	assert := assert.New(t)
+	u := NewUpgrader()
+	u.ReadLimit = 0
+	errCh := make(chan error, 1)
+	s, url := newServer(u, func(c *Conn) {
+		_, _, err := c.ReadMessage()
+		errCh <- err
+	})
+	defer s.Close()
+	send := func(length uint64) error {
+		c, _, err := Dial(url, nil)
+		assert.Nil(err)
+		defer c.Close()
+		h := []byte{finalBit | BinaryMessage, maskBit | 127, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(h[2:], length)
+		h = append(h, 1, 2, 3, 4)
+		_, err = c.conn.Write(h)
+		assert.Nil(err)
+		return <-errCh
+	}
+	// the most significant bit of the 64-bit length must be 0.
+	assert.True(IsCloseError(send(1<<63), CloseProtocolError))
+	// 0 means the default limit, not no limit.
+	assert.True(IsCloseError(send(DefaultReadLimit+1), CloseMessageTooBig))
+}
+
+func TestKeepalive(t *testing.T) {
+	assert := assert.New(t)
+	u := NewUpgrader()
+	u.PingInterval = 20 * time.Millisecond
+	u.PongWait = 100 * time.Millisecond
+	errCh := make(chan error, 1)
+	s, url := newServer(u, func(c *Conn) {
+		_, _, err := c.ReadMessage()
+		errCh <- err
+	})
+	defer s.Close()
+
+	// the client reads and replies pong, so the server keeps the connection.
+	c, _, err := Dial(url, nil)
+	assert.Nil(err)
+	go c.ReadMessage()
+	time.Sleep(300 * time.Millisecond)
+	assert.Nil(c.WriteMessage(TextMessage, []byte("alive")))
+	assert.Nil(<-errCh)
+	c.Close()
+
+	// the client is silent, the server closes the connection after PongWait.
+	c, _, err = Dial(url, nil)
+	assert.Nil(err)
+	select {
+	case err = <-errCh:
+		assert.NotNil(err)
+	case <-time.After(time.Second):
+		t.Fatal("server should close the silent connection")
+	}
+	c.closeConn()
+}
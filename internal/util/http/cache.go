@@ -0,0 +1,148 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ghttputil
+
+import (
+	"hash/fnv"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	buildTime     time.Time
+	buildTimeOnce sync.Once
+)
+
+// BuildTime returns the modification time of the executable, it is used as the Last-Modified
+// of the embed files, because the files of embed.FS have no modification time.
+// If the executable can not be found, the time of first called is returned.
+func BuildTime() time.Time {
+	buildTimeOnce.Do(func() {
+		buildTime = time.Now()
+		if p, err := os.Executable(); err == nil {
+			if info, err := os.Stat(p); err == nil {
+				buildTime = info.ModTime()
+			}
+		}
+		// Last-Modified is in seconds.
+		buildTime = buildTime.Truncate(time.Second)
+	})
+	return buildTime
+}
+
+// FileETag returns a weak ETag of a file by the modification time and the size.
+func FileETag(modTime time.Time, size int64) string {
+	return `W/"` + strconv.FormatInt(modTime.UnixNano(), 16) + "-" + strconv.FormatInt(size, 16) + `"`
+}
+
+// ContentETag returns a weak ETag of the content.
+func ContentETag(b []byte) string {
+	h := fnv.New64a()
+	h.Write(b)
+	return `W/"` + strconv.FormatInt(int64(len(b)), 16) + "-" + strconv.FormatUint(h.Sum64(), 16) + `"`
+}
+
+// SetFileETag sets the ETag of the file to header of w if it is not set, so http.ServeFile and
+// http.ServeContent can respond 304 by If-None-Match.
+func SetFileETag(w http.ResponseWriter, info os.FileInfo) {
+	if info == nil || info.IsDir() || w.Header().Get("ETag") != "" {
+		return
+	}
+	w.Header().Set("ETag", FileETag(info.ModTime(), info.Size()))
+}
+
+// NotModified checks the conditional headers If-None-Match and If-Modified-Since of r
+// with the ETag and Last-Modified of header h, it returns true if the client cache is fresh.
+// If-Modified-Since is ignored if If-None-Match is present, the ETags are compared weakly.
+func NotModified(r *http.Request, h http.Header) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		return etagMatch(inm, etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	lm := h.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modTime, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return !modTime.After(t)
+}
+
+// WriteNotModified responds 304, the headers of body are removed.
+func WriteNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+func etagMatch(inm, etag string) bool {
+	etag = trimWeak(etag)
+	for _, v := range splitETags(inm) {
+		if v == "*" || trimWeak(v) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func splitETags(s string) (a []string) {
+	for s != "" {
+		// skip spaces and commas
+		for s != "" && (s[0] == ' ' || s[0] == '\t' || s[0] == ',') {
+			s = s[1:]
+		}
+		if s == "" {
+			break
+		}
+		if s[0] == '*' {
+			a = append(a, "*")
+			s = s[1:]
+			continue
+		}
+		start := 0
+		if len(s) > 2 && s[:2] == "W/" {
+			start = 2
+		}
+		if len(s) <= start || s[start] != '"' {
+			return
+		}
+		end := start + 1
+		for end < len(s) && s[end] != '"' {
+			end++
+		}
+		if end == len(s) {
+			return
+		}
+		a = append(a, s[:end+1])
+		s = s[end+1:]
+	}
+	return
+}
+
+func trimWeak(etag string) string {
+	if len(etag) > 2 && etag[:2] == "W/" {
+		return etag[2:]
+	}
+	return etag
+}
@@ -17,6 +17,8 @@ type ResponseWriter struct {
 	statusCode   int
 	writeByteCnt int64
 	data         *sync.Map
+	wroteHeader  bool
+	beforeHeader []func()
 }
 
 func (this *ResponseWriter) ClearData() {
@@ -37,9 +39,11 @@ func (this *ResponseWriter) SetData(k interface{}, v interface{}) {
 
 func (this *ResponseWriter) WriteHeader(status int) {
 	this.statusCode = status
+	this.callBeforeHeader()
 	this.ResponseWriter.WriteHeader(status)
 }
 func (this *ResponseWriter) Write(b []byte) (n int, err error) {
+	this.callBeforeHeader()
 	n, err = this.ResponseWriter.Write(b)
 	if n > 0 {
 		this.writeByteCnt += int64(n)
@@ -47,6 +51,33 @@ func (this *ResponseWriter) Write(b []byte) (n int, err error) {
 	return
 }
 
+// BeforeWriteHeader registers fn to be called once before the header is written, it returns false
+// if the header is already written.
+func (this *ResponseWriter) BeforeWriteHeader(fn func()) bool {
+	if this.wroteHeader {
+		return false
+	}
+	this.beforeHeader = append(this.beforeHeader, fn)
+	return true
+}
+
+// HeaderWritten returns true if the header is written.
+func (this *ResponseWriter) HeaderWritten() bool {
+	return this.wroteHeader
+}
+
+func (this *ResponseWriter) callBeforeHeader() {
+	if this.wroteHeader {
+		return
+	}
+	this.wroteHeader = true
+	fns := this.beforeHeader
+	this.beforeHeader = nil
+	for _, fn := range fns {
+		fn()
+	}
+}
+
 // WriteCount acquires outgoing bytes count by writer
 func (this *ResponseWriter) WriteCount() int64 {
 	return this.writeByteCnt
@@ -55,24 +86,54 @@ func (this *ResponseWriter) StatusCode() int {
 	return this.statusCode
 }
 
+// StatusCode returns the status code of w, w is a ResponseWriter or a writer wraps it,
+// such as the writer of compress middleware.
 func StatusCode(w http.ResponseWriter) (code int) {
-	if v, ok := w.(*ResponseWriter); ok {
-		code = v.statusCode
+	if v, ok := w.(interface{ StatusCode() int }); ok {
+		code = v.StatusCode()
 	}
 	return
 }
 func WriteCount(w http.ResponseWriter) int64 {
-	if v, ok := w.(*ResponseWriter); ok {
-		return v.writeByteCnt
+	if v, ok := w.(interface{ WriteCount() int64 }); ok {
+		return v.WriteCount()
 	}
 	return 0
 }
 
+// BeforeWriteHeader registers fn to be called before the header of w is written, w is a ResponseWriter
+// or a writer wraps it and has method Unwrap. It returns false if the header is already written or
+// w does not support it.
+func BeforeWriteHeader(w http.ResponseWriter, fn func()) bool {
+	for w != nil {
+		if v, ok := w.(interface{ BeforeWriteHeader(func()) bool }); ok {
+			return v.BeforeWriteHeader(fn)
+		}
+		v, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = v.Unwrap()
+	}
+	return false
+}
+
 func (this *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	hj, _ := this.ResponseWriter.(http.Hijacker)
+	hj, ok := this.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
 	return hj.Hijack()
 }
 
+// Flush sends the buffered data to the client if the underlying writer supports it.
+func (this *ResponseWriter) Flush() {
+	this.callBeforeHeader()
+	if f, ok := this.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func NewResponseWriter(w http.ResponseWriter) http.ResponseWriter {
 	if _, ok := w.(*ResponseWriter); ok {
 		return w
@@ -99,6 +99,32 @@ func (c *FileCache) Set(key string, val string, ttl time.Duration) error {
 	return ioutil.WriteFile(filename, data, 0700)
 }
 
+// SetNX sets value into cache only if the key does not exist or is expired, ok is false if the key exists.
+func (c *FileCache) SetNX(key string, val string, ttl time.Duration) (ok bool, err error) {
+	if _, err = c.Get(key); err == nil || err != ErrKeyNotExists {
+		return false, err
+	}
+	filename := c.filepath(key)
+	item := &Item{val, time.Now().Unix(), int64(ttl / time.Second)}
+	data, err := encodeGob(item)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(filename), 0700)
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0700)
+	if err != nil {
+		if os.IsExist(err) {
+			err = nil
+		}
+		return
+	}
+	defer f.Close()
+	if _, err = f.Write(data); err != nil {
+		return
+	}
+	return true, nil
+}
+
 func (c *FileCache) read(key string) (*Item, error) {
 	filename := c.filepath(key)
 	data, err := ioutil.ReadFile(filename)
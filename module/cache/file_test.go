@@ -26,6 +26,22 @@ func TestFileCache_Set(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal("aaa", v)
 }
+func TestFileCache_SetNX(t *testing.T) {
+	assert := assert.New(t)
+	ok, err := cFile.(*FileCache).SetNX("setnx", "a", time.Second)
+	assert.Nil(err)
+	assert.True(ok)
+	ok, err = cFile.(*FileCache).SetNX("setnx", "b", time.Second)
+	assert.Nil(err)
+	assert.False(ok)
+	v, _ := cFile.Get("setnx")
+	assert.Equal("a", v)
+	time.Sleep(time.Second * 2)
+	ok, err = cFile.(*FileCache).SetNX("setnx", "c", time.Second)
+	assert.Nil(err)
+	assert.True(ok)
+	cFile.Del("setnx")
+}
 func TestFileCache_Expire(t *testing.T) {
 	assert := assert.New(t)
 	err := cFile.Set("test", "aaa", time.Second)
@@ -62,6 +62,11 @@ func (s *MemCache) Set(key string, value string, ttl time.Duration) error {
 	s.c.Set(key, value, ttl)
 	return nil
 }
+
+// SetNX sets the value only if the key does not exist, ok is false if the key exists.
+func (s *MemCache) SetNX(key string, value string, ttl time.Duration) (ok bool, err error) {
+	return s.c.Add(key, value, ttl) == nil, nil
+}
 func (s *MemCache) Del(key string) error {
 	s.c.Delete(key)
 	return nil
@@ -24,6 +24,21 @@ func TestMemCache_Set(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal("aaa", v)
 }
+func TestMemCache_SetNX(t *testing.T) {
+	assert := assert.New(t)
+	ok, err := cMem.(*MemCache).SetNX("setnx", "a", time.Millisecond*500)
+	assert.Nil(err)
+	assert.True(ok)
+	ok, err = cMem.(*MemCache).SetNX("setnx", "b", time.Millisecond*500)
+	assert.Nil(err)
+	assert.False(ok)
+	v, _ := cMem.Get("setnx")
+	assert.Equal("a", v)
+	time.Sleep(time.Second)
+	ok, _ = cMem.(*MemCache).SetNX("setnx", "c", time.Millisecond*500)
+	assert.True(ok)
+	cMem.Del("setnx")
+}
 func TestMemCache_Expire(t *testing.T) {
 	assert := assert.New(t)
 	err := cMem.Set("test", "aaa", time.Millisecond*500)
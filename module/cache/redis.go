@@ -94,6 +94,16 @@ func (c *RedisCache) Set(key string, val string, ttl time.Duration) (err error)
 	return
 }
 
+// SetNX sets value by key only if the key does not exist, ok is false if the key exists.
+func (c *RedisCache) SetNX(key string, val string, ttl time.Duration) (ok bool, err error) {
+	c.connect()
+	reply, err := c.exec("Set", c.key(key), val, "PX", int64(ttl/time.Millisecond), "NX")
+	if err != nil {
+		return
+	}
+	return reply != nil, nil
+}
+
 // Del value by key
 func (c *RedisCache) Del(key string) (err error) {
 	c.connect()
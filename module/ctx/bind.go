@@ -0,0 +1,329 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gctx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	gvalidator "github.com/snail007/gmc/util/validator"
+)
+
+var (
+	// BindMaxMultipartMemory is the max memory used to parse the multipart form in Bind and BindMultipart.
+	BindMaxMultipartMemory int64 = 32 << 20
+	// BindTimeLayouts are the layouts used to parse the time.Time field in order,
+	// the unix timestamp is also accepted.
+	BindTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// Bind binds the path params, query and the body to obj, then validates obj by the
+// tag validate. The body is decoded by the Content-Type, application/json,
+// multipart/form-data and application/x-www-form-urlencoded are supported.
+// The field name is taken from the tag param, query, form, json for each source,
+// the Go field name is used if the tag is empty. The error of validation is gvalidator.Errors,
+// an invalid tag validate returns an error which is not gvalidator.Errors.
+//
+//	type User struct {
+//	    ID   int    `param:"id"`
+//	    Name string `form:"name" json:"name" validate:"required,max=20"`
+//	}
+func (this *Ctx) Bind(obj interface{}) (err error) {
+	if err = this.bindParams(obj); err != nil {
+		return
+	}
+	if err = this.bindQuery(obj); err != nil {
+		return
+	}
+	if this.request.Body != nil && this.request.Body != http.NoBody {
+		switch this.contentType() {
+		case "application/json":
+			err = this.bindJSON(obj)
+		case "multipart/form-data":
+			err = this.bindMultipart(obj)
+		case "application/x-www-form-urlencoded":
+			err = this.bindForm(obj)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return gvalidator.Validate(obj)
+}
+
+// BindQuery binds the url query to obj by the tag query, the tag form is used if the
+// tag query is empty, then validates obj.
+func (this *Ctx) BindQuery(obj interface{}) (err error) {
+	if err = this.bindQuery(obj); err != nil {
+		return
+	}
+	return gvalidator.Validate(obj)
+}
+
+// BindForm binds the post form to obj by the tag form, then validates obj.
+func (this *Ctx) BindForm(obj interface{}) (err error) {
+	if err = this.bindForm(obj); err != nil {
+		return
+	}
+	return gvalidator.Validate(obj)
+}
+
+// BindMultipart binds the multipart form to obj by the tag form, the field of type
+// *multipart.FileHeader or []*multipart.FileHeader is filled by the uploaded files,
+// then validates obj.
+func (this *Ctx) BindMultipart(obj interface{}) (err error) {
+	if err = this.bindMultipart(obj); err != nil {
+		return
+	}
+	return gvalidator.Validate(obj)
+}
+
+// BindJSON decodes the json body to obj, then validates obj.
+func (this *Ctx) BindJSON(obj interface{}) (err error) {
+	if err = this.bindJSON(obj); err != nil {
+		return
+	}
+	return gvalidator.Validate(obj)
+}
+
+// BindParams binds the path params of router to obj by the tag param, then validates obj.
+func (this *Ctx) BindParams(obj interface{}) (err error) {
+	if err = this.bindParams(obj); err != nil {
+		return
+	}
+	return gvalidator.Validate(obj)
+}
+
+func (this *Ctx) contentType() string {
+	t, _, _ := mime.ParseMediaType(this.request.Header.Get("Content-Type"))
+	return t
+}
+
+func (this *Ctx) bindQuery(obj interface{}) error {
+	return bindValues(obj, this.request.URL.Query(), nil, "query", "form")
+}
+
+func (this *Ctx) bindForm(obj interface{}) error {
+	if err := this.request.ParseForm(); err != nil {
+		return err
+	}
+	return bindValues(obj, this.request.PostForm, nil, "form")
+}
+
+func (this *Ctx) bindMultipart(obj interface{}) error {
+	form, err := this.MultipartForm(BindMaxMultipartMemory)
+	if err != nil {
+		return err
+	}
+	return bindValues(obj, form.Value, form.File, "form")
+}
+
+func (this *Ctx) bindJSON(obj interface{}) error {
+	if this.request.Body == nil {
+		return fmt.Errorf("bind: empty request body")
+	}
+	err := json.NewDecoder(this.request.Body).Decode(obj)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+func (this *Ctx) bindParams(obj interface{}) error {
+	if len(this.param) == 0 {
+		return nil
+	}
+	values := map[string][]string{}
+	for _, p := range this.param {
+		values[p.Key] = append(values[p.Key], p.Value)
+	}
+	return bindValues(obj, values, nil, "param")
+}
+
+// bindValues sets the fields of obj by the values, the field name is the first non-empty tag of tags.
+func bindValues(obj interface{}, values map[string][]string, files map[string][]*multipart.FileHeader, tags ...string) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("bind: %T is not a non-nil pointer", obj)
+	}
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("bind: %T is not a pointer to struct", obj)
+	}
+	return bindStruct(v, values, files, tags)
+}
+
+func bindStruct(v reflect.Value, values map[string][]string, files map[string][]*multipart.FileHeader, tags []string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := v.Field(i)
+		name := bindFieldName(f, tags)
+		if f.Anonymous && name == "" {
+			if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+				if fv.IsNil() {
+					if !fv.CanSet() {
+						continue
+					}
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := bindStruct(fv, values, files, tags); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		switch f.Type {
+		case fileHeaderType:
+			if fs := files[name]; len(fs) > 0 {
+				fv.Set(reflect.ValueOf(fs[0]))
+			}
+			continue
+		case fileHeaderSliceType:
+			if fs := files[name]; len(fs) > 0 {
+				fv.Set(reflect.ValueOf(fs))
+			}
+			continue
+		}
+		vals, ok := values[name]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+		if err := setField(fv, vals); err != nil {
+			return fmt.Errorf("bind: field %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func bindFieldName(f reflect.StructField, tags []string) string {
+	for _, tag := range tags {
+		if name := strings.Split(f.Tag.Get(tag), ",")[0]; name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+func setField(v reflect.Value, vals []string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		// an empty value of a pointer to non-string is absent, it keeps nil.
+		if vals[0] == "" && v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		nv := reflect.New(v.Type().Elem())
+		if err := setField(nv.Elem(), vals); err != nil {
+			return err
+		}
+		v.Set(nv)
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(vals[0]))
+			return nil
+		}
+		s := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setField(s.Index(i), []string{val}); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	return setValue(v, vals[0])
+}
+
+func setValue(v reflect.Value, val string) error {
+	if val == "" && v.Kind() != reflect.String {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Type() == timeType {
+		t, err := parseTime(val)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(val)
+	case reflect.Bool:
+		if val == "on" {
+			val = "true"
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(val, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func parseTime(val string) (t time.Time, err error) {
+	for _, layout := range BindTimeLayouts {
+		if t, err = time.ParseInLocation(layout, val, time.Local); err == nil {
+			return
+		}
+	}
+	if n, e := strconv.ParseInt(val, 10, 64); e == nil {
+		return time.Unix(n, 0), nil
+	}
+	return
+}
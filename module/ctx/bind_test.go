@@ -0,0 +1,146 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gctx
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gvalidator "github.com/snail007/gmc/util/validator"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+type bindPage struct {
+	Page int `query:"page" validate:"omitempty,min=1"`
+}
+
+type bindUser struct {
+	bindPage
+	ID       int64                   `param:"id" json:"id"`
+	Name     string                  `form:"name" json:"name" validate:"required,max=10"`
+	Age      *uint8                  `form:"age" json:"age" validate:"omitempty,min=18"`
+	Tags     []string                `form:"tag" json:"tags"`
+	VIP      bool                    `form:"vip" json:"vip"`
+	Score    float64                 `form:"score" json:"score"`
+	Birthday time.Time               `form:"birthday" json:"birthday"`
+	Avatar   *multipart.FileHeader   `form:"avatar" json:"-"`
+	Photos   []*multipart.FileHeader `form:"photo" json:"-"`
+	Ignored  string                  `form:"-" json:"-"`
+}
+
+func TestCtx_BindForm(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := mockCtx("POST", "/user?page=2", "name=jack&age=20&tag=a&tag=b&vip=on&score=1.5&birthday=2020-01-02&Ignored=x")
+	ctx.SetParam(gcore.Params{{Key: "id", Value: "7"}})
+	u := &bindUser{}
+	assert.Nil(ctx.Bind(u))
+	assert.Equal(2, u.Page)
+	assert.Equal(int64(7), u.ID)
+	assert.Equal("jack", u.Name)
+	assert.Equal(uint8(20), *u.Age)
+	assert.Equal([]string{"a", "b"}, u.Tags)
+	assert.True(u.VIP)
+	assert.Equal(1.5, u.Score)
+	assert.Equal("2020-01-02", u.Birthday.Format("2006-01-02"))
+	assert.Equal("", u.Ignored)
+
+	u = &bindUser{}
+	assert.Nil(mockCtx("POST", "/", "name=tom&age=").BindForm(u))
+	assert.Equal("tom", u.Name)
+	assert.Nil(u.Age)
+
+	assert.NotNil(mockCtx("POST", "/", "name=tom&age=abc").BindForm(&bindUser{}))
+	assert.NotNil(mockCtx("POST", "/", "name=tom").BindForm(bindUser{}))
+}
+
+func TestCtx_BindValidate(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := mockCtx("POST", "/?page=0", "name=toolongname1&age=10")
+	err := ctx.Bind(&bindUser{})
+	assert.NotNil(err)
+	errs, ok := err.(gvalidator.Errors)
+	assert.True(ok)
+	assert.Len(errs, 2)
+	assert.Equal("name", errs[0].Field)
+	assert.Equal("max", errs[0].Rule)
+	assert.Equal("age", errs[1].Field)
+
+	err = mockCtx("GET", "/?page=-1", "").BindQuery(&bindPage{})
+	assert.NotNil(err)
+	assert.Equal("page must be at least 1", err.Error())
+
+	// an invalid tag returns an error instead of panic.
+	type badTag struct {
+		Name string `form:"name" validate:"required,max=abc"`
+	}
+	err = mockCtx("POST", "/", "name=tom").Bind(&badTag{})
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid param of rule max=abc")
+}
+
+func TestCtx_BindJSON(t *testing.T) {
+	assert := assert2.New(t)
+	r := httptest.NewRequest("POST", "/user/1?page=3", strings.NewReader(`{"name":"jack","age":30,"tags":["x"],"birthday":"2020-01-02T00:00:00Z"}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	ctx := NewCtxWithHTTP(httptest.NewRecorder(), r)
+	ctx.SetParam(gcore.Params{{Key: "id", Value: "1"}})
+	u := &bindUser{}
+	assert.Nil(ctx.Bind(u))
+	assert.Equal(int64(1), u.ID)
+	assert.Equal(3, u.Page)
+	assert.Equal("jack", u.Name)
+	assert.Equal(uint8(30), *u.Age)
+	assert.Equal([]string{"x"}, u.Tags)
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":""}`))
+	err := NewCtxWithHTTP(httptest.NewRecorder(), r).BindJSON(&bindUser{})
+	assert.IsType(gvalidator.Errors{}, err)
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{bad`))
+	assert.NotNil(NewCtxWithHTTP(httptest.NewRecorder(), r).BindJSON(&bindUser{}))
+}
+
+func TestCtx_BindMultipart(t *testing.T) {
+	assert := assert2.New(t)
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	w.WriteField("name", "jack")
+	fw, _ := w.CreateFormFile("avatar", "a.png")
+	fw.Write([]byte("avatar"))
+	for _, name := range []string{"1.png", "2.png"} {
+		fw, _ = w.CreateFormFile("photo", name)
+		fw.Write([]byte(name))
+	}
+	w.Close()
+	r := httptest.NewRequest("POST", "/", body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	ctx := NewCtxWithHTTP(httptest.NewRecorder(), r)
+	u := &bindUser{}
+	assert.Nil(ctx.Bind(u))
+	assert.Equal("jack", u.Name)
+	assert.Equal("a.png", u.Avatar.Filename)
+	assert.Len(u.Photos, 2)
+	assert.Equal("2.png", u.Photos[1].Filename)
+}
+
+func TestCtx_BindParams(t *testing.T) {
+	assert := assert2.New(t)
+	type T struct {
+		ID   int    `param:"id" validate:"required"`
+		Slug string `param:"slug"`
+	}
+	ctx := mockCtx("GET", "/", "")
+	ctx.SetParam(gcore.Params{{Key: "id", Value: "5"}, {Key: "slug", Value: "hello"}})
+	v := &T{}
+	assert.Nil(ctx.BindParams(v))
+	assert.Equal(T{ID: 5, Slug: "hello"}, *v)
+	assert.NotNil(mockCtx("GET", "/", "").BindParams(&T{}))
+}
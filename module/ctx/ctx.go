@@ -7,6 +7,7 @@ package gctx
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -21,6 +22,7 @@ import (
 	"time"
 
 	gcore "github.com/snail007/gmc/core"
+	gcookie "github.com/snail007/gmc/http/cookie"
 	gmap "github.com/snail007/gmc/util/map"
 	"github.com/snail007/gmc/util/paginator"
 
@@ -235,6 +237,17 @@ func (this *Ctx) SetRequest(request *http.Request) {
 	this.request = request
 }
 
+// Context returns the context of the request, it is canceled when the client
+// disconnects or the request is done, so it can be passed to the database
+// methods such as QueryContext to stop the query. If the request is nil,
+// context.Background() is returned.
+func (this *Ctx) Context() context.Context {
+	if this.request == nil {
+		return context.Background()
+	}
+	return this.request.Context()
+}
+
 func (this *Ctx) Response() http.ResponseWriter {
 	return this.response
 }
@@ -699,8 +712,36 @@ func (this *Ctx) Cookie(name string) string {
 	return val
 }
 
+// SetSignedCookie sets a cookie signed with the keys of [cookie] in config, see gcore.Cookies.SetSigned.
+func (this *Ctx) SetSignedCookie(name, value string, options ...*gcore.CookieOptions) {
+	gcookie.NewFromConfig(this).SetSigned(name, value, options...)
+}
+
+// SignedCookie returns the value of the cookie set by SetSignedCookie, empty string is returned
+// if the cookie is missing, expired or the signature is invalid.
+func (this *Ctx) SignedCookie(name string) string {
+	val, _ := gcookie.NewFromConfig(this).GetSigned(name)
+	return val
+}
+
+// SetEncryptedCookie sets a cookie encrypted with the keys of [cookie] in config, see gcore.Cookies.SetEncrypted.
+func (this *Ctx) SetEncryptedCookie(name, value string, options ...*gcore.CookieOptions) {
+	gcookie.NewFromConfig(this).SetEncrypted(name, value, options...)
+}
+
+// EncryptedCookie returns the value of the cookie set by SetEncryptedCookie, empty string is returned
+// if the cookie is missing, expired or can not be decrypted.
+func (this *Ctx) EncryptedCookie(name string) string {
+	val, _ := gcookie.NewFromConfig(this).GetEncrypted(name)
+	return val
+}
+
 // WriteFile writes the specified file into the body stream in an efficient way.
+// The ETag and Last-Modified of the file are set, the request with fresh cache is responded 304.
 func (this *Ctx) WriteFile(filepath string) {
+	if info, err := os.Stat(filepath); err == nil {
+		ghttputil.SetFileETag(this.response, info)
+	}
 	http.ServeFile(this.response, this.request, filepath)
 }
 
@@ -712,6 +753,11 @@ func (this *Ctx) WriteFileFromFS(filepath string, fs http.FileSystem) {
 
 	this.request.URL.Path = filepath
 
+	if f, err := fs.Open(filepath); err == nil {
+		info, _ := f.Stat()
+		f.Close()
+		ghttputil.SetFileETag(this.response, info)
+	}
 	http.FileServer(fs).ServeHTTP(this.response, this.request)
 }
 
@@ -719,7 +765,7 @@ func (this *Ctx) WriteFileFromFS(filepath string, fs http.FileSystem) {
 // On the client side, the file will typically be downloaded with the given filename
 func (this *Ctx) WriteFileAttachment(filepath, filename string) {
 	this.response.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-	http.ServeFile(this.response, this.request, filepath)
+	this.WriteFile(filepath)
 }
 
 // FullPath returns a matched route full path. For not found routes
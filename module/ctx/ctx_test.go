@@ -2,6 +2,7 @@ package gctx
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -13,6 +14,7 @@ import (
 
 	gcore "github.com/snail007/gmc/core"
 	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gconfig "github.com/snail007/gmc/module/config"
 	assert2 "github.com/stretchr/testify/assert"
 )
 
@@ -67,6 +69,31 @@ func TestCtx_Cookie(t *testing.T) {
 	}
 }
 
+func TestCtx_SignedCookie(t *testing.T) {
+	assert := assert2.New(t)
+	cfg := gconfig.New()
+	cfg.Set("cookie.keys", []string{"secret"})
+	cfg.Set("cookie.same_site", "strict")
+	ctx := mockCtx("GET", "/foo", "")
+	ctx.SetConfig(cfg)
+	ctx.SetSignedCookie("a", "1")
+	ctx.SetEncryptedCookie("b", "secret")
+	cookies := ctx.response.(*httptest.ResponseRecorder).Result().Cookies()
+	assert.Len(cookies, 2)
+	assert.Equal(http.SameSiteStrictMode, cookies[0].SameSite)
+	assert.NotContains(cookies[1].Value, "secret")
+
+	ctx1 := mockCtx("GET", "/foo", "")
+	ctx1.SetConfig(cfg)
+	for _, c := range cookies {
+		ctx1.request.AddCookie(c)
+	}
+	assert.Equal("1", ctx1.SignedCookie("a"))
+	assert.Equal("secret", ctx1.EncryptedCookie("b"))
+	assert.Equal("", ctx1.SignedCookie("b"))
+	assert.Equal("", ctx1.EncryptedCookie("a"))
+}
+
 func TestCtx_GETArray(t *testing.T) {
 	assert := assert2.New(t)
 	for _, v := range []struct {
@@ -482,3 +509,14 @@ func mockCtx(method, path string, body string) *Ctx {
 	c := NewCtxWithHTTP(w, r)
 	return c
 }
+
+func TestCtx_Context(t *testing.T) {
+	assert := assert2.New(t)
+	assert.Equal(context.Background(), NewCtx().Context())
+	r, _ := http.NewRequest("GET", "/foo", nil)
+	reqCtx, cancel := context.WithCancel(context.Background())
+	ctx := NewCtxWithHTTP(httptest.NewRecorder(), r.WithContext(reqCtx))
+	assert.Nil(ctx.Context().Err())
+	cancel()
+	assert.Equal(context.Canceled, ctx.Context().Err())
+}
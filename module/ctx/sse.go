@@ -0,0 +1,202 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gctx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+var (
+	// SSEHeartbeat is the default interval of heartbeat comments.
+	SSEHeartbeat = 15 * time.Second
+	// ErrSSENotSupported is returned by SSE if the response writer can not be flushed.
+	ErrSSENotSupported = errors.New("sse: streaming is not supported by the response writer")
+	// ErrSSEClosed is returned by the stream methods after the handler of SSE returned.
+	ErrSSEClosed = errors.New("sse: stream is closed")
+)
+
+// SSE starts a server-sent events stream, handler writes events by the stream,
+// the stream is closed after handler returned.
+// A heartbeat comment is sent every heartbeat interval to keep the connection alive,
+// default is SSEHeartbeat, 0 disables it.
+// Check stream.Done() to stop when the client disconnected.
+func (this *Ctx) SSE(handler func(stream gcore.SSEStream), heartbeat ...time.Duration) error {
+	flusher, ok := this.response.(http.Flusher)
+	if !ok {
+		return ErrSSENotSupported
+	}
+	interval := SSEHeartbeat
+	if len(heartbeat) > 0 {
+		interval = heartbeat[0]
+	}
+	h := this.response.Header()
+	h.Set("Content-Type", "text/event-stream; charset=utf-8")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// disable the buffering of nginx.
+	h.Set("X-Accel-Buffering", "no")
+	this.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s := &sseStream{
+		ctx:     this,
+		flusher: flusher,
+		done:    this.Context().Done(),
+		lastID:  this.Header("Last-Event-ID"),
+	}
+	if s.lastID == "" {
+		// EventSource polyfills can not set the header, they pass it by query string.
+		s.lastID = this.GET("lastEventId")
+	}
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	if interval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-s.done:
+					return
+				case <-t.C:
+					if s.Comment("heartbeat") != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+	defer func() {
+		close(stop)
+		wg.Wait()
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+	}()
+	handler(s)
+	return nil
+}
+
+type sseStream struct {
+	ctx     *Ctx
+	flusher http.Flusher
+	done    <-chan struct{}
+	lastID  string
+	mu      sync.Mutex
+	closed  bool
+	err     error
+}
+
+func (s *sseStream) Send(event gcore.SSEEvent) error {
+	var buf bytes.Buffer
+	if event.ID != "" {
+		writeSSEField(&buf, "id", event.ID)
+	}
+	if event.Event != "" {
+		writeSSEField(&buf, "event", event.Event)
+	}
+	if event.Retry > 0 {
+		writeSSEField(&buf, "retry", strconv.FormatInt(event.Retry.Milliseconds(), 10))
+	}
+	var data string
+	switch v := event.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	if event.Data != nil {
+		writeSSEField(&buf, "data", data)
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+func (s *sseStream) Event(event string, data interface{}) error {
+	return s.Send(gcore.SSEEvent{Event: event, Data: data})
+}
+
+func (s *sseStream) Data(data interface{}) error {
+	return s.Send(gcore.SSEEvent{Data: data})
+}
+
+func (s *sseStream) Comment(comment string) error {
+	var buf bytes.Buffer
+	for _, line := range splitSSELines(comment) {
+		buf.WriteString(": " + line + "\n")
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+// LastEventID returns the id of the last event received by the client before reconnecting.
+func (s *sseStream) LastEventID() string {
+	return s.lastID
+}
+
+// Done is closed when the client disconnected.
+func (s *sseStream) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *sseStream) write(b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSSEClosed
+	}
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.ctx.Context().Err(); err != nil {
+		s.err = err
+		return err
+	}
+	if _, err := s.ctx.Response().Write(b); err != nil {
+		s.err = err
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// writeSSEField writes a field, the value of multiple lines is written as multiple fields,
+// the line breaks in id and event are removed, they must be single line.
+func writeSSEField(buf *bytes.Buffer, name, value string) {
+	if name != "data" {
+		buf.WriteString(name + ": " + strings.Join(splitSSELines(value), "") + "\n")
+		return
+	}
+	for _, line := range splitSSELines(value) {
+		buf.WriteString("data: " + line + "\n")
+	}
+}
+
+func splitSSELines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gctx
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func TestCtx_SSE(t *testing.T) {
+	assert := assert2.New(t)
+	ctx := mockCtx("GET", "/events", "")
+	ctx.Request().Header.Set("Last-Event-ID", "7")
+	var stream gcore.SSEStream
+	err := ctx.SSE(func(s gcore.SSEStream) {
+		stream = s
+		assert.Equal("7", s.LastEventID())
+		assert.Nil(s.Send(gcore.SSEEvent{ID: "8", Event: "progress", Retry: 3 * time.Second, Data: "a\nb"}))
+		assert.Nil(s.Event("user", map[string]int{"id": 1}))
+		assert.Nil(s.Data([]byte("raw")))
+		assert.Nil(s.Comment("hi"))
+		assert.Nil(s.Send(gcore.SSEEvent{ID: "9\r\n"}))
+	}, 0)
+	assert.Nil(err)
+	assert.Equal(ErrSSEClosed, stream.Data("x"))
+	w := ctx.Response().(*httptest.ResponseRecorder)
+	assert.Equal("text/event-stream; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal("no-cache", w.Header().Get("Cache-Control"))
+	assert.True(w.Flushed)
+	assert.Equal("id: 8\nevent: progress\nretry: 3000\ndata: a\ndata: b\n\n"+
+		"event: user\ndata: {\"id\":1}\n\n"+
+		"data: raw\n\n"+
+		": hi\n\n"+
+		"id: 9\n\n", w.Body.String())
+
+	ctx = mockCtx("GET", "/events?lastEventId=5", "")
+	ctx.SSE(func(s gcore.SSEStream) {
+		assert.Equal("5", s.LastEventID())
+	})
+
+	ctx = NewCtxWithHTTP(struct{ http.ResponseWriter }{httptest.NewRecorder()}, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(ErrSSENotSupported, ctx.SSE(func(s gcore.SSEStream) {}))
+}
+
+func TestCtx_SSEDisconnect(t *testing.T) {
+	assert := assert2.New(t)
+	errCh := make(chan error, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := NewCtxWithHTTP(ghttputil.NewResponseWriter(w), r)
+		ctx.SSE(func(s gcore.SSEStream) {
+			s.Data("hello")
+			<-s.Done()
+			errCh <- s.Data("bye")
+		}, 20*time.Millisecond)
+	}))
+	defer s.Close()
+	resp, err := http.Get(s.URL)
+	assert.Nil(err)
+	r := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 4 {
+		line, err := r.ReadString('\n')
+		assert.Nil(err)
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal([]string{"data: hello", ": heartbeat", ": heartbeat", ": heartbeat"}, lines)
+	resp.Body.Close()
+	select {
+	case err = <-errCh:
+		assert.NotNil(err)
+	case <-time.After(time.Second):
+		t.Fatal("stream should stop after the client disconnected")
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+var (
+	errTagCacheStale     = errors.New("cached data is stale")
+	statementTablesRegex = regexp.MustCompile("(?i)\\b(?:FROM|JOIN)\\s+([`\"\\w.]+)")
+	tagVersionSeq        uint64
+	// txInvalidations keeps the tables written in the transactions started by Transaction,
+	// the key is *sql.Tx, the value is *txTables.
+	txInvalidations sync.Map
+)
+
+type txTables struct {
+	sync.Mutex
+	cache  gcore.DBCache
+	tables []string
+}
+
+type tagCacheItem struct {
+	Tags    []string
+	Version string
+	Data    []byte
+}
+
+// TagCache is a gcore.DBTagCache based on a gcore.Cache, such as the redis, memory and file
+// cache of gmc. Every tag has a version stored in the cache, the version is changed when
+// the tag is invalidated, the cached data is stale if the versions of its tags changed.
+type TagCache struct {
+	cache gcore.Cache
+	// TagPrefix is the key prefix of tag versions, default is gmc_db_tag_.
+	TagPrefix string
+	// TagTTL is the ttl of tag versions, the data cached longer than it is always stale,
+	// default is 7 days.
+	TagTTL time.Duration
+}
+
+// NewTagCache creates a TagCache based on cache.
+func NewTagCache(cache gcore.Cache) *TagCache {
+	return &TagCache{
+		cache:     cache,
+		TagPrefix: "gmc_db_tag_",
+		TagTTL:    time.Hour * 24 * 7,
+	}
+}
+
+// Get returns the cached data of key, error returned if the key not exists or the data is stale.
+func (c *TagCache) Get(key string) (data []byte, err error) {
+	v, err := c.cache.Get(key)
+	if err != nil {
+		return
+	}
+	item := new(tagCacheItem)
+	err = gob.NewDecoder(bytes.NewReader([]byte(v))).Decode(item)
+	if err != nil {
+		return
+	}
+	if len(item.Tags) > 0 {
+		var version string
+		version, err = c.TagsVersion(item.Tags)
+		if err != nil {
+			return
+		}
+		if version != item.Version {
+			return nil, errTagCacheStale
+		}
+	}
+	return item.Data, nil
+}
+
+// Set caches val without tags.
+func (c *TagCache) Set(key string, val []byte, expire uint) (err error) {
+	return c.set(key, &tagCacheItem{Data: val}, expire)
+}
+
+func (c *TagCache) SetWithTags(key string, val []byte, expire uint, tags []string, version string) (err error) {
+	return c.set(key, &tagCacheItem{Tags: tags, Version: version, Data: val}, expire)
+}
+
+func (c *TagCache) set(key string, item *tagCacheItem, expire uint) (err error) {
+	b := new(bytes.Buffer)
+	err = gob.NewEncoder(b).Encode(item)
+	if err != nil {
+		return
+	}
+	return c.cache.Set(key, b.String(), time.Second*time.Duration(expire))
+}
+
+// TagsVersion returns the joined versions of tags, the version of a tag is created if it not exists.
+func (c *TagCache) TagsVersion(tags []string) (version string, err error) {
+	versions := make([]string, len(tags))
+	for i, tag := range tags {
+		v, e := c.cache.Get(c.TagPrefix + tag)
+		if e != nil || v == "" {
+			v = newTagVersion()
+			err = c.cache.Set(c.TagPrefix+tag, v, c.TagTTL)
+			if err != nil {
+				return
+			}
+		}
+		versions[i] = v
+	}
+	return strings.Join(versions, ","), nil
+}
+
+// InvalidateTags changes the versions of tags, then the data cached with the tags becomes stale.
+func (c *TagCache) InvalidateTags(tags ...string) (err error) {
+	for _, tag := range tags {
+		err = c.cache.Set(c.TagPrefix+tag, newTagVersion(), c.TagTTL)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func newTagVersion() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + "." +
+		strconv.FormatUint(atomic.AddUint64(&tagVersionSeq, 1), 36)
+}
+
+// queryCacheTags returns the tables read by the statement and the current version of them
+// if cache is a gcore.DBTagCache.
+func queryCacheTags(cache gcore.DBCache, sqlStr string) (tags []string, version string, err error) {
+	c, ok := cache.(gcore.DBTagCache)
+	if !ok {
+		return
+	}
+	tags = statementTables(sqlStr)
+	if len(tags) == 0 {
+		return
+	}
+	version, err = c.TagsVersion(tags)
+	return
+}
+
+func setQueryCache(cache gcore.DBCache, key string, val []byte, expire uint, tags []string, version string) (err error) {
+	if c, ok := cache.(gcore.DBTagCache); ok && len(tags) > 0 {
+		return c.SetWithTags(key, val, expire, tags, version)
+	}
+	return cache.Set(key, val, expire)
+}
+
+// invalidateQueryCache invalidates the cached queries tagged with the table written by the statement.
+// If the statement is executed in a transaction started by Transaction, the invalidation is delayed
+// until the transaction committed, otherwise the rows not committed may be cached with the new version.
+// The error of cache is logged, it does not fail the statement executed successfully.
+func invalidateQueryCache(cache gcore.DBCache, preparer sqlPreparer, sqlStr string) {
+	if _, ok := cache.(gcore.DBTagCache); !ok {
+		return
+	}
+	switch statementType(sqlStr) {
+	case "INSERT", "UPDATE", "DELETE", "REPLACE":
+	default:
+		return
+	}
+	table := statementTable(sqlStr)
+	if table == "" {
+		return
+	}
+	if tx, ok := preparer.(*sql.Tx); ok {
+		if v, ok := txInvalidations.Load(tx); ok {
+			t := v.(*txTables)
+			t.Lock()
+			t.cache = cache
+			t.tables = append(t.tables, table)
+			t.Unlock()
+			return
+		}
+	}
+	invalidateTables(cache, table)
+}
+
+func invalidateTables(cache gcore.DBCache, tables ...string) {
+	c, ok := cache.(gcore.DBTagCache)
+	if !ok || len(tables) == 0 {
+		return
+	}
+	if err := c.InvalidateTags(tables...); err != nil && gcore.ProviderLogger() != nil {
+		gcore.ProviderLogger()(nil, "[db]").Warnf("invalidate query cache of tables %v fail, error: %s", tables, err)
+	}
+}
+
+// watchTxInvalidations starts to queue the invalidations of the statements executed in tx.
+func watchTxInvalidations(tx *sql.Tx) {
+	txInvalidations.Store(tx, &txTables{})
+}
+
+// flushTxInvalidations invalidates the queued tables if committed is true, and stops queuing.
+func flushTxInvalidations(tx *sql.Tx, committed bool) {
+	v, ok := txInvalidations.LoadAndDelete(tx)
+	if !ok || !committed {
+		return
+	}
+	t := v.(*txTables)
+	t.Lock()
+	defer t.Unlock()
+	invalidateTables(t.cache, t.tables...)
+}
+
+// statementTables returns the tables after FROM and JOIN of the statement, without duplicate.
+func statementTables(sqlStr string) (tables []string) {
+	exists := map[string]bool{}
+	for _, m := range statementTablesRegex.FindAllStringSubmatch(sqlStr, -1) {
+		table := strings.NewReplacer("`", "", `"`, "").Replace(m[1])
+		if table == "" || exists[table] {
+			continue
+		}
+		exists[table] = true
+		tables = append(tables, table)
+	}
+	return
+}
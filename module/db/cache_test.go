@@ -0,0 +1,198 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gcache "github.com/snail007/gmc/module/cache"
+	gmap "github.com/snail007/gmc/util/map"
+	"github.com/stretchr/testify/assert"
+)
+
+func testTagCacheBackends(t *testing.T) map[string]gcore.Cache {
+	cfg := gcache.NewFileCacheConfig()
+	cfg.Dir = t.TempDir()
+	file, err := gcache.NewFileCache(cfg)
+	assert.Nil(t, err)
+	return map[string]gcore.Cache{
+		"memory": gcache.NewMemCache(gcache.NewMemCacheConfig()),
+		"file":   file,
+	}
+}
+
+func TestTagCache(t *testing.T) {
+	for name, backend := range testTagCacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			c := NewTagCache(backend)
+			assert.Nil(c.Set("plain", []byte("a"), 60))
+			d, err := c.Get("plain")
+			assert.Nil(err)
+			assert.Equal([]byte("a"), d)
+
+			version, err := c.TagsVersion([]string{"user", "group"})
+			assert.Nil(err)
+			assert.Nil(c.SetWithTags("tagged", []byte("b"), 60, []string{"user", "group"}, version))
+			d, err = c.Get("tagged")
+			assert.Nil(err)
+			assert.Equal([]byte("b"), d)
+
+			assert.Nil(c.InvalidateTags("other"))
+			_, err = c.Get("tagged")
+			assert.Nil(err)
+			assert.Nil(c.InvalidateTags("group"))
+			_, err = c.Get("tagged")
+			assert.Equal(errTagCacheStale, err)
+			d, err = c.Get("plain")
+			assert.Nil(err)
+			assert.Equal([]byte("a"), d)
+
+			// stale if the tag version is expired
+			c.TagTTL = time.Second
+			version, _ = c.TagsVersion([]string{"expired"})
+			c.SetWithTags("expired", []byte("c"), 60, []string{"expired"}, version)
+			backend.Del(c.TagPrefix + "expired")
+			_, err = c.Get("expired")
+			assert.Equal(errTagCacheStale, err)
+		})
+	}
+}
+
+func TestTagCache_Query(t *testing.T) {
+	for name, backend := range testTagCacheBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			group := NewSQLite3DBGroupCache("default", NewTagCache(backend))
+			group.Regist("default", NewSQLite3DBConfigWith("test_cache.db", OpenModeReadWriteCreate, CacheModeShared, SyncModeOff))
+			db := group.DB()
+			defer os.Remove("test_cache.db")
+			db.ExecSQL("drop table cache_user")
+			db.ExecSQL("drop table cache_group")
+			db.ExecSQL("create table cache_user(id integer primary key autoincrement, name varchar(20), gid int)")
+			db.ExecSQL("create table cache_group(id integer primary key autoincrement, name varchar(20))")
+			db.ExecSQL("insert into cache_group(name) values ('admin')")
+
+			count := func(key string) int {
+				rs, err := db.Query(db.AR().From("cache_user").Cache(key, 60))
+				assert.Nil(err)
+				return rs.Len()
+			}
+			join := func() int {
+				rs, err := db.Query(db.AR().Select("u.*").FromAs("cache_user", "u").
+					Join("cache_group", "g", "u.gid=g.id", "LEFT").Cache("join", 60))
+				assert.Nil(err)
+				return rs.Len()
+			}
+			assert.Equal(0, count("user"))
+			assert.Equal(0, join())
+
+			_, err := db.Exec(db.AR().Insert("cache_user", gmap.M{"name": "a", "gid": 1}))
+			assert.Nil(err)
+			assert.Equal(1, count("user"))
+			assert.Equal(1, join())
+
+			_, err = db.ExecSQL("insert into cache_user(name, gid) values ('b', 1)")
+			assert.Nil(err)
+			assert.Equal(2, count("user"))
+			assert.Equal(2, join())
+
+			// insert bypasses the Database is invisible until the cache expired
+			pool := db.(*SQLite3DB).ConnPool
+			pool.Exec("insert into cache_user(name, gid) values ('c', 1)")
+			assert.Equal(2, count("user"))
+
+			_, err = db.Exec(db.AR().Delete("cache_user", gmap.M{"name": "c"}))
+			assert.Nil(err)
+			assert.Equal(2, count("user"))
+
+			// write to the joined table
+			_, err = db.Exec(db.AR().Update("cache_group", gmap.M{"name": "root"}, gmap.M{"id": 1}))
+			assert.Nil(err)
+			pool.Exec("delete from cache_user where name='b'")
+			assert.Equal(1, join())
+			assert.Equal(2, count("user"))
+
+			_, err = db.Exec(db.AR().Replace("cache_user", gmap.M{"id": 1, "name": "a", "gid": 1}))
+			assert.Nil(err)
+			assert.Equal(1, count("user"))
+			db.ExecSQL("drop table cache_user")
+			db.ExecSQL("drop table cache_group")
+		})
+	}
+}
+
+// failTagCache is a tag cache whose invalidation always fails.
+type failTagCache struct {
+	*TagCache
+}
+
+func (c *failTagCache) InvalidateTags(tags ...string) error {
+	return errors.New("backend down")
+}
+
+func TestTagCache_Transaction(t *testing.T) {
+	assert := assert.New(t)
+	cache := NewTagCache(gcache.NewMemCache(gcache.NewMemCacheConfig()))
+	group := NewSQLite3DBGroupCache("default", cache)
+	group.Regist("default", NewSQLite3DBConfigWith("test_cache_tx.db", OpenModeReadWriteCreate, CacheModeShared, SyncModeOff))
+	db := group.DB()
+	defer os.Remove("test_cache_tx.db")
+	db.ExecSQL("drop table cache_tx")
+	db.ExecSQL("create table cache_tx(id integer primary key autoincrement, name varchar(20))")
+	version := func() string {
+		v, err := cache.TagsVersion([]string{"cache_tx"})
+		assert.Nil(err)
+		return v
+	}
+	v0 := version()
+
+	// the invalidation is delayed until committed
+	err := db.Transaction(func(tx gcore.Tx) error {
+		_, err := tx.Exec(tx.AR().Insert("cache_tx", gmap.M{"name": "a"}))
+		assert.Nil(err)
+		assert.Equal(v0, version())
+		return nil
+	})
+	assert.Nil(err)
+	v1 := version()
+	assert.NotEqual(v0, v1)
+
+	// the invalidation is dropped when rolled back
+	err = db.Transaction(func(tx gcore.Tx) error {
+		tx.Exec(tx.AR().Insert("cache_tx", gmap.M{"name": "b"}))
+		return errors.New("rollback")
+	})
+	assert.NotNil(err)
+	assert.Equal(v1, version())
+
+	// the error of cache does not fail the statement
+	group = NewSQLite3DBGroupCache("default", &failTagCache{cache})
+	group.Regist("default", NewSQLite3DBConfigWith("test_cache_tx.db", OpenModeReadWriteCreate, CacheModeShared, SyncModeOff))
+	db = group.DB()
+	_, err = db.Exec(db.AR().Insert("cache_tx", gmap.M{"name": "c"}))
+	assert.Nil(err)
+	err = db.Transaction(func(tx gcore.Tx) error {
+		_, err := tx.Exec(tx.AR().Insert("cache_tx", gmap.M{"name": "d"}))
+		return err
+	})
+	assert.Nil(err)
+	rs, err := db.QuerySQL("select * from cache_tx")
+	assert.Nil(err)
+	assert.Equal(3, rs.Len())
+	db.ExecSQL("drop table cache_tx")
+}
+
+func TestStatementTables(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{"user", "group"}, statementTables("SELECT `u`.* \nFROM `user` AS `u` \nLEFT JOIN `group` `g` ON `u`.`gid`=`g`.`id`"))
+	assert.Equal([]string{"user", "log"}, statementTables(`select * from "user" where id in (select uid from log) or id in (select uid from "user")`))
+	assert.Nil(statementTables("select 1"))
+}
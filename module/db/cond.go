@@ -0,0 +1,208 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"reflect"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// Cond is a gcore.DBCond built by a function, the conditions are passed to
+// ActiveRecord.WhereCond, such as:
+//
+//	ar.WhereCond(gdb.And(gdb.Eq("status", 1), gdb.Or(gdb.Like("name", "a%"), gdb.IsNull("name"))))
+type Cond func(quote func(column string) string) (sqlStr string, args []interface{})
+
+func (c Cond) Build(quote func(column string) string) (sqlStr string, args []interface{}) {
+	return c(quote)
+}
+
+// And joins the conditions by AND, nil conditions are ignored, it is always true if conditions is empty.
+func And(conds ...gcore.DBCond) Cond {
+	return group("AND", "1=1", conds)
+}
+
+// Or joins the conditions by OR, nil conditions are ignored, it is always false if conditions is empty.
+func Or(conds ...gcore.DBCond) Cond {
+	return group("OR", "1=0", conds)
+}
+
+func group(op, empty string, conds []gcore.DBCond) Cond {
+	return func(quote func(column string) string) (sqlStr string, args []interface{}) {
+		var items []string
+		for _, c := range conds {
+			if v, ok := c.(Cond); c == nil || ok && v == nil {
+				continue
+			}
+			s, a := c.Build(quote)
+			items = append(items, s)
+			args = append(args, a...)
+		}
+		switch len(items) {
+		case 0:
+			return empty, nil
+		case 1:
+			return items[0], args
+		}
+		return "(" + strings.Join(items, " "+op+" ") + ")", args
+	}
+}
+
+// Not negates the condition.
+func Not(cond gcore.DBCond) Cond {
+	return func(quote func(column string) string) (sqlStr string, args []interface{}) {
+		sqlStr, args = cond.Build(quote)
+		return "NOT (" + sqlStr + ")", args
+	}
+}
+
+// Op is the condition column op value, such as Op("age", ">=", 18).
+func Op(column, op string, value interface{}) Cond {
+	return func(quote func(column string) string) (sqlStr string, args []interface{}) {
+		return quote(column) + " " + op + " ?", []interface{}{value}
+	}
+}
+
+// Eq is the condition column = value, column IS NULL if value is nil.
+func Eq(column string, value interface{}) Cond {
+	if value == nil {
+		return IsNull(column)
+	}
+	return Op(column, "=", value)
+}
+
+// Neq is the condition column <> value, column IS NOT NULL if value is nil.
+func Neq(column string, value interface{}) Cond {
+	if value == nil {
+		return IsNotNull(column)
+	}
+	return Op(column, "<>", value)
+}
+
+// Gt is the condition column > value.
+func Gt(column string, value interface{}) Cond {
+	return Op(column, ">", value)
+}
+
+// Gte is the condition column >= value.
+func Gte(column string, value interface{}) Cond {
+	return Op(column, ">=", value)
+}
+
+// Lt is the condition column < value.
+func Lt(column string, value interface{}) Cond {
+	return Op(column, "<", value)
+}
+
+// Lte is the condition column <= value.
+func Lte(column string, value interface{}) Cond {
+	return Op(column, "<=", value)
+}
+
+// Like is the condition column LIKE pattern.
+func Like(column, pattern string) Cond {
+	return Op(column, "LIKE", pattern)
+}
+
+// NotLike is the condition column NOT LIKE pattern.
+func NotLike(column, pattern string) Cond {
+	return Op(column, "NOT LIKE", pattern)
+}
+
+// IsNull is the condition column IS NULL.
+func IsNull(column string) Cond {
+	return func(quote func(column string) string) (sqlStr string, args []interface{}) {
+		return quote(column) + " IS NULL", nil
+	}
+}
+
+// IsNotNull is the condition column IS NOT NULL.
+func IsNotNull(column string) Cond {
+	return func(quote func(column string) string) (sqlStr string, args []interface{}) {
+		return quote(column) + " IS NOT NULL", nil
+	}
+}
+
+// Between is the condition column BETWEEN start AND end.
+func Between(column string, start, end interface{}) Cond {
+	return func(quote func(column string) string) (sqlStr string, args []interface{}) {
+		return quote(column) + " BETWEEN ? AND ?", []interface{}{start, end}
+	}
+}
+
+// NotBetween is the condition column NOT BETWEEN start AND end.
+func NotBetween(column string, start, end interface{}) Cond {
+	return func(quote func(column string) string) (sqlStr string, args []interface{}) {
+		return quote(column) + " NOT BETWEEN ? AND ?", []interface{}{start, end}
+	}
+}
+
+// In is the condition column IN (values), values can be a slice, an array or a subquery
+// gcore.ActiveRecord. It is always false if values is an empty slice.
+func In(column string, values interface{}) Cond {
+	return in(column, "IN", "1=0", values)
+}
+
+// NotIn is the condition column NOT IN (values), values can be a slice, an array or a
+// subquery gcore.ActiveRecord. It is always true if values is an empty slice.
+func NotIn(column string, values interface{}) Cond {
+	return in(column, "NOT IN", "1=1", values)
+}
+
+func in(column, op, empty string, values interface{}) Cond {
+	return func(quote func(column string) string) (sqlStr string, args []interface{}) {
+		if sub, ok := values.(gcore.ActiveRecord); ok {
+			sqlStr, args = subQuery(sub)
+			return quote(column) + " " + op + " (" + sqlStr + ")", args
+		}
+		v := reflect.ValueOf(values)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return quote(column) + " " + op + " (?)", []interface{}{values}
+		}
+		if v.Len() == 0 {
+			return empty, nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			args = append(args, v.Index(i).Interface())
+		}
+		return quote(column) + " " + op + " (" + strings.TrimSuffix(strings.Repeat("?,", v.Len()), ",") + ")", args
+	}
+}
+
+// Exists is the condition EXISTS (subquery).
+func Exists(sub gcore.ActiveRecord) Cond {
+	return func(quote func(column string) string) (sqlStr string, args []interface{}) {
+		sqlStr, args = subQuery(sub)
+		return "EXISTS (" + sqlStr + ")", args
+	}
+}
+
+// NotExists is the condition NOT EXISTS (subquery).
+func NotExists(sub gcore.ActiveRecord) Cond {
+	return func(quote func(column string) string) (sqlStr string, args []interface{}) {
+		sqlStr, args = subQuery(sub)
+		return "NOT EXISTS (" + sqlStr + ")", args
+	}
+}
+
+// Expr is a raw SQL fragment with ? placeholders and args, the fragment is wrapped by parentheses.
+func Expr(sqlStr string, args ...interface{}) Cond {
+	return func(quote func(column string) string) (string, []interface{}) {
+		return "(" + sqlStr + ")", args
+	}
+}
+
+// subQuery returns the SQL with ? placeholders and the args of the ActiveRecord.
+func subQuery(ar gcore.ActiveRecord) (sqlStr string, args []interface{}) {
+	if v, ok := ar.(interface{ placeholderSQL() string }); ok {
+		sqlStr = v.placeholderSQL()
+	} else {
+		sqlStr = ar.SQL()
+	}
+	return strings.TrimSpace(sqlStr), ar.Values()
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"strings"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	gmap "github.com/snail007/gmc/util/map"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhereCond(t *testing.T) {
+	assert := assert.New(t)
+	sub := ar().From("order").Select("uid").WhereCond(Gt("amount", 100))
+	_ar := ar().From("user").
+		Where(gmap.M{"status": 1}).
+		WhereCond(And(
+			Or(Like("name", "a%"), IsNull("u.name"), nil),
+			Not(Between("age", 18, 30)),
+			In("id", sub),
+			In("level", []int{1, 2}),
+			Expr("score > ? OR vip = ?", 60, true),
+		))
+	want := "SELECT * \nFROM `user` \nWHERE `status` = ?   AND ((`name` LIKE ? OR `u`.`name` IS NULL) AND NOT (`age` BETWEEN ? AND ?) AND " +
+		"`id` IN (SELECT `uid` \nFROM `order` \nWHERE `amount` > ?) AND `level` IN (?,?) AND (score > ? OR vip = ?))"
+	assert.Equal(want, strings.TrimSpace(_ar.SQL()))
+	assert.Equal([]interface{}{1, "a%", 18, 30, 100, 1, 2, 60, true}, _ar.Values())
+}
+
+func TestWhereCond_Single(t *testing.T) {
+	assert := assert.New(t)
+	_ar := ar().From("user").WhereCond(Eq("id", 1)).WhereCond(Neq("name", nil))
+	assert.Equal("SELECT * \nFROM `user` \nWHERE `id` = ?   AND `name` IS NOT NULL", strings.TrimSpace(_ar.SQL()))
+	assert.Equal([]interface{}{1}, _ar.Values())
+
+	_ar = ar().From("user").WhereCond(And()).WhereCond(Or()).WhereCond(In("id", []int{})).WhereCond(NotIn("id", []string{}))
+	assert.Equal("SELECT * \nFROM `user` \nWHERE 1=1   AND 1=0   AND 1=0   AND 1=1", strings.TrimSpace(_ar.SQL()))
+
+	_ar = ar().From("user").WhereCond(Exists(ar().From("order").WhereCond(Expr("order.uid=user.id"))))
+	assert.Equal("SELECT * \nFROM `user` \nWHERE EXISTS (SELECT * \nFROM `order` \nWHERE (order.uid=user.id))", strings.TrimSpace(_ar.SQL()))
+}
+
+func TestWhereCond_Deterministic(t *testing.T) {
+	assert := assert.New(t)
+	build := func() string {
+		return arSqlite3().From("user").WhereCond(Or(Eq("b", 1), Eq("a", 2), Lte("c", 3), Gte("d", 4), Lt("e", 5))).SQL()
+	}
+	want := build()
+	for i := 0; i < 20; i++ {
+		assert.Equal(want, build())
+	}
+	assert.Contains(want, "(`b` = ? OR `a` = ? OR `c` <= ? OR `d` >= ? OR `e` < ?)")
+}
+
+func TestWhereCond_Postgres(t *testing.T) {
+	assert := assert.New(t)
+	sub := arPostgres().From("order").Select("uid").WhereCond(Gt("amount", 100))
+	_ar := arPostgres().From("user").Where(gmap.M{"status": 1}).WhereCond(And(In("id", sub), NotLike("name", "a%")))
+	want := "SELECT * \nFROM \"user\" \nWHERE \"status\" = $1   AND (\"id\" IN (SELECT \"uid\" \nFROM \"order\" \nWHERE \"amount\" > $2) AND \"name\" NOT LIKE $3)"
+	assert.Equal(want, strings.TrimSpace(_ar.SQL()))
+	assert.Equal([]interface{}{1, 100, "a%"}, _ar.Values())
+}
+
+func TestWhereCond_SQLite3(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	db.ExecSQL("drop table cond_user")
+	db.ExecSQL("create table cond_user(id integer primary key autoincrement, name varchar(20), age int)")
+	defer db.ExecSQL("drop table cond_user")
+	for i, name := range []string{"a1", "a2", "b1", "b2"} {
+		db.Exec(db.AR().Insert("cond_user", gmap.M{"name": name, "age": 10 * (i + 1)}))
+	}
+	db.Exec(db.AR().Insert("cond_user", gmap.M{"name": nil, "age": 50}))
+	query := func(cond gcore.DBCond) []string {
+		rs, err := db.Query(db.AR().From("cond_user").WhereCond(cond).OrderBy("id", "asc"))
+		assert.Nil(err)
+		return rs.Values("id")
+	}
+	assert.Equal([]string{"1", "2"}, query(Like("name", "a%")))
+	assert.Equal([]string{"2", "3", "5"}, query(Or(Between("age", 15, 30), IsNull("name"))))
+	assert.Equal([]string{"1", "4", "5"}, query(Not(Between("age", 15, 30))))
+	assert.Equal([]string{"3", "4"}, query(In("id", db.AR().From("cond_user").Select("id").WhereCond(Like("name", "b%")))))
+	assert.Equal([]string{"1", "2", "5"}, query(NotIn("id", db.AR().From("cond_user").Select("id").WhereCond(Like("name", "b%")))))
+	assert.Equal([]string{"4"}, query(And(Expr("age > ?", 30), IsNotNull("name"))))
+}
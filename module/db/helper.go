@@ -6,20 +6,26 @@
 package gdb
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"reflect"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/snail007/gmc/core"
+	makeutil "github.com/snail007/gmc/internal/util/make"
 	"github.com/snail007/gmc/util/cast"
 	gmap "github.com/snail007/gmc/util/map"
 )
 
 var (
-	groupMySQL   = NewMySQLDBGroup("default")
-	groupSQLite3 = NewSQLite3DBGroup("default")
-	cfg          gcore.Config
-	defaultDB    string
+	groupMySQL    = NewMySQLDBGroup("default")
+	groupSQLite3  = NewSQLite3DBGroup("default")
+	groupPostgres = NewPostgresDBGroup("default")
+	cfg           gcore.Config
+	defaultDB     string
 )
 
 type M map[string]interface{}
@@ -39,6 +45,18 @@ func InitFromFile(cfgFile string) (err error) {
 func Init(cfg0 gcore.Config) (err error) {
 	defaultDB = cfg0.GetString("database.default")
 	cfg = cfg0
+	// slow query log of all databases, threshold is in milliseconds
+	addSlowQueryHook := func(db gcore.Database) {
+		threshold := cfg.GetInt("database.slow_query_threshold")
+		if threshold <= 0 || gcore.ProviderLogger() == nil {
+			return
+		}
+		h := NewSlowQueryHook(gcore.ProviderLogger()(nil, "[db]"), time.Duration(threshold)*time.Millisecond)
+		if v, ok := db.(*MySQLDB); ok && cfg.GetBool("database.slow_query_explain") {
+			h.Explain(v)
+		}
+		db.AddHook(h)
+	}
 	for k, v := range cfg.Sub("database").AllSettings() {
 		if _, ok := v.([]interface{}); !ok {
 			continue
@@ -69,10 +87,14 @@ func Init(cfg0 gcore.Config) (err error) {
 					WriteTimeout:             gcast.ToInt(vvv["writetimeout"]),
 					MaxIdleConns:             gcast.ToInt(vvv["maxidle"]),
 					MaxOpenConns:             gcast.ToInt(vvv["maxconns"]),
+					Replicas:                 parseMySQLReplicas(vvv["replicas"]),
+					ReplicaStrategy:          gcast.ToString(vvv["replica_strategy"]),
+					ReplicaCheckInterval:     gcast.ToInt(vvv["replica_check_interval"]),
 				})
 				if err != nil {
 					return
 				}
+				addSlowQueryHook(groupMySQL.DB(id))
 			} else if k == "sqlite3" {
 				db := groupSQLite3.DB(id)
 				if db != nil {
@@ -89,9 +111,78 @@ func Init(cfg0 gcore.Config) (err error) {
 				if err != nil {
 					return
 				}
+				addSlowQueryHook(groupSQLite3.DB(id))
+			} else if k == "postgres" {
+				db := groupPostgres.DB(id)
+				if db != nil {
+					return
+				}
+				err = groupPostgres.Regist(id, PostgresDBConfig{
+					Host:                     gcast.ToString(vvv["host"]),
+					Port:                     gcast.ToInt(vvv["port"]),
+					Database:                 gcast.ToString(vvv["database"]),
+					Username:                 gcast.ToString(vvv["username"]),
+					Password:                 gcast.ToString(vvv["password"]),
+					Schema:                   gcast.ToString(vvv["schema"]),
+					SSLMode:                  gcast.ToString(vvv["sslmode"]),
+					TablePrefix:              gcast.ToString(vvv["prefix"]),
+					TablePrefixSQLIdentifier: gcast.ToString(vvv["prefix_sql_holder"]),
+					Timeout:                  gcast.ToInt(vvv["timeout"]),
+					MaxIdleConns:             gcast.ToInt(vvv["maxidle"]),
+					MaxOpenConns:             gcast.ToInt(vvv["maxconns"]),
+					PrimaryKey:               postgresPrimaryKey(vvv["primary_key"]),
+				})
+				if err != nil {
+					return
+				}
+				addSlowQueryHook(groupPostgres.DB(id))
 			}
 		}
 	}
+	// apply the pending migrations of default database
+	if dir := cfg.GetString("database.migrate_dir"); dir != "" {
+		db := DB()
+		if db == nil {
+			return fmt.Errorf("migrate fail, default database [%s] not found", defaultDB)
+		}
+		var m *Migrator
+		m, err = NewMigrator(db, dir)
+		if err != nil {
+			return
+		}
+		if table := cfg.GetString("database.migrate_table"); table != "" {
+			m.SetTable(table)
+		}
+		err = m.Up()
+	}
+	return
+}
+
+// parseMySQLReplicas parses the [[database.mysql.replicas]] of a mysql configuration.
+func parseMySQLReplicas(v interface{}) (replicas []MySQLReplicaConfig) {
+	var items []map[string]interface{}
+	switch vv := v.(type) {
+	case []map[string]interface{}:
+		items = vv
+	case []interface{}:
+		for _, item := range vv {
+			if m, ok := item.(map[string]interface{}); ok {
+				items = append(items, m)
+			}
+		}
+	}
+	for _, item := range items {
+		if _, ok := item["enable"]; ok && !gcast.ToBool(item["enable"]) {
+			continue
+		}
+		replicas = append(replicas, MySQLReplicaConfig{
+			Host:     gcast.ToString(item["host"]),
+			Port:     gcast.ToInt(item["port"]),
+			Username: gcast.ToString(item["username"]),
+			Password: gcast.ToString(item["password"]),
+			Weight:   gcast.ToInt(item["weight"]),
+		})
+	}
 	return
 }
 
@@ -101,6 +192,21 @@ func DB(id ...string) gcore.Database {
 		return DBMySQL(id...)
 	case "sqlite3":
 		return DBSQLite3(id...)
+	case "postgres":
+		return DBPostgres(id...)
+	}
+	return nil
+}
+
+// Group returns the database group of the default driver, nil if the default driver is unknown.
+func Group() gcore.DatabaseGroup {
+	switch defaultDB {
+	case "mysql":
+		return groupMySQL
+	case "sqlite3":
+		return groupSQLite3
+	case "postgres":
+		return groupPostgres
 	}
 	return nil
 }
@@ -123,6 +229,75 @@ func DBSQLite3(id ...string) *SQLite3DB {
 	return groupSQLite3.DB(id...).(*SQLite3DB)
 }
 
+//DBPostgres acquires a postgres db object associated the id, id default is : `default`
+func DBPostgres(id ...string) *PostgresDB {
+	// no postgres database enabled, just return nil
+	if len(groupPostgres.dbGroup) == 0 {
+		return nil
+	}
+	return groupPostgres.DB(id...).(*PostgresDB)
+}
+
+// sqlPreparer is implemented by both *sql.DB and *sql.Tx.
+type sqlPreparer interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// queryRows executes the query by the preparer with hooks, and scans all rows into raw rows.
+func queryRows(ctx context.Context, hooks *dbHooks, preparer sqlPreparer, sqlStr string, values ...interface{}) (results []map[string][]byte, err error) {
+	ctx, event := hooks.before(ctx, preparer, sqlStr, values)
+	defer func() {
+		hooks.after(ctx, event, int64(len(results)), err)
+	}()
+	var stmt *sql.Stmt
+	stmt, err = preparer.PrepareContext(ctx, sqlStr)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.QueryContext(ctx, values...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	cols, e := rows.Columns()
+	if e != nil {
+		return nil, e
+	}
+	closCnt := len(cols)
+
+	// scans := make([]interface{},closCnt)
+	var scans []interface{}
+	scans = makeutil.GetX(scans, uint64(len(cols)), func() interface{} {
+		a := make([]interface{}, closCnt)
+		for i := 0; i < closCnt; i++ {
+			a[i] = new([]byte)
+		}
+		return a
+	}).([]interface{})
+	defer func() {
+		for i := 0; i < closCnt; i++ {
+			scans[i] = new([]byte)
+		}
+		makeutil.PutX(scans, uint64(len(cols)))
+	}()
+
+	for rows.Next() {
+		err = rows.Scan(scans...)
+		if err != nil {
+			return
+		}
+		row := map[string][]byte{}
+		for i := range cols {
+			row[cols[i]] = *(scans[i].(*[]byte))
+		}
+		results = append(results, row)
+	}
+	err = rows.Err()
+	return
+}
+
 func isArray(v interface{}) bool {
 	if v == nil {
 		return false
@@ -159,3 +334,11 @@ func sortMap(data gmap.M, asc bool) []map[string]interface{} {
 	}
 	return m
 }
+
+// postgresPrimaryKey returns the primary_key of [[database.postgres]], default is id.
+func postgresPrimaryKey(v interface{}) string {
+	if pk := gcast.ToString(v); pk != "" {
+		return pk
+	}
+	return "id"
+}
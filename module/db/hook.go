@@ -0,0 +1,280 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// dbHooks is the hooks of a Database, it is nil safe.
+type dbHooks struct {
+	driver string
+	hooks  []gcore.DBHook
+	lock   sync.RWMutex
+}
+
+func newDBHooks(driver string) *dbHooks {
+	return &dbHooks{driver: driver}
+}
+
+func (h *dbHooks) add(hook gcore.DBHook) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.hooks = append(h.hooks, hook)
+}
+
+func (h *dbHooks) list() []gcore.DBHook {
+	if h == nil {
+		return nil
+	}
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.hooks
+}
+
+// before calls Before of all hooks, the returned event must be passed to after.
+func (h *dbHooks) before(ctx context.Context, preparer sqlPreparer, sqlStr string, args []interface{}) (context.Context, *gcore.DBQueryEvent) {
+	hooks := h.list()
+	if len(hooks) == 0 {
+		return ctx, nil
+	}
+	_, inTx := preparer.(*sql.Tx)
+	e := &gcore.DBQueryEvent{
+		Driver: h.driver,
+		SQL:    sqlStr,
+		Args:   args,
+		InTx:   inTx,
+		Start:  time.Now(),
+	}
+	for _, hook := range hooks {
+		ctx = hook.Before(ctx, e)
+	}
+	return ctx, e
+}
+
+func (h *dbHooks) after(ctx context.Context, e *gcore.DBQueryEvent, rowsAffected int64, err error) {
+	if e == nil {
+		return
+	}
+	e.Duration = time.Since(e.Start)
+	e.RowsAffected = rowsAffected
+	e.Err = err
+	for _, hook := range h.list() {
+		hook.After(ctx, e)
+	}
+}
+
+// execStmt prepares and executes the statement by the preparer with hooks.
+func execStmt(ctx context.Context, hooks *dbHooks, preparer sqlPreparer, sqlStr string, values ...interface{}) (result sql.Result, err error) {
+	ctx, e := hooks.before(ctx, preparer, sqlStr, values)
+	defer func() {
+		var rowsAffected int64
+		if err == nil {
+			rowsAffected, _ = result.RowsAffected()
+		}
+		hooks.after(ctx, e, rowsAffected, err)
+	}()
+	var stmt *sql.Stmt
+	stmt, err = preparer.PrepareContext(ctx, sqlStr)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	return stmt.ExecContext(ctx, values...)
+}
+
+// SlowQueryHook logs the statements whose time used is greater than or equal to the threshold.
+type SlowQueryHook struct {
+	logger    gcore.Logger
+	threshold time.Duration
+	explainDB *MySQLDB
+}
+
+// NewSlowQueryHook creates a slow query hook, the slow statements are logged by logger with warn level.
+func NewSlowQueryHook(logger gcore.Logger, threshold time.Duration) *SlowQueryHook {
+	return &SlowQueryHook{
+		logger:    logger,
+		threshold: threshold,
+	}
+}
+
+// Explain enables capturing the EXPLAIN of slow SELECT statements of mysql,
+// the EXPLAIN is executed on the primary of db.
+func (h *SlowQueryHook) Explain(db *MySQLDB) *SlowQueryHook {
+	h.explainDB = db
+	return h
+}
+
+func (h *SlowQueryHook) Before(ctx context.Context, e *gcore.DBQueryEvent) context.Context {
+	return ctx
+}
+
+func (h *SlowQueryHook) After(ctx context.Context, e *gcore.DBQueryEvent) {
+	if e.Duration < h.threshold {
+		return
+	}
+	msg := fmt.Sprintf("slow sql, time used: %s, sql: %s, args: %v", e.Duration, e.SQL, e.Args)
+	if e.Err != nil {
+		msg += ", error: " + e.Err.Error()
+	}
+	if h.explainDB != nil && e.Driver == "mysql" && statementType(e.SQL) == "SELECT" {
+		plan, err := h.explain(e)
+		if err != nil {
+			msg += ", explain error: " + err.Error()
+		} else {
+			msg += ", explain: " + plan
+		}
+	}
+	h.logger.Warn(msg)
+}
+
+var explainColumns = []string{"id", "select_type", "table", "partitions", "type", "possible_keys",
+	"key", "key_len", "ref", "rows", "filtered", "Extra"}
+
+func (h *SlowQueryHook) explain(e *gcore.DBQueryEvent) (plan string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	// no hooks, to avoid the EXPLAIN being logged as a slow query.
+	rows, err := queryRows(ctx, nil, h.explainDB.ConnPool, "EXPLAIN "+e.SQL, e.Args...)
+	if err != nil {
+		return
+	}
+	var lines []string
+	for _, row := range rows {
+		var fields []string
+		for _, col := range explainColumns {
+			if v, ok := row[col]; ok {
+				fields = append(fields, col+"="+string(v))
+			}
+		}
+		lines = append(lines, "["+strings.Join(fields, " ")+"]")
+	}
+	return strings.Join(lines, " "), nil
+}
+
+var (
+	defaultMetricsBuckets = []time.Duration{time.Millisecond, time.Millisecond * 5, time.Millisecond * 10,
+		time.Millisecond * 50, time.Millisecond * 100, time.Millisecond * 500, time.Second, time.Second * 5}
+	statementTableRegexp = regexp.MustCompile("(?is)^\\s*(?:SELECT\\b.*?\\bFROM|INSERT\\b.*?\\bINTO|REPLACE\\b.*?\\bINTO|" +
+		"UPDATE|DELETE\\b.*?\\bFROM)\\s+([`\"\\w.]+)")
+)
+
+// QueryStats is the statistics of a statement type on a table, Buckets[i] is the count of
+// statements whose time used is less than or equal to Bounds[i], the last one is the count
+// of the others.
+type QueryStats struct {
+	Type          string
+	Table         string
+	Count         int64
+	Errors        int64
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+	Bounds        []time.Duration
+	Buckets       []int64
+}
+
+// MetricsHook collects the counters and latency histograms of statements by table and statement type.
+type MetricsHook struct {
+	bounds []time.Duration
+	stats  map[string]*QueryStats
+	lock   sync.Mutex
+}
+
+// NewMetricsHook creates a metrics hook, bounds are the upper bounds of histogram buckets in
+// ascending order, default is 1ms, 5ms, 10ms, 50ms, 100ms, 500ms, 1s, 5s.
+func NewMetricsHook(bounds ...time.Duration) *MetricsHook {
+	if len(bounds) == 0 {
+		bounds = defaultMetricsBuckets
+	}
+	return &MetricsHook{
+		bounds: bounds,
+		stats:  map[string]*QueryStats{},
+	}
+}
+
+func (h *MetricsHook) Before(ctx context.Context, e *gcore.DBQueryEvent) context.Context {
+	return ctx
+}
+
+func (h *MetricsHook) After(ctx context.Context, e *gcore.DBQueryEvent) {
+	typ, table := statementType(e.SQL), statementTable(e.SQL)
+	key := typ + ":" + table
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	s, ok := h.stats[key]
+	if !ok {
+		s = &QueryStats{
+			Type:    typ,
+			Table:   table,
+			Bounds:  h.bounds,
+			Buckets: make([]int64, len(h.bounds)+1),
+		}
+		h.stats[key] = s
+	}
+	s.Count++
+	if e.Err != nil {
+		s.Errors++
+	}
+	s.TotalDuration += e.Duration
+	if e.Duration > s.MaxDuration {
+		s.MaxDuration = e.Duration
+	}
+	s.Buckets[sort.Search(len(h.bounds), func(i int) bool {
+		return e.Duration <= h.bounds[i]
+	})]++
+}
+
+// Stats returns a snapshot of the statistics, sorted by table and type.
+func (h *MetricsHook) Stats() (stats []QueryStats) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, s := range h.stats {
+		v := *s
+		v.Buckets = append([]int64{}, s.Buckets...)
+		stats = append(stats, v)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Table != stats[j].Table {
+			return stats[i].Table < stats[j].Table
+		}
+		return stats[i].Type < stats[j].Type
+	})
+	return
+}
+
+// Reset clears the statistics.
+func (h *MetricsHook) Reset() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.stats = map[string]*QueryStats{}
+}
+
+// statementType returns the upper case first keyword of the statement, such as SELECT.
+func statementType(sqlStr string) string {
+	fields := strings.Fields(sqlStr)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// statementTable returns the first table name of the statement, empty returned if not found.
+func statementTable(sqlStr string) string {
+	m := statementTableRegexp.FindStringSubmatch(sqlStr)
+	if m == nil {
+		return ""
+	}
+	return strings.NewReplacer("`", "", `"`, "").Replace(m[1])
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	glog "github.com/snail007/gmc/module/log"
+	gmap "github.com/snail007/gmc/util/map"
+	"github.com/stretchr/testify/assert"
+)
+
+type hookCtxKey struct{}
+
+type recordHook struct {
+	events []gcore.DBQueryEvent
+	ctxOK  []bool
+	lock   sync.Mutex
+}
+
+func (h *recordHook) Before(ctx context.Context, e *gcore.DBQueryEvent) context.Context {
+	return context.WithValue(ctx, hookCtxKey{}, e.SQL)
+}
+
+func (h *recordHook) After(ctx context.Context, e *gcore.DBQueryEvent) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.events = append(h.events, *e)
+	h.ctxOK = append(h.ctxOK, ctx.Value(hookCtxKey{}) == e.SQL)
+}
+
+func newHookTestDB(t *testing.T) (gcore.Database, *recordHook) {
+	group := NewSQLite3DBGroup("default")
+	group.Regist("default", NewSQLite3DBConfigWith("test_hook.db", OpenModeReadWriteCreate, CacheModeShared, SyncModeOff))
+	db := group.DB()
+	t.Cleanup(func() { os.Remove("test_hook.db") })
+	db.ExecSQL("drop table hook_test")
+	db.ExecSQL("create table hook_test(id integer primary key autoincrement, name varchar(20))")
+	h := &recordHook{}
+	db.AddHook(h)
+	return db, h
+}
+
+func TestHook_Events(t *testing.T) {
+	assert := assert.New(t)
+	db, h := newHookTestDB(t)
+	defer db.ExecSQL("drop table hook_test")
+	_, err := db.Exec(db.AR().InsertBatch("hook_test", []gmap.M{{"name": "a"}, {"name": "b"}}))
+	assert.Nil(err)
+	_, err = db.Query(db.AR().From("hook_test"))
+	assert.Nil(err)
+	_, err = db.QuerySQL("select * from none_table where id=?", 1)
+	assert.NotNil(err)
+	db.Transaction(func(tx gcore.Tx) error {
+		_, err := tx.ExecSQL("delete from hook_test")
+		return err
+	})
+	assert.Len(h.events, 4)
+	for _, ok := range h.ctxOK {
+		assert.True(ok)
+	}
+
+	e := h.events[0]
+	assert.Equal("sqlite3", e.Driver)
+	assert.Contains(e.SQL, "INSERT INTO")
+	assert.Equal([]interface{}{"a", "b"}, e.Args)
+	assert.Equal(int64(2), e.RowsAffected)
+	assert.False(e.InTx)
+	assert.Nil(e.Err)
+	assert.False(e.Start.IsZero())
+
+	e = h.events[1]
+	assert.Contains(e.SQL, "SELECT")
+	assert.Equal(int64(2), e.RowsAffected)
+
+	e = h.events[2]
+	assert.NotNil(e.Err)
+	assert.Equal([]interface{}{1}, e.Args)
+
+	e = h.events[3]
+	assert.True(e.InTx)
+	assert.Equal(int64(2), e.RowsAffected)
+}
+
+func TestMetricsHook(t *testing.T) {
+	assert := assert.New(t)
+	db, _ := newHookTestDB(t)
+	defer db.ExecSQL("drop table hook_test")
+	m := NewMetricsHook(time.Millisecond, time.Second)
+	db.AddHook(m)
+	db.Exec(db.AR().Insert("hook_test", gmap.M{"name": "a"}))
+	db.Exec(db.AR().Update("hook_test", gmap.M{"name": "b"}, gmap.M{"id": 1}))
+	db.Query(db.AR().From("hook_test"))
+	db.Query(db.AR().From("hook_test").Where(gmap.M{"id": 1}))
+	db.QuerySQL("select * from none_table")
+	db.Exec(db.AR().Delete("hook_test", gmap.M{"id": 1}))
+
+	stats := m.Stats()
+	assert.Len(stats, 5)
+	var types []string
+	for _, s := range stats {
+		types = append(types, s.Type+":"+s.Table)
+	}
+	assert.Equal([]string{"DELETE:hook_test", "INSERT:hook_test", "SELECT:hook_test", "UPDATE:hook_test", "SELECT:none_table"}, types)
+	s := stats[2]
+	assert.Equal(int64(2), s.Count)
+	assert.Equal(int64(0), s.Errors)
+	assert.Len(s.Buckets, 3)
+	assert.Equal(int64(2), s.Buckets[0]+s.Buckets[1]+s.Buckets[2])
+	assert.True(s.MaxDuration > 0)
+	assert.Equal(int64(1), stats[4].Errors)
+
+	m.Reset()
+	assert.Len(m.Stats(), 0)
+}
+
+func TestSlowQueryHook(t *testing.T) {
+	assert := assert.New(t)
+	buf := bytes.NewBuffer(nil)
+	l := glog.New()
+	l.SetOutput(glog.NewLoggerWriter(buf))
+	h := NewSlowQueryHook(l, time.Millisecond*100)
+	h.After(context.Background(), &gcore.DBQueryEvent{SQL: "select 1", Duration: time.Millisecond})
+	assert.Equal("", buf.String())
+	h.After(context.Background(), &gcore.DBQueryEvent{SQL: "select 2", Args: []interface{}{1}, Duration: time.Second})
+	assert.Contains(buf.String(), "slow sql")
+	assert.Contains(buf.String(), "select 2")
+	assert.Contains(buf.String(), "[1]")
+}
+
+func TestStatementTable(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("user", statementTable("SELECT `a`.* \nFROM `user` AS `a`"))
+	assert.Equal("user", statementTable("INSERT INTO  `user` (`a`) VALUES (?)"))
+	assert.Equal("user", statementTable(`REPLACE INTO "user" (a) VALUES (?)`))
+	assert.Equal("db.user", statementTable("update  db.user set a=1"))
+	assert.Equal("user", statementTable("delete from user where id=1"))
+	assert.Equal("", statementTable("select 1"))
+	assert.Equal("SELECT", statementType("  select 1"))
+	assert.Equal("", statementType(""))
+}
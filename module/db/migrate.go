@@ -0,0 +1,398 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gcast "github.com/snail007/gmc/util/cast"
+	gmap "github.com/snail007/gmc/util/map"
+	grand "github.com/snail007/gmc/util/rand"
+)
+
+const (
+	defaultMigrateTable = "gmc_migrations"
+)
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a versioned schema change, the sql of Up and Down can contain
+// multiple statements separated by semicolon.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is the status of a migration, AppliedAt is zero if it is not applied.
+type MigrationStatus struct {
+	Version   uint64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and rolls back the migrations found in a directory or a fs.FS,
+// the file name of migration must be like: 20201010120000_create_user.up.sql
+// and 20201010120000_create_user.down.sql, the down file is optional.
+// The applied versions are recorded in table gmc_migrations, and a lock table
+// gmc_migrations_lock is used to avoid concurrent migrating of multiple app instances.
+type Migrator struct {
+	db         gcore.Database
+	fsys       fs.FS
+	table      string
+	migrations []*Migration
+	// LockTimeout is the max duration to wait for the migrate lock.
+	LockTimeout time.Duration
+	// LockExpire is the duration after which a lock is treated as stale and can be released,
+	// it is used when the app instance holding the lock crashed. The lock held is refreshed
+	// every LockExpire/3, so a migration running longer than LockExpire keeps the lock.
+	LockExpire time.Duration
+}
+
+// NewMigrator creates a migrator with migrations in directory dir.
+func NewMigrator(db gcore.Database, dir string) (m *Migrator, err error) {
+	return NewMigratorFS(db, os.DirFS(dir))
+}
+
+// NewMigratorFS creates a migrator with migrations in fsys, such as an embed.FS,
+// the migration files must be in the root directory of fsys, use fs.Sub to change
+// the root directory if needed. All statements of the migrator are executed on the
+// primary, the applied versions are not read from a lagging replica.
+func NewMigratorFS(db gcore.Database, fsys fs.FS) (m *Migrator, err error) {
+	m = &Migrator{
+		db:          primaryDatabase(db),
+		fsys:        fsys,
+		table:       defaultMigrateTable,
+		LockTimeout: time.Minute,
+		LockExpire:  time.Minute * 10,
+	}
+	err = m.load()
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// SetTable sets the name of bookkeeping table, default is gmc_migrations,
+// the lock table name is the table name with suffix _lock.
+func (m *Migrator) SetTable(table string) *Migrator {
+	m.table = table
+	return m
+}
+
+// Migrations returns all migrations sorted by version.
+func (m *Migrator) Migrations() []*Migration {
+	return m.migrations
+}
+
+func (m *Migrator) load() (err error) {
+	entries, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return
+	}
+	all := map[uint64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version := gcast.ToUint64(match[1])
+		migration, ok := all[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			all[version] = migration
+		} else if migration.Name != match[2] {
+			return fmt.Errorf("duplicate migration version %d, %s and %s", version, migration.Name, match[2])
+		}
+		var b []byte
+		b, err = fs.ReadFile(m.fsys, entry.Name())
+		if err != nil {
+			return
+		}
+		if match[3] == "up" {
+			migration.Up = string(b)
+		} else {
+			migration.Down = string(b)
+		}
+	}
+	m.migrations = nil
+	for _, migration := range all {
+		m.migrations = append(m.migrations, migration)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+	return
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() (err error) {
+	if len(m.migrations) == 0 {
+		return
+	}
+	return m.To(m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down rolls back the latest applied migration.
+func (m *Migrator) Down() (err error) {
+	return m.withLock(func() (err error) {
+		applied, err := m.applied()
+		if err != nil {
+			return
+		}
+		var latest *Migration
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				latest = migration
+			}
+		}
+		if latest == nil {
+			return
+		}
+		return m.down(latest)
+	})
+}
+
+// To migrates the schema to the version, pending migrations less than or equal to
+// the version are applied, and applied migrations greater than the version are rolled back.
+func (m *Migrator) To(version uint64) (err error) {
+	return m.withLock(func() (err error) {
+		applied, err := m.applied()
+		if err != nil {
+			return
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; ok && migration.Version > version {
+				err = m.down(migration)
+				if err != nil {
+					return
+				}
+			}
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				err = m.up(migration)
+				if err != nil {
+					return
+				}
+			}
+		}
+		return
+	})
+}
+
+// Status returns the status of all migrations sorted by version.
+func (m *Migrator) Status() (status []MigrationStatus, err error) {
+	err = m.createTables()
+	if err != nil {
+		return
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return
+	}
+	for _, migration := range m.migrations {
+		s := MigrationStatus{
+			Version: migration.Version,
+			Name:    migration.Name,
+		}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = appliedAt
+		}
+		status = append(status, s)
+	}
+	return
+}
+
+func (m *Migrator) up(migration *Migration) (err error) {
+	err = m.db.Transaction(func(tx gcore.Tx) (err error) {
+		for _, s := range splitSQLStatements(migration.Up) {
+			_, err = tx.ExecSQL(s)
+			if err != nil {
+				return
+			}
+		}
+		_, err = tx.Exec(tx.AR().Insert(m.table, gmap.M{
+			"version":    migration.Version,
+			"name":       migration.Name,
+			"applied_at": time.Now().Unix(),
+		}))
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("migrate up %d_%s fail, error: %s", migration.Version, migration.Name, err)
+	}
+	return
+}
+
+func (m *Migrator) down(migration *Migration) (err error) {
+	if strings.TrimSpace(migration.Down) == "" {
+		return fmt.Errorf("migrate down %d_%s fail, error: down sql not found", migration.Version, migration.Name)
+	}
+	err = m.db.Transaction(func(tx gcore.Tx) (err error) {
+		for _, s := range splitSQLStatements(migration.Down) {
+			_, err = tx.ExecSQL(s)
+			if err != nil {
+				return
+			}
+		}
+		_, err = tx.Exec(tx.AR().Delete(m.table, gmap.M{
+			"version": migration.Version,
+		}))
+		return
+	})
+	if err != nil {
+		return fmt.Errorf("migrate down %d_%s fail, error: %s", migration.Version, migration.Name, err)
+	}
+	return
+}
+
+func (m *Migrator) applied() (applied map[uint64]time.Time, err error) {
+	rs, err := m.db.Query(m.db.AR().From(m.table))
+	if err != nil {
+		return
+	}
+	applied = map[uint64]time.Time{}
+	for _, row := range rs.Rows() {
+		applied[gcast.ToUint64(row["version"])] = time.Unix(gcast.ToInt64(row["applied_at"]), 0)
+	}
+	return
+}
+
+func (m *Migrator) lockTable() string {
+	return m.table + "_lock"
+}
+
+func (m *Migrator) createTables() (err error) {
+	_, err = m.db.ExecSQL(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, "+
+		"name VARCHAR(255) NOT NULL, applied_at BIGINT NOT NULL)", m.table))
+	if err != nil {
+		return
+	}
+	_, err = m.db.ExecSQL(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id INT NOT NULL PRIMARY KEY, "+
+		"owner VARCHAR(64) NOT NULL, locked_at BIGINT NOT NULL)", m.lockTable()))
+	return
+}
+
+// withLock runs fn with the migrate lock held, the lock is a row in the lock table,
+// inserting the row fails with duplicate primary key when the lock is held by others.
+func (m *Migrator) withLock(fn func() error) (err error) {
+	err = m.createTables()
+	if err != nil {
+		return
+	}
+	owner := grand.String(32)
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		m.db.Exec(m.db.AR().Delete(m.lockTable(), gmap.M{
+			"locked_at <": time.Now().Add(-m.LockExpire).Unix(),
+		}))
+		_, err = m.db.Exec(m.db.AR().Insert(m.lockTable(), gmap.M{
+			"id":        1,
+			"owner":     owner,
+			"locked_at": time.Now().Unix(),
+		}))
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("acquire migrate lock timeout, error: %s", err)
+		}
+		time.Sleep(time.Millisecond * 500)
+	}
+	defer m.db.Exec(m.db.AR().Delete(m.lockTable(), gmap.M{
+		"id":    1,
+		"owner": owner,
+	}))
+	done := make(chan struct{})
+	defer close(done)
+	go m.refreshLock(owner, done)
+	return fn()
+}
+
+// refreshLock updates locked_at of the lock held by owner until done is closed,
+// so the lock of a long running migration is not treated as stale by others.
+func (m *Migrator) refreshLock(owner string, done chan struct{}) {
+	interval := m.LockExpire / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			m.db.Exec(m.db.AR().Update(m.lockTable(), gmap.M{
+				"locked_at": time.Now().Unix(),
+			}, gmap.M{
+				"id":    1,
+				"owner": owner,
+			}))
+		}
+	}
+}
+
+// splitSQLStatements splits the sql by semicolon, the semicolon in quoted strings,
+// identifiers, line comments and block comments are ignored, the statements
+// with only comments and spaces are dropped.
+func splitSQLStatements(sqlStr string) (statements []string) {
+	var quote byte
+	start := 0
+	hasCode := false
+	add := func(s string) {
+		s = strings.TrimSpace(s)
+		if s != "" && hasCode {
+			statements = append(statements, s)
+		}
+		hasCode = false
+	}
+	for i := 0; i < len(sqlStr); i++ {
+		c := sqlStr[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '-' && i+1 < len(sqlStr) && sqlStr[i+1] == '-':
+			for i < len(sqlStr) && sqlStr[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(sqlStr) && sqlStr[i+1] == '*':
+			end := strings.Index(sqlStr[i+2:], "*/")
+			if end < 0 {
+				i = len(sqlStr)
+			} else {
+				i += end + 3
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			hasCode = true
+		case c == ';':
+			add(sqlStr[start:i])
+			start = i + 1
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			hasCode = true
+		}
+	}
+	add(sqlStr[start:])
+	return
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gcast "github.com/snail007/gmc/util/cast"
+	gmap "github.com/snail007/gmc/util/map"
+	"github.com/stretchr/testify/assert"
+)
+
+var migrateTestFS = fstest.MapFS{
+	"1_create_m1.up.sql":   {Data: []byte("create table m1(id integer primary key, name varchar(20));\ninsert into m1(name) values('a;b');")},
+	"1_create_m1.down.sql": {Data: []byte("drop table m1;")},
+	"2_create_m2.up.sql":   {Data: []byte("create table m2(id integer primary key)")},
+	"2_create_m2.down.sql": {Data: []byte("drop table m2")},
+	"readme.txt":           {Data: []byte("not a migration")},
+}
+
+func newTestMigrator(t *testing.T, db gcore.Database, fsys fstest.MapFS) *Migrator {
+	db.ExecSQL("drop table m1")
+	db.ExecSQL("drop table m2")
+	db.ExecSQL("drop table migrate_test")
+	db.ExecSQL("drop table migrate_test_lock")
+	m, err := NewMigratorFS(db, fsys)
+	assert.Nil(t, err)
+	m.SetTable("migrate_test")
+	return m
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	m := newTestMigrator(t, db, migrateTestFS)
+	assert.Len(m.Migrations(), 2)
+	assert.Nil(m.Up())
+	rs, err := db.QuerySQL("select * from m1")
+	assert.Nil(err)
+	assert.Equal("a;b", rs.Value("name"))
+	_, err = db.QuerySQL("select * from m2")
+	assert.Nil(err)
+	status, err := m.Status()
+	assert.Nil(err)
+	assert.Len(status, 2)
+	assert.True(status[0].Applied)
+	assert.True(status[1].Applied)
+	assert.False(status[1].AppliedAt.IsZero())
+
+	// apply again does nothing
+	assert.Nil(m.Up())
+
+	assert.Nil(m.Down())
+	_, err = db.QuerySQL("select * from m2")
+	assert.NotNil(err)
+	status, _ = m.Status()
+	assert.True(status[0].Applied)
+	assert.False(status[1].Applied)
+
+	assert.Nil(m.To(0))
+	_, err = db.QuerySQL("select * from m1")
+	assert.NotNil(err)
+	status, _ = m.Status()
+	assert.False(status[0].Applied)
+
+	assert.Nil(m.To(1))
+	status, _ = m.Status()
+	assert.True(status[0].Applied)
+	assert.False(status[1].Applied)
+	db.ExecSQL("drop table m1")
+}
+
+func TestMigrator_Fail(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	m := newTestMigrator(t, db, fstest.MapFS{
+		"1_create_m1.up.sql": {Data: []byte("create table m1(id integer primary key);insert into m1_none values(1)")},
+	})
+	assert.NotNil(m.Up())
+	// the migration is rolled back
+	_, err := db.QuerySQL("select * from m1")
+	assert.NotNil(err)
+	status, _ := m.Status()
+	assert.False(status[0].Applied)
+	// lock is released
+	cnt, _ := Table("migrate_test_lock", db).Count(nil)
+	assert.Equal(int64(0), cnt)
+}
+
+func TestMigrator_DuplicateVersion(t *testing.T) {
+	_, err := NewMigratorFS(db1(), fstest.MapFS{
+		"1_a.up.sql": {Data: []byte("select 1")},
+		"1_b.up.sql": {Data: []byte("select 1")},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestMigrator_Lock(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	m := newTestMigrator(t, db, migrateTestFS)
+	m.LockTimeout = time.Second
+	assert.Nil(m.createTables())
+	_, err := db.Exec(db.AR().Insert("migrate_test_lock", gmap.M{
+		"id":        1,
+		"owner":     "other",
+		"locked_at": time.Now().Unix(),
+	}))
+	assert.Nil(err)
+	assert.NotNil(m.Up())
+	// stale lock is released
+	m.LockExpire = 0
+	db.Exec(db.AR().Update("migrate_test_lock", gmap.M{"locked_at": time.Now().Unix() - 1}, gmap.M{"id": 1}))
+	assert.Nil(m.Up())
+	m.To(0)
+}
+
+func TestMigrator_LockRefresh(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	m := newTestMigrator(t, db, migrateTestFS)
+	m.LockExpire = time.Second * 3
+	start := time.Now().Unix()
+	err := m.withLock(func() error {
+		time.Sleep(time.Millisecond * 2100)
+		rs, err := db.Query(db.AR().From("migrate_test_lock"))
+		assert.Nil(err)
+		assert.Greater(gcast.ToInt64(rs.Value("locked_at")), start)
+		return nil
+	})
+	assert.Nil(err)
+	rs, err := db.Query(db.AR().From("migrate_test_lock"))
+	assert.Nil(err)
+	assert.Equal(0, rs.Len())
+}
+
+func TestSplitSQLStatements(t *testing.T) {
+	assert.Equal(t, []string{"a", "b ';' \"c;\"", "`d;`"}, splitSQLStatements("a;\n b ';' \"c;\";;`d;`;\n"))
+	assert.Equal(t, []string{"-- don't\ncreate table a(id int)", "/* it's; */ insert into a values(1)", "b -- c;d'"},
+		splitSQLStatements("-- don't\ncreate table a(id int);\n/* it's; */ insert into a values(1);\nb -- c;d'\n;\n-- end;\n/* end */"))
+}
@@ -15,11 +15,21 @@ import (
 	gmap "github.com/snail007/gmc/util/map"
 )
 
+// modelDB is the statement executor of Model, both gcore.Database and gcore.Tx implement it.
+type modelDB interface {
+	AR() gcore.ActiveRecord
+	Exec(ar gcore.ActiveRecord) (gcore.ResultSet, error)
+	Query(ar gcore.ActiveRecord) (gcore.ResultSet, error)
+	QuerySQL(sqlStr string, values ...interface{}) (gcore.ResultSet, error)
+}
+
 type Model struct {
-	db         gcore.Database
+	db         modelDB
 	table      string
 	primaryKey string
-	once       *sync.Once
+	// primaryKeySet is true if the primary key is set by SetPrimaryKey.
+	primaryKeySet bool
+	once          *sync.Once
 }
 
 func Table(table string, db ...interface{}) *Model {
@@ -41,19 +51,31 @@ func Table(table string, db ...interface{}) *Model {
 		m.db = v
 	case *SQLite3DB:
 		m.db = v
+	case *PostgresDB:
+		m.db = v
+	case gcore.Tx:
+		m.db = v
 	}
 	if m.db == nil {
-		panic(gcore.ProviderError()().New((fmt.Errorf("table db arguments must be 'db string ID' or *gdb.MySQLDB or *gdb.SQLite3DB"))))
+		panic(gcore.ProviderError()().New((fmt.Errorf("table db arguments must be 'db string ID' or *gdb.MySQLDB or *gdb.SQLite3DB or *gdb.PostgresDB or gcore.Tx"))))
 	}
 	return m
 }
 
+// Tx returns a copy of the model, all statements of the copy are executed in the transaction tx.
+func (s *Model) Tx(tx gcore.Tx) *Model {
+	m := *s
+	m.db = tx
+	return &m
+}
+
 func (s *Model) PrimaryKey() string {
 	return s.primaryKey
 }
 
 func (s *Model) SetPrimaryKey(primaryKey string) *Model {
 	s.primaryKey = primaryKey
+	s.primaryKeySet = true
 	return s
 }
 
@@ -212,7 +234,7 @@ func (s *Model) DeleteByIDs(ids []string) (cnt int64, err error) {
 
 func (s *Model) Insert(data map[string]interface{}) (lastInsertID int64, err error) {
 	db := s.db
-	rs, err := db.Exec(db.AR().Insert(s.table, data))
+	rs, err := db.Exec(s.returning(db.AR().Insert(s.table, data)))
 	if err != nil {
 		return 0, err
 	}
@@ -222,7 +244,7 @@ func (s *Model) Insert(data map[string]interface{}) (lastInsertID int64, err err
 
 func (s *Model) InsertBatch(data []map[string]interface{}) (cnt, lastInsertID int64, err error) {
 	db := s.db
-	rs, err := db.Exec(db.AR().InsertBatch(s.table, data))
+	rs, err := db.Exec(s.returning(db.AR().InsertBatch(s.table, data)))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -301,6 +323,68 @@ func (s *Model) ListWithFields(fields string, where map[string]interface{}, offs
 	return
 }
 
+// ChunkByID walks the rows matched where in ascending order of the primary key, fn is called
+// with every chunk of at most size rows. It uses keyset pagination, WHERE pk > last pk of
+// previous chunk, so it is fast on large tables. fn can return ErrStopEach to stop walking.
+func (s *Model) ChunkByID(where map[string]interface{}, size int, fn func(rows []map[string]string) error) (err error) {
+	return s.ChunkByIDWithFields("*", where, size, fn)
+}
+
+// ChunkByIDWithFields same as ChunkByID, fields must contain the primary key.
+func (s *Model) ChunkByIDWithFields(fields string, where map[string]interface{}, size int, fn func(rows []map[string]string) error) (err error) {
+	if size <= 0 {
+		return fmt.Errorf("chunk size must be greater than 0")
+	}
+	db := s.db
+	var lastID string
+	for first := true; ; first = false {
+		ar := db.AR().Select(fields).From(s.table).Where(where).OrderBy(s.primaryKey, "ASC").Limit(0, size)
+		if !first {
+			ar.Where(map[string]interface{}{s.primaryKey + " >": lastID})
+		}
+		rs, err := db.Query(ar)
+		if err != nil {
+			return err
+		}
+		rows := rs.Rows()
+		if len(rows) == 0 {
+			return nil
+		}
+		var ok bool
+		lastID, ok = rows[len(rows)-1][s.primaryKey]
+		if !ok {
+			return fmt.Errorf("primary key %s not found in the fields", s.primaryKey)
+		}
+		err = fn(rows)
+		if err != nil {
+			if err == ErrStopEach {
+				err = nil
+			}
+			return err
+		}
+		if len(rows) < size {
+			return nil
+		}
+	}
+}
+
+// returning makes the postgres insert statement return the primary key,
+// because postgres driver can not get the last insert id without RETURNING.
+// The primary_key of postgres config is used if SetPrimaryKey is not called,
+// RETURNING is skipped if it is empty.
+func (s *Model) returning(ar gcore.ActiveRecord) gcore.ActiveRecord {
+	if v, ok := ar.(*PostgresActiveRecord); ok {
+		primaryKey := v.primaryKey
+		if s.primaryKeySet {
+			primaryKey = s.primaryKey
+		}
+		if primaryKey != "" {
+			v.Returning(primaryKey)
+		}
+	}
+	return ar
+}
+
 func (s *Model) OrderBy(ar gcore.ActiveRecord, orderBy ...string) (ret [][]string) {
 	if len(orderBy) == 0 || len(orderBy)%2 != 0 {
 		return nil
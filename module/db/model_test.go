@@ -340,6 +340,50 @@ func createTestTable(db gcore.Database) {
 	}
 }
 
+func TestModel_ChunkByID(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	db.ExecSQL("drop table test_table")
+	db.ExecSQL("create table test_table(test_table_id integer primary key autoincrement, column1 varchar(255))")
+	defer db.ExecSQL("drop table test_table")
+	for i := 0; i < 21; i++ {
+		db.ExecSQL("insert into test_table(column1) values ('value1')")
+	}
+	model := Table("test_table", db)
+	model.DeleteByIDs([]string{"2", "10"})
+	var ids []string
+	chunks := 0
+	err := model.ChunkByIDWithFields("test_table_id", map[string]interface{}{"test_table_id <": 20}, 5, func(rows []map[string]string) error {
+		chunks++
+		assert.LessOrEqual(len(rows), 5)
+		for _, row := range rows {
+			ids = append(ids, row["test_table_id"])
+		}
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(4, chunks)
+	assert.Len(ids, 17)
+	assert.Equal("1", ids[0])
+	assert.Equal("3", ids[1])
+	assert.Equal("19", ids[16])
+
+	// stop early
+	chunks = 0
+	err = model.ChunkByID(nil, 3, func(rows []map[string]string) error {
+		chunks++
+		return ErrStopEach
+	})
+	assert.Nil(err)
+	assert.Equal(1, chunks)
+
+	err = model.ChunkByIDWithFields("column1", nil, 3, func(rows []map[string]string) error {
+		return nil
+	})
+	assert.NotNil(err)
+	assert.NotNil(model.ChunkByID(nil, 0, nil))
+}
+
 func insertTestData(db gcore.Database) {
 	// 插入测试数据的 SQL 语句
 	sqlStr := `
@@ -7,6 +7,7 @@ package gdb
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/gob"
 	"fmt"
@@ -16,7 +17,6 @@ import (
 	"time"
 
 	"github.com/snail007/gmc/core"
-	makeutil "github.com/snail007/gmc/internal/util/make"
 	gmap "github.com/snail007/gmc/util/map"
 	// require mysql driver
 	_ "github.com/go-sql-driver/mysql"
@@ -86,9 +86,12 @@ func (g *MySQLDBGroup) DB(name ...string) (db gcore.Database) {
 }
 
 type MySQLDB struct {
-	Config   MySQLDBConfig
-	ConnPool *sql.DB
-	DSN      string
+	Config       MySQLDBConfig
+	ConnPool     *sql.DB
+	DSN          string
+	replicas     *replicaSet
+	forcePrimary bool
+	hooks        *dbHooks
 }
 
 func NewMySQLDB(config MySQLDBConfig) (db *MySQLDB, err error) {
@@ -98,23 +101,70 @@ func NewMySQLDB(config MySQLDBConfig) (db *MySQLDB, err error) {
 }
 func (db *MySQLDB) init(config MySQLDBConfig) (err error) {
 	db.Config = config
+	db.hooks = newDBHooks("mysql")
 	db.DSN = db.getDSN()
 	db.ConnPool, err = db.getDB()
+	if err != nil {
+		return
+	}
+	return db.initReplicas()
+}
+
+// initReplicas opens the replicas, the unavailable replicas are not an error,
+// they are ejected until the health check succeeds.
+func (db *MySQLDB) initReplicas() (err error) {
+	if len(db.Config.Replicas) == 0 {
+		return
+	}
+	timeout := time.Duration(db.Config.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = time.Second * 3
+	}
+	db.replicas = newReplicaSet(db.Config.ReplicaStrategy, timeout)
+	for _, r := range db.Config.Replicas {
+		cfg := db.Config
+		cfg.Host = r.Host
+		if r.Port > 0 {
+			cfg.Port = r.Port
+		}
+		if r.Username != "" {
+			cfg.Username = r.Username
+			cfg.Password = r.Password
+		}
+		var connPool *sql.DB
+		connPool, err = sql.Open("mysql", getMySQLDSN(cfg))
+		if err != nil {
+			db.replicas.close()
+			db.replicas = nil
+			return
+		}
+		connPool.SetMaxOpenConns(db.Config.MaxOpenConns)
+		connPool.SetMaxIdleConns(db.Config.MaxIdleConns)
+		db.replicas.add(fmt.Sprintf("%s:%d", cfg.Host, cfg.Port), connPool, r.Weight)
+	}
+	interval := time.Duration(db.Config.ReplicaCheckInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second * 5
+	}
+	db.replicas.start(interval)
 	return
 }
 
 func (db *MySQLDB) getDSN() string {
+	return getMySQLDSN(db.Config)
+}
+func getMySQLDSN(cfg MySQLDBConfig) string {
 	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?timeout=%dms&readTimeout=%dms&writeTimeout=%dms&charset=%s&collation=%s",
-		url.QueryEscape(db.Config.Username),
-		db.Config.Password,
-		url.QueryEscape(db.Config.Host),
-		db.Config.Port,
-		url.QueryEscape(db.Config.Database),
-		db.Config.Timeout,
-		db.Config.ReadTimeout,
-		db.Config.WriteTimeout,
-		url.QueryEscape(db.Config.Charset),
-		url.QueryEscape(db.Config.Collate))
+		url.QueryEscape(cfg.Username),
+		cfg.Password,
+		url.QueryEscape(cfg.Host),
+		cfg.Port,
+		url.QueryEscape(cfg.Database),
+		cfg.Timeout,
+		cfg.ReadTimeout,
+		cfg.WriteTimeout,
+		url.QueryEscape(cfg.Charset),
+		url.QueryEscape(cfg.Collate))
 }
 func (db *MySQLDB) getDB() (connPool *sql.DB, err error) {
 	connPool, err = sql.Open("mysql", db.getDSN())
@@ -126,6 +176,47 @@ func (db *MySQLDB) getDB() (connPool *sql.DB, err error) {
 	err = connPool.Ping()
 	return
 }
+
+// Primary returns a copy of db, all queries of the copy are executed on the primary.
+func (db *MySQLDB) Primary() *MySQLDB {
+	d := *db
+	d.forcePrimary = true
+	return &d
+}
+
+// PrimaryDatabase is same as Primary, but returns gcore.Database, the internal writers such as
+// the migrator and the session store use it to read the data they just wrote from the primary.
+func (db *MySQLDB) PrimaryDatabase() gcore.Database {
+	return db.Primary()
+}
+
+// HealthyReplicas returns the addresses of healthy replicas.
+func (db *MySQLDB) HealthyReplicas() []string {
+	if db.replicas == nil {
+		return nil
+	}
+	return db.replicas.healthy()
+}
+
+// Close stops the health check of replicas, and closes the connection pools.
+func (db *MySQLDB) Close() error {
+	if db.replicas != nil {
+		db.replicas.close()
+	}
+	return db.ConnPool.Close()
+}
+
+// readPool returns the connection pool for queries out of transaction, a healthy replica is
+// returned if there is any, otherwise the primary is returned.
+func (db *MySQLDB) readPool(ctx context.Context) sqlPreparer {
+	if db.replicas == nil || db.forcePrimary || isPrimaryContext(ctx) {
+		return db.ConnPool
+	}
+	if pool := db.replicas.pick(); pool != nil {
+		return pool
+	}
+	return db.ConnPool
+}
 func (db *MySQLDB) AR() (ar gcore.ActiveRecord) {
 	ar0 := new(MySQLActiveRecord)
 	ar0.Reset()
@@ -133,58 +224,59 @@ func (db *MySQLDB) AR() (ar gcore.ActiveRecord) {
 	ar0.tablePrefixSQLIdentifier = db.Config.TablePrefixSQLIdentifier
 	return ar0
 }
+// AddHook adds a hook called before and after every sql statement executed.
+func (db *MySQLDB) AddHook(hook gcore.DBHook) {
+	db.hooks.add(hook)
+}
 func (db *MySQLDB) Stats() sql.DBStats {
 	return db.ConnPool.Stats()
 }
 func (db *MySQLDB) Begin() (tx *sql.Tx, err error) {
-	return db.ConnPool.Begin()
+	return db.BeginContext(context.Background(), nil)
+}
+func (db *MySQLDB) BeginContext(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
+	return db.ConnPool.BeginTx(ctx, opts)
+}
+// Transaction executes fn in a transaction, the transaction is committed if fn returns nil,
+// and rolled back if fn returns an error or panics.
+func (db *MySQLDB) Transaction(fn func(tx gcore.Tx) error) (err error) {
+	return db.TransactionContext(context.Background(), nil, fn)
 }
-func (db *MySQLDB) ExecTx(ar0 gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+func (db *MySQLDB) TransactionContext(ctx context.Context, opts *sql.TxOptions, fn func(tx gcore.Tx) error) (err error) {
+	return transaction(ctx, db, opts, fn)
+}
+func (db *MySQLDB) ExecTx(ar gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+	return db.ExecTxContext(context.Background(), ar, tx)
+}
+func (db *MySQLDB) ExecTxContext(ctx context.Context, ar0 gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
 	ar := ar0.(*MySQLActiveRecord)
-	return db.ExecSQLTx(tx, ar.SQL(), ar.values...)
+	return db.ExecSQLTxContext(ctx, tx, ar.SQL(), ar.values...)
 }
 func (db *MySQLDB) ExecSQLTx(tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
-	start := time.Now()
-	if db.Config.TablePrefix != "" && db.Config.TablePrefixSQLIdentifier != "" {
-		sqlStr = strings.Replace(sqlStr, db.Config.TablePrefixSQLIdentifier, db.Config.TablePrefix, -1)
-	}
-	var stmt *sql.Stmt
-	var result sql.Result
-
-	stmt, err = tx.Prepare(sqlStr)
-	if err != nil {
-		return
-	}
-	defer stmt.Close()
-	result, err = stmt.Exec(values...)
-	if err != nil {
-		return
-	}
-	rsRaw := new(ResultSet)
-	rsRaw.rowsAffected, err = result.RowsAffected()
-	rsRaw.lastInsertID, err = result.LastInsertId()
-	rsRaw.timeUsed = time.Now().Sub(start)
-	rsRaw.sql = sqlStr
-	rs = rsRaw
-	return
+	return db.ExecSQLTxContext(context.Background(), tx, sqlStr, values...)
+}
+func (db *MySQLDB) ExecSQLTxContext(ctx context.Context, tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.execSQL(ctx, tx, sqlStr, values...)
 }
 func (db *MySQLDB) Exec(ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
-	return db.ExecSQL(ar.SQL(), ar.(*MySQLActiveRecord).values...)
+	return db.ExecContext(context.Background(), ar)
+}
+func (db *MySQLDB) ExecContext(ctx context.Context, ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return db.ExecSQLContext(ctx, ar.SQL(), ar.(*MySQLActiveRecord).values...)
 }
 func (db *MySQLDB) ExecSQL(sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.ExecSQLContext(context.Background(), sqlStr, values...)
+}
+func (db *MySQLDB) ExecSQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.execSQL(ctx, db.ConnPool, sqlStr, values...)
+}
+func (db *MySQLDB) execSQL(ctx context.Context, preparer sqlPreparer, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
 	start := time.Now()
 	if db.Config.TablePrefix != "" && db.Config.TablePrefixSQLIdentifier != "" {
 		sqlStr = strings.Replace(sqlStr, db.Config.TablePrefixSQLIdentifier, db.Config.TablePrefix, -1)
 	}
-	var stmt *sql.Stmt
 	var result sql.Result
-
-	stmt, err = db.ConnPool.Prepare(sqlStr)
-	if err != nil {
-		return
-	}
-	defer stmt.Close()
-	result, err = stmt.Exec(values...)
+	result, err = execStmt(ctx, db.hooks, preparer, sqlStr, values...)
 	if err != nil {
 		return
 	}
@@ -197,66 +289,60 @@ func (db *MySQLDB) ExecSQL(sqlStr string, values ...interface{}) (rs gcore.Resul
 		return
 	}
 	rs = rsRaw
+	invalidateQueryCache(db.Config.Cache, preparer, sqlStr)
 	return
 }
 func (db *MySQLDB) QuerySQL(sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.QuerySQLContext(context.Background(), sqlStr, values...)
+}
+func (db *MySQLDB) QuerySQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.querySQL(ctx, db.readPool(ctx), sqlStr, values...)
+}
+func (db *MySQLDB) QuerySQLTx(tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.QuerySQLTxContext(context.Background(), tx, sqlStr, values...)
+}
+func (db *MySQLDB) QuerySQLTxContext(ctx context.Context, tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.querySQL(ctx, tx, sqlStr, values...)
+}
+func (db *MySQLDB) querySQL(ctx context.Context, preparer sqlPreparer, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
 	if db.Config.TablePrefix != "" && db.Config.TablePrefixSQLIdentifier != "" {
 		sqlStr = strings.Replace(sqlStr, db.Config.TablePrefixSQLIdentifier, db.Config.TablePrefix, -1)
 	}
 	start := time.Now()
 	var results []map[string][]byte
-	var stmt *sql.Stmt
-	stmt, err = db.ConnPool.Prepare(sqlStr)
+	results, err = queryRows(ctx, db.hooks, preparer, sqlStr, values...)
 	if err != nil {
 		return
 	}
-	defer stmt.Close()
-	var rows *sql.Rows
-	rows, err = stmt.Query(values...)
-	if err != nil {
-		return
-	}
-	defer rows.Close()
-	cols, e := rows.Columns()
-	if e != nil {
-		return nil, e
-	}
-	closCnt := len(cols)
-
-	// scans := make([]interface{},closCnt)
-	var scans []interface{}
-	scans = makeutil.GetX(scans, uint64(len(cols)), func() interface{} {
-		a := make([]interface{}, closCnt)
-		for i := 0; i < closCnt; i++ {
-			a[i] = new([]byte)
-		}
-		return a
-	}).([]interface{})
-	defer func() {
-		for i := 0; i < closCnt; i++ {
-			scans[i] = new([]byte)
-		}
-		makeutil.PutX(scans, uint64(len(cols)))
-	}()
-
-	for rows.Next() {
-		err = rows.Scan(scans...)
-		if err != nil {
-			return
-		}
-		row := map[string][]byte{}
-		for i := range cols {
-			row[cols[i]] = *(scans[i].(*[]byte))
-		}
-		results = append(results, row)
-	}
 	rsRaw := NewResultSet(&results)
 	rsRaw.timeUsed = time.Now().Sub(start)
 	rsRaw.sql = sqlStr
 	rs = rsRaw
 	return
 }
-func (db *MySQLDB) Query(ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+func (db *MySQLDB) Query(ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return db.QueryContext(context.Background(), ar)
+}
+func (db *MySQLDB) QueryContext(ctx context.Context, ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return db.query(ctx, db.readPool(ctx), ar)
+}
+
+// QueryEach executes the query and calls fn with every row, the rows are scanned one by one
+// instead of loading all of them into memory, fn can return ErrStopEach to stop iterating.
+func (db *MySQLDB) QueryEach(ar gcore.ActiveRecord, fn func(row gcore.DBRow) error) (err error) {
+	return db.QueryEachContext(context.Background(), ar, fn)
+}
+func (db *MySQLDB) QueryEachContext(ctx context.Context, ar gcore.ActiveRecord, fn func(row gcore.DBRow) error) (err error) {
+	sqlStr := ar.SQL()
+	return eachRow(ctx, db.hooks, db.readPool(ctx), sqlStr, fn, ar.Values()...)
+}
+func (db *MySQLDB) QueryTx(ar gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+	return db.QueryTxContext(context.Background(), ar, tx)
+}
+func (db *MySQLDB) QueryTxContext(ctx context.Context, ar gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+	return db.query(ctx, tx, ar)
+}
+func (db *MySQLDB) query(ctx context.Context, preparer sqlPreparer, ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
 	ar := ar0.(*MySQLActiveRecord)
 	start := time.Now()
 	var results []map[string][]byte
@@ -272,51 +358,17 @@ func (db *MySQLDB) Query(ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err error)
 		}
 	}
 	if results == nil || len(results) == 0 {
-		sqlStr := ar.SQL()
-		var stmt *sql.Stmt
-		stmt, err = db.ConnPool.Prepare(sqlStr)
-		if err != nil {
-			return
-		}
-		defer stmt.Close()
-		var rows *sql.Rows
-		rows, err = stmt.Query(ar.values...)
-		if err != nil {
-			return
-		}
-		defer rows.Close()
-		cols, e := rows.Columns()
-		if e != nil {
-			return nil, e
-		}
-		closCnt := len(cols)
-
-		// scans := make([]interface{},closCnt)
-		var scans []interface{}
-		scans = makeutil.GetX(scans, uint64(len(cols)), func() interface{} {
-			a := make([]interface{}, closCnt)
-			for i := 0; i < closCnt; i++ {
-				a[i] = new([]byte)
-			}
-			return a
-		}).([]interface{})
-		defer func() {
-			for i := 0; i < closCnt; i++ {
-				scans[i] = new([]byte)
-			}
-			makeutil.PutX(scans, uint64(len(cols)))
-		}()
-
-		for rows.Next() {
-			err = rows.Scan(scans...)
+		var tags []string
+		var version string
+		if ar.cacheKey != "" {
+			tags, version, err = queryCacheTags(db.Config.Cache, ar.SQL())
 			if err != nil {
 				return
 			}
-			row := map[string][]byte{}
-			for i := range cols {
-				row[cols[i]] = *(scans[i].(*[]byte))
-			}
-			results = append(results, row)
+		}
+		results, err = queryRows(ctx, db.hooks, preparer, ar.SQL(), ar.values...)
+		if err != nil {
+			return
 		}
 		if ar.cacheKey != "" {
 			b := new(bytes.Buffer)
@@ -325,7 +377,7 @@ func (db *MySQLDB) Query(ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err error)
 			if err != nil {
 				return
 			}
-			err = db.Config.Cache.Set(ar.cacheKey, b.Bytes(), ar.cacheSeconds)
+			err = setQueryCache(db.Config.Cache, ar.cacheKey, b.Bytes(), ar.cacheSeconds, tags, version)
 			if err != nil {
 				return
 			}
@@ -354,6 +406,24 @@ type MySQLDBConfig struct {
 	MaxIdleConns             int
 	MaxOpenConns             int
 	Cache                    gcore.DBCache
+	// Replicas are the read replicas, Query and QuerySQL are routed to a healthy replica,
+	// Exec and transactions are always executed on the primary.
+	Replicas []MySQLReplicaConfig
+	// ReplicaStrategy is the selection strategy of replicas, roundrobin or weighted, default is roundrobin.
+	ReplicaStrategy string
+	// ReplicaCheckInterval is the health check interval of replicas in milliseconds, default is 5000.
+	ReplicaCheckInterval int
+}
+
+// MySQLReplicaConfig is a read replica of mysql, the other settings are same as the primary,
+// Port and the account are same as the primary if they are empty.
+type MySQLReplicaConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// Weight is used by weighted strategy, default is 1.
+	Weight int
 }
 
 func NewMySQLDBConfigWith(host string, port int, dbName, user, pass string) (cfg MySQLDBConfig) {
@@ -481,6 +551,14 @@ func (ar *MySQLActiveRecord) WhereWrap(where gmap.M, leftWrap, rightWrap string)
 	}
 	return ar
 }
+
+// WhereCond adds the condition built by gdb.And, gdb.Or, gdb.In etc, it is joined by AND.
+func (ar *MySQLActiveRecord) WhereCond(cond gcore.DBCond) gcore.ActiveRecord {
+	if cond != nil {
+		ar.arWhere = append(ar.arWhere, []interface{}{cond, "AND", "", len(ar.arWhere)})
+	}
+	return ar
+}
 func (ar *MySQLActiveRecord) GroupBy(column string) gcore.ActiveRecord {
 	for _, columnCurrent := range strings.Split(column, ",") {
 		ar.arGroupBy = append(ar.arGroupBy, strings.TrimSpace(columnCurrent))
@@ -850,6 +928,21 @@ func (ar *MySQLActiveRecord) compileOrderBy() string {
 	})
 	return strings.Join(orderBy, ",")
 }
+func (ar *MySQLActiveRecord) compileCond(cond gcore.DBCond, leftWrap, rightWrap string, index int) string {
+	if index == 0 {
+		leftWrap = ""
+	}
+	sqlStr, args := cond.Build(ar.wrapColumn)
+	ar.values = append(ar.values, args...)
+	return fmt.Sprintf(" %s %s %s ", leftWrap, sqlStr, rightWrap)
+}
+func (ar *MySQLActiveRecord) wrapColumn(column string) string {
+	keys := strings.Split(strings.TrimSpace(column), ".")
+	if len(keys) == 2 {
+		return ar.protectIdentifier(ar.checkPrefix(keys[0])) + "." + ar.protectIdentifier(keys[1])
+	}
+	return ar.protectIdentifier(keys[0])
+}
 func (ar *MySQLActiveRecord) compileWhere(where0 interface{}, leftWrap, rightWrap string, index int) string {
 
 	_where := []string{}
@@ -1036,6 +1129,10 @@ func (ar *MySQLActiveRecord) getWhere() string {
 	hasEmptyIn := false
 
 	for _, v := range ar.arWhere {
+		if cond, ok := v[0].(gcore.DBCond); ok {
+			where = append(where, ar.compileCond(cond, v[1].(string), v[2].(string), v[3].(int)))
+			continue
+		}
 		for _, value := range v[0].(gmap.M) {
 			if isArray(value) && reflect.ValueOf(value).Len() == 0 {
 				hasEmptyIn = true
@@ -0,0 +1,1209 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snail007/gmc/core"
+	gmap "github.com/snail007/gmc/util/map"
+	// require postgres driver
+	_ "github.com/lib/pq"
+)
+
+type PostgresDBGroup struct {
+	defaultConfigKey string
+	config           map[string]PostgresDBConfig
+	dbGroup          map[string]*PostgresDB
+	cache            gcore.DBCache
+}
+
+func NewPostgresDBGroupCache(defaultConfigName string, cache gcore.DBCache) (group *PostgresDBGroup) {
+	group = &PostgresDBGroup{}
+	group.defaultConfigKey = defaultConfigName
+	group.config = map[string]PostgresDBConfig{}
+	group.dbGroup = map[string]*PostgresDB{}
+	group.cache = cache
+	return
+}
+func NewPostgresDBGroup(defaultConfigName string) (group *PostgresDBGroup) {
+	group = &PostgresDBGroup{}
+	group.defaultConfigKey = defaultConfigName
+	group.config = map[string]PostgresDBConfig{}
+	group.dbGroup = map[string]*PostgresDB{}
+	return
+}
+func (g *PostgresDBGroup) RegistGroup(cfg interface{}) (err error) {
+	g.config = cfg.(map[string]PostgresDBConfig)
+	for name, config := range g.config {
+		if config.Cache == nil {
+			config.Cache = g.cache
+		}
+		err = g.Regist(name, config)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+func (g *PostgresDBGroup) Regist(name string, cfgI interface{}) (err error) {
+	var db *PostgresDB
+	cfg := cfgI.(PostgresDBConfig)
+	if cfg.Cache == nil {
+		cfg.Cache = g.cache
+	}
+	db, err = NewPostgresDB(cfg)
+	if err != nil {
+		return
+	}
+	g.config[name] = cfg
+	g.dbGroup[name] = db
+	return
+}
+func (g *PostgresDBGroup) DB(name ...string) (db gcore.Database) {
+	key := ""
+	if len(name) == 0 {
+		key = g.defaultConfigKey
+	} else {
+		key = name[0]
+	}
+	db0, ok := g.dbGroup[key]
+	if ok {
+		return db0
+	}
+	return nil
+}
+
+type PostgresDB struct {
+	Config   PostgresDBConfig
+	ConnPool *sql.DB
+	DSN      string
+	hooks    *dbHooks
+}
+
+func NewPostgresDB(config PostgresDBConfig) (db *PostgresDB, err error) {
+	db = &PostgresDB{}
+	err = db.init(config)
+	return
+}
+func (db *PostgresDB) init(config PostgresDBConfig) (err error) {
+	db.Config = config
+	db.hooks = newDBHooks("postgres")
+	db.DSN = db.getDSN()
+	db.ConnPool, err = db.getDB()
+	return
+}
+
+func (db *PostgresDB) getDSN() string {
+	query := url.Values{}
+	sslMode := db.Config.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	query.Set("sslmode", sslMode)
+	if db.Config.Timeout > 0 {
+		// connect_timeout is in seconds, round up the milliseconds of config.
+		query.Set("connect_timeout", strconv.Itoa((db.Config.Timeout+999)/1000))
+	}
+	if db.Config.Schema != "" {
+		query.Set("search_path", db.Config.Schema)
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(db.Config.Username, db.Config.Password),
+		Host:     net.JoinHostPort(db.Config.Host, strconv.Itoa(db.Config.Port)),
+		Path:     "/" + db.Config.Database,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+func (db *PostgresDB) getDB() (connPool *sql.DB, err error) {
+	connPool, err = sql.Open("postgres", db.getDSN())
+	if err != nil {
+		return
+	}
+	connPool.SetMaxOpenConns(db.Config.MaxOpenConns)
+	connPool.SetMaxIdleConns(db.Config.MaxIdleConns)
+	err = connPool.Ping()
+	return
+}
+func (db *PostgresDB) AR() (ar gcore.ActiveRecord) {
+	ar0 := new(PostgresActiveRecord)
+	ar0.Reset()
+	ar0.tablePrefix = db.Config.TablePrefix
+	ar0.tablePrefixSQLIdentifier = db.Config.TablePrefixSQLIdentifier
+	ar0.primaryKey = db.Config.PrimaryKey
+	return ar0
+}
+// AddHook adds a hook called before and after every sql statement executed.
+func (db *PostgresDB) AddHook(hook gcore.DBHook) {
+	db.hooks.add(hook)
+}
+func (db *PostgresDB) Stats() sql.DBStats {
+	return db.ConnPool.Stats()
+}
+func (db *PostgresDB) Begin() (tx *sql.Tx, err error) {
+	return db.BeginContext(context.Background(), nil)
+}
+func (db *PostgresDB) BeginContext(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
+	return db.ConnPool.BeginTx(ctx, opts)
+}
+// Transaction executes fn in a transaction, the transaction is committed if fn returns nil,
+// and rolled back if fn returns an error or panics.
+func (db *PostgresDB) Transaction(fn func(tx gcore.Tx) error) (err error) {
+	return db.TransactionContext(context.Background(), nil, fn)
+}
+func (db *PostgresDB) TransactionContext(ctx context.Context, opts *sql.TxOptions, fn func(tx gcore.Tx) error) (err error) {
+	return transaction(ctx, db, opts, fn)
+}
+func (db *PostgresDB) ExecTx(ar gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+	return db.ExecTxContext(context.Background(), ar, tx)
+}
+func (db *PostgresDB) ExecTxContext(ctx context.Context, ar0 gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+	ar := ar0.(*PostgresActiveRecord)
+	return db.execSQL(ctx, tx, ar.SQL(), ar.returning, ar.values...)
+}
+func (db *PostgresDB) ExecSQLTx(tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.ExecSQLTxContext(context.Background(), tx, sqlStr, values...)
+}
+func (db *PostgresDB) ExecSQLTxContext(ctx context.Context, tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.execSQL(ctx, tx, postgresRebindRaw(sqlStr), "", values...)
+}
+func (db *PostgresDB) Exec(ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return db.ExecContext(context.Background(), ar)
+}
+func (db *PostgresDB) ExecContext(ctx context.Context, ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	ar := ar0.(*PostgresActiveRecord)
+	return db.execSQL(ctx, db.ConnPool, ar.SQL(), ar.returning, ar.values...)
+}
+func (db *PostgresDB) ExecSQL(sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.ExecSQLContext(context.Background(), sqlStr, values...)
+}
+func (db *PostgresDB) ExecSQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.execSQL(ctx, db.ConnPool, postgresRebindRaw(sqlStr), "", values...)
+}
+
+// execSQL executes the sql statement. lib/pq does not support LastInsertId, so when
+// returning is not empty, the statement must end with a RETURNING clause, and the
+// returned values are used to fill the LastInsertID and RowsAffected of the result set.
+func (db *PostgresDB) execSQL(ctx context.Context, preparer sqlPreparer, sqlStr, returning string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	start := time.Now()
+	if db.Config.TablePrefix != "" && db.Config.TablePrefixSQLIdentifier != "" {
+		sqlStr = strings.Replace(sqlStr, db.Config.TablePrefixSQLIdentifier, db.Config.TablePrefix, -1)
+	}
+	rsRaw := new(ResultSet)
+	if returning != "" {
+		rsRaw.lastInsertID, rsRaw.rowsAffected, err = db.execReturning(ctx, preparer, sqlStr, values...)
+		if err != nil {
+			return
+		}
+	} else {
+		var result sql.Result
+		result, err = execStmt(ctx, db.hooks, preparer, sqlStr, values...)
+		if err != nil {
+			return
+		}
+		rsRaw.rowsAffected, err = result.RowsAffected()
+		if err != nil {
+			return
+		}
+	}
+	rsRaw.timeUsed = time.Now().Sub(start)
+	rsRaw.sql = sqlStr
+	rs = rsRaw
+	invalidateQueryCache(db.Config.Cache, preparer, sqlStr)
+	return
+}
+
+// execReturning executes the statement with a RETURNING clause, returns the first returned id and the count of rows.
+func (db *PostgresDB) execReturning(ctx context.Context, preparer sqlPreparer, sqlStr string, values ...interface{}) (lastInsertID, rowsAffected int64, err error) {
+	ctx, e := db.hooks.before(ctx, preparer, sqlStr, values)
+	defer func() {
+		db.hooks.after(ctx, e, rowsAffected, err)
+	}()
+	var stmt *sql.Stmt
+	stmt, err = preparer.PrepareContext(ctx, sqlStr)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.QueryContext(ctx, values...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id []byte
+		err = rows.Scan(&id)
+		if err != nil {
+			return
+		}
+		if rowsAffected == 0 {
+			// keep the same behavior as mysql, the id of the first inserted row.
+			lastInsertID, _ = strconv.ParseInt(string(id), 10, 64)
+		}
+		rowsAffected++
+	}
+	err = rows.Err()
+	return
+}
+func (db *PostgresDB) QuerySQL(sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.QuerySQLContext(context.Background(), sqlStr, values...)
+}
+func (db *PostgresDB) QuerySQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.querySQL(ctx, db.ConnPool, postgresRebindRaw(sqlStr), values...)
+}
+func (db *PostgresDB) QuerySQLTx(tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.QuerySQLTxContext(context.Background(), tx, sqlStr, values...)
+}
+func (db *PostgresDB) QuerySQLTxContext(ctx context.Context, tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.querySQL(ctx, tx, postgresRebindRaw(sqlStr), values...)
+}
+func (db *PostgresDB) querySQL(ctx context.Context, preparer sqlPreparer, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	if db.Config.TablePrefix != "" && db.Config.TablePrefixSQLIdentifier != "" {
+		sqlStr = strings.Replace(sqlStr, db.Config.TablePrefixSQLIdentifier, db.Config.TablePrefix, -1)
+	}
+	start := time.Now()
+	var results []map[string][]byte
+	results, err = queryRows(ctx, db.hooks, preparer, sqlStr, values...)
+	if err != nil {
+		return
+	}
+	rsRaw := NewResultSet(&results)
+	rsRaw.timeUsed = time.Now().Sub(start)
+	rsRaw.sql = sqlStr
+	rs = rsRaw
+	return
+}
+func (db *PostgresDB) Query(ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return db.QueryContext(context.Background(), ar)
+}
+func (db *PostgresDB) QueryContext(ctx context.Context, ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return db.query(ctx, db.ConnPool, ar)
+}
+
+// QueryEach executes the query and calls fn with every row, the rows are scanned one by one
+// instead of loading all of them into memory, fn can return ErrStopEach to stop iterating.
+func (db *PostgresDB) QueryEach(ar gcore.ActiveRecord, fn func(row gcore.DBRow) error) (err error) {
+	return db.QueryEachContext(context.Background(), ar, fn)
+}
+func (db *PostgresDB) QueryEachContext(ctx context.Context, ar gcore.ActiveRecord, fn func(row gcore.DBRow) error) (err error) {
+	sqlStr := ar.SQL()
+	return eachRow(ctx, db.hooks, db.ConnPool, sqlStr, fn, ar.Values()...)
+}
+func (db *PostgresDB) QueryTx(ar gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+	return db.QueryTxContext(context.Background(), ar, tx)
+}
+func (db *PostgresDB) QueryTxContext(ctx context.Context, ar gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+	return db.query(ctx, tx, ar)
+}
+func (db *PostgresDB) query(ctx context.Context, preparer sqlPreparer, ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	ar := ar0.(*PostgresActiveRecord)
+	start := time.Now()
+	var results []map[string][]byte
+	if ar.cacheKey != "" {
+		var data []byte
+		data, err = db.Config.Cache.Get(ar.cacheKey)
+		if err == nil {
+			d := gob.NewDecoder(bytes.NewReader(data))
+			err = d.Decode(&results)
+			if err != nil {
+				return
+			}
+		}
+	}
+	if results == nil || len(results) == 0 {
+		var tags []string
+		var version string
+		if ar.cacheKey != "" {
+			tags, version, err = queryCacheTags(db.Config.Cache, ar.SQL())
+			if err != nil {
+				return
+			}
+		}
+		results, err = queryRows(ctx, db.hooks, preparer, ar.SQL(), ar.values...)
+		if err != nil {
+			return
+		}
+		if ar.cacheKey != "" {
+			b := new(bytes.Buffer)
+			e := gob.NewEncoder(b)
+			err = e.Encode(results)
+			if err != nil {
+				return
+			}
+			err = setQueryCache(db.Config.Cache, ar.cacheKey, b.Bytes(), ar.cacheSeconds, tags, version)
+			if err != nil {
+				return
+			}
+		}
+	}
+	rsRaw := NewResultSet(&results)
+	rsRaw.timeUsed = time.Now().Sub(start)
+	rsRaw.sql = ar.SQL()
+	rs = rsRaw
+	return
+}
+
+type PostgresDBConfig struct {
+	Database                 string
+	Host                     string
+	Port                     int
+	Username                 string
+	Password                 string
+	Schema                   string
+	SSLMode                  string
+	TablePrefix              string
+	TablePrefixSQLIdentifier string
+	// Timeout connect timeout in milliseconds, postgres only supports seconds,
+	// so it will be rounded up to seconds.
+	Timeout      int
+	MaxIdleConns int
+	MaxOpenConns int
+	// PrimaryKey is the default conflict column of Replace and ReplaceBatch.
+	PrimaryKey string
+	Cache      gcore.DBCache
+}
+
+func NewPostgresDBConfigWith(host string, port int, dbName, user, pass string) (cfg PostgresDBConfig) {
+	cfg = NewPostgresDBConfig()
+	cfg.Host = host
+	cfg.Port = port
+	cfg.Username = user
+	cfg.Password = pass
+	cfg.Database = dbName
+	return
+}
+func NewPostgresDBConfig() PostgresDBConfig {
+	return PostgresDBConfig{
+		Database:                 "test",
+		Host:                     "127.0.0.1",
+		Port:                     5432,
+		Username:                 "postgres",
+		Password:                 "",
+		Schema:                   "",
+		SSLMode:                  "disable",
+		TablePrefix:              "",
+		TablePrefixSQLIdentifier: "",
+		Timeout:                  3000,
+		MaxOpenConns:             500,
+		MaxIdleConns:             50,
+		PrimaryKey:               "id",
+	}
+}
+
+type PostgresActiveRecord struct {
+	arSelect                 [][]interface{}
+	arFrom                   []string
+	arJoin                   [][]string
+	arWhere                  [][]interface{}
+	arGroupBy                []string
+	arHaving                 [][]interface{}
+	arOrderBy                *gmap.Map
+	arLimit                  string
+	arSet                    map[string][]interface{}
+	arUpdateBatch            []interface{}
+	arInsert                 gmap.M
+	arInsertBatch            []gmap.M
+	asTable                  map[string]bool
+	values                   []interface{}
+	sqlType                  string
+	currentSQL               string
+	placeholderCurrentSQL    string
+	tablePrefix              string
+	tablePrefixSQLIdentifier string
+	cacheKey                 string
+	cacheSeconds             uint
+	primaryKey               string
+	returning                string
+	conflictColumns          []string
+}
+
+func (ar *PostgresActiveRecord) Cache(key string, seconds uint) gcore.ActiveRecord {
+	ar.cacheKey = key
+	ar.cacheSeconds = seconds
+	return ar
+}
+func (ar *PostgresActiveRecord) GetValues() []interface{} {
+	return ar.values
+}
+func (ar *PostgresActiveRecord) Reset() {
+	ar.arSelect = [][]interface{}{}
+	ar.arFrom = []string{}
+	ar.arJoin = [][]string{}
+	ar.arWhere = [][]interface{}{}
+	ar.arGroupBy = []string{}
+	ar.arHaving = [][]interface{}{}
+	ar.arOrderBy = gmap.New()
+	ar.arLimit = ""
+	ar.arSet = map[string][]interface{}{}
+	ar.arUpdateBatch = []interface{}{}
+	ar.arInsert = gmap.M{}
+	ar.arInsertBatch = []gmap.M{}
+	ar.asTable = map[string]bool{}
+	ar.values = []interface{}{}
+	ar.sqlType = "select"
+	ar.currentSQL = ""
+	ar.placeholderCurrentSQL = ""
+	ar.cacheKey = ""
+	ar.cacheSeconds = 0
+	ar.returning = ""
+	ar.conflictColumns = nil
+}
+
+// Returning sets the column of RETURNING clause of Insert, InsertBatch, Replace and ReplaceBatch,
+// the value of the column will be used as the LastInsertID of the result set.
+func (ar *PostgresActiveRecord) Returning(column string) gcore.ActiveRecord {
+	ar.returning = column
+	return ar
+}
+
+// OnConflict sets the conflict columns of Replace and ReplaceBatch,
+// default is the PrimaryKey of PostgresDBConfig.
+func (ar *PostgresActiveRecord) OnConflict(columns ...string) gcore.ActiveRecord {
+	ar.conflictColumns = columns
+	return ar
+}
+
+func (ar *PostgresActiveRecord) Select(columns string) gcore.ActiveRecord {
+	return ar._select(columns, true)
+}
+func (ar *PostgresActiveRecord) SelectNoWrap(columns string) gcore.ActiveRecord {
+	return ar._select(columns, false)
+}
+
+func (ar *PostgresActiveRecord) _select(columns string, wrap bool) gcore.ActiveRecord {
+	for _, column := range strings.Split(columns, ",") {
+		ar.arSelect = append(ar.arSelect, []interface{}{column, wrap})
+	}
+	return ar
+}
+func (ar *PostgresActiveRecord) From(from string) gcore.ActiveRecord {
+	ar.FromAs(from, "")
+	return ar
+}
+func (ar *PostgresActiveRecord) FromAs(from, as string) gcore.ActiveRecord {
+	ar.arFrom = []string{from, as}
+	if as != "" {
+		ar.asTable[as] = true
+	}
+	return ar
+}
+
+func (ar *PostgresActiveRecord) Join(table, as, on, typ string) gcore.ActiveRecord {
+	ar.arJoin = append(ar.arJoin, []string{table, as, on, typ})
+	return ar
+}
+func (ar *PostgresActiveRecord) Where(where gmap.M) gcore.ActiveRecord {
+	if len(where) > 0 {
+		ar.WhereWrap(where, "AND", "")
+	}
+	return ar
+}
+func (ar *PostgresActiveRecord) WhereRaw(where string) gcore.ActiveRecord {
+	ar.Where(gmap.M{":" + where: ""})
+	return ar
+}
+func (ar *PostgresActiveRecord) WhereWrap(where gmap.M, leftWrap, rightWrap string) gcore.ActiveRecord {
+	if len(where) > 0 {
+		ar.arWhere = append(ar.arWhere, []interface{}{where, leftWrap, rightWrap, len(ar.arWhere)})
+	}
+	return ar
+}
+
+// WhereCond adds the condition built by gdb.And, gdb.Or, gdb.In etc, it is joined by AND.
+func (ar *PostgresActiveRecord) WhereCond(cond gcore.DBCond) gcore.ActiveRecord {
+	if cond != nil {
+		ar.arWhere = append(ar.arWhere, []interface{}{cond, "AND", "", len(ar.arWhere)})
+	}
+	return ar
+}
+func (ar *PostgresActiveRecord) GroupBy(column string) gcore.ActiveRecord {
+	for _, columnCurrent := range strings.Split(column, ",") {
+		ar.arGroupBy = append(ar.arGroupBy, strings.TrimSpace(columnCurrent))
+	}
+	return ar
+}
+func (ar *PostgresActiveRecord) Having(having string) gcore.ActiveRecord {
+	ar.HavingWrap(having, "AND", "")
+	return ar
+}
+func (ar *PostgresActiveRecord) HavingWrap(having, leftWrap, rightWrap string) gcore.ActiveRecord {
+	ar.arHaving = append(ar.arHaving, []interface{}{having, leftWrap, rightWrap, len(ar.arHaving)})
+	return ar
+}
+
+func (ar *PostgresActiveRecord) OrderBy(column, typ string) gcore.ActiveRecord {
+	ar.arOrderBy.Store(column, typ)
+	return ar
+}
+
+// Limit Limit(offset,count) or Limit(count)
+func (ar *PostgresActiveRecord) Limit(limit ...int) gcore.ActiveRecord {
+	if len(limit) == 1 {
+		ar.arLimit = fmt.Sprintf("%d", limit[0])
+	} else if len(limit) == 2 {
+		ar.arLimit = fmt.Sprintf("%d OFFSET %d", limit[1], limit[0])
+	} else {
+		ar.arLimit = ""
+	}
+	return ar
+}
+
+func (ar *PostgresActiveRecord) Insert(table string, data gmap.M) gcore.ActiveRecord {
+	ar.sqlType = "insert"
+	ar.arInsert = data
+	ar.From(table)
+	return ar
+}
+func (ar *PostgresActiveRecord) Replace(table string, data gmap.M) gcore.ActiveRecord {
+	ar.sqlType = "replace"
+	ar.arInsert = data
+	ar.From(table)
+	return ar
+}
+
+func (ar *PostgresActiveRecord) InsertBatch(table string, data []gmap.M) gcore.ActiveRecord {
+	ar.sqlType = "insertBatch"
+	ar.arInsertBatch = data
+	ar.From(table)
+	return ar
+}
+func (ar *PostgresActiveRecord) ReplaceBatch(table string, data []gmap.M) gcore.ActiveRecord {
+	ar.InsertBatch(table, data)
+	ar.sqlType = "replaceBatch"
+	return ar
+}
+
+func (ar *PostgresActiveRecord) Delete(table string, where gmap.M) gcore.ActiveRecord {
+	ar.From(table)
+	ar.Where(where)
+	ar.sqlType = "delete"
+	return ar
+}
+func (ar *PostgresActiveRecord) Update(table string, data, where gmap.M) gcore.ActiveRecord {
+	ar.From(table)
+	ar.Where(where)
+	_data := sortMap(data, true)
+	for _, val := range _data {
+		k, v := val["col"].(string), val["value"]
+		if v == nil {
+			ar.SetNoWrap(k, "NULL")
+		} else {
+			ar.Set(k, v)
+		}
+	}
+	return ar
+}
+
+func (ar *PostgresActiveRecord) UpdateBatch(table string, values []gmap.M, whereColumn []string) gcore.ActiveRecord {
+	ar.From(table)
+	ar.sqlType = "updateBatch"
+	ar.arUpdateBatch = []interface{}{values, whereColumn}
+	if len(values) > 0 {
+		for _, whereCol := range whereColumn {
+			ids := []interface{}{}
+			for _, val := range values {
+				ids = append(ids, val[whereCol])
+			}
+			ar.Where(gmap.M{whereCol: ids})
+		}
+	}
+	return ar
+}
+
+func (ar *PostgresActiveRecord) Set(column string, value interface{}) gcore.ActiveRecord {
+	ar.sqlType = "update"
+	ar.arSet[column] = []interface{}{value, true}
+	return ar
+}
+func (ar *PostgresActiveRecord) SetNoWrap(column string, value interface{}) gcore.ActiveRecord {
+	ar.sqlType = "update"
+	ar.arSet[column] = []interface{}{value, false}
+	return ar
+}
+func (ar *PostgresActiveRecord) Wrap(v string) string {
+	columns := strings.Split(v, ".")
+	if len(columns) == 2 {
+		return ar.protectIdentifier(ar.checkPrefix(columns[0])) + "." + ar.checkPrefix(columns[1])
+	}
+	return ar.protectIdentifier(ar.checkPrefix(columns[0]))
+}
+func (ar *PostgresActiveRecord) Raw(sql string, values ...interface{}) gcore.ActiveRecord {
+	if ar.tablePrefix != "" && ar.tablePrefixSQLIdentifier != "" {
+		sql = strings.Replace(sql, ar.tablePrefixSQLIdentifier, ar.tablePrefix, -1)
+	}
+	ar.placeholderCurrentSQL = sql
+	ar.currentSQL = postgresRebindRaw(sql)
+	if len(values) > 0 {
+		ar.values = append(ar.values, values...)
+	}
+	return ar
+}
+func (ar *PostgresActiveRecord) Values() []interface{} {
+	return ar.values
+}
+func (ar *PostgresActiveRecord) SQL() string {
+
+	if ar.currentSQL != "" {
+		return ar.currentSQL
+	}
+	switch ar.sqlType {
+	case "select":
+		ar.currentSQL = ar.getSelectSQL()
+	case "update":
+		ar.currentSQL = ar.getUpdateSQL()
+	case "updateBatch":
+		ar.currentSQL = ar.getUpdateBatchSQL()
+	case "insert":
+		ar.currentSQL = ar.getInsertSQL()
+	case "insertBatch":
+		ar.currentSQL = ar.getInsertBatchSQL()
+	case "replace":
+		ar.currentSQL = ar.getReplaceSQL()
+	case "replaceBatch":
+		ar.currentSQL = ar.getReplaceBatchSQL()
+	case "delete":
+		ar.currentSQL = ar.getDeleteSQL()
+	}
+	if ar.tablePrefix != "" && ar.tablePrefixSQLIdentifier != "" {
+		ar.currentSQL = strings.Replace(ar.currentSQL, ar.tablePrefixSQLIdentifier, ar.tablePrefix, -1)
+	}
+	ar.placeholderCurrentSQL = ar.currentSQL
+	ar.currentSQL = postgresRebind(ar.currentSQL)
+	return ar.currentSQL
+}
+
+// placeholderSQL returns the SQL with ? placeholders, it is used to build subqueries.
+func (ar *PostgresActiveRecord) placeholderSQL() string {
+	sqlStr := ar.SQL()
+	if ar.placeholderCurrentSQL != "" {
+		return ar.placeholderCurrentSQL
+	}
+	return sqlStr
+}
+
+// getUpdateSQL postgres does not support ORDER BY and LIMIT in UPDATE statement, they are ignored.
+func (ar *PostgresActiveRecord) getUpdateSQL() string {
+	SQL := []string{"UPDATE "}
+	SQL = append(SQL, ar.getFrom())
+	SQL = append(SQL, "\nSET")
+	SQL = append(SQL, ar.compileSet())
+	SQL = append(SQL, ar.getWhere())
+	return strings.Join(SQL, " ")
+}
+
+func (ar *PostgresActiveRecord) getUpdateBatchSQL() string {
+	SQL := []string{"UPDATE "}
+	SQL = append(SQL, ar.getFrom())
+	SQL = append(SQL, "\nSET")
+	SQL = append(SQL, ar.compileUpdateBatch())
+	SQL = append(SQL, ar.getWhere())
+	return strings.Join(SQL, " ")
+}
+func (ar *PostgresActiveRecord) getInsertSQL() string {
+	SQL := []string{"INSERT INTO "}
+	SQL = append(SQL, ar.getFrom())
+	SQL = append(SQL, ar.compileInsert())
+	SQL = append(SQL, ar.compileReturning())
+	return strings.Join(SQL, " ")
+}
+func (ar *PostgresActiveRecord) getReplaceSQL() string {
+	SQL := []string{"INSERT INTO "}
+	SQL = append(SQL, ar.getFrom())
+	SQL = append(SQL, ar.compileInsert())
+	SQL = append(SQL, ar.compileOnConflict(sortMap(ar.arInsert, true)))
+	SQL = append(SQL, ar.compileReturning())
+	return strings.Join(SQL, " ")
+}
+func (ar *PostgresActiveRecord) getInsertBatchSQL() string {
+	SQL := []string{"INSERT INTO "}
+	SQL = append(SQL, ar.getFrom())
+	SQL = append(SQL, ar.compileInsertBatch())
+	SQL = append(SQL, ar.compileReturning())
+	return strings.Join(SQL, " ")
+}
+func (ar *PostgresActiveRecord) getReplaceBatchSQL() string {
+	SQL := []string{"INSERT INTO "}
+	SQL = append(SQL, ar.getFrom())
+	SQL = append(SQL, ar.compileInsertBatch())
+	SQL = append(SQL, ar.compileOnConflict(sortMap(ar.arInsertBatch[0], true)))
+	SQL = append(SQL, ar.compileReturning())
+	return strings.Join(SQL, " ")
+}
+
+// getDeleteSQL postgres does not support ORDER BY and LIMIT in DELETE statement, they are ignored.
+func (ar *PostgresActiveRecord) getDeleteSQL() string {
+	SQL := []string{"DELETE FROM "}
+	SQL = append(SQL, ar.getFrom())
+	SQL = append(SQL, ar.getWhere())
+	return strings.Join(SQL, " ")
+}
+func (ar *PostgresActiveRecord) compileReturning() string {
+	if ar.returning == "" {
+		return ""
+	}
+	return "\nRETURNING " + ar.protectIdentifier(ar.returning)
+}
+
+// compileOnConflict makes Replace and ReplaceBatch work like REPLACE INTO of mysql,
+// the row conflicted with the conflict columns will be updated by the new values.
+func (ar *PostgresActiveRecord) compileOnConflict(data []map[string]interface{}) string {
+	conflictColumns := ar.conflictColumns
+	if len(conflictColumns) == 0 && ar.primaryKey != "" {
+		conflictColumns = []string{ar.primaryKey}
+	}
+	if len(conflictColumns) == 0 {
+		return "\nON CONFLICT DO NOTHING"
+	}
+	isConflictColumn := map[string]bool{}
+	var target []string
+	for _, col := range conflictColumns {
+		isConflictColumn[col] = true
+		target = append(target, ar.protectIdentifier(col))
+	}
+	var set []string
+	for _, val := range data {
+		col := val["col"].(string)
+		if isConflictColumn[col] {
+			continue
+		}
+		set = append(set, fmt.Sprintf("%s = EXCLUDED.%s", ar.protectIdentifier(col), ar.protectIdentifier(col)))
+	}
+	if len(set) == 0 {
+		return fmt.Sprintf("\nON CONFLICT (%s) DO NOTHING", strings.Join(target, ","))
+	}
+	return fmt.Sprintf("\nON CONFLICT (%s) DO UPDATE SET %s", strings.Join(target, ","), strings.Join(set, ","))
+}
+func (ar *PostgresActiveRecord) getSelectSQL() string {
+	from := ar.getFrom()
+	where := ar.getWhere()
+	having := ""
+	for _, w := range ar.arHaving {
+		having += ar.compileWhere(w[0], w[1].(string), w[2].(string), w[3].(int))
+	}
+	having = strings.TrimSpace(having)
+	if having != "" {
+		having = fmt.Sprintf("\nHAVING %s", having)
+	}
+	groupBy := strings.TrimSpace(ar.compileGroupBy())
+	if groupBy != "" {
+		groupBy = fmt.Sprintf("\nGROUP BY %s", groupBy)
+	}
+	orderBy := strings.TrimSpace(ar.compileOrderBy())
+	if orderBy != "" {
+		orderBy = fmt.Sprintf("\nORDER BY %s", orderBy)
+	}
+	limit := ar.getLimit()
+	Select := ar.compileSelect()
+	return fmt.Sprintf("SELECT %s \nFROM %s %s %s %s %s %s", Select, from, where, groupBy, having, orderBy, limit)
+}
+func (ar *PostgresActiveRecord) compileUpdateBatch() string {
+	_values, _index := ar.arUpdateBatch[0], ar.arUpdateBatch[1]
+	index := _index.([]string)
+	values := _values.([]gmap.M)
+	columns := []string{}
+	for _, val := range sortMap(values[0], true) {
+		k := val["col"].(string)
+		_continue := false
+		for _, v1 := range index {
+			if k == v1 {
+				_continue = true
+				break
+			}
+		}
+		if _continue {
+			continue
+		}
+		columns = append(columns, k)
+	}
+	str := ""
+	for _, column := range columns {
+		_column := column
+		realColumnArr := strings.Split(column, " ")
+		if len(realColumnArr) == 2 {
+			_column = realColumnArr[0]
+		}
+		str += fmt.Sprintf("%s = CASE \n", ar.protectIdentifier(_column))
+		for _, row := range values {
+			_when := []string{}
+			for _, col := range index {
+				_when = append(_when, fmt.Sprintf("%s = ?", ar.protectIdentifier(col)))
+				ar.values = append(ar.values, row[col])
+			}
+			_whenStr := strings.Join(_when, " AND ")
+			if len(realColumnArr) == 2 {
+				str += fmt.Sprintf("WHEN %s THEN %s %s ? \n", _whenStr, ar.protectIdentifier(_column), realColumnArr[1])
+			} else {
+				str += fmt.Sprintf("WHEN %s THEN ? \n", _whenStr)
+			}
+			ar.values = append(ar.values, row[column])
+		}
+		str += fmt.Sprintf("ELSE %s END,", ar.protectIdentifier(_column))
+	}
+	return strings.TrimRight(str, " ,")
+}
+
+func (ar *PostgresActiveRecord) compileInsert() string {
+	var columns = []string{}
+	var values = []string{}
+	data := sortMap(ar.arInsert, true)
+	for _, val := range data {
+		k, v := val["col"].(string), val["value"]
+		columns = append(columns, ar.protectIdentifier(k))
+		values = append(values, "?")
+		ar.values = append(ar.values, v)
+	}
+	if len(columns) > 0 {
+		return fmt.Sprintf("(%s) \nVALUES (%s)", strings.Join(columns, ","), strings.Join(values, ","))
+	}
+	return ""
+}
+func (ar *PostgresActiveRecord) compileInsertBatch() string {
+	var columns []string
+	var values []string
+	data := sortMap(ar.arInsertBatch[0], true)
+	for _, val := range data {
+		col := val["col"].(string)
+		columns = append(columns, ar.protectIdentifier(col))
+	}
+	for _, row := range ar.arInsertBatch {
+		_values := []string{}
+		for _, col := range columns {
+			_values = append(_values, "?")
+			ar.values = append(ar.values, row[strings.Trim(col, `"`)])
+		}
+		values = append(values, fmt.Sprintf("(%s)", strings.Join(_values, ",")))
+	}
+	return fmt.Sprintf("(%s) \nVALUES %s", strings.Join(columns, ","), strings.Join(values, ","))
+}
+func (ar *PostgresActiveRecord) compileSet() string {
+	set := []string{}
+	for key, _value := range ar.arSet {
+		value, wrap := _value[0], _value[1]
+		_column := key
+		op := ""
+		realColumnArr := strings.Split(key, " ")
+		if len(realColumnArr) == 2 {
+			_column = realColumnArr[0]
+			op = realColumnArr[1]
+		}
+		if wrap.(bool) {
+			if op != "" {
+				set = append(set, fmt.Sprintf("%s = %s %s ?", ar.protectIdentifier(_column), ar.protectIdentifier(_column), op))
+			} else {
+				set = append(set, fmt.Sprintf("%s = ?", ar.protectIdentifier(_column)))
+			}
+			ar.values = append(ar.values, value)
+		} else {
+			set = append(set, fmt.Sprintf("%s = %s", ar.protectIdentifier(_column), value))
+		}
+	}
+	return strings.Join(set, ",")
+}
+func (ar *PostgresActiveRecord) compileGroupBy() string {
+	groupBy := []string{}
+	for _, key := range ar.arGroupBy {
+		_key := strings.Split(key, ".")
+		if len(_key) == 2 {
+			groupBy = append(groupBy, fmt.Sprintf("%s.%s", ar.protectIdentifier(ar.checkPrefix(_key[0])), ar.protectIdentifier(_key[1])))
+		} else {
+			groupBy = append(groupBy, fmt.Sprintf("%s", ar.protectIdentifier(_key[0])))
+		}
+	}
+	return strings.Join(groupBy, ",")
+}
+
+func (ar *PostgresActiveRecord) compileOrderBy() string {
+	orderBy := []string{}
+	ar.arOrderBy.RangeFast(func(k, v interface{}) bool {
+		key := k.(string)
+		Type := strings.ToUpper(v.(string))
+		_key := strings.Split(key, ".")
+		if len(_key) == 2 {
+			orderBy = append(orderBy, fmt.Sprintf("%s.%s %s", ar.protectIdentifier(ar.checkPrefix(_key[0])), ar.protectIdentifier(_key[1]), Type))
+		} else {
+			orderBy = append(orderBy, fmt.Sprintf("%s %s", ar.protectIdentifier(_key[0]), Type))
+		}
+		return true
+	})
+	return strings.Join(orderBy, ",")
+}
+func (ar *PostgresActiveRecord) compileCond(cond gcore.DBCond, leftWrap, rightWrap string, index int) string {
+	if index == 0 {
+		leftWrap = ""
+	}
+	sqlStr, args := cond.Build(ar.wrapColumn)
+	ar.values = append(ar.values, args...)
+	return fmt.Sprintf(" %s %s %s ", leftWrap, sqlStr, rightWrap)
+}
+func (ar *PostgresActiveRecord) wrapColumn(column string) string {
+	keys := strings.Split(strings.TrimSpace(column), ".")
+	if len(keys) == 2 {
+		return ar.protectIdentifier(ar.checkPrefix(keys[0])) + "." + ar.protectIdentifier(keys[1])
+	}
+	return ar.protectIdentifier(keys[0])
+}
+func (ar *PostgresActiveRecord) compileWhere(where0 interface{}, leftWrap, rightWrap string, index int) string {
+
+	_where := []string{}
+	if index == 0 {
+		str := strings.ToUpper(strings.TrimSpace(leftWrap))
+		if strings.Contains(str, "AND") || strings.Contains(str, "OR") {
+			leftWrap = ""
+		}
+	}
+	if reflect.TypeOf(where0).Kind() == reflect.String {
+		return fmt.Sprintf(" %s %s %s ", leftWrap, where0, rightWrap)
+	}
+	where := sortMap(where0.(gmap.M), true)
+	for _, val := range where {
+		key, value := val["col"].(string), val["value"]
+		k := ""
+		k = strings.TrimSpace(key)
+		_key := strings.SplitN(k, " ", 2)
+		op := ""
+		if len(_key) == 2 {
+			op = _key[1]
+		}
+		keys := strings.Split(_key[0], ".")
+		if len(keys) == 2 {
+			k = ar.protectIdentifier(ar.checkPrefix(keys[0])) + "." + ar.protectIdentifier(keys[1])
+		} else {
+			k = ar.protectIdentifier(keys[0])
+		}
+
+		if isArray(value) {
+			if op != "" {
+				op += " IN"
+			} else {
+				op = "IN"
+			}
+			op = strings.ToUpper(op)
+			l := reflect.ValueOf(value).Len()
+
+			_v := []string{}
+			for i := 0; i < l; i++ {
+				_v = append(_v, "?")
+			}
+			_where = append(_where, fmt.Sprintf("%s %s (%s)", k, op, strings.Join(_v, ",")))
+			for _, v := range *ar.interface2Slice(value) {
+				ar.values = append(ar.values, v)
+			}
+		} else if isBool(value) {
+			if op == "" {
+				op = "="
+			}
+			op = strings.ToUpper(op)
+			_where = append(_where, fmt.Sprintf("%s %s ?", k, op))
+			ar.values = append(ar.values, value)
+		} else if value == nil {
+			if op == "" {
+				op = "IS"
+			}
+			op = strings.ToUpper(op)
+			_where = append(_where, fmt.Sprintf("%s %s NULL", k, op))
+		} else {
+			if op == "" {
+				op = "="
+			}
+			op = strings.ToUpper(op)
+			if key[0] == ':' {
+				_where = append(_where, key[1:])
+			} else {
+				_where = append(_where, fmt.Sprintf("%s %s ?", k, op))
+				ar.values = append(ar.values, value)
+			}
+		}
+	}
+	return fmt.Sprintf(" %s %s %s ", leftWrap, strings.Join(_where, " AND "), rightWrap)
+}
+func (ar *PostgresActiveRecord) interface2Slice(data interface{}) (arr *[]interface{}) {
+	arr = &[]interface{}{}
+	val := reflect.ValueOf(data)
+	if val.Kind() == reflect.Array || val.Kind() == reflect.Slice {
+		for i := 0; i < val.Len(); i++ {
+			e := val.Index(i)
+			*arr = append(*arr, e.Interface())
+		}
+	}
+	return
+}
+func (ar *PostgresActiveRecord) compileSelect() string {
+	selects := ar.arSelect
+	columns := []string{}
+	if len(selects) == 0 {
+		selects = append(selects, []interface{}{"*", true})
+	}
+	for _, v := range selects {
+		protect := v[1].(bool)
+		value := strings.TrimSpace(v[0].(string))
+		if value != "*" {
+			info := strings.Split(value, ".")
+			if len(info) == 2 {
+				_v := ar.checkPrefix(info[0])
+				if protect {
+					info[0] = ar.protectIdentifier(_v)
+					info[1] = ar.protectIdentifier(info[1])
+				} else {
+					info[0] = _v
+				}
+				value = strings.Join(info, ".")
+			} else if protect {
+				value = ar.protectIdentifier(value)
+			}
+		}
+		columns = append(columns, value)
+	}
+	return strings.Join(columns, ",")
+}
+
+func (ar *PostgresActiveRecord) checkPrefix(v string) string {
+	if strings.Contains(v, "(") || strings.Contains(v, ")") || strings.TrimSpace(v) == "*" {
+		return v
+	}
+	if ar.tablePrefix != "" && !strings.Contains(v, ar.tablePrefix) {
+		if _, exists := ar.asTable[v]; !exists {
+			return ar.tablePrefix + v
+		}
+	}
+	return v
+}
+func (ar *PostgresActiveRecord) protectIdentifier(v string) string {
+	if strings.Contains(v, "(") || strings.Contains(v, ")") || strings.TrimSpace(v) == "*" {
+		return v
+	}
+	values := strings.Split(v, " ")
+	if len(values) == 3 && strings.ToLower(values[1]) == "as" {
+		return fmt.Sprintf(`"%s" AS "%s"`, values[0], values[2])
+	}
+	return fmt.Sprintf(`"%s"`, v)
+}
+func (ar *PostgresActiveRecord) compileFrom(from, as string) string {
+	if as != "" {
+		ar.asTable[as] = true
+		as = " AS " + ar.protectIdentifier(as) + " "
+	}
+	return ar.protectIdentifier(ar.checkPrefix(from)) + as
+}
+func (ar *PostgresActiveRecord) compileJoin(table, as, on, typ string) string {
+	tableUsed := ""
+	if as != "" {
+		ar.asTable[table] = true
+		tableUsed = ar.protectIdentifier(ar.checkPrefix(table)) + " AS " + ar.protectIdentifier(as)
+	} else {
+		tableUsed = ar.protectIdentifier(ar.checkPrefix(table))
+	}
+	a := strings.Split(on, "=")
+	if len(a) == 2 {
+		left := strings.Split(a[0], ".")
+		right := strings.Split(a[1], ".")
+		left[0] = ar.protectIdentifier(ar.checkPrefix(left[0]))
+		left[1] = ar.protectIdentifier(left[1])
+		right[0] = ar.protectIdentifier(ar.checkPrefix(right[0]))
+		right[1] = ar.protectIdentifier(right[1])
+		on = strings.Join(left, ".") + "=" + strings.Join(right, ".")
+	}
+	return fmt.Sprintf(" %s JOIN %s ON %s ", typ, tableUsed, on)
+}
+
+func (ar *PostgresActiveRecord) getFrom() string {
+	table := ar.compileFrom(ar.arFrom[0], ar.arFrom[1])
+	for _, v := range ar.arJoin {
+		table += ar.compileJoin(v[0], v[1], v[2], v[3])
+	}
+	return table
+}
+func (ar *PostgresActiveRecord) getLimit() string {
+	limit := ar.arLimit
+	if limit != "" {
+		limit = fmt.Sprintf("\nLIMIT %s", limit)
+	}
+	return limit
+}
+func (ar *PostgresActiveRecord) getWhere() string {
+	where := []string{}
+	hasEmptyIn := false
+
+	for _, v := range ar.arWhere {
+		if cond, ok := v[0].(gcore.DBCond); ok {
+			where = append(where, ar.compileCond(cond, v[1].(string), v[2].(string), v[3].(int)))
+			continue
+		}
+		for _, value := range v[0].(gmap.M) {
+			if isArray(value) && reflect.ValueOf(value).Len() == 0 {
+				hasEmptyIn = true
+				break
+			}
+		}
+		if hasEmptyIn {
+			break
+		}
+		where = append(where, ar.compileWhere(v[0].(gmap.M), v[1].(string), v[2].(string), v[3].(int)))
+	}
+	if hasEmptyIn {
+		return "\nWHERE FALSE"
+	}
+	allWhere := strings.TrimSpace(strings.Join(where, ""))
+	if allWhere != "" {
+		allWhere = fmt.Sprintf("\nWHERE %s", allWhere)
+	}
+	return allWhere
+}
+
+// postgresRebindRaw converts the ? placeholders of the SQL written by the user, so the same SQL
+// works on mysql, sqlite3 and postgres. The SQL using $n placeholders outside the quoted strings is kept,
+// it is the way to use the jsonb operators ?, ?| and ?& in raw SQL.
+func postgresRebindRaw(sqlStr string) string {
+	return postgresRebindSQL(sqlStr, true)
+}
+
+// postgresRebind converts the ? placeholders to postgres $n placeholders,
+// the ? in quoted strings and identifiers are kept.
+func postgresRebind(sqlStr string) string {
+	return postgresRebindSQL(sqlStr, false)
+}
+
+// postgresRebindSQL converts the ? placeholders outside the quoted strings and identifiers,
+// if keepNumbered is true and a $n placeholder is found outside them, sqlStr is returned unchanged.
+func postgresRebindSQL(sqlStr string, keepNumbered bool) string {
+	if !strings.Contains(sqlStr, "?") {
+		return sqlStr
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(sqlStr)+16))
+	var quote byte
+	n := 0
+	for i := 0; i < len(sqlStr); i++ {
+		c := sqlStr[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			buf.WriteString("$" + strconv.Itoa(n))
+			continue
+		case keepNumbered && c == '$' && i+1 < len(sqlStr) && sqlStr[i+1] >= '0' && sqlStr[i+1] <= '9':
+			return sqlStr
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
@@ -0,0 +1,183 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+const (
+	ReplicaStrategyRoundRobin = "roundrobin"
+	ReplicaStrategyWeighted   = "weighted"
+)
+
+type primaryContextKey struct{}
+
+// WithPrimary returns a copy of ctx, the queries executed with the returned context are
+// routed to the primary, it is useful for reading data just written, because the replicas
+// may be lagging behind.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+func isPrimaryContext(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryContextKey{}).(bool)
+	return v
+}
+
+// primaryDatabase returns a copy of db which executes all queries on the primary,
+// db is returned if it has no replicas.
+func primaryDatabase(db gcore.Database) gcore.Database {
+	if v, ok := db.(interface{ PrimaryDatabase() gcore.Database }); ok {
+		return v.PrimaryDatabase()
+	}
+	return db
+}
+
+type replica struct {
+	addr    string
+	pool    *sql.DB
+	weight  int
+	current int
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+// replicaSet selects a healthy replica for queries, the replicas are pinged
+// periodically, the replica fails to respond is ejected until it recovers.
+type replicaSet struct {
+	replicas []*replica
+	strategy string
+	counter  uint64
+	timeout  time.Duration
+	lock     sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newReplicaSet(strategy string, timeout time.Duration) *replicaSet {
+	return &replicaSet{
+		strategy: strategy,
+		timeout:  timeout,
+		stop:     make(chan struct{}),
+	}
+}
+
+func (s *replicaSet) add(addr string, pool *sql.DB, weight int) {
+	if weight <= 0 {
+		weight = 1
+	}
+	s.replicas = append(s.replicas, &replica{
+		addr:   addr,
+		pool:   pool,
+		weight: weight,
+	})
+}
+
+// pick returns the pool of a healthy replica, nil returned if there is no healthy replica.
+func (s *replicaSet) pick() *sql.DB {
+	if s.strategy == ReplicaStrategyWeighted {
+		return s.pickWeighted()
+	}
+	var healthy []*replica
+	for _, r := range s.replicas {
+		if r.isHealthy() {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	idx := atomic.AddUint64(&s.counter, 1) - 1
+	return healthy[idx%uint64(len(healthy))].pool
+}
+
+// pickWeighted is the smooth weighted round-robin selection, same as nginx.
+func (s *replicaSet) pickWeighted() *sql.DB {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var best *replica
+	total := 0
+	for _, r := range s.replicas {
+		if !r.isHealthy() {
+			continue
+		}
+		r.current += r.weight
+		total += r.weight
+		if best == nil || r.current > best.current {
+			best = r
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	best.current -= total
+	return best.pool
+}
+
+// check pings all replicas, and updates their health status.
+func (s *replicaSet) check() {
+	g := sync.WaitGroup{}
+	for _, r := range s.replicas {
+		g.Add(1)
+		go func(r *replica) {
+			defer g.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+			defer cancel()
+			if r.pool.PingContext(ctx) == nil {
+				atomic.StoreInt32(&r.healthy, 1)
+			} else {
+				atomic.StoreInt32(&r.healthy, 0)
+			}
+		}(r)
+	}
+	g.Wait()
+}
+
+// start checks the replicas at once, then checks them every interval in background.
+func (s *replicaSet) start(interval time.Duration) {
+	s.check()
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-t.C:
+				s.check()
+			}
+		}
+	}()
+}
+
+// healthy returns the addresses of healthy replicas.
+func (s *replicaSet) healthy() (addrs []string) {
+	for _, r := range s.replicas {
+		if r.isHealthy() {
+			addrs = append(addrs, r.addr)
+		}
+	}
+	return
+}
+
+func (s *replicaSet) close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		for _, r := range s.replicas {
+			r.pool.Close()
+		}
+	})
+}
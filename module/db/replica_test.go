@@ -0,0 +1,148 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gconfig "github.com/snail007/gmc/module/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestReplicaSet(strategy string, weights ...int) (s *replicaSet, pools []*sql.DB) {
+	s = newReplicaSet(strategy, time.Second)
+	for i, w := range weights {
+		pool, _ := sql.Open("sqlite3", ":memory:")
+		pools = append(pools, pool)
+		s.add(string(rune('a'+i)), pool, w)
+	}
+	s.check()
+	return
+}
+
+func TestReplicaSet_RoundRobin(t *testing.T) {
+	assert := assert.New(t)
+	s, pools := newTestReplicaSet(ReplicaStrategyRoundRobin, 1, 1, 1)
+	defer s.close()
+	assert.Equal([]string{"a", "b", "c"}, s.healthy())
+	assert.Equal(pools[0], s.pick())
+	assert.Equal(pools[1], s.pick())
+	assert.Equal(pools[2], s.pick())
+	assert.Equal(pools[0], s.pick())
+}
+
+func TestReplicaSet_Weighted(t *testing.T) {
+	assert := assert.New(t)
+	s, pools := newTestReplicaSet(ReplicaStrategyWeighted, 3, 1)
+	defer s.close()
+	cnt := map[*sql.DB]int{}
+	for i := 0; i < 8; i++ {
+		cnt[s.pick()]++
+	}
+	assert.Equal(6, cnt[pools[0]])
+	assert.Equal(2, cnt[pools[1]])
+}
+
+func TestReplicaSet_Eject(t *testing.T) {
+	assert := assert.New(t)
+	s, pools := newTestReplicaSet(ReplicaStrategyRoundRobin, 1, 1)
+	defer s.close()
+	pools[0].Close()
+	s.check()
+	assert.Equal([]string{"b"}, s.healthy())
+	for i := 0; i < 3; i++ {
+		assert.Equal(pools[1], s.pick())
+	}
+	pools[1].Close()
+	s.check()
+	assert.Nil(s.pick())
+	assert.Nil(s.pickWeighted())
+}
+
+func TestMySQLDB_ReadPool(t *testing.T) {
+	assert := assert.New(t)
+	s, pools := newTestReplicaSet(ReplicaStrategyRoundRobin, 1)
+	primary, _ := sql.Open("sqlite3", ":memory:")
+	db := &MySQLDB{ConnPool: primary, replicas: s}
+	defer db.Close()
+	ctx := context.Background()
+	assert.Equal(pools[0], db.readPool(ctx))
+	assert.Equal(primary, db.readPool(WithPrimary(ctx)))
+	assert.Equal(primary, db.Primary().readPool(ctx))
+	assert.Equal([]string{"a"}, db.HealthyReplicas())
+	// fallback to primary if no healthy replica
+	pools[0].Close()
+	s.check()
+	assert.Equal(primary, db.readPool(ctx))
+	assert.Nil((&MySQLDB{}).HealthyReplicas())
+}
+
+// newLaggingMySQLDB returns a db whose only replica never receives the writes of the primary.
+func newLaggingMySQLDB(t *testing.T) *MySQLDB {
+	dir := t.TempDir()
+	primary, _ := sql.Open("sqlite3", filepath.Join(dir, "primary.db"))
+	s := newReplicaSet(ReplicaStrategyRoundRobin, time.Second)
+	replica, _ := sql.Open("sqlite3", filepath.Join(dir, "replica.db"))
+	s.add("replica", replica, 1)
+	s.check()
+	db := &MySQLDB{ConnPool: primary, replicas: s, Config: NewMySQLDBConfig()}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrator_LaggingReplica(t *testing.T) {
+	assert := assert.New(t)
+	db := newLaggingMySQLDB(t)
+	// the bookkeeping table of replica is always empty, the applied versions must be read from the primary.
+	db.replicas.replicas[0].pool.Exec("CREATE TABLE gmc_migrations (version BIGINT NOT NULL PRIMARY KEY, " +
+		"name VARCHAR(255) NOT NULL, applied_at BIGINT NOT NULL)")
+	m, err := NewMigratorFS(db, migrateTestFS)
+	assert.Nil(err)
+	assert.Nil(m.Up())
+	assert.Nil(m.Up())
+	status, err := m.Status()
+	assert.Nil(err)
+	assert.True(status[1].Applied)
+	rs, err := db.Primary().QuerySQL("select * from m1")
+	assert.Nil(err)
+	assert.Equal(1, rs.Len())
+	assert.Equal(db.ConnPool, primaryDatabase(db).(*MySQLDB).readPool(context.Background()))
+}
+
+func TestParseMySQLReplicas(t *testing.T) {
+	assert := assert.New(t)
+	cfg, err := gconfig.NewConfigBytes([]byte(`
+[database]
+default="mysql"
+[[database.mysql]]
+enable=true
+id="default"
+replica_strategy="weighted"
+[[database.mysql.replicas]]
+host="10.0.0.2"
+weight=3
+[[database.mysql.replicas]]
+host="10.0.0.3"
+port=3307
+username="reader"
+password="pass"
+[[database.mysql.replicas]]
+enable=false
+host="10.0.0.4"
+`))
+	assert.Nil(err)
+	mysql := cfg.Sub("database").AllSettings()["mysql"].([]interface{})[0].(map[string]interface{})
+	replicas := parseMySQLReplicas(mysql["replicas"])
+	assert.Equal([]MySQLReplicaConfig{
+		{Host: "10.0.0.2", Weight: 3},
+		{Host: "10.0.0.3", Port: 3307, Username: "reader", Password: "pass"},
+	}, replicas)
+	assert.Nil(parseMySQLReplicas(nil))
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	gcore "github.com/snail007/gmc/core"
+	gcast "github.com/snail007/gmc/util/cast"
+	gmap "github.com/snail007/gmc/util/map"
+	gvalue "github.com/snail007/gmc/util/value"
+)
+
+// ErrStopEach can be returned by the callback of QueryEach and ChunkByID to stop
+// iterating, and nil error is returned by them.
+var ErrStopEach = errors.New("stop each")
+
+// Row is a row scanned by Database.QueryEach, it is reused for every row, so do not keep
+// it after the callback returned, but the values returned by its getters can be kept.
+type Row struct {
+	columns []string
+	index   map[string]int
+	values  [][]byte
+}
+
+func newRow(columns []string) *Row {
+	r := &Row{
+		columns: columns,
+		index:   make(map[string]int, len(columns)),
+		values:  make([][]byte, len(columns)),
+	}
+	for i, col := range columns {
+		r.index[col] = i
+	}
+	return r
+}
+
+func (r *Row) Columns() []string {
+	return r.columns
+}
+
+// IsNull returns true if the value of column is NULL or the column not exists.
+func (r *Row) IsNull(column string) bool {
+	return r.Bytes(column) == nil
+}
+
+func (r *Row) Bytes(column string) []byte {
+	if i, ok := r.index[column]; ok {
+		return r.values[i]
+	}
+	return nil
+}
+
+func (r *Row) String(column string) string {
+	return string(r.Bytes(column))
+}
+
+func (r *Row) Int64(column string) int64 {
+	return gcast.ToInt64(r.String(column))
+}
+
+func (r *Row) Uint64(column string) uint64 {
+	return gcast.ToUint64(r.String(column))
+}
+
+func (r *Row) Float64(column string) float64 {
+	return gcast.ToFloat64(r.String(column))
+}
+
+func (r *Row) Bool(column string) bool {
+	return gcast.ToBool(r.String(column))
+}
+
+func (r *Row) Map() map[string]string {
+	m := make(map[string]string, len(r.columns))
+	for i, col := range r.columns {
+		m[col] = string(r.values[i])
+	}
+	return m
+}
+
+// Struct converts the row to a struct same as ResultSet.Struct, default tag name is column.
+func (r *Row) Struct(strucT interface{}, tagName ...string) (Struct interface{}, err error) {
+	tag := "column"
+	if len(tagName) == 1 {
+		tag = tagName[0]
+	}
+	return gvalue.MapToStructWithTag(gmap.ToAny(r.Map()), strucT, tag)
+}
+
+// eachRow executes the query and scans the rows one by one, fn is called with every row.
+func eachRow(ctx context.Context, hooks *dbHooks, preparer sqlPreparer, sqlStr string, fn func(row gcore.DBRow) error, values ...interface{}) (err error) {
+	var cnt int64
+	ctx, event := hooks.before(ctx, preparer, sqlStr, values)
+	defer func() {
+		hooks.after(ctx, event, cnt, err)
+	}()
+	var stmt *sql.Stmt
+	stmt, err = preparer.PrepareContext(ctx, sqlStr)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	var rows *sql.Rows
+	rows, err = stmt.QueryContext(ctx, values...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return
+	}
+	row := newRow(cols)
+	scans := make([]interface{}, len(cols))
+	for i := range cols {
+		scans[i] = &row.values[i]
+	}
+	for rows.Next() {
+		err = rows.Scan(scans...)
+		if err != nil {
+			return
+		}
+		cnt++
+		err = fn(row)
+		if err != nil {
+			if err == ErrStopEach {
+				err = nil
+			}
+			return
+		}
+	}
+	return rows.Err()
+}
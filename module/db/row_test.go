@@ -0,0 +1,92 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	gmap "github.com/snail007/gmc/util/map"
+	"github.com/stretchr/testify/assert"
+)
+
+func createEachTable(db gcore.Database, rows int) {
+	db.ExecSQL("drop table each_test")
+	db.ExecSQL("create table each_test(id integer primary key autoincrement, name varchar(20), score real, vip int, memo text)")
+	var data []gmap.M
+	for i := 1; i <= rows; i++ {
+		data = append(data, gmap.M{"name": "n" + string(rune('0'+i%10)), "score": float64(i) + 0.5, "vip": i % 2, "memo": nil})
+	}
+	db.Exec(db.AR().InsertBatch("each_test", data))
+}
+
+func TestQueryEach(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	createEachTable(db, 5)
+	defer db.ExecSQL("drop table each_test")
+	h := &recordHook{}
+	db.AddHook(h)
+	var ids []int64
+	err := db.QueryEach(db.AR().From("each_test").OrderBy("id", "asc"), func(row gcore.DBRow) error {
+		ids = append(ids, row.Int64("id"))
+		assert.Equal([]string{"id", "name", "score", "vip", "memo"}, row.Columns())
+		if row.Int64("id") == 3 {
+			assert.Equal("n3", row.String("name"))
+			assert.Equal(uint64(3), row.Uint64("id"))
+			assert.Equal(3.5, row.Float64("score"))
+			assert.True(row.Bool("vip"))
+			assert.True(row.IsNull("memo"))
+			assert.True(row.IsNull("none"))
+			assert.False(row.IsNull("name"))
+			assert.Equal([]byte("n3"), row.Bytes("name"))
+			assert.Equal(map[string]string{"id": "3", "name": "n3", "score": "3.5", "vip": "1", "memo": ""}, row.Map())
+			v, err := row.Struct(&User1{})
+			assert.Nil(err)
+			assert.Equal("n3", v.(User1).Name)
+			assert.Equal(3, v.(User1).ID)
+		}
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal([]int64{1, 2, 3, 4, 5}, ids)
+	assert.Len(h.events, 1)
+	assert.Equal(int64(5), h.events[0].RowsAffected)
+
+	// stop early
+	ids = nil
+	err = db.QueryEach(db.AR().From("each_test").Where(gmap.M{"vip": 1}).OrderBy("id", "desc"), func(row gcore.DBRow) error {
+		ids = append(ids, row.Int64("id"))
+		if len(ids) == 2 {
+			return ErrStopEach
+		}
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal([]int64{5, 3}, ids)
+
+	// error of callback
+	e := errors.New("fail")
+	err = db.QueryEach(db.AR().From("each_test"), func(row gcore.DBRow) error {
+		return e
+	})
+	assert.Equal(e, err)
+
+	// canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = db.QueryEachContext(ctx, db.AR().From("each_test"), func(row gcore.DBRow) error {
+		return nil
+	})
+	assert.NotNil(err)
+
+	err = db.QueryEach(db.AR().Raw("select * from none_table"), func(row gcore.DBRow) error {
+		return nil
+	})
+	assert.NotNil(err)
+}
@@ -7,6 +7,7 @@ package gdb
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"database/sql"
 	"encoding/gob"
@@ -18,7 +19,6 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/snail007/gmc/core"
-	makeutil "github.com/snail007/gmc/internal/util/make"
 	gmap "github.com/snail007/gmc/util/map"
 )
 
@@ -89,6 +89,7 @@ type SQLite3DB struct {
 	Config   SQLite3DBConfig
 	ConnPool *sql.DB
 	DSN      string
+	hooks    *dbHooks
 }
 
 func NewSQLite3DB(config SQLite3DBConfig) (db SQLite3DB, err error) {
@@ -98,6 +99,7 @@ func NewSQLite3DB(config SQLite3DBConfig) (db SQLite3DB, err error) {
 }
 func (db *SQLite3DB) init(config SQLite3DBConfig) (err error) {
 	db.Config = config
+	db.hooks = newDBHooks("sqlite3")
 	db.DSN = db.getDSN()
 	db.ConnPool, err = db.getDB()
 	return
@@ -130,73 +132,60 @@ func (db *SQLite3DB) AR() gcore.ActiveRecord {
 	ar.tablePrefixSQLIdentifier = db.Config.TablePrefixSQLIdentifier
 	return ar
 }
+// AddHook adds a hook called before and after every sql statement executed.
+func (db *SQLite3DB) AddHook(hook gcore.DBHook) {
+	db.hooks.add(hook)
+}
 func (db *SQLite3DB) Stats() sql.DBStats {
 	return db.ConnPool.Stats()
 }
 func (db *SQLite3DB) Begin() (tx *sql.Tx, err error) {
-	return db.ConnPool.Begin()
+	return db.BeginContext(context.Background(), nil)
+}
+func (db *SQLite3DB) BeginContext(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
+	return db.ConnPool.BeginTx(ctx, opts)
+}
+// Transaction executes fn in a transaction, the transaction is committed if fn returns nil,
+// and rolled back if fn returns an error or panics.
+func (db *SQLite3DB) Transaction(fn func(tx gcore.Tx) error) (err error) {
+	return db.TransactionContext(context.Background(), nil, fn)
 }
-func (db *SQLite3DB) ExecTx(ar0 gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+func (db *SQLite3DB) TransactionContext(ctx context.Context, opts *sql.TxOptions, fn func(tx gcore.Tx) error) (err error) {
+	return transaction(ctx, db, opts, fn)
+}
+func (db *SQLite3DB) ExecTx(ar gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+	return db.ExecTxContext(context.Background(), ar, tx)
+}
+func (db *SQLite3DB) ExecTxContext(ctx context.Context, ar0 gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
 	ar := ar0.(*SQLite3ActiveRecord)
-	return db.execSQLTx(ar.SQL(), len(ar.arInsertBatch), tx, ar.values...)
+	return db.execSQL(ctx, tx, ar.SQL(), len(ar.arInsertBatch), ar.values...)
 }
 func (db *SQLite3DB) ExecSQLTx(tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
-	return db.execSQLTx(sqlStr, 0, tx, values...)
+	return db.execSQL(context.Background(), tx, sqlStr, 0, values...)
 }
-func (db *SQLite3DB) execSQLTx(sqlStr string, arInsertBatchCnt int, tx *sql.Tx, values ...interface{}) (rs gcore.ResultSet, err error) {
-	if db.Config.TablePrefix != "" && db.Config.TablePrefixSQLIdentifier != "" {
-		sqlStr = strings.Replace(sqlStr, db.Config.TablePrefixSQLIdentifier, db.Config.TablePrefix, -1)
-	}
-	start := time.Now()
-	var stmt *sql.Stmt
-	var result sql.Result
-
-	stmt, err = tx.Prepare(sqlStr)
-	if err != nil {
-		return
-	}
-	defer stmt.Close()
-	result, err = stmt.Exec(values...)
-	if err != nil {
-		return
-	}
-	rsRaw := new(ResultSet)
-	rsRaw.rowsAffected, err = result.RowsAffected()
-	rsRaw.lastInsertID, err = result.LastInsertId()
-	rsRaw.timeUsed = time.Now().Sub(start)
-	rsRaw.sql = sqlStr
-	if err != nil {
-		return
-	}
-	l := int64(arInsertBatchCnt)
-	if l > 1 {
-		rsRaw.lastInsertID = rsRaw.lastInsertID - +1
-		rsRaw.rowsAffected = l
-	}
-	rs = rsRaw
-	return
+func (db *SQLite3DB) ExecSQLTxContext(ctx context.Context, tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.execSQL(ctx, tx, sqlStr, 0, values...)
+}
+func (db *SQLite3DB) Exec(ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return db.ExecContext(context.Background(), ar)
 }
-func (db *SQLite3DB) Exec(ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+func (db *SQLite3DB) ExecContext(ctx context.Context, ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
 	ar := ar0.(*SQLite3ActiveRecord)
-	return db.execSQL(ar.SQL(), len(ar.arInsertBatch), ar.values...)
+	return db.execSQL(ctx, db.ConnPool, ar.SQL(), len(ar.arInsertBatch), ar.values...)
 }
 func (db *SQLite3DB) ExecSQL(sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
-	return db.execSQL(sqlStr, 0, values...)
+	return db.execSQL(context.Background(), db.ConnPool, sqlStr, 0, values...)
 }
-func (db *SQLite3DB) execSQL(sqlStr string, arInsertBatchCnt int, values ...interface{}) (rs gcore.ResultSet, err error) {
+func (db *SQLite3DB) ExecSQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.execSQL(ctx, db.ConnPool, sqlStr, 0, values...)
+}
+func (db *SQLite3DB) execSQL(ctx context.Context, preparer sqlPreparer, sqlStr string, arInsertBatchCnt int, values ...interface{}) (rs gcore.ResultSet, err error) {
 	if db.Config.TablePrefix != "" && db.Config.TablePrefixSQLIdentifier != "" {
 		sqlStr = strings.Replace(sqlStr, db.Config.TablePrefixSQLIdentifier, db.Config.TablePrefix, -1)
 	}
 	start := time.Now()
-	var stmt *sql.Stmt
 	var result sql.Result
-
-	stmt, err = db.ConnPool.Prepare(sqlStr)
-	if err != nil {
-		return
-	}
-	defer stmt.Close()
-	result, err = stmt.Exec(values...)
+	result, err = execStmt(ctx, db.hooks, preparer, sqlStr, values...)
 	if err != nil {
 		return
 	}
@@ -205,72 +194,69 @@ func (db *SQLite3DB) execSQL(sqlStr string, arInsertBatchCnt int, values ...inte
 	rsRaw.lastInsertID, err = result.LastInsertId()
 	rsRaw.timeUsed = time.Now().Sub(start)
 	rsRaw.sql = sqlStr
+	if err != nil {
+		return
+	}
 	l := int64(arInsertBatchCnt)
 	if l > 1 {
 		rsRaw.lastInsertID = rsRaw.lastInsertID - +1
 		rsRaw.rowsAffected = l
 	}
 	rs = rsRaw
+	invalidateQueryCache(db.Config.Cache, preparer, sqlStr)
 	return
 }
 func (db *SQLite3DB) QuerySQL(sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.QuerySQLContext(context.Background(), sqlStr, values...)
+}
+func (db *SQLite3DB) QuerySQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.querySQL(ctx, db.ConnPool, sqlStr, values...)
+}
+func (db *SQLite3DB) QuerySQLTx(tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.QuerySQLTxContext(context.Background(), tx, sqlStr, values...)
+}
+func (db *SQLite3DB) QuerySQLTxContext(ctx context.Context, tx *sql.Tx, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return db.querySQL(ctx, tx, sqlStr, values...)
+}
+func (db *SQLite3DB) querySQL(ctx context.Context, preparer sqlPreparer, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
 	if db.Config.TablePrefix != "" && db.Config.TablePrefixSQLIdentifier != "" {
 		sqlStr = strings.Replace(sqlStr, db.Config.TablePrefixSQLIdentifier, db.Config.TablePrefix, -1)
 	}
 	start := time.Now()
 	var results []map[string][]byte
-	var stmt *sql.Stmt
-	stmt, err = db.ConnPool.Prepare(sqlStr)
-	if err != nil {
-		return
-	}
-	defer stmt.Close()
-	var rows *sql.Rows
-	rows, err = stmt.Query(values...)
+	results, err = queryRows(ctx, db.hooks, preparer, sqlStr, values...)
 	if err != nil {
 		return
 	}
-	defer rows.Close()
-	cols, e := rows.Columns()
-	if e != nil {
-		return nil, e
-	}
-	closCnt := len(cols)
-
-	// scans := make([]interface{},closCnt)
-	var scans []interface{}
-	scans = makeutil.GetX(scans, uint64(len(cols)), func() interface{} {
-		a := make([]interface{}, closCnt)
-		for i := 0; i < closCnt; i++ {
-			a[i] = new([]byte)
-		}
-		return a
-	}).([]interface{})
-	defer func() {
-		for i := 0; i < closCnt; i++ {
-			scans[i] = new([]byte)
-		}
-		makeutil.PutX(scans, uint64(len(cols)))
-	}()
-
-	for rows.Next() {
-		err = rows.Scan(scans...)
-		if err != nil {
-			return
-		}
-		row := map[string][]byte{}
-		for i := range cols {
-			row[cols[i]] = *(scans[i].(*[]byte))
-		}
-		results = append(results, row)
-	}
 	rsRaw := NewResultSet(&results)
 	rsRaw.timeUsed = time.Now().Sub(start)
 	rsRaw.sql = sqlStr
 	rs = rsRaw
 	return
 }
-func (db *SQLite3DB) Query(ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+func (db *SQLite3DB) Query(ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return db.QueryContext(context.Background(), ar)
+}
+func (db *SQLite3DB) QueryContext(ctx context.Context, ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return db.query(ctx, db.ConnPool, ar)
+}
+
+// QueryEach executes the query and calls fn with every row, the rows are scanned one by one
+// instead of loading all of them into memory, fn can return ErrStopEach to stop iterating.
+func (db *SQLite3DB) QueryEach(ar gcore.ActiveRecord, fn func(row gcore.DBRow) error) (err error) {
+	return db.QueryEachContext(context.Background(), ar, fn)
+}
+func (db *SQLite3DB) QueryEachContext(ctx context.Context, ar gcore.ActiveRecord, fn func(row gcore.DBRow) error) (err error) {
+	sqlStr := ar.SQL()
+	return eachRow(ctx, db.hooks, db.ConnPool, sqlStr, fn, ar.Values()...)
+}
+func (db *SQLite3DB) QueryTx(ar gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+	return db.QueryTxContext(context.Background(), ar, tx)
+}
+func (db *SQLite3DB) QueryTxContext(ctx context.Context, ar gcore.ActiveRecord, tx *sql.Tx) (rs gcore.ResultSet, err error) {
+	return db.query(ctx, tx, ar)
+}
+func (db *SQLite3DB) query(ctx context.Context, preparer sqlPreparer, ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
 	ar := ar0.(*SQLite3ActiveRecord)
 	start := time.Now()
 	var results []map[string][]byte
@@ -286,52 +272,17 @@ func (db *SQLite3DB) Query(ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err erro
 		}
 	}
 	if results == nil || len(results) == 0 {
-		sqlStr := ar.SQL()
-		var stmt *sql.Stmt
-		stmt, err = db.ConnPool.Prepare(sqlStr)
-		if err != nil {
-			return
-		}
-		defer stmt.Close()
-		var rows *sql.Rows
-		rows, err = stmt.Query(ar.values...)
-		if err != nil {
-			return
-		}
-		defer rows.Close()
-		cols := []string{}
-		cols, err = rows.Columns()
-		if err != nil {
-			return
-		}
-		closCnt := len(cols)
-
-		// scans := make([]interface{},closCnt)
-		var scans []interface{}
-		scans = makeutil.GetX(scans, uint64(len(cols)), func() interface{} {
-			a := make([]interface{}, closCnt)
-			for i := 0; i < closCnt; i++ {
-				a[i] = new([]byte)
-			}
-			return a
-		}).([]interface{})
-		defer func() {
-			for i := 0; i < closCnt; i++ {
-				scans[i] = new([]byte)
-			}
-			makeutil.PutX(scans, uint64(len(cols)))
-		}()
-
-		for rows.Next() {
-			err = rows.Scan(scans...)
+		var tags []string
+		var version string
+		if ar.cacheKey != "" {
+			tags, version, err = queryCacheTags(db.Config.Cache, ar.SQL())
 			if err != nil {
 				return
 			}
-			row := map[string][]byte{}
-			for i := range cols {
-				row[cols[i]] = *(scans[i].(*[]byte))
-			}
-			results = append(results, row)
+		}
+		results, err = queryRows(ctx, db.hooks, preparer, ar.SQL(), ar.values...)
+		if err != nil {
+			return
 		}
 		if ar.cacheKey != "" {
 			b := new(bytes.Buffer)
@@ -340,7 +291,7 @@ func (db *SQLite3DB) Query(ar0 gcore.ActiveRecord) (rs gcore.ResultSet, err erro
 			if err != nil {
 				return
 			}
-			err = db.Config.Cache.Set(ar.cacheKey, b.Bytes(), ar.cacheSeconds)
+			err = setQueryCache(db.Config.Cache, ar.cacheKey, b.Bytes(), ar.cacheSeconds, tags, version)
 			if err != nil {
 				return
 			}
@@ -492,6 +443,14 @@ func (ar *SQLite3ActiveRecord) WhereWrap(where gmap.M, leftWrap, rightWrap strin
 	}
 	return ar
 }
+
+// WhereCond adds the condition built by gdb.And, gdb.Or, gdb.In etc, it is joined by AND.
+func (ar *SQLite3ActiveRecord) WhereCond(cond gcore.DBCond) gcore.ActiveRecord {
+	if cond != nil {
+		ar.arWhere = append(ar.arWhere, []interface{}{cond, "AND", "", len(ar.arWhere)})
+	}
+	return ar
+}
 func (ar *SQLite3ActiveRecord) GroupBy(column string) gcore.ActiveRecord {
 	for _, columnCurrent := range strings.Split(column, ",") {
 		ar.arGroupBy = append(ar.arGroupBy, strings.TrimSpace(columnCurrent))
@@ -861,6 +820,21 @@ func (ar *SQLite3ActiveRecord) compileOrderBy() string {
 	})
 	return strings.Join(orderBy, ",")
 }
+func (ar *SQLite3ActiveRecord) compileCond(cond gcore.DBCond, leftWrap, rightWrap string, index int) string {
+	if index == 0 {
+		leftWrap = ""
+	}
+	sqlStr, args := cond.Build(ar.wrapColumn)
+	ar.values = append(ar.values, args...)
+	return fmt.Sprintf(" %s %s %s ", leftWrap, sqlStr, rightWrap)
+}
+func (ar *SQLite3ActiveRecord) wrapColumn(column string) string {
+	keys := strings.Split(strings.TrimSpace(column), ".")
+	if len(keys) == 2 {
+		return ar.protectIdentifier(ar.checkPrefix(keys[0])) + "." + ar.protectIdentifier(keys[1])
+	}
+	return ar.protectIdentifier(keys[0])
+}
 func (ar *SQLite3ActiveRecord) compileWhere(where0 interface{}, leftWrap, rightWrap string, index int) string {
 
 	_where := []string{}
@@ -1047,6 +1021,10 @@ func (ar *SQLite3ActiveRecord) getWhere() string {
 	hasEmptyIn := false
 
 	for _, v := range ar.arWhere {
+		if cond, ok := v[0].(gcore.DBCond); ok {
+			where = append(where, ar.compileCond(cond, v[1].(string), v[2].(string), v[3].(int)))
+			continue
+		}
 		for _, value := range v[0].(gmap.M) {
 			if isArray(value) && reflect.ValueOf(value).Len() == 0 {
 				hasEmptyIn = true
@@ -6,6 +6,7 @@
 package gdb
 
 import (
+	"context"
 	"fmt"
 	gcast "github.com/snail007/gmc/util/cast"
 	"strings"
@@ -348,3 +349,36 @@ func TestMySQLActiveRecord_WhereRaw1(t *testing.T) {
 		t.Errorf("\n==> Except : \n%s\n==> Got : \n%s", want, got)
 	}
 }
+
+func TestSQLite3DB_Context(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	db.ExecSQL("drop table ctx_test")
+	_, err := db.ExecSQLContext(context.Background(), "create table ctx_test(id int)")
+	assert.Nil(err)
+	defer db.ExecSQL("drop table ctx_test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tx, err := db.BeginContext(ctx, nil)
+	assert.Nil(err)
+	_, err = db.ExecTxContext(ctx, db.AR().Insert("ctx_test", gmap.M{"id": 1}), tx)
+	assert.Nil(err)
+	_, err = db.ExecSQLTxContext(ctx, tx, "insert into ctx_test(id) values(?)", 2)
+	assert.Nil(err)
+	assert.Nil(tx.Commit())
+
+	rs, err := db.QueryContext(ctx, db.AR().From("ctx_test"))
+	assert.Nil(err)
+	assert.Equal(2, rs.Len())
+	rs, err = db.QuerySQLContext(ctx, "select * from ctx_test where id=?", 2)
+	assert.Nil(err)
+	assert.Equal("2", rs.Value("id"))
+
+	cancel()
+	_, err = db.QueryContext(ctx, db.AR().From("ctx_test"))
+	assert.ErrorIs(err, context.Canceled)
+	_, err = db.ExecContext(ctx, db.AR().Insert("ctx_test", gmap.M{"id": 3}))
+	assert.ErrorIs(err, context.Canceled)
+	_, err = db.BeginContext(ctx, nil)
+	assert.ErrorIs(err, context.Canceled)
+}
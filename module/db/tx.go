@@ -0,0 +1,127 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// Tx is the transaction object passed to the function of Database.Transaction,
+// all statements executed by Tx are in the same transaction.
+type Tx struct {
+	db    gcore.Database
+	tx    *sql.Tx
+	ctx   context.Context
+	depth int
+}
+
+func newTx(ctx context.Context, db gcore.Database, tx *sql.Tx, depth int) *Tx {
+	return &Tx{
+		db:    db,
+		tx:    tx,
+		ctx:   ctx,
+		depth: depth,
+	}
+}
+
+// Raw returns the underlying *sql.Tx.
+func (t *Tx) Raw() *sql.Tx {
+	return t.tx
+}
+
+func (t *Tx) AR() gcore.ActiveRecord {
+	return t.db.AR()
+}
+
+func (t *Tx) Exec(ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return t.db.ExecTxContext(t.ctx, ar, t.tx)
+}
+
+func (t *Tx) ExecContext(ctx context.Context, ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return t.db.ExecTxContext(ctx, ar, t.tx)
+}
+
+func (t *Tx) ExecSQL(sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return t.db.ExecSQLTxContext(t.ctx, t.tx, sqlStr, values...)
+}
+
+func (t *Tx) ExecSQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return t.db.ExecSQLTxContext(ctx, t.tx, sqlStr, values...)
+}
+
+func (t *Tx) Query(ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return t.db.QueryTxContext(t.ctx, ar, t.tx)
+}
+
+func (t *Tx) QueryContext(ctx context.Context, ar gcore.ActiveRecord) (rs gcore.ResultSet, err error) {
+	return t.db.QueryTxContext(ctx, ar, t.tx)
+}
+
+func (t *Tx) QuerySQL(sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return t.db.QuerySQLTxContext(t.ctx, t.tx, sqlStr, values...)
+}
+
+func (t *Tx) QuerySQLContext(ctx context.Context, sqlStr string, values ...interface{}) (rs gcore.ResultSet, err error) {
+	return t.db.QuerySQLTxContext(ctx, t.tx, sqlStr, values...)
+}
+
+// Transaction starts a nested transaction by SAVEPOINT, if fn returns an error or panics,
+// the statements executed in fn are rolled back to the savepoint, and the outer
+// transaction is not affected unless the error is returned by the outer fn too.
+func (t *Tx) Transaction(fn func(tx gcore.Tx) error) (err error) {
+	savepoint := fmt.Sprintf("gmc_savepoint_%d", t.depth+1)
+	_, err = t.tx.ExecContext(t.ctx, "SAVEPOINT "+savepoint)
+	if err != nil {
+		return
+	}
+	nested := newTx(t.ctx, t.db, t.tx, t.depth+1)
+	return runTx(nested, fn, func() error {
+		_, e := t.tx.ExecContext(t.ctx, "RELEASE SAVEPOINT "+savepoint)
+		return e
+	}, func() error {
+		_, e := t.tx.ExecContext(t.ctx, "ROLLBACK TO SAVEPOINT "+savepoint)
+		return e
+	})
+}
+
+// transaction begins a transaction on db, commits it if fn returns nil, rolls back it if
+// fn returns an error or panics, the panic will be re-panicked after rolling back.
+func transaction(ctx context.Context, db gcore.Database, opts *sql.TxOptions, fn func(tx gcore.Tx) error) (err error) {
+	var tx *sql.Tx
+	tx, err = db.BeginContext(ctx, opts)
+	if err != nil {
+		return
+	}
+	watchTxInvalidations(tx)
+	return runTx(newTx(ctx, db, tx, 0), fn, func() (err error) {
+		err = tx.Commit()
+		flushTxInvalidations(tx, err == nil)
+		return
+	}, func() error {
+		flushTxInvalidations(tx, false)
+		return tx.Rollback()
+	})
+}
+
+func runTx(tx *Tx, fn func(tx gcore.Tx) error, commit, rollback func() error) (err error) {
+	panicked := true
+	defer func() {
+		if panicked {
+			rollback()
+		}
+	}()
+	err = fn(tx)
+	panicked = false
+	if err != nil {
+		rollback()
+		return
+	}
+	return commit()
+}
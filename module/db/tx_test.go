@@ -0,0 +1,104 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"errors"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	gmap "github.com/snail007/gmc/util/map"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTxTestTable(db gcore.Database) {
+	db.ExecSQL("drop table tx_test")
+	db.ExecSQL("create table tx_test(tx_test_id integer primary key autoincrement, name varchar(20))")
+}
+
+func txTestCount(db gcore.Database) int64 {
+	cnt, _ := Table("tx_test", db).Count(nil)
+	return cnt
+}
+
+func TestTransaction_Commit(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	createTxTestTable(db)
+	defer db.ExecSQL("drop table tx_test")
+	err := db.Transaction(func(tx gcore.Tx) error {
+		_, err := tx.Exec(tx.AR().Insert("tx_test", gmap.M{"name": "a"}))
+		if err != nil {
+			return err
+		}
+		id, err := Table("tx_test", tx).Insert(gmap.M{"name": "b"})
+		if err != nil {
+			return err
+		}
+		assert.Equal(int64(2), id)
+		rs, err := tx.Query(tx.AR().From("tx_test"))
+		if err != nil {
+			return err
+		}
+		assert.Equal(2, rs.Len())
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(int64(2), txTestCount(db))
+}
+
+func TestTransaction_Rollback(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	createTxTestTable(db)
+	defer db.ExecSQL("drop table tx_test")
+	errFoo := errors.New("foo")
+	err := db.Transaction(func(tx gcore.Tx) error {
+		_, err := tx.ExecSQL("insert into tx_test(name) values(?)", "a")
+		assert.Nil(err)
+		return errFoo
+	})
+	assert.Equal(errFoo, err)
+	assert.Equal(int64(0), txTestCount(db))
+
+	assert.Panics(func() {
+		db.Transaction(func(tx gcore.Tx) error {
+			tx.ExecSQL("insert into tx_test(name) values(?)", "a")
+			panic("bar")
+		})
+	})
+	assert.Equal(int64(0), txTestCount(db))
+}
+
+func TestTransaction_Nested(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	createTxTestTable(db)
+	defer db.ExecSQL("drop table tx_test")
+	err := db.Transaction(func(tx gcore.Tx) error {
+		tx.ExecSQL("insert into tx_test(name) values(?)", "a")
+		err := tx.Transaction(func(tx gcore.Tx) error {
+			tx.ExecSQL("insert into tx_test(name) values(?)", "b")
+			return tx.Transaction(func(tx gcore.Tx) error {
+				tx.ExecSQL("insert into tx_test(name) values(?)", "c")
+				return nil
+			})
+		})
+		assert.Nil(err)
+		err = tx.Transaction(func(tx gcore.Tx) error {
+			tx.ExecSQL("insert into tx_test(name) values(?)", "d")
+			return errors.New("rollback d")
+		})
+		assert.NotNil(err)
+		rs, err := Table("tx_test", db).Tx(tx).MGetBy(nil, "tx_test_id", "asc")
+		assert.Nil(err)
+		assert.Len(rs, 3)
+		return nil
+	})
+	assert.Nil(err)
+	rs, _ := db.QuerySQL("select name from tx_test order by tx_test_id")
+	assert.Equal([]string{"a", "b", "c"}, rs.Values("name"))
+}
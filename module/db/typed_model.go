@@ -0,0 +1,433 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gmap "github.com/snail007/gmc/util/map"
+	gvalue "github.com/snail007/gmc/util/value"
+)
+
+var (
+	typeOfTime       = reflect.TypeOf(time.Time{})
+	typedStructCache = sync.Map{}
+)
+
+// typedField is a struct field mapped to a table column.
+type typedField struct {
+	index     int
+	column    string
+	omitEmpty bool
+	isTime    bool
+}
+
+// now sets the field to current time, the field can be a time.Time or an integer
+// of unix timestamp, the value of the field is returned.
+func (f *typedField) now(v reflect.Value) interface{} {
+	now := time.Unix(time.Now().Unix(), 0)
+	fv := v.Field(f.index)
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		fv.SetInt(now.Unix())
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		fv.SetUint(uint64(now.Unix()))
+	default:
+		if f.isTime {
+			fv.Set(reflect.ValueOf(now))
+		}
+	}
+	return fv.Interface()
+}
+
+// typedStruct is the columns mapping of a struct, parsed from the `column` tag,
+// the column name is the field name if the tag is empty, the tag options are:
+//
+//	pk         the primary key column.
+//	created    the create time column, it is set when inserting.
+//	updated    the update time column, it is set when inserting and updating.
+//	deleted    the soft delete column, NULL of time column or 0 of integer column means not deleted,
+//	           0 of integer column is written when inserting.
+//	omitempty  the column is skipped when inserting or updating if the field is zero value.
+type typedStruct struct {
+	fields  []*typedField
+	pk      *typedField
+	created *typedField
+	updated *typedField
+	deleted *typedField
+}
+
+func parseTypedStruct(typ reflect.Type) (s *typedStruct, err error) {
+	if v, ok := typedStructCache.Load(typ); ok {
+		return v.(*typedStruct), nil
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("typed model type must be a struct, got %s", typ.String())
+	}
+	s = &typedStruct{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" || field.Anonymous {
+			continue
+		}
+		tags := strings.Split(field.Tag.Get("column"), ",")
+		if tags[0] == "-" {
+			continue
+		}
+		f := &typedField{
+			index:  i,
+			column: strings.TrimSpace(tags[0]),
+			isTime: field.Type == typeOfTime,
+		}
+		if f.column == "" {
+			f.column = field.Name
+		}
+		for _, opt := range tags[1:] {
+			switch strings.TrimSpace(opt) {
+			case "pk":
+				s.pk = f
+			case "created":
+				s.created = f
+			case "updated":
+				s.updated = f
+			case "deleted":
+				s.deleted = f
+			case "omitempty":
+				f.omitEmpty = true
+			}
+		}
+		s.fields = append(s.fields, f)
+	}
+	typedStructCache.Store(typ, s)
+	return
+}
+
+func (s *typedStruct) field(column string) *typedField {
+	for _, f := range s.fields {
+		if f.column == column {
+			return f
+		}
+	}
+	return nil
+}
+
+// TypedModel is a generic Model, rows are mapped to struct T by the `column` tag,
+// the fields of T must be non-pointer types. For example:
+//
+//	type User struct {
+//		ID        int64     `column:"id,pk"`
+//		Name      string    `column:"name"`
+//		CreatedAt time.Time `column:"created_at,created"`
+//		UpdatedAt int64     `column:"updated_at,updated"`
+//		DeletedAt time.Time `column:"deleted_at,deleted"`
+//	}
+//
+//	user, err := gdb.TypedTable[User]("user").GetByID(1)
+type TypedModel[T any] struct {
+	model    *Model
+	meta     *typedStruct
+	pk       *typedField
+	omitZero bool
+	unscoped bool
+}
+
+// TypedTable creates a TypedModel of table, the arguments db is same as Table,
+// if T has no pk field, the primary key is table_id, same as Model.
+func TypedTable[T any](table string, db ...interface{}) *TypedModel[T] {
+	meta, err := parseTypedStruct(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		panic(err)
+	}
+	m := &TypedModel[T]{
+		model: Table(table, db...),
+		meta:  meta,
+	}
+	if meta.pk != nil {
+		m.model.SetPrimaryKey(meta.pk.column)
+	}
+	m.pk = meta.field(m.model.PrimaryKey())
+	return m
+}
+
+func (s *TypedModel[T]) clone() *TypedModel[T] {
+	m := *s
+	return &m
+}
+
+// Model returns the underlying Model.
+func (s *TypedModel[T]) Model() *Model {
+	return s.model
+}
+
+// Tx returns a copy of the model, all statements of the copy are executed in the transaction tx.
+func (s *TypedModel[T]) Tx(tx gcore.Tx) *TypedModel[T] {
+	m := s.clone()
+	m.model = s.model.Tx(tx)
+	return m
+}
+
+// OmitZero returns a copy of the model, the zero value fields are skipped by Insert and Update of the copy.
+func (s *TypedModel[T]) OmitZero() *TypedModel[T] {
+	m := s.clone()
+	m.omitZero = true
+	return m
+}
+
+// Unscoped returns a copy of the model, the soft deleted rows are included in the queries of the copy,
+// and the deletes of the copy are real deletes.
+func (s *TypedModel[T]) Unscoped() *TypedModel[T] {
+	m := s.clone()
+	m.unscoped = true
+	return m
+}
+
+func (s *TypedModel[T]) PrimaryKey() string {
+	return s.model.PrimaryKey()
+}
+
+func (s *TypedModel[T]) Count(where gmap.M) (count int64, err error) {
+	return s.model.Count(s.scope(where))
+}
+
+// GetByID returns the row of the primary key id, nil is returned if not found.
+func (s *TypedModel[T]) GetByID(id interface{}) (ret *T, err error) {
+	return s.GetBy(gmap.M{s.PrimaryKey(): id})
+}
+
+// GetBy returns the first row matched the where, nil is returned if not found.
+func (s *TypedModel[T]) GetBy(where gmap.M) (ret *T, err error) {
+	row, err := s.model.GetBy(s.scope(where))
+	if err != nil || len(row) == 0 {
+		return nil, err
+	}
+	rows, err := s.decode([]map[string]string{row})
+	if err != nil {
+		return nil, err
+	}
+	return &rows[0], nil
+}
+
+// MGetByIDs returns the rows of the primary keys ids, ids must be a slice.
+func (s *TypedModel[T]) MGetByIDs(ids interface{}, orderBy ...string) (ret []T, err error) {
+	return s.MGetBy(gmap.M{s.PrimaryKey(): ids}, orderBy...)
+}
+
+func (s *TypedModel[T]) MGetBy(where gmap.M, orderBy ...string) (ret []T, err error) {
+	rows, err := s.model.MGetBy(s.scope(where), orderBy...)
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(rows)
+}
+
+func (s *TypedModel[T]) GetAll(orderBy ...string) (ret []T, err error) {
+	return s.MGetBy(nil, orderBy...)
+}
+
+func (s *TypedModel[T]) Page(where gmap.M, offset, length int, orderBy ...string) (ret []T, total int, err error) {
+	rows, total, err := s.model.Page(s.scope(where), offset, length, orderBy...)
+	if err != nil {
+		return nil, 0, err
+	}
+	ret, err = s.decode(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return
+}
+
+func (s *TypedModel[T]) List(where gmap.M, offset, length int, orderBy ...string) (ret []T, err error) {
+	rows, err := s.model.List(s.scope(where), offset, length, orderBy...)
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(rows)
+}
+
+// ChunkByID walks the rows in ascending order of the primary key by keyset pagination,
+// see Model.ChunkByID.
+func (s *TypedModel[T]) ChunkByID(where gmap.M, size int, fn func(rows []T) error) (err error) {
+	return s.model.ChunkByID(s.scope(where), size, func(rows []map[string]string) error {
+		ret, err := s.decode(rows)
+		if err != nil {
+			return err
+		}
+		return fn(ret)
+	})
+}
+
+// Insert inserts the row, the created and updated fields are set to current time,
+// if the primary key field is zero, it is skipped and set to the last insert id after inserting.
+func (s *TypedModel[T]) Insert(row *T) (lastInsertID int64, err error) {
+	v := reflect.ValueOf(row).Elem()
+	lastInsertID, err = s.model.Insert(s.insertData(v, s.omitZero))
+	if err != nil {
+		return 0, err
+	}
+	s.setPK(v, lastInsertID)
+	return
+}
+
+// InsertBatch inserts the rows, OmitZero is ignored, because all rows must have the same columns.
+func (s *TypedModel[T]) InsertBatch(rows []*T) (cnt, lastInsertID int64, err error) {
+	data := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		data = append(data, s.insertData(reflect.ValueOf(row).Elem(), false))
+	}
+	return s.model.InsertBatch(data)
+}
+
+// Update updates the row by its primary key, the updated field is set to current time,
+// the primary key, created and deleted fields are not updated.
+func (s *TypedModel[T]) Update(row *T) (cnt int64, err error) {
+	v := reflect.ValueOf(row).Elem()
+	if s.pk == nil || v.Field(s.pk.index).IsZero() {
+		return 0, fmt.Errorf("primary key of the row is empty")
+	}
+	data := gmap.M{}
+	for _, f := range s.meta.fields {
+		if f == s.pk || f == s.meta.created || f == s.meta.deleted {
+			continue
+		}
+		if f == s.meta.updated {
+			data[f.column] = f.now(v)
+			continue
+		}
+		fv := v.Field(f.index)
+		if (s.omitZero || f.omitEmpty) && fv.IsZero() {
+			continue
+		}
+		data[f.column] = fv.Interface()
+	}
+	rs, err := s.model.db.Exec(s.model.db.AR().Update(s.model.table, data, s.scope(gmap.M{
+		s.pk.column: v.Field(s.pk.index).Interface(),
+	})))
+	if err != nil {
+		return 0, err
+	}
+	return rs.RowsAffected(), nil
+}
+
+// UpdateBy updates the rows matched the where, the updated column is set to current time if it is not in data.
+func (s *TypedModel[T]) UpdateBy(where, data gmap.M) (cnt int64, err error) {
+	if s.meta.updated != nil {
+		if _, ok := data[s.meta.updated.column]; !ok {
+			d := gmap.M{}
+			for k, v := range data {
+				d[k] = v
+			}
+			d[s.meta.updated.column] = s.meta.updated.now(reflect.New(reflect.TypeOf((*T)(nil)).Elem()).Elem())
+			data = d
+		}
+	}
+	return s.model.UpdateBy(s.scope(where), data)
+}
+
+// Delete deletes the row by its primary key.
+func (s *TypedModel[T]) Delete(row *T) (cnt int64, err error) {
+	if s.pk == nil {
+		return 0, fmt.Errorf("primary key field of %s not found", s.model.table)
+	}
+	return s.DeleteBy(gmap.M{s.pk.column: reflect.ValueOf(row).Elem().Field(s.pk.index).Interface()})
+}
+
+// DeleteByIDs deletes the rows of the primary keys ids, ids must be a slice.
+func (s *TypedModel[T]) DeleteByIDs(ids interface{}) (cnt int64, err error) {
+	return s.DeleteBy(gmap.M{s.PrimaryKey(): ids})
+}
+
+// DeleteBy deletes the rows matched the where, if T has a deleted field, the rows are
+// soft deleted by setting the deleted column to current time, use Unscoped to delete them really.
+func (s *TypedModel[T]) DeleteBy(where gmap.M) (cnt int64, err error) {
+	if s.meta.deleted == nil || s.unscoped {
+		return s.model.DeleteBy(where)
+	}
+	return s.model.UpdateBy(s.scope(where), gmap.M{
+		s.meta.deleted.column: s.meta.deleted.now(reflect.New(reflect.TypeOf((*T)(nil)).Elem()).Elem()),
+	})
+}
+
+// scope returns a copy of where with the not deleted condition of soft delete column.
+func (s *TypedModel[T]) scope(where gmap.M) gmap.M {
+	if s.meta.deleted == nil || s.unscoped {
+		return where
+	}
+	w := gmap.M{}
+	for k, v := range where {
+		w[k] = v
+	}
+	if _, ok := w[s.meta.deleted.column]; !ok {
+		if s.meta.deleted.isTime {
+			w[s.meta.deleted.column] = nil
+		} else {
+			w[s.meta.deleted.column] = 0
+		}
+	}
+	return w
+}
+
+func (s *TypedModel[T]) insertData(v reflect.Value, omitZero bool) (data gmap.M) {
+	data = gmap.M{}
+	for _, f := range s.meta.fields {
+		fv := v.Field(f.index)
+		switch {
+		case f == s.meta.updated, f == s.meta.created && fv.IsZero():
+			data[f.column] = f.now(v)
+		case f == s.meta.deleted && !f.isTime:
+			// write 0 for the integer column, the scope matches it by deleted = 0.
+			data[f.column] = fv.Interface()
+		case f == s.pk, f == s.meta.deleted:
+			if !fv.IsZero() {
+				data[f.column] = fv.Interface()
+			}
+		case (omitZero || f.omitEmpty) && fv.IsZero():
+		default:
+			data[f.column] = fv.Interface()
+		}
+	}
+	return
+}
+
+func (s *TypedModel[T]) setPK(v reflect.Value, id int64) {
+	if s.pk == nil || id == 0 {
+		return
+	}
+	fv := v.Field(s.pk.index)
+	if !fv.IsZero() {
+		return
+	}
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fv.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		fv.SetUint(uint64(id))
+	}
+}
+
+func (s *TypedModel[T]) decode(rows []map[string]string) (ret []T, err error) {
+	ret = make([]T, 0, len(rows))
+	for _, row := range rows {
+		data := gmap.ToAny(row)
+		// NULL time column is empty string, keep the field zero value.
+		for _, f := range s.meta.fields {
+			if f.isTime && row[f.column] == "" {
+				delete(data, f.column)
+			}
+		}
+		var v interface{}
+		v, err = gvalue.MapToStructWithTag(data, new(T), "column")
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v.(T))
+	}
+	return
+}
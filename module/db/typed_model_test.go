@@ -0,0 +1,227 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gmap "github.com/snail007/gmc/util/map"
+	"github.com/stretchr/testify/assert"
+)
+
+type typedUser struct {
+	ID        int64     `column:"id,pk"`
+	Name      string    `column:"name"`
+	Age       int       `column:"age,omitempty"`
+	CreatedAt time.Time `column:"created_at,created"`
+	UpdatedAt int64     `column:"updated_at,updated"`
+	DeletedAt time.Time `column:"deleted_at,deleted"`
+	Ignored   string    `column:"-"`
+}
+
+type typedItem struct {
+	ItemID int    `column:"typed_item_id"`
+	Title  string `column:"title"`
+}
+
+func createTypedTestTable(db gcore.Database) {
+	db.ExecSQL("drop table typed_user")
+	db.ExecSQL("create table typed_user(id integer primary key autoincrement, name varchar(20), age int default 18, " +
+		"created_at datetime, updated_at int, deleted_at datetime null)")
+}
+
+func TestTypedModel_CRUD(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	createTypedTestTable(db)
+	defer db.ExecSQL("drop table typed_user")
+	m := TypedTable[typedUser]("typed_user", db)
+	assert.Equal("id", m.PrimaryKey())
+
+	u := &typedUser{Name: "a"}
+	id, err := m.Insert(u)
+	assert.Nil(err)
+	assert.Equal(id, u.ID)
+	assert.False(u.CreatedAt.IsZero())
+	assert.NotEqual(int64(0), u.UpdatedAt)
+
+	got, err := m.GetByID(id)
+	assert.Nil(err)
+	assert.Equal("a", got.Name)
+	// omitempty field is skipped, default value of column is used
+	assert.Equal(18, got.Age)
+	assert.Equal(u.CreatedAt.Unix(), got.CreatedAt.Unix())
+	assert.True(got.DeletedAt.IsZero())
+
+	got, err = m.GetByID(id + 100)
+	assert.Nil(err)
+	assert.Nil(got)
+
+	cnt, _, err := m.InsertBatch([]*typedUser{{Name: "b", Age: 20}, {Name: "c", Age: 30}})
+	assert.Nil(err)
+	assert.Equal(int64(2), cnt)
+
+	all, err := m.GetAll("id", "asc")
+	assert.Nil(err)
+	assert.Len(all, 3)
+	assert.Equal("c", all[2].Name)
+
+	rows, total, err := m.Page(gmap.M{"age >": 18}, 0, 1, "id", "desc")
+	assert.Nil(err)
+	assert.Equal(2, total)
+	assert.Len(rows, 1)
+	assert.Equal("c", rows[0].Name)
+
+	rows, err = m.List(nil, 1, 1, "id", "asc")
+	assert.Nil(err)
+	assert.Equal("b", rows[0].Name)
+
+	rows, err = m.MGetByIDs([]int64{all[0].ID, all[1].ID}, "id", "asc")
+	assert.Nil(err)
+	assert.Len(rows, 2)
+
+	u.Name = "aa"
+	u.Age = 0
+	cnt, err = m.OmitZero().Update(u)
+	assert.Nil(err)
+	assert.Equal(int64(1), cnt)
+	got, _ = m.GetByID(u.ID)
+	assert.Equal("aa", got.Name)
+	assert.Equal(18, got.Age)
+
+	cnt, err = m.UpdateBy(gmap.M{"name": "b"}, gmap.M{"age": 21})
+	assert.Nil(err)
+	assert.Equal(int64(1), cnt)
+	got, _ = m.GetBy(gmap.M{"name": "b"})
+	assert.Equal(21, got.Age)
+
+	_, err = m.Update(&typedUser{Name: "x"})
+	assert.NotNil(err)
+}
+
+func TestTypedModel_SoftDelete(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	createTypedTestTable(db)
+	defer db.ExecSQL("drop table typed_user")
+	m := TypedTable[typedUser]("typed_user", db)
+	u1, u2 := &typedUser{Name: "a"}, &typedUser{Name: "b"}
+	m.Insert(u1)
+	m.Insert(u2)
+
+	cnt, err := m.Delete(u1)
+	assert.Nil(err)
+	assert.Equal(int64(1), cnt)
+	got, err := m.GetByID(u1.ID)
+	assert.Nil(err)
+	assert.Nil(got)
+	count, _ := m.Count(nil)
+	assert.Equal(int64(1), count)
+
+	got, err = m.Unscoped().GetByID(u1.ID)
+	assert.Nil(err)
+	assert.False(got.DeletedAt.IsZero())
+
+	cnt, err = m.Unscoped().DeleteByIDs([]int64{u1.ID, u2.ID})
+	assert.Nil(err)
+	assert.Equal(int64(2), cnt)
+	count, _ = m.Unscoped().Count(nil)
+	assert.Equal(int64(0), count)
+}
+
+func TestTypedModel_Tx(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	createTypedTestTable(db)
+	defer db.ExecSQL("drop table typed_user")
+	m := TypedTable[typedUser]("typed_user", db)
+	db.Transaction(func(tx gcore.Tx) error {
+		m.Tx(tx).Insert(&typedUser{Name: "a"})
+		return errors.New("rollback")
+	})
+	count, _ := m.Count(nil)
+	assert.Equal(int64(0), count)
+}
+
+func TestTypedModel_ChunkByID(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	createTypedTestTable(db)
+	defer db.ExecSQL("drop table typed_user")
+	m := TypedTable[typedUser]("typed_user", db)
+	for i := 0; i < 5; i++ {
+		m.Insert(&typedUser{Name: "u", Age: i})
+	}
+	m.Delete(&typedUser{ID: 2})
+	var chunks [][]int64
+	err := m.ChunkByID(nil, 2, func(rows []typedUser) error {
+		var ids []int64
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		chunks = append(chunks, ids)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal([][]int64{{1, 3}, {4, 5}}, chunks)
+}
+
+func TestTypedModel_DefaultPrimaryKey(t *testing.T) {
+	db := db1()
+	db.ExecSQL("drop table typed_item")
+	db.ExecSQL("create table typed_item(typed_item_id integer primary key autoincrement, title varchar(20))")
+	defer db.ExecSQL("drop table typed_item")
+	m := TypedTable[typedItem]("typed_item", db)
+	assert.Equal(t, "typed_item_id", m.PrimaryKey())
+	item := &typedItem{Title: "foo"}
+	_, err := m.Insert(item)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, item.ItemID)
+	cnt, err := m.Delete(item)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), cnt)
+}
+
+type typedPost struct {
+	ID      int64  `column:"id,pk"`
+	Title   string `column:"title"`
+	Deleted int64  `column:"deleted,deleted"`
+}
+
+func TestTypedModel_IntDeleted(t *testing.T) {
+	assert := assert.New(t)
+	db := db1()
+	db.ExecSQL("drop table typed_post")
+	// the deleted column has no default value.
+	db.ExecSQL("create table typed_post(id integer primary key autoincrement, title varchar(20), deleted int null)")
+	defer db.ExecSQL("drop table typed_post")
+	m := TypedTable[typedPost]("typed_post", db)
+	post := &typedPost{Title: "foo"}
+	_, err := m.Insert(post)
+	assert.Nil(err)
+	p, err := m.GetByID(post.ID)
+	assert.Nil(err)
+	assert.NotNil(p)
+	_, _, err = m.InsertBatch([]*typedPost{{Title: "bar"}})
+	assert.Nil(err)
+	list, err := m.List(nil, 0, 10)
+	assert.Nil(err)
+	assert.Len(list, 2)
+	_, err = m.Delete(post)
+	assert.Nil(err)
+	p, err = m.GetByID(post.ID)
+	assert.Nil(err)
+	assert.Nil(p)
+}
+
+func TestTypedTable_NotStruct(t *testing.T) {
+	assert.Panics(t, func() {
+		TypedTable[int]("foo", db1())
+	})
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package bodylimit
+
+import (
+	"net/http"
+
+	gcore "github.com/snail007/gmc/core"
+	gbytes "github.com/snail007/gmc/util/bytes"
+)
+
+// DefaultMaxSize is the default max size of request body.
+const DefaultMaxSize = 10 << 20
+
+// NewFromConfig creates a body size limit middleware from section [bodylimit] of config, if the section
+// is missing or enable is false, the middleware does nothing.
+// max_size is a size string such as 512KB, 10MB, default is 10MB.
+func NewFromConfig(c gcore.Config) gcore.Middleware {
+	cfg := c.Sub("bodylimit")
+	if cfg == nil || !cfg.GetBool("enable") {
+		return func(ctx gcore.Ctx) bool { return false }
+	}
+	maxSize := int64(DefaultMaxSize)
+	if s := cfg.GetString("max_size"); s != "" {
+		size, err := gbytes.ParseSize(s)
+		if err != nil {
+			panic("bodylimit: invalid max_size " + s)
+		}
+		maxSize = int64(size)
+	}
+	return New(maxSize)
+}
+
+// New creates a body size limit middleware, the request with Content-Length larger than maxSize
+// is responded with 413, otherwise reading the body returns error after maxSize bytes read.
+func New(maxSize int64) gcore.Middleware {
+	return func(ctx gcore.Ctx) (isStop bool) {
+		r := ctx.Request()
+		if r.ContentLength > maxSize {
+			ctx.WriteHeader(http.StatusRequestEntityTooLarge)
+			ctx.Write(http.StatusText(http.StatusRequestEntityTooLarge))
+			return true
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(ctx.Response(), r.Body, maxSize)
+		}
+		return false
+	}
+}
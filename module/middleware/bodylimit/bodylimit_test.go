@@ -0,0 +1,52 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package bodylimit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gconfig "github.com/snail007/gmc/module/config"
+	gctx "github.com/snail007/gmc/module/ctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimit(t *testing.T) {
+	assert := assert.New(t)
+	m := New(10)
+
+	w := httptest.NewRecorder()
+	assert.True(m(gctx.NewCtxWithHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("01234567890")))))
+	assert.Equal(http.StatusRequestEntityTooLarge, w.Code)
+
+	// unknown length
+	r := httptest.NewRequest("POST", "/", ioutil.NopCloser(strings.NewReader("01234567890")))
+	r.ContentLength = -1
+	ctx := gctx.NewCtxWithHTTP(httptest.NewRecorder(), r)
+	assert.False(m(ctx))
+	_, err := ioutil.ReadAll(ctx.Request().Body)
+	assert.NotNil(err)
+
+	ctx = gctx.NewCtxWithHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("0123456789")))
+	assert.False(m(ctx))
+	b, err := ioutil.ReadAll(ctx.Request().Body)
+	assert.Nil(err)
+	assert.Equal("0123456789", string(b))
+}
+
+func TestNewFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gconfig.New()
+	cfg.SetConfigType("toml")
+	assert.Nil(cfg.ReadConfig(bytes.NewReader([]byte("[bodylimit]\nenable=true\nmax_size=\"1KB\""))))
+	m := NewFromConfig(cfg)
+	assert.True(m(gctx.NewCtxWithHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a", 1025))))))
+	assert.False(m(gctx.NewCtxWithHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a", 1024))))))
+}
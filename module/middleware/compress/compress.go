@@ -0,0 +1,212 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	gcore "github.com/snail007/gmc/core"
+	gbytes "github.com/snail007/gmc/util/bytes"
+)
+
+// Writer is the compress writer created by Encoder.
+type Writer interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// Encoder creates a Writer which writes the compressed data to w, level is the compression level,
+// -1 is the default level of the encoder.
+type Encoder func(w io.Writer, level int) (Writer, error)
+
+var (
+	encoders = map[string]Encoder{
+		"gzip": func(w io.Writer, level int) (Writer, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		"deflate": func(w io.Writer, level int) (Writer, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+		"br": func(w io.Writer, level int) (Writer, error) {
+			if level < 0 {
+				level = brotli.DefaultCompression
+			}
+			return brotli.NewWriterLevel(w, level), nil
+		},
+	}
+	encodersLock sync.RWMutex
+)
+
+// RegisterEncoder registers the encoder of the content coding name, br, gzip and deflate are built in,
+// registering a built-in name replaces it.
+func RegisterEncoder(name string, encoder Encoder) {
+	encodersLock.Lock()
+	defer encodersLock.Unlock()
+	encoders[strings.ToLower(name)] = encoder
+}
+
+func getEncoder(name string) Encoder {
+	encodersLock.RLock()
+	defer encodersLock.RUnlock()
+	return encoders[name]
+}
+
+// DefaultExcludeTypes are the content types which are already compressed or streamed,
+// an item ends with / matches the prefix.
+var DefaultExcludeTypes = []string{
+	"text/event-stream",
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"video/", "audio/",
+	"font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-bzip2",
+	"application/x-xz", "application/x-7z-compressed", "application/x-rar-compressed",
+	"application/zstd", "application/pdf", "application/octet-stream",
+}
+
+// Option is the options of compress middleware.
+type Option struct {
+	// Level is the compression level, 0 or -1 is the default level of encoder.
+	Level int
+	// MinSize is the min size of body to compress, the smaller body is responded without compression,
+	// default is 1KB, -1 compresses all.
+	MinSize int
+	// Encodings are the content codings in the order of preference when the client accepts them
+	// with the same quality, default is br, gzip, deflate, the unknown codings are ignored.
+	Encodings []string
+	// ExcludeTypes are the content types not compressed, an item ends with / matches the prefix,
+	// default is DefaultExcludeTypes.
+	ExcludeTypes []string
+}
+
+// NewFromConfig creates a compress middleware from section [compress] of config, if the section
+// is missing or enable is false, the middleware does nothing.
+// It is a route middleware, add it by Use of router or api server.
+func NewFromConfig(c gcore.Config) gcore.RouteMiddleware {
+	cfg := c.Sub("compress")
+	if cfg == nil || !cfg.GetBool("enable") {
+		return func(ctx gcore.Ctx, next func()) { next() }
+	}
+	opt := Option{
+		Level:        cfg.GetInt("level"),
+		Encodings:    cfg.GetStringSlice("encodings"),
+		ExcludeTypes: cfg.GetStringSlice("exclude_types"),
+	}
+	if v := cfg.GetString("min_size"); v != "" {
+		size, err := gbytes.ParseSize(v)
+		if err != nil {
+			panic("parse compress min_size fail, " + err.Error())
+		}
+		opt.MinSize = int(size)
+		if opt.MinSize == 0 {
+			opt.MinSize = -1
+		}
+	}
+	return New(opt)
+}
+
+// New creates a compress middleware with the options, the response is compressed by the encoding
+// negotiated from Accept-Encoding of request.
+// The response is not compressed if it is already encoded, a partial content, a download with
+// Content-Disposition attachment, or the content type is excluded, such as text/event-stream.
+// The response writer is replaced during the handler, ctx.WriteCount() returns the bytes written
+// by the handler before compression, ctx.StatusCode(), Flush and Hijack work as usual.
+func New(opt Option) gcore.RouteMiddleware {
+	if opt.Level == 0 {
+		opt.Level = flate.DefaultCompression
+	}
+	if opt.MinSize == 0 {
+		opt.MinSize = 1024
+	}
+	if len(opt.Encodings) == 0 {
+		opt.Encodings = []string{"br", "gzip", "deflate"}
+	}
+	if len(opt.ExcludeTypes) == 0 {
+		opt.ExcludeTypes = DefaultExcludeTypes
+	}
+	if _, err := gzip.NewWriterLevel(io.Discard, opt.Level); err != nil {
+		panic("invalid compress level " + strconv.Itoa(opt.Level))
+	}
+	return func(ctx gcore.Ctx, next func()) {
+		r := ctx.Request()
+		if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next()
+			return
+		}
+		if _, ok := ctx.Response().(*responseWriter); ok {
+			next()
+			return
+		}
+		encoding := negotiate(r.Header.Get("Accept-Encoding"), opt.Encodings)
+		if encoding == "" {
+			addVary(ctx.Response().Header())
+			next()
+			return
+		}
+		w := newResponseWriter(ctx.Response(), encoding, opt)
+		ctx.SetResponse(w)
+		defer func() {
+			ctx.SetResponse(w.ResponseWriter)
+			w.close()
+		}()
+		next()
+	}
+}
+
+// negotiate returns the encoding of encodings with the highest quality in accept,
+// the encodings with same quality are chosen by the order of encodings.
+func negotiate(accept string, encodings []string) string {
+	if accept == "" {
+		return ""
+	}
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, quality := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			name = part[:i]
+			param := strings.TrimSpace(part[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					continue
+				}
+				quality = v
+			}
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = quality
+	}
+	best, bestQ := "", 0.0
+	for _, e := range encodings {
+		v, ok := q[e]
+		if !ok {
+			v, ok = q["*"]
+		}
+		if !ok || v <= bestQ || getEncoder(e) == nil {
+			continue
+		}
+		best, bestQ = e, v
+	}
+	return best
+}
+
+func addVary(h http.Header) {
+	for _, v := range h.Values("Vary") {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.TrimSpace(s)
+			if s == "*" || strings.EqualFold(s, "Accept-Encoding") {
+				return
+			}
+		}
+	}
+	h.Add("Vary", "Accept-Encoding")
+}
@@ -0,0 +1,226 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	gcore "github.com/snail007/gmc/core"
+	ghttpserver "github.com/snail007/gmc/http/server"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gconfig "github.com/snail007/gmc/module/config"
+	gctx "github.com/snail007/gmc/module/ctx"
+	_ "github.com/snail007/gmc/using/web"
+	"github.com/stretchr/testify/assert"
+)
+
+var body = strings.Repeat("hello gmc, ", 200)
+
+func mockCtx(acceptEncoding string) (*gctx.Ctx, *httptest.ResponseRecorder) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	ctx := gctx.NewCtxWithHTTP(ghttputil.NewResponseWriter(w), r)
+	return ctx, w
+}
+
+func gunzip(b []byte) string {
+	r, err := gzip.NewReader(strings.NewReader(string(b)))
+	if err != nil {
+		return err.Error()
+	}
+	d, _ := io.ReadAll(r)
+	return string(d)
+}
+
+func TestGzip(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{})
+	ctx, w := mockCtx("deflate;q=0.5, gzip")
+	m(ctx, func() {
+		ctx.Response().Header().Set("Content-Length", "2200")
+		ctx.Response().Header().Set("ETag", `"v1"`)
+		ctx.WriteHeader(http.StatusCreated)
+		ctx.Write(body[:10])
+		assert.Empty(w.Header().Get("Content-Encoding"))
+		ctx.Write(body[10:])
+		assert.Equal(int64(len(body)), ctx.WriteCount())
+		assert.Equal(http.StatusCreated, ctx.StatusCode())
+	})
+	assert.Equal(http.StatusCreated, w.Code)
+	assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal("Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal("text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(`W/"v1"`, w.Header().Get("ETag"))
+	assert.Empty(w.Header().Get("Content-Length"))
+	assert.True(w.Body.Len() < len(body))
+	assert.Equal(body, gunzip(w.Body.Bytes()))
+	// the response writer is restored.
+	assert.IsType(&ghttputil.ResponseWriter{}, ctx.Response())
+}
+
+func TestDeflate(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{})
+	ctx, w := mockCtx("gzip;q=0.5, deflate")
+	m(ctx, func() {
+		ctx.Write(body)
+	})
+	assert.Equal("deflate", w.Header().Get("Content-Encoding"))
+	r, err := zlib.NewReader(w.Body)
+	assert.Nil(err)
+	d, _ := io.ReadAll(r)
+	assert.Equal(body, string(d))
+}
+
+func TestBrotli(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{})
+	ctx, w := mockCtx("gzip, deflate, br")
+	m(ctx, func() {
+		ctx.Write(body)
+	})
+	assert.Equal("br", w.Header().Get("Content-Encoding"))
+	assert.True(w.Body.Len() < len(body))
+	d, _ := io.ReadAll(brotli.NewReader(w.Body))
+	assert.Equal(body, string(d))
+}
+
+func TestSkip(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{})
+	for _, fn := range []func(ctx gcore.Ctx){
+		// small body
+		func(ctx gcore.Ctx) { ctx.Write("hello") },
+		func(ctx gcore.Ctx) {
+			ctx.SetHeader("Content-Type", "image/png")
+			ctx.Write(body)
+		},
+		func(ctx gcore.Ctx) {
+			ctx.SetHeader("Content-Disposition", `attachment; filename="a.txt"`)
+			ctx.Write(body)
+		},
+		func(ctx gcore.Ctx) {
+			ctx.SetHeader("Content-Encoding", "gzip")
+			ctx.Write(body)
+		},
+		func(ctx gcore.Ctx) {
+			ctx.WriteHeader(http.StatusPartialContent)
+			ctx.Write(body)
+		},
+	} {
+		ctx, w := mockCtx("gzip")
+		m(ctx, func() { fn(ctx) })
+		// the body is not compressed.
+		assert.True(w.Body.String() == "hello" || w.Body.String() == body)
+	}
+
+	// client does not accept
+	ctx, w := mockCtx("")
+	m(ctx, func() { ctx.Write(body) })
+	assert.Empty(w.Header().Get("Content-Encoding"))
+	assert.Equal("Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(body, w.Body.String())
+
+	ctx, w = mockCtx("zstd;q=1.0, identity;q=0.5, *;q=0")
+	m(ctx, func() { ctx.Write(body) })
+	assert.Empty(w.Header().Get("Content-Encoding"))
+}
+
+func TestFlush(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{})
+	ctx, w := mockCtx("gzip")
+	m(ctx, func() {
+		ctx.Write("data")
+		ctx.Response().(http.Flusher).Flush()
+		assert.True(w.Flushed)
+		assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+	})
+	assert.Equal("data", gunzip(w.Body.Bytes()))
+
+	ctx, w = mockCtx("gzip")
+	m(ctx, func() {
+		ctx.SSE(func(stream gcore.SSEStream) {
+			stream.Data("hello")
+			assert.Contains(w.Body.String(), "data: hello")
+		}, 0)
+	})
+	assert.Empty(w.Header().Get("Content-Encoding"))
+}
+
+func TestNegotiate(t *testing.T) {
+	assert := assert.New(t)
+	encodings := []string{"br", "gzip", "deflate"}
+	assert.Equal("br", negotiate("gzip, deflate, br", encodings))
+	assert.Equal("gzip", negotiate("gzip, deflate", encodings))
+	assert.Equal("deflate", negotiate("gzip;q=0.5, deflate", encodings))
+	assert.Equal("br", negotiate("*", encodings))
+	assert.Equal("", negotiate("gzip;q=0, deflate;q=0", encodings))
+	assert.Equal("", negotiate("identity", encodings))
+	assert.Equal("gzip", negotiate("gzip, deflate, br;q=0.9", encodings))
+	// the unknown coding is ignored.
+	assert.Equal("gzip", negotiate("gzip, zstd", append([]string{"zstd"}, encodings...)))
+}
+
+func TestServer(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gcore.ProviderConfig()()
+	cfg.SetConfigFile("../../app/app.toml")
+	cfg.ReadInConfig()
+	cfg.Set("template.dir", "../../../http/template/tests/views")
+	s := ghttpserver.NewHTTPServer(gcore.ProviderCtx()())
+	assert.Nil(s.Init(cfg))
+	s.Router().Use(New(Option{}))
+	s.Router().HandlerFunc("GET", "/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+	s.Router().HandlerFunc("GET", "/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("error")
+	})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	assert.Equal("gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(body, gunzip(w.Body.Bytes()))
+
+	// nothing is written before panic, the server responds 500.
+	r = httptest.NewRequest("GET", "/panic", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	assert.Equal(http.StatusInternalServerError, w.Code)
+	assert.Empty(w.Header().Get("Content-Encoding"))
+}
+
+func TestNewFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gconfig.New()
+	ctx, w := mockCtx("gzip")
+	NewFromConfig(cfg)(ctx, func() { ctx.Write(body) })
+	assert.Equal(body, w.Body.String())
+	cfg.Set("compress.enable", true)
+	cfg.Set("compress.min_size", "10KB")
+	ctx, w = mockCtx("gzip")
+	NewFromConfig(cfg)(ctx, func() { ctx.Write(body) })
+	assert.Equal(body, w.Body.String())
+	cfg.Set("compress.min_size", "1KB")
+	ctx, w = mockCtx("gzip")
+	NewFromConfig(cfg)(ctx, func() { ctx.Write(body) })
+	assert.Equal(body, gunzip(w.Body.Bytes()))
+	cfg.Set("compress.level", 100)
+	assert.Panics(func() { NewFromConfig(cfg) })
+}
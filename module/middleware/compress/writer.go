@@ -0,0 +1,232 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package compress
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// responseWriter buffers the body until MinSize reached, then decides to compress it or not by
+// the headers, the status code and the header are written to the underlying writer after decided.
+type responseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	opt         Option
+	status      int
+	wroteHeader bool
+	decided     bool
+	hijacked    bool
+	written     int64
+	buf         []byte
+	enc         Writer
+	data        *sync.Map
+}
+
+func newResponseWriter(w http.ResponseWriter, encoding string, opt Option) *responseWriter {
+	return &responseWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+		opt:            opt,
+		status:         http.StatusOK,
+		data:           &sync.Map{},
+	}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	if !w.compressible(false) {
+		w.start(false)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (n int, err error) {
+	if w.hijacked {
+		return 0, http.ErrHijacked
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		w.written += int64(len(b))
+		if len(w.buf) >= w.opt.MinSize {
+			if err = w.start(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		n, err = w.enc.Write(b)
+	} else {
+		n, err = w.ResponseWriter.Write(b)
+	}
+	w.written += int64(n)
+	return
+}
+
+// start writes the header and the buffered body to the underlying writer, the body is compressed
+// if compress is true and the response is compressible.
+func (w *responseWriter) start(compress bool) (err error) {
+	w.decided = true
+	h := w.Header()
+	if compress && w.compressible(true) {
+		if w.enc, err = getEncoder(w.encoding)(w.ResponseWriter, w.opt.Level); err != nil {
+			w.enc = nil
+		} else {
+			h.Del("Content-Length")
+			h.Del("Accept-Ranges")
+			h.Set("Content-Encoding", w.encoding)
+			addVary(h)
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				// the compressed body is not byte-for-byte identical.
+				h.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return
+	}
+	buf := w.buf
+	w.buf = nil
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return
+}
+
+// compressible checks the status code and the headers, if sniff is true, the content type is
+// detected by the buffered body when it is not set.
+func (w *responseWriter) compressible(sniff bool) bool {
+	if w.status < http.StatusOK || w.status == http.StatusNoContent ||
+		w.status == http.StatusPartialContent || w.status == http.StatusNotModified {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" ||
+		strings.HasPrefix(strings.ToLower(h.Get("Content-Disposition")), "attachment") {
+		return false
+	}
+	if v := h.Get("Content-Length"); v != "" {
+		if size, err := strconv.Atoi(v); err == nil && size < w.opt.MinSize {
+			return false
+		}
+	}
+	typ := h.Get("Content-Type")
+	if typ == "" && sniff && len(w.buf) > 0 {
+		typ = http.DetectContentType(w.buf)
+		h.Set("Content-Type", typ)
+	}
+	typ = strings.ToLower(strings.TrimSpace(strings.Split(typ, ";")[0]))
+	for _, v := range w.opt.ExcludeTypes {
+		if typ == v || strings.HasSuffix(v, "/") && strings.HasPrefix(typ, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Flush compresses the buffered body and sends it to the client.
+func (w *responseWriter) Flush() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		if !w.decided {
+			w.start(true)
+		}
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// close writes the buffered body and closes the encoder, if nothing was written,
+// the underlying writer is untouched, so the server can still respond an error page.
+func (w *responseWriter) close() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		if !w.wroteHeader && len(w.buf) == 0 {
+			return
+		}
+		w.start(false)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+	}
+}
+
+// WriteCount returns the bytes written by the handler before compression.
+func (w *responseWriter) WriteCount() int64 {
+	return w.written
+}
+
+func (w *responseWriter) StatusCode() int {
+	return w.status
+}
+
+func (w *responseWriter) ClearData() {
+	if v, ok := w.ResponseWriter.(gcore.ResponseWriter); ok {
+		v.ClearData()
+		return
+	}
+	w.data = &sync.Map{}
+}
+
+func (w *responseWriter) Data(k interface{}) interface{} {
+	if v, ok := w.ResponseWriter.(gcore.ResponseWriter); ok {
+		return v.Data(k)
+	}
+	v, _ := w.data.Load(k)
+	return v
+}
+
+func (w *responseWriter) SetData(k interface{}, v interface{}) {
+	if rw, ok := w.ResponseWriter.(gcore.ResponseWriter); ok {
+		rw.SetData(k, v)
+		return
+	}
+	w.data.Store(k, v)
+}
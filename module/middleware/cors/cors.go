@@ -0,0 +1,150 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// Option is the options of cors middleware.
+type Option struct {
+	// AllowOrigins are the allowed origins, such as https://example.com,
+	// https://*.example.com matches the sub domains, * allows any origin.
+	AllowOrigins []string
+	AllowMethods []string
+	// AllowHeaders are the allowed request headers, * allows the headers requested by the preflight.
+	AllowHeaders  []string
+	ExposeHeaders []string
+	// AllowCredentials can not be used with the origin *, the explicit origins are required.
+	AllowCredentials bool
+	// MaxAge is the seconds of the preflight result can be cached, 0 means not set.
+	MaxAge int
+}
+
+// DefaultOption allows any origin with the common methods.
+func DefaultOption() Option {
+	return Option{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
+		AllowHeaders: []string{"*"},
+		MaxAge:       600,
+	}
+}
+
+// NewFromConfig creates a cors middleware from section [cors] of config, if the section
+// is missing or enable is false, the middleware does nothing.
+// It should be added by AddMiddleware0, so the preflight requests are responded before routing.
+func NewFromConfig(c gcore.Config) gcore.Middleware {
+	cfg := c.Sub("cors")
+	if cfg == nil || !cfg.GetBool("enable") {
+		return func(ctx gcore.Ctx) bool { return false }
+	}
+	opt := DefaultOption()
+	if cfg.IsSet("allow_origins") {
+		opt.AllowOrigins = cfg.GetStringSlice("allow_origins")
+	}
+	if cfg.IsSet("allow_methods") {
+		opt.AllowMethods = cfg.GetStringSlice("allow_methods")
+	}
+	if cfg.IsSet("allow_headers") {
+		opt.AllowHeaders = cfg.GetStringSlice("allow_headers")
+	}
+	if cfg.IsSet("max_age") {
+		opt.MaxAge = cfg.GetInt("max_age")
+	}
+	opt.ExposeHeaders = cfg.GetStringSlice("expose_headers")
+	opt.AllowCredentials = cfg.GetBool("allow_credentials")
+	return New(opt)
+}
+
+// New creates a cors middleware with the options. It panics if AllowCredentials is true and
+// AllowOrigins contains *, because any site could send credentialed requests.
+func New(opt Option) gcore.Middleware {
+	anyOrigin := contains(opt.AllowOrigins, "*")
+	if anyOrigin && opt.AllowCredentials {
+		panic("cors: allow_credentials requires explicit allow_origins, * is not allowed")
+	}
+	allowMethods := strings.Join(opt.AllowMethods, ", ")
+	allowHeaders := strings.Join(opt.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(opt.ExposeHeaders, ", ")
+	anyHeader := contains(opt.AllowHeaders, "*")
+	return func(ctx gcore.Ctx) (isStop bool) {
+		origin := ctx.Header("Origin")
+		if origin == "" {
+			return false
+		}
+		h := ctx.Response().Header()
+		h.Add("Vary", "Origin")
+		preflight := ctx.IsOPTIONS() && ctx.Header("Access-Control-Request-Method") != ""
+		if !allowOrigin(opt.AllowOrigins, origin) {
+			if preflight {
+				ctx.WriteHeader(http.StatusForbidden)
+				return true
+			}
+			return false
+		}
+		if anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if opt.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if exposeHeaders != "" {
+				h.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			return false
+		}
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", allowMethods)
+		if anyHeader {
+			if reqHeaders := ctx.Header("Access-Control-Request-Headers"); reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+		} else if allowHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+		if opt.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(opt.MaxAge))
+		}
+		ctx.WriteHeader(http.StatusNoContent)
+		return true
+	}
+}
+
+func allowOrigin(origins []string, origin string) bool {
+	for _, o := range origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+		// https://*.example.com
+		if i := strings.Index(o, "*."); i > 0 {
+			prefix, suffix := strings.ToLower(o[:i]), strings.ToLower(o[i+1:])
+			lo := strings.ToLower(origin)
+			if strings.HasPrefix(lo, prefix) && strings.HasSuffix(lo, suffix) &&
+				len(lo) > len(prefix)+len(suffix) && !strings.Contains(lo[len(prefix):len(lo)-len(suffix)], "/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(arr []string, s string) bool {
+	for _, v := range arr {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
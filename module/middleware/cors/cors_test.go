@@ -0,0 +1,97 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package cors
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gconfig "github.com/snail007/gmc/module/config"
+	gctx "github.com/snail007/gmc/module/ctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func request(m func(*gctx.Ctx) bool, method, origin string, header ...string) (*httptest.ResponseRecorder, bool) {
+	r := httptest.NewRequest(method, "/api", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	return w, m(gctx.NewCtxWithHTTP(w, r))
+}
+
+func TestCORS(t *testing.T) {
+	assert := assert.New(t)
+	opt := DefaultOption()
+	opt.AllowOrigins = []string{"https://example.com", "https://*.example.org"}
+	opt.AllowCredentials = true
+	opt.ExposeHeaders = []string{"X-Total"}
+	mw := New(opt)
+	m := func(c *gctx.Ctx) bool { return mw(c) }
+
+	w, stop := request(m, "GET", "")
+	assert.False(stop)
+	assert.Empty(w.Header().Get("Access-Control-Allow-Origin"))
+
+	w, stop = request(m, "GET", "https://example.com")
+	assert.False(stop)
+	assert.Equal("https://example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal("true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal("X-Total", w.Header().Get("Access-Control-Expose-Headers"))
+
+	w, stop = request(m, "OPTIONS", "https://a.example.org",
+		"Access-Control-Request-Method", "PUT", "Access-Control-Request-Headers", "X-Token")
+	assert.True(stop)
+	assert.Equal(http.StatusNoContent, w.Code)
+	assert.Equal("https://a.example.org", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal("X-Token", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal("600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Contains(w.Header().Get("Access-Control-Allow-Methods"), "PUT")
+
+	w, stop = request(m, "GET", "https://evil.com")
+	assert.False(stop)
+	assert.Empty(w.Header().Get("Access-Control-Allow-Origin"))
+	w, stop = request(m, "OPTIONS", "https://example.org.evil.com", "Access-Control-Request-Method", "PUT")
+	assert.True(stop)
+	assert.Equal(http.StatusForbidden, w.Code)
+
+	// any origin without credentials
+	mw = New(DefaultOption())
+	w, _ = request(m, "GET", "https://evil.com")
+	assert.Equal("*", w.Header().Get("Access-Control-Allow-Origin"))
+
+	// any origin with credentials is refused
+	opt = DefaultOption()
+	opt.AllowCredentials = true
+	assert.Panics(func() { New(opt) })
+}
+
+func TestNewFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gconfig.New()
+	cfg.SetConfigType("toml")
+	assert.Nil(cfg.ReadConfig(bytes.NewReader([]byte("[cors]\nenable=true\nallow_origins=[\"https://example.com\"]\nmax_age=60"))))
+	mw := NewFromConfig(cfg)
+	m := func(c *gctx.Ctx) bool { return mw(c) }
+	w, stop := request(m, "OPTIONS", "https://example.com", "Access-Control-Request-Method", "GET")
+	assert.True(stop)
+	assert.Equal("60", w.Header().Get("Access-Control-Max-Age"))
+
+	cfg = gconfig.New()
+	cfg.SetConfigType("toml")
+	assert.Nil(cfg.ReadConfig(bytes.NewReader([]byte("[cors]\nenable=true\nallow_credentials=true"))))
+	assert.Panics(func() { NewFromConfig(cfg) })
+
+	mw = NewFromConfig(gconfig.New())
+	w, stop = request(m, "OPTIONS", "https://example.com", "Access-Control-Request-Method", "GET")
+	assert.False(stop)
+	assert.Empty(w.Header().Get("Access-Control-Allow-Origin"))
+}
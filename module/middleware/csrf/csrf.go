@@ -0,0 +1,275 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package csrf
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+	gsession "github.com/snail007/gmc/http/session"
+)
+
+const (
+	// StoreSession stores the token in session.
+	StoreSession = "session"
+	// StoreCookie stores the token in a cookie, the request must submit the same token, known as double submit cookie.
+	StoreCookie = "cookie"
+
+	// SessionKey is the key of token in session.
+	SessionKey = "_csrf_token"
+)
+
+type ctxKey struct{}
+
+// Option is the options of csrf middleware.
+type Option struct {
+	// Store is StoreSession or StoreCookie, if it is empty, session is used when the session of
+	// web server is enabled, otherwise cookie is used.
+	Store string
+	// Field is the form field of token, default is _csrf.
+	Field string
+	// Header is the header of token, default is X-CSRF-Token.
+	Header string
+	// Cookie options of StoreCookie, the default name is _csrf.
+	CookieName     string
+	CookiePath     string
+	CookieDomain   string
+	CookieMaxAge   int
+	CookieSecure   bool
+	CookieHTTPOnly bool
+	// Exempt are the paths not checked, such as /webhook, a path ends with * matches the prefix, such as /api/*.
+	Exempt []string
+	// ExemptFunc returns true if the request is not checked.
+	ExemptFunc func(ctx gcore.Ctx) bool
+	// ErrorHandler is called if the token is invalid, default responds 403.
+	ErrorHandler func(ctx gcore.Ctx)
+}
+
+// NewFromConfig creates a csrf middleware from section [csrf] of config, if the section
+// is missing or enable is false, the middleware does nothing.
+// It should be added by AddMiddleware1.
+func NewFromConfig(c gcore.Config) gcore.Middleware {
+	cfg := c.Sub("csrf")
+	if cfg == nil || !cfg.GetBool("enable") {
+		return func(ctx gcore.Ctx) bool { return false }
+	}
+	return New(Option{
+		Store:          cfg.GetString("store"),
+		Field:          cfg.GetString("field"),
+		Header:         cfg.GetString("header"),
+		CookieName:     cfg.GetString("cookie_name"),
+		CookiePath:     cfg.GetString("cookie_path"),
+		CookieDomain:   cfg.GetString("cookie_domain"),
+		CookieMaxAge:   cfg.GetInt("cookie_max_age"),
+		CookieSecure:   cfg.GetBool("cookie_secure"),
+		CookieHTTPOnly: cfg.GetBool("cookie_httponly"),
+		Exempt:         cfg.GetStringSlice("exempt"),
+	})
+}
+
+// New creates a csrf middleware with the options.
+// The token is generated for each client, the requests with method not GET, HEAD, OPTIONS and TRACE
+// must submit the token by the form field or the header.
+// The token can be got by Token(ctx), and it is set to the view data of controller,
+// so it can be used in templates by {{csrf_field .}} or {{csrf_token .}}.
+func New(opt Option) gcore.Middleware {
+	if opt.Field == "" {
+		opt.Field = "_csrf"
+	}
+	if opt.Header == "" {
+		opt.Header = "X-CSRF-Token"
+	}
+	if opt.CookieName == "" {
+		opt.CookieName = "_csrf"
+	}
+	if opt.CookiePath == "" {
+		opt.CookiePath = "/"
+	}
+	if opt.ErrorHandler == nil {
+		opt.ErrorHandler = func(ctx gcore.Ctx) {
+			ctx.WriteHeader(http.StatusForbidden)
+			ctx.Write("invalid csrf token")
+		}
+	}
+	return func(ctx gcore.Ctx) (isStop bool) {
+		var s store
+		if opt.Store == StoreSession || opt.Store == "" && sessionStore(ctx) != nil {
+			s = &session{ctx: ctx}
+		} else {
+			s = &cookie{ctx: ctx, opt: opt}
+		}
+		token := s.get()
+		if !safeMethod(ctx.Request().Method) && !exempt(ctx, opt) {
+			submitted := ctx.Header(opt.Header)
+			if submitted == "" {
+				submitted = ctx.Request().PostFormValue(opt.Field)
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(submitted)) != 1 {
+				opt.ErrorHandler(ctx)
+				return true
+			}
+		}
+		if token == "" {
+			token = generate()
+			if err := s.set(token); err != nil {
+				ctx.Logger().Warnf("save csrf token fail, %s", err)
+			}
+		}
+		ctx.Set(ctxKey{}, token)
+		data, _ := ctx.MustGet(gcore.CtxKeyViewData).(map[string]interface{})
+		if data == nil {
+			data = map[string]interface{}{}
+			ctx.Set(gcore.CtxKeyViewData, data)
+		}
+		data["CSRFToken"] = token
+		data["CSRFField"] = opt.Field
+		return false
+	}
+}
+
+// Token returns the csrf token of the request, it can be responded to the client to submit by the header.
+func Token(ctx gcore.Ctx) string {
+	if v, ok := ctx.Get(ctxKey{}); ok {
+		return v.(string)
+	}
+	return ""
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func exempt(ctx gcore.Ctx, opt Option) bool {
+	if opt.ExemptFunc != nil && opt.ExemptFunc(ctx) {
+		return true
+	}
+	path := ctx.Request().URL.Path
+	for _, p := range opt.Exempt {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func generate() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func sessionStore(ctx gcore.Ctx) gcore.SessionStorage {
+	if ctx.WebServer() == nil {
+		return nil
+	}
+	st := ctx.WebServer().SessionStore()
+	if s, ok := st.(gcore.CtxSessionStorage); ok {
+		return s.WithCtx(ctx)
+	}
+	return st
+}
+
+type store interface {
+	get() string
+	set(token string) error
+}
+
+type session struct {
+	ctx  gcore.Ctx
+	sess gcore.Session
+}
+
+func (s *session) get() string {
+	st := sessionStore(s.ctx)
+	if st == nil {
+		return ""
+	}
+	sid, _ := gcore.ProviderCookies()(s.ctx).Get(s.ctx.Config().GetString("session.cookiename"))
+	if sid == "" {
+		return ""
+	}
+	sess, ok := st.Load(sid)
+	if !ok || !gsession.IsValid(s.ctx, sess) {
+		return ""
+	}
+	s.sess = sess
+	token, _ := sess.Get(SessionKey).(string)
+	return token
+}
+
+// set saves the token to session, if the session is not exists, a new session is started
+// and the session id is also set to the request cookie, so the controller uses the same session.
+func (s *session) set(token string) (err error) {
+	st := sessionStore(s.ctx)
+	if st == nil {
+		return
+	}
+	if s.sess == nil {
+		cookieName := s.ctx.Config().GetString("session.cookiename")
+		s.sess = gcore.ProviderSession()()
+		s.sess.Touch()
+		s.sess.SetFingerprint(gsession.Fingerprint(s.ctx))
+		gcore.ProviderCookies()(s.ctx).Set(cookieName, s.sess.SessionID(), &gcore.CookieOptions{
+			Path:     "/",
+			MaxAge:   s.ctx.Config().GetInt("session.ttl"),
+			HTTPOnly: true,
+		})
+		setRequestCookie(s.ctx.Request(), cookieName, s.sess.SessionID())
+	}
+	s.sess.Set(SessionKey, token)
+	return st.Save(s.sess)
+}
+
+type cookie struct {
+	ctx gcore.Ctx
+	opt Option
+}
+
+func (s *cookie) get() string {
+	c, err := s.ctx.Request().Cookie(s.opt.CookieName)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+func (s *cookie) set(token string) error {
+	http.SetCookie(s.ctx.Response(), &http.Cookie{
+		Name:     s.opt.CookieName,
+		Value:    token,
+		Path:     s.opt.CookiePath,
+		Domain:   s.opt.CookieDomain,
+		MaxAge:   s.opt.CookieMaxAge,
+		Secure:   s.opt.CookieSecure,
+		HttpOnly: s.opt.CookieHTTPOnly,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// setRequestCookie replaces the cookie name of request with value.
+func setRequestCookie(r *http.Request, name, value string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != name {
+			r.AddCookie(c)
+		}
+	}
+	r.AddCookie(&http.Cookie{Name: name, Value: value})
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttpserver "github.com/snail007/gmc/http/server"
+	gsession "github.com/snail007/gmc/http/session"
+	gconfig "github.com/snail007/gmc/module/config"
+	gctx "github.com/snail007/gmc/module/ctx"
+	_ "github.com/snail007/gmc/using/web"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockCtx(method, path, body string, cookies ...*http.Cookie) (*gctx.Ctx, *httptest.ResponseRecorder) {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	ctx := gctx.NewCtxWithHTTP(w, r)
+	cfg := gconfig.New()
+	cfg.Set("session.cookiename", "gmcsid")
+	cfg.Set("session.ttl", 3600)
+	ctx.SetConfig(cfg)
+	return ctx, w
+}
+
+func TestCookie(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{Exempt: []string{"/hook", "/api/*"}})
+
+	ctx, w := mockCtx("GET", "/form", "")
+	assert.False(m(ctx))
+	token := Token(ctx)
+	assert.NotEmpty(token)
+	cookie := w.Result().Cookies()[0]
+	assert.Equal("_csrf", cookie.Name)
+	assert.Equal(token, cookie.Value)
+	data := ctx.MustGet(gcore.CtxKeyViewData).(map[string]interface{})
+	assert.Equal(token, data["CSRFToken"])
+	assert.Equal("_csrf", data["CSRFField"])
+
+	// the token is kept
+	ctx, w = mockCtx("GET", "/form", "", cookie)
+	assert.False(m(ctx))
+	assert.Equal(token, Token(ctx))
+	assert.Empty(w.Result().Cookies())
+
+	ctx, w = mockCtx("POST", "/form", "a=1", cookie)
+	assert.True(m(ctx))
+	assert.Equal(http.StatusForbidden, w.Code)
+
+	ctx, _ = mockCtx("POST", "/form", "_csrf="+url.QueryEscape(token), cookie)
+	assert.False(m(ctx))
+
+	ctx, _ = mockCtx("DELETE", "/form", "", cookie)
+	ctx.Request().Header.Set("X-CSRF-Token", token)
+	assert.False(m(ctx))
+
+	// without cookie
+	ctx, _ = mockCtx("POST", "/form", "_csrf="+url.QueryEscape(token))
+	assert.True(m(ctx))
+
+	ctx, _ = mockCtx("POST", "/hook", "")
+	assert.False(m(ctx))
+	ctx, _ = mockCtx("POST", "/api/user", "")
+	assert.False(m(ctx))
+	ctx, _ = mockCtx("POST", "/api", "")
+	assert.True(m(ctx))
+
+	m = New(Option{ErrorHandler: func(ctx gcore.Ctx) {
+		ctx.JSON(http.StatusBadRequest, map[string]string{"error": "csrf"})
+	}})
+	ctx, w = mockCtx("PUT", "/form", "")
+	assert.True(m(ctx))
+	assert.Equal(http.StatusBadRequest, w.Code)
+	assert.Equal(`{"error":"csrf"}`, w.Body.String())
+}
+
+func TestSession(t *testing.T) {
+	assert := assert.New(t)
+	s := ghttpserver.NewHTTPServer(gcore.ProviderCtx()())
+	st, _ := gsession.NewMemoryStore(gsession.NewMemoryStoreConfig())
+	s.SetSessionStore(st)
+	m := New(Option{})
+
+	ctx, w := mockCtx("GET", "/form", "")
+	ctx.SetWebServer(s)
+	assert.False(m(ctx))
+	token := Token(ctx)
+	assert.NotEmpty(token)
+	cookie := w.Result().Cookies()[0]
+	assert.Equal("gmcsid", cookie.Name)
+	// the controller loads the same session by the request cookie.
+	c, err := ctx.Request().Cookie("gmcsid")
+	assert.Nil(err)
+	assert.Equal(cookie.Value, c.Value)
+	sess, ok := st.Load(cookie.Value)
+	assert.True(ok)
+	assert.Equal(token, sess.Get(SessionKey))
+
+	ctx, _ = mockCtx("POST", "/form", "_csrf="+url.QueryEscape(token), cookie)
+	ctx.SetWebServer(s)
+	assert.False(m(ctx))
+	assert.Equal(token, Token(ctx))
+
+	ctx, w = mockCtx("POST", "/form", "_csrf="+url.QueryEscape(token))
+	ctx.SetWebServer(s)
+	assert.True(m(ctx))
+	assert.Equal(http.StatusForbidden, w.Code)
+
+	// the token of other session
+	ctx, _ = mockCtx("GET", "/form", "")
+	ctx.SetWebServer(s)
+	m(ctx)
+	ctx, _ = mockCtx("POST", "/form", "_csrf="+url.QueryEscape(Token(ctx)), cookie)
+	ctx.SetWebServer(s)
+	assert.True(m(ctx))
+}
+
+func TestNewFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gconfig.New()
+	ctx, _ := mockCtx("POST", "/form", "")
+	assert.False(NewFromConfig(cfg)(ctx))
+	cfg.Set("csrf.enable", true)
+	cfg.Set("csrf.exempt", []string{"/form"})
+	assert.False(NewFromConfig(cfg)(ctx))
+	ctx, _ = mockCtx("POST", "/form1", "")
+	assert.True(NewFromConfig(cfg)(ctx))
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package httpcache
+
+import (
+	"net/http"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gbytes "github.com/snail007/gmc/util/bytes"
+	gcast "github.com/snail007/gmc/util/cast"
+)
+
+// Option is the options of http cache middleware.
+type Option struct {
+	// DisableETag disables the weak ETag generated by the response body.
+	DisableETag bool
+	// MaxSize is the max size of body buffered to generate ETag, the larger response is sent
+	// without ETag, default is 1MB.
+	MaxSize int
+	// Rules set Cache-Control of the responses by the path prefix.
+	Rules []Rule
+}
+
+// Rule sets Cache-Control of the paths with the prefix.
+type Rule struct {
+	Path         string
+	CacheControl string
+}
+
+// NewFromConfig creates a http cache middleware from section [httpcache] of config, if the section
+// is missing or enable is false, the middleware does nothing.
+// It is a route middleware, add it by Use of router or api server.
+func NewFromConfig(c gcore.Config) gcore.RouteMiddleware {
+	cfg := c.Sub("httpcache")
+	if cfg == nil || !cfg.GetBool("enable") {
+		return func(ctx gcore.Ctx, next func()) { next() }
+	}
+	opt := Option{}
+	if cfg.IsSet("etag") {
+		opt.DisableETag = !cfg.GetBool("etag")
+	}
+	if v := cfg.GetString("max_size"); v != "" {
+		size, err := gbytes.ParseSize(v)
+		if err != nil {
+			panic("parse httpcache max_size fail, " + err.Error())
+		}
+		opt.MaxSize = int(size)
+	}
+	items, _ := cfg.Get("rules").([]interface{})
+	for _, v := range items {
+		vv, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		opt.Rules = append(opt.Rules, Rule{
+			Path:         gcast.ToString(vv["path"]),
+			CacheControl: gcast.ToString(vv["cache_control"]),
+		})
+	}
+	return New(opt)
+}
+
+// New creates a http cache middleware with the options.
+// The response of GET and HEAD with status 200 is buffered, a weak ETag is generated by the body
+// if the handler did not set it, then If-None-Match and If-Modified-Since of request are checked with
+// the ETag and Last-Modified of response, the request with fresh cache is responded 304 without body.
+// The response is sent without buffering if the handler set ETag, calls Flush or writes more than MaxSize.
+// The Cache-Control of the first matched rule replaces the one set by the handler.
+func New(opt Option) gcore.RouteMiddleware {
+	if opt.MaxSize <= 0 {
+		opt.MaxSize = 1 << 20
+	}
+	return func(ctx gcore.Ctx, next func()) {
+		r := ctx.Request()
+		cacheControl := ""
+		for _, v := range opt.Rules {
+			if strings.HasPrefix(r.URL.Path, v.Path) {
+				cacheControl = v.CacheControl
+				break
+			}
+		}
+		conditional := r.Method == http.MethodGet || r.Method == http.MethodHead
+		if (!conditional || opt.DisableETag) && cacheControl == "" {
+			next()
+			return
+		}
+		if r.Header.Get("Upgrade") != "" {
+			next()
+			return
+		}
+		w := &responseWriter{
+			ResponseWriter: ctx.Response(),
+			req:            r,
+			opt:            opt,
+			status:         http.StatusOK,
+			conditional:    conditional,
+			cacheControl:   cacheControl,
+		}
+		ctx.SetResponse(w)
+		defer func() {
+			ctx.SetResponse(w.ResponseWriter)
+			w.close()
+		}()
+		next()
+	}
+}
+
+// Check checks the conditional headers of request with the ETag and Last-Modified set to the response,
+// if the client cache is fresh, 304 is responded and true is returned, the handler should return without writing body.
+//
+//	ctx.SetHeader("ETag", `"v1"`)
+//	if httpcache.Check(ctx) {
+//		return
+//	}
+func Check(ctx gcore.Ctx) bool {
+	if ghttputil.NotModified(ctx.Request(), ctx.Response().Header()) {
+		ghttputil.WriteNotModified(ctx.Response())
+		return true
+	}
+	return false
+}
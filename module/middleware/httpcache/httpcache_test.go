@@ -0,0 +1,205 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+	gconfig "github.com/snail007/gmc/module/config"
+	gctx "github.com/snail007/gmc/module/ctx"
+	_ "github.com/snail007/gmc/using/web"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockCtx(method, path string, header ...string) (*gctx.Ctx, *httptest.ResponseRecorder) {
+	r := httptest.NewRequest(method, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	ctx := gctx.NewCtxWithHTTP(ghttputil.NewResponseWriter(w), r)
+	return ctx, w
+}
+
+func TestETag(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{})
+	ctx, w := mockCtx("GET", "/")
+	m(ctx, func() {
+		ctx.JSON(200, map[string]string{"a": "b"})
+		assert.Equal(int64(9), ctx.WriteCount())
+		assert.Equal(http.StatusOK, ctx.StatusCode())
+	})
+	etag := w.Header().Get("ETag")
+	assert.True(strings.HasPrefix(etag, `W/"`))
+	assert.Equal(`{"a":"b"}`, w.Body.String())
+	assert.Equal("application/json", w.Header().Get("Content-Type"))
+
+	ctx, w = mockCtx("GET", "/", "If-None-Match", `"x", `+etag)
+	m(ctx, func() {
+		ctx.JSON(200, map[string]string{"a": "b"})
+	})
+	assert.Equal(http.StatusNotModified, w.Code)
+	assert.Empty(w.Body.String())
+	assert.Empty(w.Header().Get("Content-Type"))
+	assert.Equal(etag, w.Header().Get("ETag"))
+
+	// changed
+	ctx, w = mockCtx("GET", "/", "If-None-Match", etag)
+	m(ctx, func() {
+		ctx.JSON(200, map[string]string{"a": "c"})
+	})
+	assert.Equal(http.StatusOK, w.Code)
+	assert.NotEqual(etag, w.Header().Get("ETag"))
+
+	// not GET
+	ctx, w = mockCtx("POST", "/", "If-None-Match", etag)
+	m(ctx, func() {
+		ctx.JSON(200, map[string]string{"a": "b"})
+	})
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Empty(w.Header().Get("ETag"))
+
+	// not 200
+	ctx, w = mockCtx("GET", "/")
+	m(ctx, func() {
+		ctx.WriteHeader(http.StatusNotFound)
+		ctx.Write("not found")
+	})
+	assert.Equal(http.StatusNotFound, w.Code)
+	assert.Empty(w.Header().Get("ETag"))
+
+	// larger than MaxSize
+	m = New(Option{MaxSize: 5})
+	ctx, w = mockCtx("GET", "/")
+	m(ctx, func() {
+		ctx.Write("hello world")
+	})
+	assert.Equal("hello world", w.Body.String())
+	assert.Empty(w.Header().Get("ETag"))
+
+	// nothing written
+	ctx, w = mockCtx("GET", "/")
+	m(ctx, func() {})
+	assert.False(w.Flushed)
+	assert.Empty(w.Header())
+}
+
+func TestHandlerValidators(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{})
+	ctx, w := mockCtx("GET", "/", "If-None-Match", `"v1"`)
+	m(ctx, func() {
+		ctx.SetHeader("ETag", `"v1"`)
+		ctx.Write("hello")
+		ctx.Write("hello")
+	})
+	assert.Equal(http.StatusNotModified, w.Code)
+	assert.Empty(w.Body.String())
+
+	lm := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	ctx, w = mockCtx("GET", "/", "If-Modified-Since", time.Now().UTC().Format(http.TimeFormat))
+	m(ctx, func() {
+		ctx.SetHeader("Last-Modified", lm)
+		ctx.Write("hello")
+	})
+	assert.Equal(http.StatusNotModified, w.Code)
+
+	ctx, w = mockCtx("GET", "/", "If-None-Match", `"v1"`)
+	m(ctx, func() {
+		ctx.SetHeader("ETag", `"v1"`)
+		if Check(ctx) {
+			return
+		}
+		ctx.Write("hello")
+	})
+	assert.Equal(http.StatusNotModified, w.Code)
+}
+
+func TestWriteFile(t *testing.T) {
+	assert := assert.New(t)
+	file := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(file, []byte("hello"), 0644)
+	m := New(Option{})
+	ctx, w := mockCtx("GET", "/a.txt")
+	m(ctx, func() {
+		ctx.WriteFile(file)
+	})
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(etag)
+	assert.NotEmpty(w.Header().Get("Last-Modified"))
+	assert.Equal("hello", w.Body.String())
+
+	ctx, w = mockCtx("GET", "/a.txt", "If-None-Match", etag)
+	m(ctx, func() {
+		ctx.WriteFile(file)
+	})
+	assert.Equal(http.StatusNotModified, w.Code)
+}
+
+func TestStream(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{})
+	ctx, w := mockCtx("GET", "/")
+	m(ctx, func() {
+		ctx.SSE(func(stream gcore.SSEStream) {
+			stream.Data("hello")
+			assert.True(w.Flushed)
+			assert.Contains(w.Body.String(), "data: hello")
+		}, 0)
+	})
+	assert.Empty(w.Header().Get("ETag"))
+}
+
+func TestCacheControl(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{DisableETag: true, Rules: []Rule{
+		{Path: "/api/", CacheControl: "no-cache"},
+		{Path: "/", CacheControl: "public, max-age=60"},
+	}})
+	ctx, w := mockCtx("GET", "/api/user")
+	m(ctx, func() {
+		ctx.SetHeader("Cache-Control", "max-age=3600")
+		ctx.Write("hello")
+	})
+	assert.Equal("no-cache", w.Header().Get("Cache-Control"))
+	assert.Empty(w.Header().Get("ETag"))
+	ctx, w = mockCtx("POST", "/user")
+	m(ctx, func() {
+		ctx.Write("hello")
+	})
+	assert.Equal("public, max-age=60", w.Header().Get("Cache-Control"))
+}
+
+func TestNewFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gconfig.New()
+	ctx, w := mockCtx("GET", "/")
+	NewFromConfig(cfg)(ctx, func() { ctx.Write("hello") })
+	assert.Empty(w.Header().Get("ETag"))
+	cfg.Set("httpcache.enable", true)
+	cfg.Set("httpcache.rules", []interface{}{
+		map[string]interface{}{"path": "/", "cache_control": "no-cache"},
+	})
+	ctx, w = mockCtx("GET", "/")
+	NewFromConfig(cfg)(ctx, func() { ctx.Write("hello") })
+	assert.NotEmpty(w.Header().Get("ETag"))
+	assert.Equal("no-cache", w.Header().Get("Cache-Control"))
+	cfg.Set("httpcache.etag", false)
+	ctx, w = mockCtx("GET", "/")
+	NewFromConfig(cfg)(ctx, func() { ctx.Write("hello") })
+	assert.Empty(w.Header().Get("ETag"))
+	cfg.Set("httpcache.max_size", "foo")
+	assert.Panics(func() { NewFromConfig(cfg) })
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package httpcache
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync"
+
+	gcore "github.com/snail007/gmc/core"
+	ghttputil "github.com/snail007/gmc/internal/util/http"
+)
+
+// responseWriter buffers the body of the response with status 200, the header and the body are
+// sent to the underlying writer when it is committed.
+type responseWriter struct {
+	http.ResponseWriter
+	req          *http.Request
+	opt          Option
+	status       int
+	conditional  bool
+	cacheControl string
+	wroteHeader  bool
+	committed    bool
+	notModified  bool
+	hijacked     bool
+	written      int64
+	buf          []byte
+	data         sync.Map
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	if status != http.StatusOK || !w.conditional || w.Header().Get("ETag") != "" || w.opt.DisableETag {
+		w.commit(false)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (n int, err error) {
+	if w.hijacked {
+		return 0, http.ErrHijacked
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.written += int64(len(b))
+	if w.notModified {
+		return len(b), nil
+	}
+	if w.committed {
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) > w.opt.MaxSize {
+		if err = w.commit(false); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// commit writes the header and the buffered body, if final is true, the body is complete,
+// and the ETag is generated by it.
+func (w *responseWriter) commit(final bool) (err error) {
+	w.committed = true
+	h := w.Header()
+	if w.cacheControl != "" {
+		h.Set("Cache-Control", w.cacheControl)
+	}
+	if w.status == http.StatusOK && w.conditional {
+		if final && !w.opt.DisableETag && h.Get("ETag") == "" {
+			h.Set("ETag", ghttputil.ContentETag(w.buf))
+		}
+		if ghttputil.NotModified(w.req, h) {
+			w.notModified = true
+			w.buf = nil
+			ghttputil.WriteNotModified(w.ResponseWriter)
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) > 0 {
+		buf := w.buf
+		w.buf = nil
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return
+}
+
+// Flush sends the buffered body without ETag.
+func (w *responseWriter) Flush() {
+	if w.hijacked || w.notModified {
+		return
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.committed {
+		w.commit(false)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// close commits the response, if nothing was written, the underlying writer is untouched,
+// so the server can still respond an error page.
+func (w *responseWriter) close() {
+	if w.hijacked || w.committed {
+		return
+	}
+	if !w.wroteHeader && len(w.buf) == 0 {
+		return
+	}
+	w.commit(true)
+}
+
+// WriteCount returns the bytes written by the handler.
+func (w *responseWriter) WriteCount() int64 {
+	return w.written
+}
+
+func (w *responseWriter) StatusCode() int {
+	return w.status
+}
+
+func (w *responseWriter) ClearData() {
+	if v, ok := w.ResponseWriter.(gcore.ResponseWriter); ok {
+		v.ClearData()
+		return
+	}
+	w.data = sync.Map{}
+}
+
+func (w *responseWriter) Data(k interface{}) interface{} {
+	if v, ok := w.ResponseWriter.(gcore.ResponseWriter); ok {
+		return v.Data(k)
+	}
+	v, _ := w.data.Load(k)
+	return v
+}
+
+func (w *responseWriter) SetData(k interface{}, v interface{}) {
+	if rw, ok := w.ResponseWriter.(gcore.ResponseWriter); ok {
+		rw.SetData(k, v)
+		return
+	}
+	w.data.Store(k, v)
+}
@@ -0,0 +1,245 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gcache "github.com/snail007/gmc/module/cache"
+	gcast "github.com/snail007/gmc/util/cast"
+)
+
+// Option is the options of rate limit middleware.
+type Option struct {
+	// Limit is the max count of requests of a key in Period.
+	Limit int
+	// Period is the time window, default is one minute.
+	Period time.Duration
+	// Key are the parts of the limit key, default is ip, they can be:
+	// ip: the client ip.
+	// header:<Name>: the value of header Name, such as header:X-API-Key.
+	// session: the session id, it fallbacks to client ip if the session not started.
+	// route: the method and the path of the matched route.
+	Key []string
+	// KeyFunc returns the limit key of the request, it overrides Key.
+	KeyFunc func(ctx gcore.Ctx) string
+	// Name is the prefix of the limit key, the middlewares with different limits should use different names.
+	Name string
+	// Store counts the requests, default is NewMemoryStore().
+	Store Store
+	// DisableHeaders disables the RateLimit-* headers.
+	DisableHeaders bool
+	// Handler is called if the limit reached, default responds 429 Too Many Requests.
+	Handler func(ctx gcore.Ctx)
+}
+
+// Rule is a limit applied to the paths with the prefix, it is used to set different limits for route groups.
+type Rule struct {
+	Path   string
+	Limit  int
+	Period time.Duration
+}
+
+// NewFromConfig creates a rate limit middleware from section [ratelimit] of config, if the section
+// is missing or enable is false, the middleware does nothing.
+// The rules of [[ratelimit.rules]] are checked in order, the first rule which path is the prefix of
+// request path is used, if no rule matched, limit and period of [ratelimit] are used.
+// It should be added by AddMiddleware1, so the route key works.
+func NewFromConfig(c gcore.Config) gcore.Middleware {
+	cfg := c.Sub("ratelimit")
+	if cfg == nil || !cfg.GetBool("enable") {
+		return func(ctx gcore.Ctx) bool { return false }
+	}
+	var store Store
+	switch cfg.GetString("store") {
+	case "", "memory":
+		store = NewMemoryStore()
+	case "cache":
+		store = NewCacheStore(findCache(cfg.GetString("cache_type"), cfg.GetString("cache_id")), cfg.GetString("prefix"))
+	default:
+		panic("unknown ratelimit store: " + cfg.GetString("store"))
+	}
+	base := Option{
+		Limit:          cfg.GetInt("limit"),
+		Period:         cfg.GetDuration("period"),
+		Key:            cfg.GetStringSlice("key"),
+		Store:          store,
+		DisableHeaders: cfg.GetBool("disable_headers"),
+	}
+	var rules []Rule
+	items, _ := cfg.Get("rules").([]interface{})
+	for _, v := range items {
+		vv, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rules = append(rules, Rule{
+			Path:   gcast.ToString(vv["path"]),
+			Limit:  gcast.ToInt(vv["limit"]),
+			Period: gcast.ToDuration(vv["period"]),
+		})
+	}
+	return NewWithRules(base, rules...)
+}
+
+// NewWithRules creates a rate limit middleware with the rules, the first rule which path is the prefix
+// of request path is used, if no rule matched, opt.Limit and opt.Period are used.
+// Each rule is counted separately, the Period of rule default is opt.Period.
+func NewWithRules(opt Option, rules ...Rule) gcore.Middleware {
+	def := New(opt)
+	type item struct {
+		path string
+		m    gcore.Middleware
+	}
+	var items []item
+	for _, r := range rules {
+		o := opt
+		o.Name = opt.Name + r.Path + ":"
+		o.Limit = r.Limit
+		if r.Period > 0 {
+			o.Period = r.Period
+		}
+		items = append(items, item{path: r.Path, m: New(o)})
+	}
+	return func(ctx gcore.Ctx) (isStop bool) {
+		path := ctx.Request().URL.Path
+		for _, v := range items {
+			if strings.HasPrefix(path, v.path) {
+				return v.m(ctx)
+			}
+		}
+		return def(ctx)
+	}
+}
+
+// New creates a rate limit middleware with the options, the requests exceeded the limit are
+// responded 429, if Limit is less than 1, the middleware does nothing.
+// If the store fails, the request is allowed and a warning is logged.
+func New(opt Option) gcore.Middleware {
+	if opt.Period <= 0 {
+		opt.Period = time.Minute
+	}
+	if len(opt.Key) == 0 {
+		opt.Key = []string{"ip"}
+	}
+	for _, k := range opt.Key {
+		if k != "ip" && k != "session" && k != "route" && !strings.HasPrefix(k, "header:") {
+			panic("unknown ratelimit key: " + k)
+		}
+	}
+	if opt.Store == nil {
+		opt.Store = NewMemoryStore()
+	}
+	if opt.Handler == nil {
+		opt.Handler = func(ctx gcore.Ctx) {
+			ctx.WriteHeader(http.StatusTooManyRequests)
+			ctx.Write(http.StatusText(http.StatusTooManyRequests))
+		}
+	}
+	limit := strconv.Itoa(opt.Limit)
+	policy := limit + ";w=" + strconv.Itoa(int(opt.Period/time.Second))
+	return func(ctx gcore.Ctx) (isStop bool) {
+		if opt.Limit < 1 {
+			return false
+		}
+		var key string
+		if opt.KeyFunc != nil {
+			key = opt.KeyFunc(ctx)
+		} else {
+			key = buildKey(ctx, opt.Key)
+		}
+		allowed, remaining, reset, err := opt.Store.Take(opt.Name+key, opt.Limit, opt.Period)
+		if err != nil {
+			ctx.Logger().Warnf("ratelimit store fail, %s", err)
+			return false
+		}
+		resetSeconds := strconv.Itoa(int(math.Ceil(reset.Seconds())))
+		h := ctx.Response().Header()
+		if !opt.DisableHeaders {
+			h.Set("RateLimit-Limit", limit)
+			h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			h.Set("RateLimit-Reset", resetSeconds)
+			h.Set("RateLimit-Policy", policy)
+		}
+		if !allowed {
+			h.Set("Retry-After", resetSeconds)
+			opt.Handler(ctx)
+			return true
+		}
+		return false
+	}
+}
+
+// NewRoute creates a rate limit route middleware with the options, it can be added by Use of a route group,
+// so the group has its own limit. The Name of options should be different for each group.
+func NewRoute(opt Option) gcore.RouteMiddleware {
+	m := New(opt)
+	return func(ctx gcore.Ctx, next func()) {
+		if m(ctx) {
+			return
+		}
+		next()
+	}
+}
+
+func buildKey(ctx gcore.Ctx, parts []string) string {
+	var a []string
+	for _, p := range parts {
+		switch {
+		case p == "ip":
+			a = append(a, ctx.ClientIP())
+		case p == "session":
+			sid, _ := gcore.ProviderCookies()(ctx).Get(ctx.Config().GetString("session.cookiename"))
+			if sid == "" {
+				sid = ctx.ClientIP()
+			}
+			a = append(a, sid)
+		case p == "route":
+			path := ctx.FullPath()
+			if path == "" {
+				path = ctx.Request().URL.Path
+			}
+			a = append(a, ctx.Request().Method+" "+path)
+		default:
+			a = append(a, ctx.Header(strings.TrimPrefix(p, "header:")))
+		}
+	}
+	return strings.Join(a, "|")
+}
+
+func findCache(typ, id string) (c gcore.Cache) {
+	var ids []string
+	if id != "" {
+		ids = append(ids, id)
+	}
+	switch typ {
+	case "redis":
+		if v := gcache.Redis(ids...); v != nil {
+			c = v
+		}
+	case "memory":
+		if v := gcache.Memory(ids...); v != nil {
+			c = v
+		}
+	case "file":
+		if v := gcache.File(ids...); v != nil {
+			c = v
+		}
+	case "":
+		c = gcache.Cache(ids...)
+	default:
+		panic("unknown ratelimit cache type: " + typ)
+	}
+	if c == nil {
+		panic("ratelimit cache is not enabled, check the [cache] section of config")
+	}
+	return
+}
@@ -0,0 +1,218 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gcache "github.com/snail007/gmc/module/cache"
+	gconfig "github.com/snail007/gmc/module/config"
+	gctx "github.com/snail007/gmc/module/ctx"
+	_ "github.com/snail007/gmc/using/web"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockCtx(method, path, ip string) (*gctx.Ctx, *httptest.ResponseRecorder) {
+	r := httptest.NewRequest(method, path, nil)
+	r.RemoteAddr = ip + ":1234"
+	w := httptest.NewRecorder()
+	ctx := gctx.NewCtxWithHTTP(w, r)
+	cfg := gconfig.New()
+	cfg.Set("session.cookiename", "gmcsid")
+	ctx.SetConfig(cfg)
+	return ctx, w
+}
+
+func TestNew(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{Limit: 2, Period: time.Second})
+	for i := 0; i < 2; i++ {
+		ctx, w := mockCtx("GET", "/", "1.1.1.1")
+		assert.False(m(ctx))
+		assert.Equal("2", w.Header().Get("RateLimit-Limit"))
+		assert.Equal([]string{"1", "0"}[i], w.Header().Get("RateLimit-Remaining"))
+		assert.Equal("2;w=1", w.Header().Get("RateLimit-Policy"))
+	}
+	ctx, w := mockCtx("GET", "/", "1.1.1.1")
+	assert.True(m(ctx))
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+	assert.Equal("1", w.Header().Get("Retry-After"))
+	assert.Equal("0", w.Header().Get("RateLimit-Remaining"))
+
+	// other ip
+	ctx, _ = mockCtx("GET", "/", "2.2.2.2")
+	assert.False(m(ctx))
+
+	time.Sleep(time.Second + time.Millisecond*100)
+	ctx, _ = mockCtx("GET", "/", "1.1.1.1")
+	assert.False(m(ctx))
+}
+
+func TestKey(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{Limit: 1, Key: []string{"header:X-API-Key", "route"}, DisableHeaders: true})
+	ctx, w := mockCtx("GET", "/a", "1.1.1.1")
+	ctx.Request().Header.Set("X-API-Key", "k1")
+	assert.False(m(ctx))
+	assert.Empty(w.Header().Get("RateLimit-Limit"))
+	ctx, _ = mockCtx("GET", "/a", "2.2.2.2")
+	ctx.Request().Header.Set("X-API-Key", "k1")
+	assert.True(m(ctx))
+	ctx, _ = mockCtx("GET", "/b", "2.2.2.2")
+	ctx.Request().Header.Set("X-API-Key", "k1")
+	assert.False(m(ctx))
+	ctx, _ = mockCtx("GET", "/a", "1.1.1.1")
+	ctx.Request().Header.Set("X-API-Key", "k2")
+	assert.False(m(ctx))
+
+	m = New(Option{Limit: 1, Key: []string{"session"}})
+	ctx, _ = mockCtx("GET", "/", "1.1.1.1")
+	ctx.Request().AddCookie(&http.Cookie{Name: "gmcsid", Value: "s1"})
+	assert.False(m(ctx))
+	ctx, _ = mockCtx("GET", "/", "2.2.2.2")
+	ctx.Request().AddCookie(&http.Cookie{Name: "gmcsid", Value: "s1"})
+	assert.True(m(ctx))
+	// fallbacks to ip
+	ctx, _ = mockCtx("GET", "/", "1.1.1.1")
+	assert.False(m(ctx))
+	ctx, _ = mockCtx("GET", "/", "1.1.1.1")
+	assert.True(m(ctx))
+
+	m = New(Option{Limit: 1, KeyFunc: func(ctx gcore.Ctx) string { return "all" }})
+	ctx, _ = mockCtx("GET", "/", "1.1.1.1")
+	assert.False(m(ctx))
+	ctx, _ = mockCtx("GET", "/", "2.2.2.2")
+	assert.True(m(ctx))
+
+	assert.Panics(func() { New(Option{Key: []string{"foo"}}) })
+}
+
+func TestNewWithRules(t *testing.T) {
+	assert := assert.New(t)
+	m := NewWithRules(Option{Limit: 1}, Rule{Path: "/api/", Limit: 2})
+	ctx, _ := mockCtx("GET", "/", "1.1.1.1")
+	assert.False(m(ctx))
+	for i := 0; i < 2; i++ {
+		ctx, _ = mockCtx("GET", "/api/user", "1.1.1.1")
+		assert.False(m(ctx))
+	}
+	ctx, _ = mockCtx("GET", "/api/user", "1.1.1.1")
+	assert.True(m(ctx))
+	ctx, _ = mockCtx("GET", "/user", "1.1.1.1")
+	assert.True(m(ctx))
+}
+
+func TestNewRoute(t *testing.T) {
+	assert := assert.New(t)
+	m := NewRoute(Option{Limit: 1})
+	called := 0
+	ctx, _ := mockCtx("GET", "/", "1.1.1.1")
+	m(ctx, func() { called++ })
+	ctx, w := mockCtx("GET", "/", "1.1.1.1")
+	m(ctx, func() { called++ })
+	assert.Equal(1, called)
+	assert.Equal(http.StatusTooManyRequests, w.Code)
+}
+
+func TestCacheStore(t *testing.T) {
+	assert := assert.New(t)
+	s := NewCacheStore(gcache.NewMemCache(&gcache.MemCacheConfig{CleanupInterval: time.Second}), "")
+	for i := 0; i < 3; i++ {
+		allowed, remaining, reset, err := s.Take("k", 3, time.Minute)
+		assert.Nil(err)
+		assert.True(allowed)
+		assert.Equal(2-i, remaining)
+		assert.True(reset > 0 && reset <= time.Minute)
+	}
+	allowed, remaining, _, err := s.Take("k", 3, time.Minute)
+	assert.Nil(err)
+	assert.False(allowed)
+	assert.Equal(0, remaining)
+	allowed, _, _, _ = s.Take("k1", 3, time.Minute)
+	assert.True(allowed)
+
+	// the instances share the limit by the same cache.
+	dir := t.TempDir()
+	c1, _ := gcache.NewFileCache(&gcache.FileCacheConfig{Dir: dir, CleanupInterval: time.Second})
+	c2, _ := gcache.NewFileCache(&gcache.FileCacheConfig{Dir: dir, CleanupInterval: time.Second})
+	m1 := New(Option{Limit: 1, Store: NewCacheStore(c1, "test:")})
+	m2 := New(Option{Limit: 1, Store: NewCacheStore(c2, "test:")})
+	ctx, _ := mockCtx("GET", "/", "1.1.1.1")
+	assert.False(m1(ctx))
+	ctx, _ = mockCtx("GET", "/", "1.1.1.1")
+	assert.True(m2(ctx))
+}
+
+// spyCache counts the calls of Set and fails Incr if incrErr is set.
+type spyCache struct {
+	*gcache.MemCache
+	sets    int
+	incrErr error
+}
+
+func (c *spyCache) Set(key string, value string, ttl time.Duration) error {
+	c.sets++
+	return c.MemCache.Set(key, value, ttl)
+}
+
+func (c *spyCache) Incr(key string) (int64, error) {
+	if c.incrErr != nil {
+		return 0, c.incrErr
+	}
+	return c.MemCache.Incr(key)
+}
+
+func TestCacheStore_TTL(t *testing.T) {
+	assert := assert.New(t)
+	c := &spyCache{MemCache: gcache.NewMemCache(gcache.NewMemCacheConfig())}
+	s := NewCacheStore(c, "")
+	for i := 0; i < 3; i++ {
+		_, _, _, err := s.Take("k", 3, time.Minute)
+		assert.Nil(err)
+	}
+	// the counter is created by SetNX, and never reset by Set.
+	assert.Equal(0, c.sets)
+	_, remaining, _, _ := s.Take("k", 5, time.Minute)
+	assert.Equal(1, remaining)
+
+	c.incrErr = errors.New("incr fail")
+	_, _, _, err := s.Take("k", 5, time.Minute)
+	assert.Equal(c.incrErr, err)
+	assert.Equal(0, c.sets)
+	_, _, _, err = s.Take("k2", 5, time.Minute)
+	assert.Nil(err)
+}
+
+func TestNewFromConfig(t *testing.T) {
+	assert := assert.New(t)
+	cfg := gconfig.New()
+	ctx, _ := mockCtx("GET", "/", "1.1.1.1")
+	assert.False(NewFromConfig(cfg)(ctx))
+	cfg.Set("ratelimit.enable", true)
+	cfg.Set("ratelimit.limit", 1)
+	cfg.Set("ratelimit.period", "10s")
+	cfg.Set("ratelimit.rules", []interface{}{
+		map[string]interface{}{"path": "/api/", "limit": 2, "period": "1m"},
+	})
+	m := NewFromConfig(cfg)
+	ctx, w := mockCtx("GET", "/", "1.1.1.1")
+	assert.False(m(ctx))
+	assert.Equal("1;w=10", w.Header().Get("RateLimit-Policy"))
+	ctx, _ = mockCtx("GET", "/", "1.1.1.1")
+	assert.True(m(ctx))
+	ctx, w = mockCtx("GET", "/api/", "1.1.1.1")
+	assert.False(m(ctx))
+	assert.Equal("2;w=60", w.Header().Get("RateLimit-Policy"))
+
+	cfg.Set("ratelimit.store", "cache")
+	cfg.Set("ratelimit.cache_type", "redis")
+	assert.Panics(func() { NewFromConfig(cfg) })
+}
@@ -0,0 +1,131 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package ratelimit
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	grate "github.com/snail007/gmc/util/rate"
+)
+
+// Store counts the requests of the keys.
+type Store interface {
+	// Take takes one request of the key, allowed is false if the key reached the limit in the period.
+	// remaining is the count of requests can be made, reset is the duration until the quota is released.
+	Take(key string, limit int, period time.Duration) (allowed bool, remaining int, reset time.Duration, err error)
+}
+
+type memoryStore struct {
+	mu       sync.Mutex
+	limiters map[string]*memoryEntry
+	lastGC   time.Time
+}
+
+type memoryEntry struct {
+	limiter  *grate.SlidingWindowLimiter
+	lastSeen time.Time
+}
+
+// NewMemoryStore returns a Store which counts the requests in process by grate.SlidingWindowLimiter,
+// the limits are not shared by multiple instances.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		limiters: map[string]*memoryEntry{},
+		lastGC:   time.Now(),
+	}
+}
+
+func (s *memoryStore) Take(key string, limit int, period time.Duration) (allowed bool, remaining int, reset time.Duration, err error) {
+	now := time.Now()
+	s.mu.Lock()
+	if now.Sub(s.lastGC) > time.Minute {
+		// clean the idle keys, the counts of them are all released.
+		for k, v := range s.limiters {
+			if now.Sub(v.lastSeen) > v.limiter.Duration() {
+				delete(s.limiters, k)
+			}
+		}
+		s.lastGC = now
+	}
+	e := s.limiters[key]
+	if e == nil || e.limiter.Capacity() != limit || e.limiter.Duration() != period {
+		e = &memoryEntry{limiter: grate.NewSlidingWindowLimiter(limit, period)}
+		s.limiters[key] = e
+	}
+	e.lastSeen = now
+	s.mu.Unlock()
+	allowed = e.limiter.Allow()
+	remaining = limit - e.limiter.Count()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return allowed, remaining, e.limiter.Reset(), nil
+}
+
+// setNXCache is implemented by the caches which can set a key only if it does not exist,
+// such as the memory, file and redis cache of gmc.
+type setNXCache interface {
+	SetNX(key string, value string, ttl time.Duration) (ok bool, err error)
+}
+
+type cacheStore struct {
+	cache  gcore.Cache
+	prefix string
+}
+
+// NewCacheStore returns a Store which counts the requests in the cache, such as redis,
+// so the limits are shared by multiple instances which use the same cache.
+// It is a sliding window counter, the count of previous window is weighted by the time left.
+// The counter of a window is created with a ttl by SetNX of cache, so the cache should have method
+// SetNX(key string, value string, ttl time.Duration) (bool, error), the caches of gmc have it.
+func NewCacheStore(cache gcore.Cache, prefix string) Store {
+	if prefix == "" {
+		prefix = "ratelimit:"
+	}
+	return &cacheStore{
+		cache:  cache,
+		prefix: prefix,
+	}
+}
+
+func (s *cacheStore) Take(key string, limit int, period time.Duration) (allowed bool, remaining int, reset time.Duration, err error) {
+	now := time.Now().UnixNano()
+	window := now / period.Nanoseconds()
+	elapsed := time.Duration(now - window*period.Nanoseconds())
+	curKey := s.prefix + key + ":" + strconv.FormatInt(window, 10)
+	prevKey := s.prefix + key + ":" + strconv.FormatInt(window-1, 10)
+	var cur int64
+	if c, ok := s.cache.(setNXCache); ok {
+		// only the first request of the window sets the ttl.
+		var created bool
+		if created, err = c.SetNX(curKey, "1", period*2); err != nil {
+			return
+		}
+		if created {
+			cur = 1
+		}
+	}
+	if cur == 0 {
+		if cur, err = s.cache.Incr(curKey); err != nil {
+			return
+		}
+	}
+	var prev int64
+	if v, e := s.cache.Get(prevKey); e == nil {
+		prev, _ = strconv.ParseInt(v, 10, 64)
+	}
+	weight := 1 - float64(elapsed)/float64(period)
+	count := int(float64(prev)*weight) + int(cur)
+	if count > limit {
+		// the rejected request is not counted.
+		s.cache.Decr(curKey)
+		return false, 0, period - elapsed, nil
+	}
+	return true, limit - count, period - elapsed, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package realip
+
+import (
+	"net"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+type ctxKey struct{}
+
+// Option is the options of real ip middleware.
+type Option struct {
+	// TrustedProxies are the ip or CIDR of the trusted proxies, such as 127.0.0.1, 10.0.0.0/8.
+	TrustedProxies []string
+	// Headers are the headers to find the client ip in order, default is X-Forwarded-For, X-Real-IP.
+	Headers []string
+}
+
+// NewFromConfig creates a real ip middleware from section [realip] of config, if the section
+// is missing or enable is false, the middleware does nothing.
+func NewFromConfig(c gcore.Config) gcore.Middleware {
+	cfg := c.Sub("realip")
+	if cfg == nil || !cfg.GetBool("enable") {
+		return func(ctx gcore.Ctx) bool { return false }
+	}
+	return New(Option{
+		TrustedProxies: cfg.GetStringSlice("trusted_proxies"),
+		Headers:        cfg.GetStringSlice("headers"),
+	})
+}
+
+// New creates a real ip middleware with the options. If the request comes from a trusted proxy,
+// the client ip is found in the headers and request.RemoteAddr is replaced with it,
+// so ctx.ClientIP() and the access log get the real ip.
+// For X-Forwarded-For, the ips are checked from right to left, the first untrusted one is the client ip.
+// An invalid trusted proxy panics.
+func New(opt Option) gcore.Middleware {
+	var nets []*net.IPNet
+	for _, v := range opt.TrustedProxies {
+		if !strings.Contains(v, "/") {
+			if strings.Contains(v, ":") {
+				v += "/128"
+			} else {
+				v += "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			panic("realip: invalid trusted proxy " + v)
+		}
+		nets = append(nets, n)
+	}
+	headers := opt.Headers
+	if len(headers) == 0 {
+		headers = []string{"X-Forwarded-For", "X-Real-IP"}
+	}
+	trusted := func(ip net.IP) bool {
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(ctx gcore.Ctx) (isStop bool) {
+		r := ctx.Request()
+		host, port, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if ip := net.ParseIP(host); ip == nil || !trusted(ip) {
+			return false
+		}
+		for _, header := range headers {
+			clientIP := ""
+			if strings.EqualFold(header, "X-Forwarded-For") {
+				clientIP = fromForwardedFor(r.Header.Values(header), trusted)
+			} else if ip := net.ParseIP(strings.TrimSpace(r.Header.Get(header))); ip != nil {
+				clientIP = ip.String()
+			}
+			if clientIP != "" {
+				ctx.Set(ctxKey{}, r.RemoteAddr)
+				if port == "" {
+					port = "0"
+				}
+				r.RemoteAddr = net.JoinHostPort(clientIP, port)
+				return false
+			}
+		}
+		return false
+	}
+}
+
+// ProxyAddr returns the original request.RemoteAddr before replaced, it is the address of proxy,
+// empty string returned if the request is not from a trusted proxy.
+func ProxyAddr(ctx gcore.Ctx) string {
+	if v, ok := ctx.Get(ctxKey{}); ok {
+		return v.(string)
+	}
+	return ""
+}
+
+func fromForwardedFor(values []string, trusted func(ip net.IP) bool) string {
+	var ips []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			ips = append(ips, strings.TrimSpace(s))
+		}
+	}
+	last := ""
+	for i := len(ips) - 1; i >= 0; i-- {
+		ip := net.ParseIP(ips[i])
+		if ip == nil {
+			break
+		}
+		last = ip.String()
+		if !trusted(ip) {
+			return last
+		}
+	}
+	// all of the ips are trusted proxies, the leftmost valid one is the client.
+	return last
+}
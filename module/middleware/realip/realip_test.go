@@ -0,0 +1,39 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package realip
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	gctx "github.com/snail007/gmc/module/ctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealIP(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}})
+	check := func(remoteAddr string, header map[string]string, expected, proxy string) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remoteAddr
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		ctx := gctx.NewCtxWithHTTP(httptest.NewRecorder(), r)
+		assert.False(m(ctx))
+		assert.Equal(expected, ctx.Request().RemoteAddr)
+		assert.Equal(proxy, ProxyAddr(ctx))
+	}
+	// untrusted remote address, the header is ignored.
+	check("1.1.1.1:80", map[string]string{"X-Forwarded-For": "2.2.2.2"}, "1.1.1.1:80", "")
+	// the rightmost untrusted ip of X-Forwarded-For.
+	check("10.0.0.1:80", map[string]string{"X-Forwarded-For": "6.6.6.6, 2.2.2.2, 192.168.1.1"}, "2.2.2.2:80", "10.0.0.1:80")
+	check("192.168.1.1:80", map[string]string{"X-Real-IP": "3.3.3.3"}, "3.3.3.3:80", "192.168.1.1:80")
+	check("10.0.0.1:80", map[string]string{"X-Real-IP": "bad"}, "10.0.0.1:80", "")
+	check("10.0.0.1:80", map[string]string{"X-Forwarded-For": "10.0.0.2, 10.0.0.3"}, "10.0.0.2:80", "10.0.0.1:80")
+
+	assert.Panics(func() { New(Option{TrustedProxies: []string{"bad"}}) })
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package recovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/snail007/gmc/module/middleware/requestid"
+)
+
+// Option is the options of recovery middleware.
+type Option struct {
+	// Stack logs the stack of panic.
+	Stack bool
+	// ShowError responds the panic error and stack to client, only for development.
+	ShowError bool
+	// Handler is called after the panic logged, it responds the request, default responds 500.
+	Handler func(ctx gcore.Ctx, err interface{})
+}
+
+// Entry is the structured log of a panic, it is logged as a JSON line.
+type Entry struct {
+	Time      string `json:"time"`
+	RequestID string `json:"request_id,omitempty"`
+	Method    string `json:"method"`
+	URI       string `json:"uri"`
+	ClientIP  string `json:"client_ip"`
+	Error     string `json:"error"`
+	Stack     string `json:"stack,omitempty"`
+}
+
+// NewFromConfig creates a recovery middleware from section [recovery] of config, if the section
+// is missing or enable is false, the middleware does nothing, the panic is handled by the server.
+// It is a route middleware, add it by Use of router or api server.
+func NewFromConfig(c gcore.Config) gcore.RouteMiddleware {
+	cfg := c.Sub("recovery")
+	if cfg == nil || !cfg.GetBool("enable") {
+		return func(ctx gcore.Ctx, next func()) { next() }
+	}
+	return New(Option{
+		Stack:     cfg.GetBool("stack"),
+		ShowError: cfg.GetBool("show_error"),
+	})
+}
+
+// New creates a recovery middleware with the options, the panic of handler is recovered
+// and logged by ctx.Logger() as a JSON line of Entry.
+// ctx.Stop() is not a panic error, it is passed to the server.
+func New(opt Option) gcore.RouteMiddleware {
+	return func(ctx gcore.Ctx, next func()) {
+		defer func() {
+			e := recover()
+			if e == nil {
+				return
+			}
+			if s := fmt.Sprintf("%s", e); s == "__STOP__" || s == "__DIE__" {
+				panic(e)
+			}
+			entry := Entry{
+				Time:      time.Now().Format("2006-01-02 15:04:05"),
+				RequestID: requestid.Get(ctx),
+				Method:    ctx.Request().Method,
+				URI:       ctx.Request().URL.RequestURI(),
+				ClientIP:  ctx.ClientIP(),
+				Error:     fmt.Sprintf("%v", e),
+			}
+			stack := ""
+			if opt.Stack || opt.ShowError {
+				stack = gcore.ProviderError()().StackError(e)
+			}
+			if opt.Stack {
+				entry.Stack = stack
+			}
+			b, _ := json.Marshal(entry)
+			ctx.Logger().Error(string(b))
+			if opt.Handler != nil {
+				opt.Handler(ctx, e)
+				return
+			}
+			if ctx.WriteCount() > 0 {
+				return
+			}
+			ctx.Response().Header().Set("Content-Type", "text/plain; charset=utf-8")
+			ctx.WriteHeader(http.StatusInternalServerError)
+			msg := http.StatusText(http.StatusInternalServerError)
+			if opt.ShowError {
+				msg += "\n" + stack
+			}
+			ctx.Write(msg)
+		}()
+		next()
+	}
+}
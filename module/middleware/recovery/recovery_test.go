@@ -0,0 +1,73 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package recovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	gctx "github.com/snail007/gmc/module/ctx"
+	glog "github.com/snail007/gmc/module/log"
+	"github.com/snail007/gmc/module/middleware/requestid"
+	_ "github.com/snail007/gmc/using/basic"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockCtx() (*gctx.Ctx, *httptest.ResponseRecorder, *bytes.Buffer) {
+	w := httptest.NewRecorder()
+	ctx := gctx.NewCtxWithHTTP(w, httptest.NewRequest("GET", "/foo?a=1", nil))
+	buf := new(bytes.Buffer)
+	l := glog.New()
+	l.SetOutput(glog.NewLoggerWriter(buf))
+	l.SetFlag(gcore.LogFlagShort)
+	ctx.SetLogger(l)
+	return ctx, w, buf
+}
+
+func TestRecovery(t *testing.T) {
+	assert := assert.New(t)
+	ctx, w, buf := mockCtx()
+	requestid.New(requestid.Option{})(ctx)
+	New(Option{Stack: true})(ctx, func() {
+		panic("oops")
+	})
+	assert.Equal(http.StatusInternalServerError, w.Code)
+	assert.Equal("Internal Server Error", w.Body.String())
+	line := buf.String()
+	var e Entry
+	assert.Nil(json.Unmarshal([]byte(line[strings.Index(line, "{"):]), &e))
+	assert.Equal("oops", e.Error)
+	assert.Equal("GET", e.Method)
+	assert.Equal("/foo?a=1", e.URI)
+	assert.Equal(requestid.Get(ctx), e.RequestID)
+	assert.NotEmpty(e.Stack)
+
+	ctx, w, _ = mockCtx()
+	New(Option{ShowError: true})(ctx, func() {
+		panic("oops")
+	})
+	assert.Contains(w.Body.String(), "oops")
+
+	ctx, w, _ = mockCtx()
+	New(Option{Handler: func(ctx gcore.Ctx, err interface{}) {
+		ctx.JSON(http.StatusInternalServerError, map[string]interface{}{"error": err})
+	}})(ctx, func() {
+		panic("oops")
+	})
+	assert.Equal(`{"error":"oops"}`, w.Body.String())
+
+	ctx, _, _ = mockCtx()
+	assert.PanicsWithValue("__STOP__", func() {
+		New(Option{})(ctx, func() {
+			ctx.Stop()
+		})
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// DefaultHeader is the default header of request id.
+const DefaultHeader = "X-Request-ID"
+
+type ctxKey struct{}
+
+type requestID struct {
+	id     string
+	header string
+}
+
+// Option is the options of request id middleware.
+type Option struct {
+	// Header is the header to read and write the request id.
+	Header string
+	// TrustRequest uses the request id in the request header if it is valid,
+	// it should be true only if the server is behind a trusted gateway.
+	TrustRequest bool
+	// Generator generates a new request id, default is 32 hex characters.
+	Generator func() string
+}
+
+// NewFromConfig creates a request id middleware from section [requestid] of config, if the section
+// is missing or enable is false, the middleware does nothing.
+func NewFromConfig(c gcore.Config) gcore.Middleware {
+	cfg := c.Sub("requestid")
+	if cfg == nil || !cfg.GetBool("enable") {
+		return func(ctx gcore.Ctx) bool { return false }
+	}
+	return New(Option{
+		Header:       cfg.GetString("header"),
+		TrustRequest: cfg.GetBool("trust_request"),
+	})
+}
+
+// New creates a request id middleware with the options, the id is stored in ctx,
+// and set to the request header and response header.
+func New(opt Option) gcore.Middleware {
+	if opt.Header == "" {
+		opt.Header = DefaultHeader
+	}
+	if opt.Generator == nil {
+		opt.Generator = generate
+	}
+	return func(ctx gcore.Ctx) (isStop bool) {
+		id := ""
+		if opt.TrustRequest {
+			id = ctx.Header(opt.Header)
+			if !valid(id) {
+				id = ""
+			}
+		}
+		if id == "" {
+			id = opt.Generator()
+		}
+		ctx.Set(ctxKey{}, requestID{id: id, header: opt.Header})
+		ctx.Request().Header.Set(opt.Header, id)
+		ctx.Response().Header().Set(opt.Header, id)
+		return false
+	}
+}
+
+// Get returns the request id of ctx, empty string returned if the middleware is not used.
+func Get(ctx gcore.Ctx) string {
+	if v, ok := ctx.Get(ctxKey{}); ok {
+		return v.(requestID).id
+	}
+	return ""
+}
+
+// Propagate sets the request id of ctx to the header of req, use it when calling other services.
+func Propagate(ctx gcore.Ctx, req *http.Request) {
+	if v, ok := ctx.Get(ctxKey{}); ok {
+		rid := v.(requestID)
+		req.Header.Set(rid.header, rid.id)
+	}
+}
+
+func generate() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// valid checks the id of client is not too long and only contains the safe characters.
+func valid(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
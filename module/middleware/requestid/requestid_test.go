@@ -0,0 +1,46 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gctx "github.com/snail007/gmc/module/ctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	assert := assert.New(t)
+	ctx := gctx.NewCtxWithHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal("", Get(ctx))
+	assert.False(New(Option{})(ctx))
+	id := Get(ctx)
+	assert.Len(id, 32)
+	assert.Equal(id, ctx.Response().Header().Get(DefaultHeader))
+	assert.Equal(id, ctx.Header(DefaultHeader))
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	Propagate(ctx, req)
+	assert.Equal(id, req.Header.Get(DefaultHeader))
+
+	m := New(Option{Header: "X-Trace-ID", TrustRequest: true})
+	for in, trusted := range map[string]bool{"abc-123": true, "bad id\n": false, "": false} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Trace-ID", in)
+		ctx = gctx.NewCtxWithHTTP(httptest.NewRecorder(), r)
+		m(ctx)
+		assert.Equal(trusted, Get(ctx) == in, in)
+		assert.NotEmpty(Get(ctx))
+		assert.Equal(Get(ctx), ctx.Response().Header().Get("X-Trace-ID"))
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(DefaultHeader, "abc")
+	ctx = gctx.NewCtxWithHTTP(httptest.NewRecorder(), r)
+	New(Option{Generator: func() string { return "gen" }})(ctx)
+	assert.Equal("gen", Get(ctx))
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package secure
+
+import (
+	"strconv"
+	"strings"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// Option is the options of security headers middleware, the empty fields are not set.
+type Option struct {
+	// HSTSMaxAge is the max-age of Strict-Transport-Security in seconds, 0 disables it.
+	// It is only sent on https requests, including X-Forwarded-Proto is https.
+	HSTSMaxAge              int
+	HSTSIncludeSubdomains   bool
+	HSTSPreload             bool
+	ContentSecurityPolicy   string
+	FrameOptions            string
+	ContentTypeNosniff      bool
+	ReferrerPolicy          string
+	PermissionsPolicy       string
+	CrossOriginOpenerPolicy string
+}
+
+// DefaultOption returns the recommended options, CSP is not set because it depends on the site.
+func DefaultOption() Option {
+	return Option{
+		HSTSMaxAge:            31536000,
+		HSTSIncludeSubdomains: true,
+		FrameOptions:          "SAMEORIGIN",
+		ContentTypeNosniff:    true,
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+	}
+}
+
+// NewFromConfig creates a security headers middleware from section [secure] of config, if the section
+// is missing or enable is false, the middleware does nothing.
+func NewFromConfig(c gcore.Config) gcore.Middleware {
+	cfg := c.Sub("secure")
+	if cfg == nil || !cfg.GetBool("enable") {
+		return func(ctx gcore.Ctx) bool { return false }
+	}
+	opt := DefaultOption()
+	if cfg.IsSet("hsts_max_age") {
+		opt.HSTSMaxAge = cfg.GetInt("hsts_max_age")
+	}
+	if cfg.IsSet("hsts_include_subdomains") {
+		opt.HSTSIncludeSubdomains = cfg.GetBool("hsts_include_subdomains")
+	}
+	if cfg.IsSet("frame_options") {
+		opt.FrameOptions = cfg.GetString("frame_options")
+	}
+	if cfg.IsSet("content_type_nosniff") {
+		opt.ContentTypeNosniff = cfg.GetBool("content_type_nosniff")
+	}
+	if cfg.IsSet("referrer_policy") {
+		opt.ReferrerPolicy = cfg.GetString("referrer_policy")
+	}
+	opt.HSTSPreload = cfg.GetBool("hsts_preload")
+	opt.ContentSecurityPolicy = cfg.GetString("content_security_policy")
+	opt.PermissionsPolicy = cfg.GetString("permissions_policy")
+	opt.CrossOriginOpenerPolicy = cfg.GetString("cross_origin_opener_policy")
+	return New(opt)
+}
+
+// New creates a security headers middleware with the options.
+func New(opt Option) gcore.Middleware {
+	hsts := ""
+	if opt.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(opt.HSTSMaxAge)
+		if opt.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if opt.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	headers := [][2]string{
+		{"Content-Security-Policy", opt.ContentSecurityPolicy},
+		{"X-Frame-Options", opt.FrameOptions},
+		{"Referrer-Policy", opt.ReferrerPolicy},
+		{"Permissions-Policy", opt.PermissionsPolicy},
+		{"Cross-Origin-Opener-Policy", opt.CrossOriginOpenerPolicy},
+	}
+	if opt.ContentTypeNosniff {
+		headers = append(headers, [2]string{"X-Content-Type-Options", "nosniff"})
+	}
+	return func(ctx gcore.Ctx) (isStop bool) {
+		h := ctx.Response().Header()
+		for _, v := range headers {
+			if v[1] != "" {
+				h.Set(v[0], v[1])
+			}
+		}
+		if hsts != "" && (ctx.IsTLSRequest() || strings.EqualFold(ctx.Header("X-Forwarded-Proto"), "https")) {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		return false
+	}
+}
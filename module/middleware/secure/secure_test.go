@@ -0,0 +1,45 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package secure
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	gctx "github.com/snail007/gmc/module/ctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecure(t *testing.T) {
+	assert := assert.New(t)
+	opt := DefaultOption()
+	opt.ContentSecurityPolicy = "default-src 'self'"
+	opt.HSTSPreload = true
+	m := New(opt)
+
+	w := httptest.NewRecorder()
+	assert.False(m(gctx.NewCtxWithHTTP(w, httptest.NewRequest("GET", "/", nil))))
+	assert.Equal("default-src 'self'", w.Header().Get("Content-Security-Policy"))
+	assert.Equal("SAMEORIGIN", w.Header().Get("X-Frame-Options"))
+	assert.Equal("nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal("strict-origin-when-cross-origin", w.Header().Get("Referrer-Policy"))
+	assert.Empty(w.Header().Get("Permissions-Policy"))
+	assert.Empty(w.Header().Get("Strict-Transport-Security"))
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.TLS = &tls.ConnectionState{}
+	m(gctx.NewCtxWithHTTP(w, r))
+	assert.Equal("max-age=31536000; includeSubDomains; preload", w.Header().Get("Strict-Transport-Security"))
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	New(Option{HSTSMaxAge: 60})(gctx.NewCtxWithHTTP(w, r))
+	assert.Equal("max-age=60", w.Header().Get("Strict-Transport-Security"))
+	assert.Empty(w.Header().Get("X-Frame-Options"))
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// Option is the options of timeout middleware.
+type Option struct {
+	Timeout time.Duration
+	// StatusCode is responded if the handler timeout and nothing is written, default is 503.
+	StatusCode int
+	// Message is the body responded with StatusCode, default is the status text.
+	Message string
+}
+
+// NewFromConfig creates a timeout middleware from section [timeout] of config, if the section
+// is missing or enable is false, the middleware does nothing.
+// It is a route middleware, add it by Use of router or api server.
+func NewFromConfig(c gcore.Config) gcore.RouteMiddleware {
+	cfg := c.Sub("timeout")
+	if cfg == nil || !cfg.GetBool("enable") {
+		return func(ctx gcore.Ctx, next func()) { next() }
+	}
+	return New(Option{
+		Timeout:    cfg.GetDuration("timeout"),
+		StatusCode: cfg.GetInt("status_code"),
+		Message:    cfg.GetString("message"),
+	})
+}
+
+// New creates a timeout middleware with the options, it works like http.TimeoutHandler.
+// The handler runs in a new goroutine with a context which is canceled after the timeout,
+// and its response is buffered. If the handler returns in time, the buffered response is sent,
+// otherwise StatusCode and Message are responded at the deadline, and the later writes of
+// the handler return http.ErrHandlerTimeout. The handler should pass ctx.Context() to the slow
+// operations such as database queries and http requests, and return when it is canceled.
+// The websocket upgrade requests are not handled, because the connection can not be hijacked.
+func New(opt Option) gcore.RouteMiddleware {
+	if opt.StatusCode == 0 {
+		opt.StatusCode = http.StatusServiceUnavailable
+	}
+	if opt.Message == "" {
+		opt.Message = http.StatusText(opt.StatusCode)
+	}
+	return func(ctx gcore.Ctx, next func()) {
+		r := ctx.Request()
+		if opt.Timeout <= 0 || r.Header.Get("Upgrade") != "" {
+			next()
+			return
+		}
+		c, cancel := context.WithTimeout(ctx.Context(), opt.Timeout)
+		defer cancel()
+		w := ctx.Response()
+		tw := newResponseWriter(w)
+		ctx.SetRequest(r.WithContext(c))
+		ctx.SetResponse(tw)
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+			}()
+			next()
+			close(done)
+		}()
+		select {
+		case p := <-panicChan:
+			ctx.SetRequest(r)
+			ctx.SetResponse(w)
+			panic(p)
+		case <-done:
+			ctx.SetRequest(r)
+			ctx.SetResponse(w)
+			tw.commit()
+		case <-c.Done():
+			// the handler is still running and using ctx, so ctx is not restored.
+			if c.Err() == context.DeadlineExceeded {
+				tw.timeout(opt.StatusCode, opt.Message)
+			} else {
+				// the request is canceled by the client.
+				tw.stop()
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gcore "github.com/snail007/gmc/core"
+	gctx "github.com/snail007/gmc/module/ctx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeout(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{Timeout: 20 * time.Millisecond})
+
+	// the timeout response is sent at the deadline, the later writes are refused.
+	w := httptest.NewRecorder()
+	ctx := gctx.NewCtxWithHTTP(w, httptest.NewRequest("GET", "/", nil))
+	errCh := make(chan error, 1)
+	start := time.Now()
+	m(ctx, func() {
+		_, ok := ctx.Context().Deadline()
+		assert.True(ok)
+		ctx.Write("partial")
+		time.Sleep(200 * time.Millisecond)
+		_, err := ctx.Write("late")
+		errCh <- err
+	})
+	assert.True(time.Since(start) < 200*time.Millisecond)
+	assert.Equal(http.StatusServiceUnavailable, w.Code)
+	assert.Equal("Service Unavailable", w.Body.String())
+	assert.Equal(http.ErrHandlerTimeout, <-errCh)
+	assert.Equal("Service Unavailable", w.Body.String())
+	assert.Equal(http.StatusServiceUnavailable, ctx.StatusCode())
+
+	// the buffered response is sent if the handler returns in time.
+	w = httptest.NewRecorder()
+	ctx = gctx.NewCtxWithHTTP(w, httptest.NewRequest("GET", "/", nil))
+	rw := ctx.Response()
+	rw.Header().Set("X-Before", "1")
+	m(ctx, func() {
+		ctx.Response().Header().Set("X-Handler", "1")
+		ctx.Response().Header().Del("X-Before")
+		ctx.WriteHeader(http.StatusCreated)
+		ctx.Write("ok")
+	})
+	assert.Equal(http.StatusCreated, w.Code)
+	assert.Equal("ok", w.Body.String())
+	assert.Equal("1", w.Header().Get("X-Handler"))
+	assert.Equal("", w.Header().Get("X-Before"))
+	assert.Equal(rw, ctx.Response())
+	_, ok := ctx.Context().Deadline()
+	assert.False(ok)
+
+	w = httptest.NewRecorder()
+	ctx = gctx.NewCtxWithHTTP(w, httptest.NewRequest("GET", "/", nil))
+	New(Option{Timeout: time.Millisecond, StatusCode: http.StatusGatewayTimeout, Message: "timeout"})(ctx, func() {
+		<-ctx.Context().Done()
+	})
+	assert.Equal(http.StatusGatewayTimeout, w.Code)
+	assert.Equal("timeout", w.Body.String())
+
+	var _ gcore.RouteMiddleware = m
+}
+
+func TestTimeout_Panic(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{Timeout: time.Second})
+	w := httptest.NewRecorder()
+	ctx := gctx.NewCtxWithHTTP(w, httptest.NewRequest("GET", "/", nil))
+	rw := ctx.Response()
+	assert.PanicsWithValue("oops", func() {
+		m(ctx, func() {
+			ctx.Write("partial")
+			panic("oops")
+		})
+	})
+	assert.Equal(rw, ctx.Response())
+	assert.Equal(0, w.Body.Len())
+}
+
+func TestTimeout_Upgrade(t *testing.T) {
+	assert := assert.New(t)
+	m := New(Option{Timeout: time.Second})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Upgrade", "websocket")
+	ctx := gctx.NewCtxWithHTTP(w, r)
+	rw := ctx.Response()
+	m(ctx, func() {
+		assert.Equal(rw, ctx.Response())
+	})
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package timeout
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"sync"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+// responseWriter buffers the response of the handler, the header and the body are sent to
+// the underlying writer after the handler returned in time, they are dropped if timeout.
+type responseWriter struct {
+	http.ResponseWriter
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	timedOut    bool
+	written     int64
+	data        sync.Map
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{
+		ResponseWriter: w,
+		header:         w.Header().Clone(),
+		status:         http.StatusOK,
+	}
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+}
+
+// Write returns http.ErrHandlerTimeout after timeout.
+func (w *responseWriter) Write(b []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.wroteHeader = true
+	w.written += int64(len(b))
+	return w.buf.Write(b)
+}
+
+// commit sends the buffered response to the underlying writer, it is called after the handler returned.
+func (w *responseWriter) commit() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dst := w.ResponseWriter.Header()
+	for k := range dst {
+		if _, ok := w.header[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	if !w.wroteHeader {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+}
+
+// stop drops the buffered response, the handler may still be running, so the writer is kept
+// in ctx and refuses the later writes.
+func (w *responseWriter) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timedOut = true
+	w.buf.Reset()
+}
+
+// timeout stops the writer and writes the timeout response to the underlying writer.
+func (w *responseWriter) timeout(status int, message string) {
+	w.stop()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status = status
+	w.written = int64(len(message))
+	w.ResponseWriter.WriteHeader(status)
+	w.ResponseWriter.Write([]byte(message))
+}
+
+// Hijack is not supported, the upgrade requests are not handled by the middleware.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+
+// Unwrap returns the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// WriteCount returns the bytes written by the handler.
+func (w *responseWriter) WriteCount() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written
+}
+
+func (w *responseWriter) StatusCode() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *responseWriter) ClearData() {
+	if v, ok := w.ResponseWriter.(gcore.ResponseWriter); ok {
+		v.ClearData()
+		return
+	}
+	w.data = sync.Map{}
+}
+
+func (w *responseWriter) Data(k interface{}) interface{} {
+	if v, ok := w.ResponseWriter.(gcore.ResponseWriter); ok {
+		return v.Data(k)
+	}
+	v, _ := w.data.Load(k)
+	return v
+}
+
+func (w *responseWriter) SetData(k interface{}, v interface{}) {
+	if rw, ok := w.ResponseWriter.(gcore.ResponseWriter); ok {
+		rw.SetData(k, v)
+		return
+	}
+	w.data.Store(k, v)
+}
@@ -29,6 +29,8 @@ import (
 var _ gcore.SessionStorage = &gsession.MemoryStore{}
 var _ gcore.SessionStorage = &gsession.FileStore{}
 var _ gcore.SessionStorage = &gsession.RedisStore{}
+var _ gcore.SessionStorage = &gsession.DBStore{}
+var _ gcore.CtxSessionStorage = &gsession.CookieStore{}
 var _ gcore.Session = &gsession.Session{}
 var _ gcore.Controller = &gcontroller.Controller{}
 var _ gcore.Cookies = &gcookie.Cookies{}
@@ -46,11 +48,15 @@ var _ gcore.Cache = &gcache.RedisCache{}
 var _ gcore.Ctx = &gctx.Ctx{}
 var _ gcore.Database = &gdb.MySQLDB{}
 var _ gcore.Database = &gdb.SQLite3DB{}
+var _ gcore.Database = &gdb.PostgresDB{}
 var _ gcore.DatabaseGroup = &gdb.MySQLDBGroup{}
 var _ gcore.DatabaseGroup = &gdb.SQLite3DBGroup{}
+var _ gcore.DatabaseGroup = &gdb.PostgresDBGroup{}
 var _ gcore.ActiveRecord = &gdb.MySQLActiveRecord{}
 var _ gcore.ActiveRecord = &gdb.SQLite3ActiveRecord{}
+var _ gcore.ActiveRecord = &gdb.PostgresActiveRecord{}
 var _ gcore.ResultSet = &gdb.ResultSet{}
+var _ gcore.Tx = &gdb.Tx{}
 var _ gcore.Error = &gerror.Error{}
 var _ gcore.Database = &gdb.SQLite3DB{}
 var _ gcore.Config = &gconfig.Config{}
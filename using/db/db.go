@@ -6,6 +6,7 @@
 package db
 
 import (
+	"fmt"
 	gcore "github.com/snail007/gmc/core"
 	gdb "github.com/snail007/gmc/module/db"
 	// basic requirements
@@ -36,4 +37,19 @@ func initialize() {
 		}
 		return gdb.DB(), nil
 	})
+
+	gcore.RegisterDatabaseGroup(gcore.DefaultProviderKey, func(ctx gcore.Ctx) (gcore.DatabaseGroup, error) {
+		var err error
+		gonce.OnceDo("gmc-cache-init", func() {
+			err = gdb.Init(ctx.Config())
+		})
+		if err != nil {
+			return nil, err
+		}
+		group := gdb.Group()
+		if group == nil {
+			return nil, fmt.Errorf("unknown default database %s", ctx.Config().GetString("database.default"))
+		}
+		return group, nil
+	})
 }
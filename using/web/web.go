@@ -58,7 +58,7 @@ func initialize() {
 	})
 
 	gcore.RegisterCookies(gcore.DefaultProviderKey, func(ctx gcore.Ctx) gcore.Cookies {
-		return gcookie.New(ctx.Response(), ctx.Request())
+		return gcookie.NewFromConfig(ctx)
 	})
 
 	gcore.RegisterI18n(gcore.DefaultProviderKey, func(ctx gcore.Ctx) (gcore.I18n, error) {
@@ -81,3 +81,17 @@ func (l *SlidingWindowLimiter) Duration() time.Duration {
 func (l *SlidingWindowLimiter) Capacity() int {
 	return int(l.capacity)
 }
+
+// Count 返回当前窗口内的请求数
+func (l *SlidingWindowLimiter) Count() int {
+	return int(atomic.LoadInt32(&l.count))
+}
+
+// Reset 返回距离下一个时间窗口滑出的时间，届时会释放该窗口内的请求数
+func (l *SlidingWindowLimiter) Reset() time.Duration {
+	d := time.Duration(atomic.LoadInt64(&l.lastCheck) + l.interval.Nanoseconds() - time.Now().UnixNano())
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
@@ -102,3 +102,14 @@ func TestConcurrencyWithRecovery(t *testing.T) {
 		t.Fatalf("Expected request to be allowed after window reset")
 	}
 }
+
+func TestCountAndReset(t *testing.T) {
+	limiter := NewSlidingWindowLimiter(5, time.Second)
+	assert.Equal(t, 0, limiter.Count())
+	assert.True(t, limiter.AllowN(3))
+	assert.Equal(t, 3, limiter.Count())
+	reset := limiter.Reset()
+	assert.True(t, reset > 0 && reset <= 100*time.Millisecond)
+	time.Sleep(120 * time.Millisecond)
+	assert.Equal(t, time.Duration(0), limiter.Reset())
+}
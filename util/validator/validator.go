@@ -0,0 +1,409 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gvalidator
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	gcore "github.com/snail007/gmc/core"
+)
+
+var (
+	// TagName is the struct tag of validation rules.
+	TagName = "validate"
+	// NameTags are the struct tags used to get the field name in errors, the first
+	// non-empty one is used, the Go field name is used if all of them are empty.
+	NameTags = []string{"json", "form", "query", "param"}
+
+	emailRegexp = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+$`)
+	// structCache caches the *structRules of struct types.
+	structCache = sync.Map{}
+
+	// messages are the default messages of rules, {field} and {param} are placeholders.
+	messages = map[string]string{
+		"required": "{field} is required",
+		"min":      "{field} must be at least {param}",
+		"max":      "{field} must be at most {param}",
+		"len":      "{field} must be {param} in length",
+		"regex":    "{field} has an invalid format",
+		"email":    "{field} must be a valid email address",
+		"oneof":    "{field} must be one of [{param}]",
+	}
+)
+
+// FieldError is the error of a field failed to pass a rule.
+type FieldError struct {
+	// Field is the path of the field, such as items[0].name.
+	Field string
+	// Rule is the name of the failed rule, such as required, min.
+	Rule string
+	// Param is the parameter of the rule, such as 10 of min=10.
+	Param string
+	Value interface{}
+}
+
+func (e *FieldError) Error() string {
+	return e.format(messages[e.Rule])
+}
+
+// Tr translates the error by i18n, the key is validate.<rule>, such as validate.required,
+// the placeholders {field} and {param} in the translated text are replaced.
+func (e *FieldError) Tr(i18n gcore.I18n, lang string) string {
+	return e.format(i18n.Tr(lang, "validate."+e.Rule, messages[e.Rule]))
+}
+
+func (e *FieldError) format(msg string) string {
+	return strings.NewReplacer("{field}", e.Field, "{param}", e.Param).Replace(msg)
+}
+
+// Errors is the errors of all failed fields, in the order of the fields.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	var msgs []string
+	for _, v := range e {
+		msgs = append(msgs, v.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Tr translates the errors by i18n, the key of returned map is FieldError.Field.
+func (e Errors) Tr(i18n gcore.I18n, lang string) map[string]string {
+	m := make(map[string]string, len(e))
+	for _, v := range e {
+		if _, ok := m[v.Field]; !ok {
+			m[v.Field] = v.Tr(i18n, lang)
+		}
+	}
+	return m
+}
+
+// Validate validates the struct or pointer to struct by the rules in the tag validate,
+// such as `validate:"required,min=1,max=10"`. The nested structs, slices and maps of
+// structs are validated recursively. The error returned is Errors if any field failed.
+//
+// Rules:
+//
+//	required        the value must not be zero value, the length of slice and map must not be 0.
+//	omitempty       the other rules are skipped if the value is zero value.
+//	min=n, max=n    the number must be >= n, <= n, or the length of string, slice and map.
+//	len=n           the length of string, slice and map must be n, or the number must be n.
+//	email           the string must be an email address.
+//	oneof=a b c     the value must be one of the values separated by space.
+//	regex=pattern   the string must match the pattern, it must be the last rule.
+//
+// The tags of a struct type are parsed once and cached, an unknown rule, an invalid number
+// param of min, max, len or an invalid regex pattern returns an error which is not Errors.
+func Validate(obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: %T is not a struct", obj)
+	}
+	var errs Errors
+	if err := validateStruct(v, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// rule is a parsed rule of the tag validate.
+type rule struct {
+	name  string
+	param string
+	// size is the number param of min, max and len.
+	size float64
+	re   *regexp.Regexp
+}
+
+// fieldRules are the parsed rules of a struct field.
+type fieldRules struct {
+	index int
+	name  string
+	// embedded is an anonymous field without rules, its fields are validated without prefix.
+	embedded bool
+	rules    []rule
+}
+
+// structRules are the cached fieldRules of a struct type, err is set if any tag is invalid.
+type structRules struct {
+	fields []fieldRules
+	err    error
+}
+
+// rulesOf returns the parsed rules of the struct type t, the struct types in fields are checked too.
+func rulesOf(t reflect.Type) ([]fieldRules, error) {
+	if v, ok := structCache.Load(t); ok {
+		r := v.(*structRules)
+		return r.fields, r.err
+	}
+	r := parseStruct(t, map[reflect.Type]bool{})
+	return r.fields, r.err
+}
+
+func parseStruct(t reflect.Type, parsing map[reflect.Type]bool) *structRules {
+	if v, ok := structCache.Load(t); ok {
+		return v.(*structRules)
+	}
+	parsing[t] = true
+	r := &structRules{}
+	for i := 0; i < t.NumField() && r.err == nil; i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get(TagName)
+		fr := fieldRules{index: i, name: fieldName(f), embedded: f.Anonymous && tag == ""}
+		if !fr.embedded && fr.name == "-" {
+			continue
+		}
+		fr.rules, r.err = parseRules(tag)
+		if r.err != nil {
+			r.err = fmt.Errorf("validate: field %s.%s, %s", t, f.Name, r.err)
+			break
+		}
+		// the nested struct types are checked now, not until they have a value.
+		if nt := nestedStruct(f.Type); nt != nil && !parsing[nt] {
+			r.err = parseStruct(nt, parsing).err
+		}
+		r.fields = append(r.fields, fr)
+	}
+	delete(parsing, t)
+	structCache.Store(t, r)
+	return r
+}
+
+// nestedStruct returns the struct type in t, such as *T, []T, map[string]*T.
+func nestedStruct(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		case reflect.Struct:
+			return t
+		default:
+			return nil
+		}
+	}
+}
+
+func parseRules(tag string) (rules []rule, err error) {
+	for _, kv := range ParseRules(tag) {
+		r := rule{name: kv[0], param: kv[1]}
+		switch r.name {
+		case "required", "omitempty", "email", "oneof":
+		case "min", "max", "len":
+			if r.size, err = strconv.ParseFloat(r.param, 64); err != nil {
+				return nil, fmt.Errorf("invalid param of rule %s=%s", r.name, r.param)
+			}
+		case "regex":
+			if r.re, err = regexp.Compile(r.param); err != nil {
+				return nil, fmt.Errorf("invalid pattern of rule regex, %s", err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown rule %s", r.name)
+		}
+		rules = append(rules, r)
+	}
+	return
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *Errors) error {
+	fields, err := rulesOf(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		fv := v.Field(f.index)
+		if f.embedded {
+			if ev := indirect(fv); ev.Kind() == reflect.Struct {
+				if err = validateStruct(ev, prefix, errs); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		name := f.name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if !validateField(fv, name, f.rules, errs) {
+			continue
+		}
+		if err = validateNested(fv, name, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNested validates the structs in the value, such as a struct, a slice of structs.
+func validateNested(v reflect.Value, name string, errs *Errors) (err error) {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Struct:
+		return validateStruct(v, name, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len() && err == nil; i++ {
+			err = validateNested(v.Index(i), name+"["+strconv.Itoa(i)+"]", errs)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() && err == nil {
+			err = validateNested(iter.Value(), fmt.Sprintf("%s[%v]", name, iter.Key().Interface()), errs)
+		}
+	}
+	return
+}
+
+// validateField checks the rules on the value, false returned if any rule failed.
+func validateField(v reflect.Value, name string, rules []rule, errs *Errors) bool {
+	if len(rules) == 0 {
+		return true
+	}
+	// a nil pointer means the value is absent, only required is checked.
+	isNil := (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()
+	isZero := isNil || v.Kind() != reflect.Ptr && v.IsZero() ||
+		(v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0
+	for _, r := range rules {
+		if r.name == "omitempty" && isZero {
+			return true
+		}
+	}
+	iv := indirect(v)
+	for _, r := range rules {
+		ok := true
+		switch {
+		case r.name == "omitempty":
+			continue
+		case r.name == "required":
+			ok = !isZero
+		case isNil:
+			continue
+		}
+		switch r.name {
+		case "min", "max", "len":
+			ok = checkSize(iv, r.name, r.size)
+		case "email":
+			ok = iv.Kind() == reflect.String && emailRegexp.MatchString(iv.String())
+		case "oneof":
+			ok = false
+			s := fmt.Sprint(valueOf(iv))
+			for _, o := range strings.Fields(r.param) {
+				if o == s {
+					ok = true
+					break
+				}
+			}
+		case "regex":
+			ok = iv.Kind() == reflect.String && r.re.MatchString(iv.String())
+		}
+		if !ok {
+			*errs = append(*errs, &FieldError{
+				Field: name,
+				Rule:  r.name,
+				Param: r.param,
+				Value: valueOf(iv),
+			})
+			return false
+		}
+	}
+	return true
+}
+
+func checkSize(v reflect.Value, rule string, p float64) bool {
+	var n float64
+	switch v.Kind() {
+	case reflect.String:
+		n = float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n = float64(v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return false
+	}
+	switch rule {
+	case "min":
+		return n >= p
+	case "max":
+		return n <= p
+	}
+	return n == p
+}
+
+// ParseRules splits the validate tag to [rule, param] pairs, regex consumes the rest of tag.
+func ParseRules(tag string) (rules [][2]string) {
+	for tag != "" {
+		var item string
+		if strings.HasPrefix(tag, "regex=") {
+			item, tag = tag, ""
+		} else if idx := strings.Index(tag, ","); idx >= 0 {
+			item, tag = tag[:idx], tag[idx+1:]
+		} else {
+			item, tag = tag, ""
+		}
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		r := [2]string{kv[0], ""}
+		if len(kv) == 2 {
+			r[1] = kv[1]
+		}
+		rules = append(rules, r)
+	}
+	return
+}
+
+func fieldName(f reflect.StructField) string {
+	for _, tag := range NameTags {
+		name := strings.Split(f.Tag.Get(tag), ",")[0]
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func valueOf(v reflect.Value) interface{} {
+	if !v.IsValid() || (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+	if v.CanInterface() {
+		return v.Interface()
+	}
+	return nil
+}
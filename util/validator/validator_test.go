@@ -0,0 +1,178 @@
+// Copyright 2020 The GMC Author. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+// More information at https://github.com/snail007/gmc
+
+package gvalidator
+
+import (
+	"testing"
+
+	gcore "github.com/snail007/gmc/core"
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	Name  string `json:"name" validate:"required,max=5"`
+	Count int    `json:"count" validate:"min=1"`
+}
+
+type base struct {
+	ID int `json:"id" validate:"required"`
+}
+
+type order struct {
+	base
+	Email   string           `json:"email" validate:"required,email"`
+	Status  string           `json:"status" validate:"oneof=new paid"`
+	Code    string           `json:"code" validate:"omitempty,len=4"`
+	Phone   string           `form:"phone" validate:"omitempty,regex=^1[0-9]{2,3}$"`
+	Age     *int             `json:"age" validate:"min=18"`
+	Items   []item           `json:"items" validate:"required,min=1"`
+	Extra   map[string]*item `json:"extra"`
+	Ignored string           `json:"-" validate:"required"`
+	Address struct {
+		City string `validate:"required"`
+	} `json:"address"`
+}
+
+func validOrder() *order {
+	o := &order{
+		Email:   "a@b.com",
+		Status:  "new",
+		Items:   []item{{Name: "a", Count: 1}},
+		Ignored: "x",
+	}
+	o.ID = 1
+	o.Address.City = "bj"
+	return o
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(Validate(validOrder()))
+	o := validOrder()
+	o.Code = "1234"
+	o.Phone = "1234"
+	age := 20
+	o.Age = &age
+	assert.Nil(Validate(o))
+	assert.Nil(Validate((*order)(nil)))
+	assert.NotNil(Validate(1))
+}
+
+func TestValidate_Errors(t *testing.T) {
+	assert := assert.New(t)
+	o := validOrder()
+	o.ID = 0
+	o.Email = "bad"
+	o.Status = "done"
+	o.Code = "12"
+	o.Phone = "a1"
+	age := 10
+	o.Age = &age
+	o.Items = append(o.Items, item{Name: "toolong", Count: 0})
+	o.Extra = map[string]*item{"k": {Name: "", Count: 1}}
+	o.Address.City = ""
+	err := Validate(o)
+	assert.NotNil(err)
+	errs := err.(Errors)
+	var fields, rules []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+		rules = append(rules, e.Rule)
+	}
+	assert.Equal([]string{"id", "email", "status", "code", "phone", "age", "items[1].name", "items[1].count",
+		"extra[k].name", "address.City"}, fields)
+	assert.Equal([]string{"required", "email", "oneof", "len", "regex", "min", "max", "min", "required", "required"}, rules)
+	assert.Equal("age must be at least 18", errs[5].Error())
+	assert.Equal(10, errs[5].Value)
+	assert.Equal("status must be one of [new paid]", errs[2].Error())
+	assert.Contains(err.Error(), "id is required; email must be a valid email address")
+}
+
+func TestValidate_Required(t *testing.T) {
+	assert := assert.New(t)
+	type T struct {
+		P *int   `validate:"required"`
+		S []int  `validate:"required"`
+		M string `validate:"required,min=2"`
+	}
+	err := Validate(&T{S: []int{}})
+	assert.NotNil(err)
+	assert.Len(err.(Errors), 3)
+	zero := 0
+	assert.Nil(Validate(&T{P: &zero, S: []int{1}, M: "ab"}))
+	// nil pointer only checks required
+	type T2 struct {
+		P *int `validate:"min=1"`
+	}
+	assert.Nil(Validate(T2{}))
+}
+
+func TestValidate_InvalidTag(t *testing.T) {
+	assert := assert.New(t)
+	type T1 struct {
+		A string `validate:"foo"`
+	}
+	type T2 struct {
+		A string `validate:"required,max=ten"`
+	}
+	type T3 struct {
+		A string `validate:"regex=^(a$"`
+	}
+	type T4 struct {
+		// the nested type is checked even if the slice is empty.
+		Items []*T3
+	}
+	for _, v := range []interface{}{T1{}, &T2{}, T3{}, T4{}} {
+		var err error
+		assert.NotPanics(func() { err = Validate(v) })
+		assert.NotNil(err)
+		_, ok := err.(Errors)
+		assert.False(ok)
+	}
+	assert.Equal("validate: field gvalidator.T1.A, unknown rule foo", Validate(T1{}).Error())
+	assert.Equal("validate: field gvalidator.T2.A, invalid param of rule max=ten", Validate(T2{}).Error())
+	assert.Equal(Validate(T3{}), Validate(T4{}))
+}
+
+type node struct {
+	Name     string  `validate:"required"`
+	Children []*node `json:"children"`
+}
+
+func TestValidate_Recursive(t *testing.T) {
+	assert := assert.New(t)
+	n := &node{Name: "a", Children: []*node{{Name: "b"}, {}}}
+	err := Validate(n)
+	assert.IsType(Errors{}, err)
+	assert.Equal("children[1].Name is required", err.Error())
+}
+
+type testI18n struct {
+	gcore.I18n
+}
+
+func (testI18n) Tr(lang, key string, defaultMessage ...string) string {
+	if lang == "zh" && key == "validate.required" {
+		return "{field}不能为空"
+	}
+	return defaultMessage[0]
+}
+
+func TestErrors_Tr(t *testing.T) {
+	assert := assert.New(t)
+	o := validOrder()
+	o.ID = 0
+	o.Code = "1"
+	err := Validate(o).(Errors)
+	i18n := testI18n{}
+	assert.Equal(map[string]string{"id": "id不能为空", "code": "code must be 4 in length"}, err.Tr(i18n, "zh"))
+	assert.Equal("id is required", err[0].Tr(i18n, "en"))
+}
+
+func TestParseRules(t *testing.T) {
+	assert.Equal(t, [][2]string{{"required", ""}, {"max", "20"}, {"regex", "^a,b$"}},
+		ParseRules("required, max=20,regex=^a,b$"))
+}